@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type carveOptions struct {
+	file      string
+	indexID   uint64
+	schema    string
+	columns   string
+	pk        string
+	format    string
+	output    string
+	unaligned bool
+	pageSize  int
+}
+
+func newCarveCommand() *cobra.Command {
+	var options carveOptions
+	c := &cobra.Command{
+		Use:   "carve",
+		Short: "carve the index pages and records",
+		Long:  "Scan the file or disk image for the index pages ignoring the file space structure and the page links, and carve the records of the index id with the table schema",
+		Run: func(cmd *cobra.Command, args []string) {
+			doCarve(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file or disk image path")
+	c.Flags().Uint64VarP(&options.indexID, "index-id", "i", 0, "index id of the clustered index to carve, all index pages are listed if not specified")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file to decode the records")
+	c.Flags().StringVarP(&options.columns, "columns", "c", "", "column definitions to decode the records")
+	c.Flags().StringVar(&options.pk, "pk", "", "comma separated primary key columns of --columns")
+	c.Flags().StringVar(&options.format, "rows-format", "csv", "output format of the records, csv or sql")
+	c.Flags().StringVarP(&options.output, "output", "o", "", "output file path of the records, write to stdout if not specified")
+	c.Flags().BoolVarP(&options.unaligned, "unaligned", "u", false, "check every offset of the infimum instead of the page aligned blocks")
+	c.Flags().IntVar(&options.pageSize, "page-size", 16384, "page size in bytes, page 0 is not read")
+
+	return c
+}
+
+func doCarve(cmd *cobra.Command, options *carveOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+	if !innodb.PageSize(options.pageSize).Valid() {
+		fmt.Printf("Invalid page size %d\r\n", options.pageSize)
+		return
+	}
+	if innodb.RowFormatCSV != options.format && innodb.RowFormatSQL != options.format {
+		fmt.Println("Invalid rows format, must be csv or sql")
+		return
+	}
+
+	// The SDI is not read, the file space structure may be broken
+	table, err := innodb.LoadTableSchema(options.schema, options.columns, options.pk)
+	if nil != err {
+		fmt.Println("Load table schema error ", err)
+		return
+	}
+	if nil != table && 0 == options.indexID {
+		fmt.Println("No index id specified to carve the records")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	if nil == table {
+		fmt.Printf("%-18s%-10s%-22s%-8s%-10s%s\r\n", "offset", "page", "index id", "level", "records", "checksum")
+		found := 0
+		err = innodb.CarveIndexPages(f, options.pageSize, options.unaligned, func(offset int64, data []byte) error {
+			// The carved index page can be parsed safely
+			page, err := innodb.ParsePage(0, data, &innodb.ParsePageOptions{PageSize: options.pageSize})
+			if nil != err {
+				return nil
+			}
+			if 0 != options.indexID && page.IndexHeader.IndexID != options.indexID {
+				return nil
+			}
+			checksum := "OK"
+			if innodb.VerifyPageChecksum(data, false).Corrupt() && innodb.VerifyPageChecksum(data, true).Corrupt() {
+				checksum = "BAD"
+			}
+			fmt.Printf("0x%-16X%-10d%-22d%-8d%-10d%s\r\n", offset,
+				page.FileHeader.Offset, page.IndexHeader.IndexID,
+				page.IndexHeader.Level, page.IndexHeader.NRecs, checksum)
+			found++
+			return nil
+		})
+		if nil != err {
+			fmt.Println("Carve index pages error ", err)
+			return
+		}
+		fmt.Printf("\r\n%d index page(s) found\r\n", found)
+		return
+	}
+
+	// Decode the carved pages with the clustered index
+	index := table.ClusteredIndex()
+	index.ID = options.indexID
+
+	var out io.Writer = os.Stdout
+	if "" != options.output {
+		of, err := os.Create(options.output)
+		if nil != err {
+			fmt.Println("Create output file error ", err)
+			return
+		}
+		defer of.Close()
+		out = of
+	}
+	bw := bufio.NewWriter(out)
+	defer bw.Flush()
+	w := innodb.NewRowWriter(bw, table, options.format, true, 1)
+
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: true,
+		PageSize:     options.pageSize,
+		Table:        table,
+	}
+	pages, rows, broken := 0, 0, 0
+	err = innodb.CarveIndexPages(f, options.pageSize, options.unaligned, func(offset int64, data []byte) error {
+		hp, err := innodb.ParsePage(0, data, &innodb.ParsePageOptions{PageSize: options.pageSize})
+		if nil != err {
+			broken++
+			return nil
+		}
+		if hp.IndexHeader.IndexID != options.indexID || 0 != hp.IndexHeader.Level {
+			return nil
+		}
+		pages++
+		page, err := innodb.ParsePage(int(hp.FileHeader.Offset), data, parseOptions)
+		if nil != err {
+			broken++
+			return nil
+		}
+		carved := make([]*innodb.RecoveredRow, 0, page.IndexHeader.NRecs)
+		for _, rc := range page.UserRecorders() {
+			if nil != rc.Fields && !rc.Header.DeleteFlag {
+				carved = append(carved, &innodb.RecoveredRow{
+					Page:   page.No(),
+					Origin: int(rc.FieldDataOffset),
+					State:  innodb.RowStateCarved,
+					Fields: rc.Fields,
+				})
+			}
+		}
+		deleted, _ := innodb.FindDeletedRows(page, data, index)
+		for _, row := range append(carved, deleted...) {
+			// The page numbers of the external fields are not reliable
+			if err := w.Write(nil, row); nil != err {
+				return err
+			}
+			rows++
+		}
+		return nil
+	})
+	if nil == err {
+		err = w.Flush()
+	}
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "Carve records error %v\r\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d row(s) carved from %d leaf page(s), %d broken page(s)\r\n", rows, pages, broken)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type checksumOptions struct {
+	file     string
+	page     int
+	corrupt  bool
+	verbose  bool
+	pageSize int
+	jobs     int
+	// Rewrite mode
+	rewrite bool
+	algo    string
+	output  string
+	inPlace bool
+	apply   bool
+}
+
+func newChecksumCommand() *cobra.Command {
+	var options checksumOptions
+	c := &cobra.Command{
+		Use:   "checksum",
+		Short: "verify page checksums",
+		Long:  "Verify every page checksum with crc32, innodb, none and full_crc32 algorithms",
+		Run: func(cmd *cobra.Command, args []string) {
+			ok := false
+			if options.rewrite {
+				ok = doChecksumRewrite(cmd, &options)
+			} else {
+				ok = doChecksum(cmd, &options)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().IntVarP(&options.page, "page", "p", -1, "specify page to verify")
+	c.Flags().BoolVarP(&options.corrupt, "corrupt", "c", false, "only show corrupt pages")
+	c.Flags().BoolVarP(&options.verbose, "verbose", "v", false, "show calculated checksums of every algorithm")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
+	c.Flags().BoolVar(&options.rewrite, "rewrite", false, "recalculate and rewrite the page checksums")
+	c.Flags().StringVar(&options.algo, "algo", "crc32", "rewrite checksum algorithm (crc32,innodb,none,full_crc32)")
+	c.Flags().StringVarP(&options.output, "output", "o", "", "rewrite output file path, default is <file>.rewrite")
+	c.Flags().BoolVar(&options.inPlace, "in-place", false, "rewrite the input file instead of a copy")
+	c.Flags().BoolVar(&options.apply, "apply", false, "write the checksums, otherwise only show the changes (dry run)")
+
+	return c
+}
+
+// doChecksum returns false if any page is corrupt
+func doChecksum(cmd *cobra.Command, options *checksumOptions) bool {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return false
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return false
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return false
+	}
+	if 0 != ts.ZipSize() {
+		// The checksum of the compressed page is calculated on the physical page
+		fmt.Println("Compressed table space is not supported")
+		return false
+	}
+	// If page 0 is damaged, treat it as the mysql format
+	flags, _ := ts.FSPFlags()
+	fullCrc32 := innodb.IsFullCrc32(flags)
+
+	fmt.Printf("%-10s%-26s%-12s%-12s%-26s%-10s",
+		"page", "type", "checksum", "trailer", "algorithm", "lsn")
+	if options.verbose {
+		fmt.Printf("%-12s%-12s%-12s%-12s%-12s",
+			"crc32", "crc32(be)", "innodb", "innodb(old)", "full_crc32")
+	}
+	fmt.Printf("\r\n")
+
+	total := 0
+	corrupt := 0
+	empty := 0
+	algoCounts := make([]int, len(innodb.ChecksumAlgoStrs))
+	err = ts.MapPages(&innodb.ParsePageOptions{
+		HeaderOnly: true,
+		Jobs:       options.jobs,
+	}, func(page *innodb.Page, data []byte) interface{} {
+		if options.page >= 0 && options.page != page.No() {
+			return nil
+		}
+		return innodb.VerifyPageChecksum(data, fullCrc32)
+	}, func(page *innodb.Page, data []byte, v interface{}) error {
+		if nil == v {
+			return nil
+		}
+
+		result := v.(*innodb.PageChecksumResult)
+		total++
+		if result.Corrupt() {
+			corrupt++
+		} else if result.Empty {
+			empty++
+		} else {
+			algoCounts[result.Algo]++
+		}
+
+		if !options.corrupt || result.Corrupt() {
+			printPageChecksum(page.No(), &page.FileHeader, result, options.verbose)
+		}
+		if options.page >= 0 {
+			return innodb.ErrStopIteration
+		}
+		return nil
+	})
+	if nil != err {
+		fmt.Println(err)
+		return false
+	}
+
+	fmt.Printf("\r\n%d page(s) checked, %d corrupt, %d empty", total, corrupt, empty)
+	for algo, cnt := range algoCounts {
+		if 0 != cnt {
+			fmt.Printf(", %s %d", innodb.ChecksumAlgoToString(algo), cnt)
+		}
+	}
+	fmt.Printf("\r\n")
+
+	return 0 == corrupt
+}
+
+func printPageChecksum(pageNo int, fheader *innodb.FileHeader, result *innodb.PageChecksumResult, verbose bool) {
+	fmt.Printf("%-10d", pageNo)
+	if result.Empty {
+		fmt.Printf("%-26s", "Empty")
+	} else {
+		fmt.Printf("%-26s", innodb.PageTypeToString(int(fheader.Type)))
+	}
+	fmt.Printf("0x%-10.08X0x%-10.08X", result.HeaderChecksum, result.TrailerChecksum)
+	fmt.Printf("%-26s", innodb.ChecksumAlgoToString(result.Algo))
+	if result.LSNMatch {
+		fmt.Printf("%-10s", "ok")
+	} else {
+		fmt.Printf("%-10s", "mismatch")
+	}
+	if verbose {
+		fmt.Printf("0x%-10.08X0x%-10.08X0x%-10.08X0x%-10.08X0x%-10.08X",
+			result.Crc32, result.Crc32BigEndian, result.Innodb,
+			result.InnodbOld, result.FullCrc32)
+	}
+	fmt.Printf("\r\n")
+}
+
+// doChecksumRewrite recalculates the checksums with the specified algorithm, and writes
+// them to a copy of the file (or the file itself with --in-place). Nothing is written
+// without --apply
+func doChecksumRewrite(cmd *cobra.Command, options *checksumOptions) bool {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return false
+	}
+	algo, err := innodb.ChecksumAlgoFromString(options.algo)
+	if nil != err {
+		fmt.Println(err)
+		return false
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return false
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return false
+	}
+	if 0 != ts.ZipSize() {
+		// The checksum of the compressed page is calculated on the physical page
+		fmt.Println("Compressed table space is not supported")
+		return false
+	}
+	flags, _ := ts.FSPFlags()
+	if innodb.IsFullCrc32(flags) != (algo == innodb.ChecksumAlgoFullCrc32) {
+		fmt.Printf("Checksum algorithm %s doesn't match the table space format\r\n",
+			innodb.ChecksumAlgoToString(algo))
+		return false
+	}
+
+	// Find the pages to rewrite, the page compressed pages are verified on the
+	// decompressed pages and can't be rewritten
+	rewrites := make([]int, 0, 16)
+	skipped := 0
+	fmt.Printf("%-10s%-26s%-26s%-26s\r\n", "page", "type", "checksum", "trailer")
+	err = ts.MapPages(&innodb.ParsePageOptions{
+		HeaderOnly: true,
+		Jobs:       options.jobs,
+	}, func(page *innodb.Page, data []byte) interface{} {
+		if (options.page >= 0 && options.page != page.No()) ||
+			innodb.IsEmptyPage(data) {
+			return nil
+		}
+		return innodb.VerifyPageChecksum(data, algo == innodb.ChecksumAlgoFullCrc32)
+	}, func(page *innodb.Page, data []byte, v interface{}) error {
+		if nil == v {
+			return nil
+		}
+
+		result := v.(*innodb.PageChecksumResult)
+		if result.Algo != algo && (nil != page.Compression || nil != page.DecompressError) {
+			skipped++
+		} else if result.Algo != algo {
+			if err := innodb.WritePageChecksum(data, algo); nil != err {
+				return err
+			}
+			rewritten := innodb.VerifyPageChecksum(data, algo == innodb.ChecksumAlgoFullCrc32)
+			fmt.Printf("%-10d%-26s", page.No(), innodb.PageTypeToString(int(page.FileHeader.Type)))
+			fmt.Printf("%-26s%-26s\r\n",
+				fmt.Sprintf("0x%08X->0x%08X", result.HeaderChecksum, rewritten.HeaderChecksum),
+				fmt.Sprintf("0x%08X->0x%08X", result.TrailerChecksum, rewritten.TrailerChecksum))
+			rewrites = append(rewrites, page.No())
+		}
+		return nil
+	})
+	if nil != err {
+		fmt.Println(err)
+		return false
+	}
+	fmt.Printf("\r\n%d page(s) need to rewrite with %s\r\n", len(rewrites), innodb.ChecksumAlgoToString(algo))
+	if 0 != skipped {
+		fmt.Printf("%d page compressed page(s) skipped\r\n", skipped)
+	}
+
+	if !options.apply {
+		fmt.Println("Dry run, specify --apply to write the checksums")
+		return true
+	}
+	if 0 == len(rewrites) {
+		return true
+	}
+
+	// Prepare the output file
+	output := options.file
+	if !options.inPlace {
+		output = options.output
+		if "" == output {
+			output = options.file + ".rewrite"
+		}
+		if err = copyFile(f, output); nil != err {
+			fmt.Println("Copy file error ", err)
+			return false
+		}
+	}
+	of, err := os.OpenFile(output, os.O_RDWR, 0)
+	if nil != err {
+		fmt.Println("Open output file error ", err)
+		return false
+	}
+	defer of.Close()
+	ots, err := innodb.NewTablespace(of, ts.PageSize())
+	if nil != err {
+		fmt.Println("Open output table space error ", err)
+		return false
+	}
+
+	size := ts.PageSize()
+	data := make([]byte, size)
+	for _, no := range rewrites {
+		if err = ots.ReadPageData(no, data); nil != err {
+			fmt.Printf("Read page %d error %v\r\n", no, err)
+			return false
+		}
+		if err = innodb.WritePageChecksum(data, algo); nil != err {
+			fmt.Println(err)
+			return false
+		}
+		if _, err = of.WriteAt(data, int64(no)*int64(size)); nil != err {
+			fmt.Printf("Write page %d error %v\r\n", no, err)
+			return false
+		}
+	}
+	if err = of.Sync(); nil != err {
+		fmt.Println("Sync output file error ", err)
+		return false
+	}
+
+	// Verify the rewritten pages
+	failed := 0
+	for _, no := range rewrites {
+		if err = ots.ReadPageData(no, data); nil != err {
+			fmt.Printf("Read page %d error %v\r\n", no, err)
+			return false
+		}
+		if _, err = innodb.ParsePage(no, data, &innodb.ParsePageOptions{
+			ParsePageTypeFlag: innodb.ParsePageAll,
+		}); nil != err {
+			fmt.Printf("Verify page %d error %v\r\n", no, err)
+			failed++
+			continue
+		}
+		result := innodb.VerifyPageChecksum(data, algo == innodb.ChecksumAlgoFullCrc32)
+		if result.Algo != algo {
+			fmt.Printf("Verify page %d checksum failed\r\n", no)
+			failed++
+		}
+	}
+	fmt.Printf("%d page(s) rewritten to %s, %d verify failed\r\n", len(rewrites), output, failed)
+
+	return 0 == failed
+}
+
+// copyFile copies the file content to a new file, the destination must not exist
+func copyFile(f *os.File, dst string) error {
+	df, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if nil != err {
+		return err
+	}
+	defer df.Close()
+	if _, err = f.Seek(0, 0); nil != err {
+		return err
+	}
+	if _, err = io.Copy(df, f); nil != err {
+		return err
+	}
+	return df.Sync()
+}
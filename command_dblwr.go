@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"spf13/cobra"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type dblwrOptions struct {
+	files    []string
+	dir      string
+	space    int
+	target   string
+	restore  bool
+	output   string
+	pageSize int
+}
+
+func newDblwrCommand() *cobra.Command {
+	var options dblwrOptions
+	c := &cobra.Command{
+		Use:   "dblwr",
+		Short: "inspect the doublewrite buffer",
+		Long:  "List the page copies of the doublewrite buffer in ibdata1 or the #ib_*.dblwr files (8.0.20+), and restore the torn pages of a table space from the intact copies",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !doDblwr(cmd, &options) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	c.Flags().StringSliceVarP(&options.files, "file", "f", nil, "ibdata1 or #ib_*.dblwr file path, can be specified more than once")
+	c.Flags().StringVarP(&options.dir, "dir", "d", "", "data directory of the ibdata1 and #ib_*.dblwr files")
+	c.Flags().IntVarP(&options.space, "space", "s", -1, "only list the copies of the space id")
+	c.Flags().StringVarP(&options.target, "target", "t", "", "table space file to check the torn pages")
+	c.Flags().BoolVar(&options.restore, "restore", false, "restore the torn pages of --target into a copy")
+	c.Flags().StringVarP(&options.output, "output", "o", "", "restore output file path, default is <target>.restore")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes of ibdata1, detect from page 0 if not specified")
+
+	return c
+}
+
+// dblwrFileNameRegexp matches the doublewrite files of 8.0.20+, the page size
+// is in the file name
+var dblwrFileNameRegexp = regexp.MustCompile(`^#ib_([0-9]+)_[0-9]+\.dblwr$`)
+
+// findDoublewriteFiles returns ibdata1 and the doublewrite files in the directory
+func findDoublewriteFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	var files []string
+	for _, fi := range infos {
+		if fi.IsDir() {
+			continue
+		}
+		if "ibdata1" == fi.Name() || dblwrFileNameRegexp.MatchString(fi.Name()) {
+			files = append(files, filepath.Join(dir, fi.Name()))
+		}
+	}
+	return files, nil
+}
+
+// readDoublewriteCopies reads the page copies of ibdata1 or the doublewrite file
+func readDoublewriteCopies(path string, pageSize int) ([]*innodb.DoublewritePage, error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	defer f.Close()
+
+	if m := dblwrFileNameRegexp.FindStringSubmatch(filepath.Base(path)); nil != m {
+		size, _ := strconv.Atoi(m[1])
+		return innodb.ReadDoublewriteFile(f, size)
+	}
+	ts, err := innodb.NewTablespace(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	return ts.DoublewritePages()
+}
+
+func doDblwr(cmd *cobra.Command, options *dblwrOptions) bool {
+	paths := options.files
+	if "" != options.dir {
+		dirFiles, err := findDoublewriteFiles(options.dir)
+		if nil != err {
+			fmt.Println("Find doublewrite files error ", err)
+			return false
+		}
+		paths = append(paths, dirFiles...)
+	}
+	if 0 == len(paths) {
+		fmt.Println("No doublewrite file specified")
+		return false
+	}
+
+	var copies []*innodb.DoublewritePage
+	for _, path := range paths {
+		pages, err := readDoublewriteCopies(path, options.pageSize)
+		if nil != err {
+			fmt.Println("Read doublewrite buffer of ", path, " error ", err)
+			return false
+		}
+		printDoublewriteCopies(path, pages, options)
+		copies = append(copies, pages...)
+	}
+	if "" == options.target {
+		return true
+	}
+	return restoreTornPages(copies, options)
+}
+
+func printDoublewriteCopies(path string, pages []*innodb.DoublewritePage, options *dblwrOptions) {
+	fmt.Printf("\t\t\t==========FILE %s==========\r\n", path)
+	fmt.Printf("%-10s%-12s%-12s%-18s%-26s%s\r\n", "slot", "space id", "page no", "lsn", "type", "checksum")
+	shown := 0
+	for _, p := range pages {
+		if options.space >= 0 && uint32(options.space) != p.SpaceID {
+			continue
+		}
+		shown++
+		checksum := "corrupt"
+		if p.Valid() {
+			checksum = innodb.ChecksumAlgoToString(p.Checksum.Algo)
+		}
+		fmt.Printf("%-10d%-12d%-12d%-18d%-26s%s\r\n", p.Slot, p.SpaceID, p.PageNo, p.LSN,
+			innodb.PageTypeToString(int(p.Type)), checksum)
+	}
+	fmt.Printf("%d page copies, %d shown\r\n\r\n", len(pages), shown)
+}
+
+// restoreTornPages checks the pages of the target which have copies, and writes
+// the intact copies of the torn pages into a copy of the target
+func restoreTornPages(copies []*innodb.DoublewritePage, options *dblwrOptions) bool {
+	f, err := os.Open(options.target)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return false
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, 0)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return false
+	}
+	matches, err := ts.MatchDoublewritePages(copies)
+	if nil != err {
+		fmt.Println("Match doublewrite pages error ", err)
+		return false
+	}
+
+	fmt.Printf("\t\t\t==========TARGET %s==========\r\n", options.target)
+	fmt.Printf("%-12s%-18s%-18s%s\r\n", "page no", "page lsn", "copy lsn", "status")
+	var restores []*innodb.DoublewriteMatch
+	for _, m := range matches {
+		status := "OK"
+		switch {
+		case nil == m.Checksum:
+			{
+				status = "beyond the file"
+			}
+		case m.Restorable():
+			{
+				status = "torn, restorable"
+				restores = append(restores, m)
+			}
+		case m.Torn():
+			{
+				status = "torn, no intact copy"
+			}
+		}
+		fmt.Printf("%-12d%-18d%-18d%s\r\n", m.PageNo, m.LSN, m.Copy.LSN, status)
+	}
+	fmt.Printf("%d pages with copies, %d restorable\r\n", len(matches), len(restores))
+	if !options.restore || 0 == len(restores) {
+		return true
+	}
+
+	output := options.output
+	if "" == output {
+		output = options.target + ".restore"
+	}
+	if err = copyFile(f, output); nil != err {
+		fmt.Println("Copy file error ", err)
+		return false
+	}
+	of, err := os.OpenFile(output, os.O_RDWR, 0)
+	if nil != err {
+		fmt.Println("Open output file error ", err)
+		return false
+	}
+	defer of.Close()
+
+	size := int64(ts.PageSize())
+	for _, m := range restores {
+		if _, err = of.WriteAt(m.Copy.Data, int64(m.PageNo)*size); nil != err {
+			fmt.Printf("Write page %d error %v\r\n", m.PageNo, err)
+			return false
+		}
+	}
+	if err = of.Sync(); nil != err {
+		fmt.Println("Sync output file error ", err)
+		return false
+	}
+	fmt.Printf("%d page(s) restored to %s\r\n", len(restores), output)
+	return true
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"spf13/cobra"
+	"strings"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type dictOptions struct {
+	file     string
+	space    int
+	table    string
+	ibd      string
+	pageSize int
+}
+
+func newDictCommand() *cobra.Command {
+	var options dictOptions
+	c := &cobra.Command{
+		Use:   "dict",
+		Short: "show the legacy data dictionary",
+		Long:  "Show the tables, columns and indexes of the mysql 5.x data dictionary in the system table space",
+		Run: func(cmd *cobra.Command, args []string) {
+			doDict(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb system table space file path (ibdata1)")
+	c.Flags().IntVarP(&options.space, "space", "s", -1, "only show the table of the space id")
+	c.Flags().StringVarP(&options.table, "table", "t", "", "only show the table, e.g. db/table")
+	c.Flags().StringVarP(&options.ibd, "ibd", "i", "", "match the space id and index ids of the table space file with the dictionary")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+func doDict(cmd *cobra.Command, options *dictOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	dict, err := ts.DataDict()
+	if nil != err {
+		fmt.Println("Read data dictionary error ", err)
+		return
+	}
+
+	if "" != options.ibd {
+		matchDictIndexes(dict, options)
+		return
+	}
+
+	fmt.Printf("Max table id <%d> max index id <%d> max space id <%d>\r\n\r\n",
+		dict.MaxTableID, dict.MaxIndexID, dict.MaxSpaceID)
+	for _, t := range dict.Tables {
+		if options.space >= 0 && uint32(options.space) != t.Space {
+			continue
+		}
+		if "" != options.table && !strings.EqualFold(options.table, t.Name) {
+			continue
+		}
+		printDictTable(t)
+	}
+}
+
+func printDictTable(t *innodb.DictTable) {
+	fmt.Printf("==========TABLE %s==========\r\n", t.Name)
+	fmt.Printf("table id <%d> space id <%d> columns <%d> row format <%s>\r\n",
+		t.ID, t.Space, t.NCols&^innodb.DictNColsCompact, t.RowFormat())
+	fmt.Printf("%-8s%-32s%s\r\n", "pos", "column", "type")
+	for _, c := range t.Columns {
+		fmt.Printf("%-8d%-32s%s\r\n", c.Pos, c.Name, c.TypeString())
+	}
+	fmt.Printf("%-8s%-32s%-24s%-10s%s\r\n", "id", "index", "type", "root", "fields")
+	for _, i := range t.Indexes {
+		fmt.Printf("%-8d%-32s%-24s%-10d%s\r\n",
+			i.ID, i.Name, i.TypeString(), i.PageNo, strings.Join(i.Fields, ","))
+	}
+	fmt.Printf("\r\n")
+}
+
+// matchDictIndexes finds the table by the space id of the table space file, and
+// counts the index pages of every index id
+func matchDictIndexes(dict *innodb.DataDict, options *dictOptions) {
+	f, err := os.Open(options.ibd)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	found := false
+	var space uint32
+	counts := make(map[uint64]int)
+	ids := make([]uint64, 0, 4)
+	err = ts.IteratePages(&innodb.ParsePageOptions{
+		ParsePageTypeFlag: innodb.ParsePageFSP | innodb.ParsePageIndex,
+	}, func(page *innodb.Page, data []byte) error {
+		if 0 == page.No() {
+			if page.FileHeader.Type != innodb.PageTypeFspHDR {
+				return innodb.ErrStopIteration
+			}
+			found = true
+			space = page.FSPHeader.SpaceID
+			return nil
+		}
+		if page.FileHeader.Type != innodb.PageTypeIndex {
+			return nil
+		}
+		if _, ok := counts[page.IndexHeader.IndexID]; !ok {
+			ids = append(ids, page.IndexHeader.IndexID)
+		}
+		counts[page.IndexHeader.IndexID]++
+		return nil
+	})
+	if nil != err {
+		fmt.Println("Parse innodb data file error ", err)
+		return
+	}
+	if !found {
+		fmt.Println("File space header page not found")
+		return
+	}
+	if t := dict.FindTableBySpace(space); nil != t {
+		fmt.Printf("Space id <%d> is table %s\r\n", space, t.Name)
+	} else {
+		fmt.Printf("Space id <%d> not found in the data dictionary\r\n", space)
+	}
+
+	fmt.Printf("%-8s%-10s%-32s%s\r\n", "id", "pages", "index", "table")
+	for _, id := range ids {
+		t, i := dict.FindIndex(id)
+		if nil == i {
+			fmt.Printf("%-8d%-10d%-32s%s\r\n", id, counts[id], "N/A", "N/A")
+			continue
+		}
+		fmt.Printf("%-8d%-10d%-32s%s\r\n", id, counts[id], i.Name, t.Name)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
 )
 
 type dslotsOptions struct {
@@ -11,6 +13,9 @@ type dslotsOptions struct {
 	page      int
 	recorders bool
 	pksize    int
+	schema    string
+	pageSize  int
+	jobs      int
 }
 
 func newDslotsCommand() *cobra.Command {
@@ -28,6 +33,9 @@ func newDslotsCommand() *cobra.Command {
 	c.Flags().IntVarP(&options.page, "page", "p", -1, "specify page to show directory slots")
 	c.Flags().BoolVarP(&options.recorders, "recorders", "r", false, "show slot reference recorders")
 	c.Flags().IntVarP(&options.pksize, "pksize", "k", 8, "primary key size (BIGINT=8,INT=4,SINT=2,TINT=1)")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file to decode the records, read from the SDI if not specified, overrides --pksize")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
 
 	return c
 }
@@ -53,87 +61,187 @@ func doDslots(cmd *cobra.Command, options *dslotsOptions) {
 	}
 	defer f.Close()
 
-	pages, err := parseInnodbDataFile(f, &parsePageOptions{
-		parseRecords: options.recorders,
-		pksize:       options.pksize,
-	})
+	ts, err := innodb.NewTablespace(f, options.pageSize)
 	if nil != err {
-		fmt.Println("Parse innodb data file error ", err)
+		fmt.Println("Open table space error ", err)
+		return
 	}
-	// Show the page information
-	for pi, page := range pages {
+
+	table, err := ts.LoadTable(options.schema, "", "")
+	if nil != err {
+		if "" != options.schema {
+			fmt.Println("Load table schema error ", err)
+			return
+		}
+		// The records are still decoded with pksize
+		fmt.Println("Read SDI error ", err)
+	}
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: options.recorders,
+		PKSize:       options.pksize,
+		Table:        table,
+		Jobs:         options.jobs,
+	}
+
+	err = ts.IteratePages(parseOptions, func(page *innodb.Page, data []byte) error {
 		if options.page >= 0 {
-			if options.page != pi {
-				continue
+			if page.No() < options.page {
+				return nil
 			}
+			printPageDslots(page, options)
+			return innodb.ErrStopIteration
 		}
-		// Only index page have dslots
-		if page.dslots == nil {
-			continue
+		printPageDslots(page, options)
+		return nil
+	})
+	if nil != err {
+		fmt.Println("Parse innodb data file error ", err)
+	}
+}
+
+// printPageDslots shows the directory slots of the page, only index page have dslots
+func printPageDslots(page *innodb.Page, options *dslotsOptions) {
+	if page.DSlots == nil {
+		return
+	}
+	if isJSONOutput() {
+		printJSON(newDslotsPageDoc(page, options))
+		return
+	}
+	fmt.Printf("\t\t\t==========PAGE %d OFFSET 0x%04X LEVEL %d==========\r\n",
+		page.No(), page.Offset(), page.IndexHeader.Level)
+	fmt.Printf("%-8s%-12s%-12s%-8s%-8s%-10s\r\n",
+		"slot", "offset", "type", "owned", "key", "page ptr")
+
+	for _, slot := range page.DSlots {
+		fmt.Printf("%-8d0x%-10.04X%-12s%-8d",
+			slot.Index, slot.Value, slot.Type, slot.Owned)
+		if nil != slot.EndRecorder &&
+			slot.EndRecorder.HasKey {
+			fmt.Printf("%-8d", slot.EndRecorder.Key)
+		} else {
+			fmt.Printf("%-8s", "N/A")
 		}
-		page.pksize = options.pksize
-		fmt.Printf("\t\t\t==========PAGE %d OFFSET 0x%04X LEVEL %d==========\r\n",
-			pi, page.offset, page.pheader.level)
-		fmt.Printf("%-8s%-12s%-12s%-8s%-8s%-10s\r\n",
-			"slot", "offset", "type", "owned", "key", "page ptr")
-
-		for _, slot := range page.dslots {
-			fmt.Printf("%-8d0x%-10.04X%-12s%-8d",
-				slot.index, slot.value, slot.typ, slot.owned)
-			if nil != slot.rceptr &&
-				slot.rceptr.hasKey {
-				fmt.Printf("%-8d", slot.rceptr.key)
-			} else {
-				fmt.Printf("%-8s", "N/A")
-			}
-			if nil != slot.rceptr &&
-				0xffffffff != slot.rceptr.pageptr {
-				fmt.Printf("%-10d", slot.rceptr.pageptr)
+		if nil != slot.EndRecorder &&
+			0xffffffff != slot.EndRecorder.PagePtr {
+			fmt.Printf("%-10d", slot.EndRecorder.PagePtr)
+		} else {
+			fmt.Printf("%-8s", "N/A")
+		}
+		fmt.Printf("\r\n")
+		// Show slot reference recorders
+		if options.recorders {
+			if nil == slot.BeginRecorder {
+				fmt.Printf("No records found\r\n")
 			} else {
-				fmt.Printf("%-8s", "N/A")
-			}
-			fmt.Printf("\r\n")
-			// Show slot reference recorders
-			if options.recorders {
-				if nil == slot.rcbptr {
-					fmt.Printf("No records found\r\n")
-				} else {
-					fmt.Printf("slot reference: ")
-					ptr := slot.rcbptr
-					for i := 0; i < int(slot.owned); i++ {
-						if i+1 < int(slot.owned) {
-							if ptr.hasKey {
-								fmt.Printf("[0x%04X PK%d", ptr.fieldDataOffset, ptr.key)
-							} else {
-								fmt.Printf("[0x%04X", ptr.fieldDataOffset)
-							}
-							if ptr.pageptr != 0xffffffff {
-								fmt.Printf(" ->P%d", ptr.pageptr)
-							}
-							fmt.Printf("]->")
+				fmt.Printf("slot reference: ")
+				ptr := slot.BeginRecorder
+				for i := 0; i < int(slot.Owned); i++ {
+					if i+1 < int(slot.Owned) {
+						if ptr.HasKey {
+							fmt.Printf("[0x%04X PK%d", ptr.FieldDataOffset, ptr.Key)
 						} else {
-							if slot.rctype == recorderTypeInfimum {
-								fmt.Printf("[infimum own ")
-							} else if slot.rctype == recorderTypeSupremum {
-								fmt.Printf("[supremum own ")
-							} else {
-								fmt.Printf("[normal own ")
-							}
-							if ptr.hasKey {
-								fmt.Printf("%d 0x%04X PK%d", slot.owned, ptr.fieldDataOffset, ptr.key)
-							} else {
-								fmt.Printf("%d 0x%04X", slot.owned, ptr.fieldDataOffset)
-							}
-							if ptr.pageptr != 0xffffffff {
-								fmt.Printf(" ->P%d", ptr.pageptr)
-							}
-							fmt.Printf("]")
+							fmt.Printf("[0x%04X", ptr.FieldDataOffset)
 						}
-						ptr = ptr.next
+						if ptr.PagePtr != 0xffffffff {
+							fmt.Printf(" ->P%d", ptr.PagePtr)
+						}
+						fmt.Printf("]->")
+					} else {
+						if slot.RecorderType == innodb.RecorderTypeInfimum {
+							fmt.Printf("[infimum own ")
+						} else if slot.RecorderType == innodb.RecorderTypeSupremum {
+							fmt.Printf("[supremum own ")
+						} else {
+							fmt.Printf("[normal own ")
+						}
+						if ptr.HasKey {
+							fmt.Printf("%d 0x%04X PK%d", slot.Owned, ptr.FieldDataOffset, ptr.Key)
+						} else {
+							fmt.Printf("%d 0x%04X", slot.Owned, ptr.FieldDataOffset)
+						}
+						if ptr.PagePtr != 0xffffffff {
+							fmt.Printf(" ->P%d", ptr.PagePtr)
+						}
+						fmt.Printf("]")
 					}
-					fmt.Printf("\r\n")
+					ptr = ptr.Next
 				}
+				fmt.Printf("\r\n")
+			}
+		}
+	}
+}
+
+// dslotsPageDoc is the json document of the directory slots of the index page
+type dslotsPageDoc struct {
+	Page   int        `json:"page"`
+	Offset int        `json:"offset"`
+	Level  uint16     `json:"level"`
+	Slots  []*slotDoc `json:"slots"`
+}
+
+// slotDoc is the directory slot, the key and the page pointer are of the last
+// owned record. The owned records are written with --recorders
+type slotDoc struct {
+	Slot    int              `json:"slot"`
+	Offset  uint16           `json:"offset"`
+	Type    string           `json:"type"`
+	Owned   uint8            `json:"owned"`
+	Key     *int64           `json:"key"`
+	PagePtr *uint32          `json:"page_ptr"`
+	Records []*slotRecordDoc `json:"records,omitempty"`
+}
+
+type slotRecordDoc struct {
+	Offset  uint16  `json:"offset"`
+	Key     *int64  `json:"key"`
+	PagePtr *uint32 `json:"page_ptr"`
+}
+
+// recordKeyDoc returns the key and the page pointer of the record, nil if not exist
+func recordKeyDoc(rc *innodb.CompactRecorder) (*int64, *uint32) {
+	var key *int64
+	var pagePtr *uint32
+	if rc.HasKey {
+		k := rc.Key
+		key = &k
+	}
+	if 0xffffffff != rc.PagePtr {
+		p := rc.PagePtr
+		pagePtr = &p
+	}
+	return key, pagePtr
+}
+
+func newDslotsPageDoc(page *innodb.Page, options *dslotsOptions) *dslotsPageDoc {
+	doc := &dslotsPageDoc{
+		Page:   page.No(),
+		Offset: page.Offset(),
+		Level:  page.IndexHeader.Level,
+		Slots:  make([]*slotDoc, 0, len(page.DSlots)),
+	}
+	for _, slot := range page.DSlots {
+		sd := &slotDoc{
+			Slot:   slot.Index,
+			Offset: slot.Value,
+			Type:   slot.Type,
+			Owned:  slot.Owned,
+		}
+		if nil != slot.EndRecorder {
+			sd.Key, sd.PagePtr = recordKeyDoc(slot.EndRecorder)
+		}
+		if options.recorders {
+			sd.Records = make([]*slotRecordDoc, 0, slot.Owned)
+			ptr := slot.BeginRecorder
+			for i := 0; i < int(slot.Owned) && nil != ptr; i++ {
+				rd := &slotRecordDoc{Offset: ptr.FieldDataOffset}
+				rd.Key, rd.PagePtr = recordKeyDoc(ptr)
+				sd.Records = append(sd.Records, rd)
+				ptr = ptr.Next
 			}
 		}
+		doc.Slots = append(doc.Slots, sd)
 	}
+	return doc
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"spf13/cobra"
+	"time"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type exportOptions struct {
+	file     string
+	schema   string
+	columns  string
+	pk       string
+	format   string
+	output   string
+	batch    int
+	pageSize int
+}
+
+func newExportCommand() *cobra.Command {
+	var options exportOptions
+	c := &cobra.Command{
+		Use:   "export",
+		Short: "export the table rows",
+		Long:  "Export the records of the clustered index as CSV, JSON lines or SQL INSERT statements, the leaf pages are read one by one through the leaf page list",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !doExport(cmd, &options) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file, read from the SDI if not specified")
+	c.Flags().StringVarP(&options.columns, "columns", "c", "", "column definitions to decode the records")
+	c.Flags().StringVar(&options.pk, "pk", "", "comma separated primary key columns of --columns")
+	c.Flags().StringVar(&options.format, "rows-format", "csv", "output format, csv, ndjson or sql")
+	c.Flags().StringVarP(&options.output, "output", "o", "", "output file path, write to stdout if not specified")
+	c.Flags().IntVar(&options.batch, "batch", 100, "rows of every INSERT statement")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+// doExport returns false if the rows are not all exported
+func doExport(cmd *cobra.Command, options *exportOptions) bool {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return false
+	}
+	if innodb.RowFormatCSV != options.format && innodb.RowFormatNDJSON != options.format && innodb.RowFormatSQL != options.format {
+		fmt.Println("Invalid rows format, must be csv, ndjson or sql")
+		return false
+	}
+	if options.batch <= 0 {
+		fmt.Println("Invalid batch size")
+		return false
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return false
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return false
+	}
+
+	table, err := ts.LoadTable(options.schema, options.columns, options.pk)
+	if nil != err {
+		fmt.Println("Load table schema error ", err)
+		return false
+	}
+	if nil == table {
+		fmt.Println("No table schema specified and no SDI found")
+		return false
+	}
+	totalPages, err := ts.PageCount()
+	if nil != err {
+		fmt.Println("Get file info error ", err)
+		return false
+	}
+
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: true,
+		Table:        table,
+	}
+	var rootNo int
+	if index := table.ClusteredIndex(); 0 != index.ID {
+		rootNo, err = ts.IndexRootPageNo(index.ID)
+	} else {
+		rootNo, err = ts.FirstRootPageNo()
+	}
+	if nil != err {
+		fmt.Println("Find root index page error ", err)
+		return false
+	}
+	page, err := ts.LeftmostLeafPage(rootNo, parseOptions)
+	if nil != err {
+		fmt.Println("Find leftmost leaf page error ", err)
+		return false
+	}
+
+	var out io.Writer = os.Stdout
+	if "" != options.output {
+		of, err := os.Create(options.output)
+		if nil != err {
+			fmt.Println("Create output file error ", err)
+			return false
+		}
+		defer of.Close()
+		out = of
+	}
+	bw := bufio.NewWriterSize(out, 1<<20)
+	defer bw.Flush()
+	w := innodb.NewRowWriter(bw, table, options.format, false, options.batch)
+
+	// Progress and summary are written to stderr, the rows may be written to stdout
+	startTm := time.Now()
+	reportTm := startTm
+	rows, pages := 0, 0
+	for {
+		pages++
+		if pages > totalPages {
+			fmt.Fprintf(os.Stderr, "Leaf page list loop found at page %d\r\n", page.No())
+			return false
+		}
+		for _, rc := range page.UserRecorders() {
+			if rc.Header.DeleteFlag || nil == rc.Fields {
+				continue
+			}
+			row := &innodb.RecoveredRow{
+				Page:   page.No(),
+				Origin: int(rc.FieldDataOffset),
+				Fields: rc.Fields,
+			}
+			if err := w.Write(ts, row); nil != err {
+				fmt.Fprintf(os.Stderr, "Write row error %v\r\n", err)
+				return false
+			}
+			rows++
+		}
+		if time.Since(reportTm) >= time.Second {
+			reportTm = time.Now()
+			fmt.Fprintf(os.Stderr, "Exported %d row(s) in %d leaf page(s), page %d of %d (%.1f%%)\r\n",
+				rows, pages, page.No(), totalPages, float64(page.No())*100/float64(totalPages))
+		}
+
+		if 0xffffffff == page.FileHeader.Next {
+			break
+		}
+		next := int(page.FileHeader.Next)
+		if page, err = ts.ReadPage(next, parseOptions); nil != err {
+			fmt.Fprintf(os.Stderr, "Read page %d error %v\r\n", next, err)
+			return false
+		}
+	}
+	if err := w.Flush(); nil != err {
+		fmt.Fprintf(os.Stderr, "Write row error %v\r\n", err)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d row(s) in %d leaf page(s), cost %v\r\n",
+		rows, pages, time.Since(startTm).Round(time.Millisecond))
+	return true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"spf13/cobra"
+	"strings"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type indexesOptions struct {
+	file     string
+	schema   string
+	pageSize int
+	jobs     int
+}
+
+func newIndexesCommand() *cobra.Command {
+	var options indexesOptions
+	c := &cobra.Command{
+		Use:   "indexes",
+		Short: "show all indexes",
+		Long:  "Show all B+ tree indexes of the table space, including the root page, height, pages and records",
+		Run: func(cmd *cobra.Command, args []string) {
+			doIndexes(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file to show the index names, read from the SDI if not specified")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
+
+	return c
+}
+
+func doIndexes(cmd *cobra.Command, options *indexesOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	table, err := ts.LoadTable(options.schema, "", "")
+	if nil != err {
+		if "" != options.schema {
+			fmt.Println("Load table schema error ", err)
+			return
+		}
+		fmt.Println("Read SDI error ", err)
+	}
+
+	indexes, err := ts.Indexes(options.jobs)
+	if nil != err {
+		fmt.Println("Parse innodb data file error ", err)
+		return
+	}
+
+	fmt.Printf("%-22s%-20s%-8s%-8s%-10s%-10s%-12s%-12s%s\r\n",
+		"id", "name", "root", "height", "pages", "records", "leaf seg", "nonleaf seg", "level pages")
+	for _, index := range indexes {
+		name := "N/A"
+		if index.Type == innodb.PageTypeSDI {
+			name = "SDI"
+		} else if nil != table {
+			for _, ti := range table.Indexes {
+				if ti.ID == index.ID {
+					name = ti.Name
+					break
+				}
+			}
+		}
+		root := "N/A"
+		if index.Root >= 0 {
+			root = fmt.Sprintf("%d", index.Root)
+		}
+		total := 0
+		levels := make([]string, 0, index.Height())
+		for level := index.Height() - 1; level >= 0; level-- {
+			total += index.LevelPages[level]
+			levels = append(levels, fmt.Sprintf("L%d:%d", level, index.LevelPages[level]))
+		}
+		fmt.Printf("%-22d%-20s%-8s%-8d%-10d%-10d%-12d%-12d%s\r\n",
+			index.ID, name, root, index.Height(), total, index.Records,
+			index.LeafSegmentID, index.NonleafSegmentID, strings.Join(levels, " "))
+	}
+}
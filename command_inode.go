@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"os"
 	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
 )
 
 type inodeOptions struct {
 	file          string
 	unused        bool
 	fragmentArray bool
+	pageSize      int
+	jobs          int
 }
 
 func newInodeCommand() *cobra.Command {
@@ -26,6 +30,8 @@ func newInodeCommand() *cobra.Command {
 	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
 	c.Flags().BoolVarP(&options.unused, "unused", "u", false, "show unused inode")
 	c.Flags().BoolVarP(&options.fragmentArray, "fragment", "r", false, "show fragment array")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
 
 	return c
 }
@@ -43,73 +49,148 @@ func doInode(cmd *cobra.Command, options *inodeOptions) {
 	}
 	defer f.Close()
 
-	pages, err := parseInnodbDataFile(f, &parsePageOptions{
-		parsePageTypeFlag: parsePageInode,
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	err = ts.IteratePages(&innodb.ParsePageOptions{
+		ParsePageTypeFlag: innodb.ParsePageInode,
+		Jobs:              options.jobs,
+	}, func(page *innodb.Page, data []byte) error {
+		printInodePage(page, options)
+		return nil
 	})
 	if nil != err {
 		fmt.Println("Parse innodb data file error ", err)
 	}
+}
 
-	for _, page := range pages {
-		fmt.Printf("\t\t\t==========PAGE %d OFFSET 0x%04X==========\r\n",
-			page.no, page.offset)
+// printInodePage shows the file segment inode entries of the inode page
+func printInodePage(page *innodb.Page, options *inodeOptions) {
+	if isJSONOutput() {
+		printJSON(newInodePageDoc(page, options))
+		return
+	}
+	ps := innodb.PageSize(page.Size())
+	fmt.Printf("\t\t\t==========PAGE %d OFFSET 0x%04X==========\r\n",
+		page.No(), page.Offset())
 
-		// Print table headers
-		fmt.Printf("%-51s", "page list")
-		fmt.Printf("\r\n")
+	// Print table headers
+	fmt.Printf("%-51s", "page list")
+	fmt.Printf("\r\n")
+	// Print table columns
+	fmt.Printf("%-51s", page.INode.InodePageList.ToString(38))
+	fmt.Printf("\r\n\r\n")
+
+	// Print table headers
+	fmt.Printf("%-20s", "file segment id")
+	fmt.Printf("%-10s", "used(nf)")
+	fmt.Printf("%-51s", "free list")
+	fmt.Printf("%-51s", "not_full list")
+	fmt.Printf("%-51s", "full list")
+	if options.fragmentArray {
+		fmt.Printf("fragment array")
+	}
+	fmt.Printf("\r\n")
+
+	// Print inode
+	for ni, node := range page.INode.Inodes {
+		if nil == node {
+			panic(fmt.Sprintf("nil inode, index = %d", ni))
+		}
 		// Print table columns
-		fmt.Printf("%-51s", page.inode.inodePageList.toString(38))
-		fmt.Printf("\r\n\r\n")
-
-		// Print table headers
-		fmt.Printf("%-20s", "file segment id")
-		fmt.Printf("%-10s", "used(nf)")
-		fmt.Printf("%-51s", "free list")
-		fmt.Printf("%-51s", "not_full list")
-		fmt.Printf("%-51s", "full list")
-		if options.fragmentArray {
-			fmt.Printf("fragment array")
+		if 0 == node.FileSegmentID {
+			// Unused
+			if !options.unused {
+				continue
+			}
+			fmt.Printf("0x%08X:%-9s", 38+12+ni*ps.InodeEntrySize(), "<unused>")
+		} else {
+			fmt.Printf("0x%08X:%-9d", 38+12+ni*ps.InodeEntrySize(), node.FileSegmentID)
 		}
-		fmt.Printf("\r\n")
 
-		// Print inode
-		for ni, node := range page.inode.inodes {
-			if nil == node {
-				panic(fmt.Sprintf("nil inode, index = %d", ni))
-			}
-			// Print table columns
-			if 0 == node.fileSegmentID {
-				// Unused
-				if !options.unused {
+		fmt.Printf("%-10d", node.UsedPagesInNotFullList)
+		fmt.Printf("%-51s", node.FreeList.ToString(8))
+		fmt.Printf("%-51s", node.NotFullList.ToString(8))
+		fmt.Printf("%-51s", node.FullList.ToString(8))
+
+		if options.fragmentArray {
+			cnt := 0
+			for _, v := range node.FragmentArrayEntry {
+				if v == 0xffffffff {
 					continue
 				}
-				fmt.Printf("0x%08X:%-9s", 38+12+ni*192, "<unused>")
+				fmt.Printf("%d ", v)
+				cnt++
+			}
+			if cnt == len(node.FragmentArrayEntry) {
+				fmt.Printf("(extend allocate)")
 			} else {
-				fmt.Printf("0x%08X:%-9d", 38+12+ni*192, node.fileSegmentID)
+				fmt.Printf("(page allocate)")
 			}
+		}
 
-			fmt.Printf("%-10d", node.usedPagesInNotFullList)
-			fmt.Printf("%-51s", node.freeList.toString(8))
-			fmt.Printf("%-51s", node.notFullList.toString(8))
-			fmt.Printf("%-51s", node.fullList.toString(8))
-
-			if options.fragmentArray {
-				cnt := 0
-				for _, v := range node.fragmentArrayEntry {
-					if v == 0xffffffff {
-						continue
-					}
-					fmt.Printf("%d ", v)
-					cnt++
-				}
-				if cnt == len(node.fragmentArrayEntry) {
-					fmt.Printf("(extend allocate)")
-				} else {
-					fmt.Printf("(page allocate)")
+		fmt.Printf("\r\n")
+	}
+}
+
+// inodePageDoc is the json document of the inode page
+type inodePageDoc struct {
+	Page     int              `json:"page"`
+	Offset   int              `json:"offset"`
+	PageList *listNodeDoc     `json:"page_list"`
+	Inodes   []*inodeEntryDoc `json:"inodes"`
+}
+
+// inodeEntryDoc is the file segment inode entry, the file segment id of the
+// unused entry is 0. The fragment array is written with --fragment
+type inodeEntryDoc struct {
+	EntryOffset   int          `json:"entry_offset"`
+	FileSegmentID uint64       `json:"file_segment_id"`
+	UsedNotFull   uint32       `json:"used_not_full"`
+	FreeList      *listNodeDoc `json:"free_list"`
+	NotFullList   *listNodeDoc `json:"not_full_list"`
+	FullList      *listNodeDoc `json:"full_list"`
+	FragmentArray []uint32     `json:"fragment_array,omitempty"`
+	Allocate      string       `json:"allocate,omitempty"`
+}
+
+func newInodePageDoc(page *innodb.Page, options *inodeOptions) *inodePageDoc {
+	ps := innodb.PageSize(page.Size())
+	doc := &inodePageDoc{
+		Page:     page.No(),
+		Offset:   page.Offset(),
+		PageList: newListNodeDoc(&page.INode.InodePageList, 38),
+		Inodes:   make([]*inodeEntryDoc, 0, len(page.INode.Inodes)),
+	}
+	for ni, node := range page.INode.Inodes {
+		if 0 == node.FileSegmentID && !options.unused {
+			continue
+		}
+		ed := &inodeEntryDoc{
+			EntryOffset:   38 + 12 + ni*ps.InodeEntrySize(),
+			FileSegmentID: node.FileSegmentID,
+			UsedNotFull:   node.UsedPagesInNotFullList,
+			FreeList:      newListBaseNodeDoc(&node.FreeList, 8),
+			NotFullList:   newListBaseNodeDoc(&node.NotFullList, 8),
+			FullList:      newListBaseNodeDoc(&node.FullList, 8),
+		}
+		if options.fragmentArray {
+			ed.FragmentArray = make([]uint32, 0, len(node.FragmentArrayEntry))
+			for _, v := range node.FragmentArrayEntry {
+				if v != 0xffffffff {
+					ed.FragmentArray = append(ed.FragmentArray, v)
 				}
 			}
-
-			fmt.Printf("\r\n")
+			if len(ed.FragmentArray) == len(node.FragmentArrayEntry) {
+				ed.Allocate = "extent"
+			} else {
+				ed.Allocate = "page"
+			}
 		}
+		doc.Inodes = append(doc.Inodes, ed)
 	}
+	return doc
 }
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
 	"os"
 	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
 )
 
 type overviewOptions struct {
-	file    string
-	verbose bool
-	page    int
+	file     string
+	verbose  bool
+	page     int
+	pageSize int
+	jobs     int
+	// Punch hole block size of the page compressed pages
+	blockSize int
 }
 
 func newOverviewCommand() *cobra.Command {
@@ -26,6 +33,9 @@ func newOverviewCommand() *cobra.Command {
 	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
 	c.Flags().BoolVarP(&options.verbose, "verbose", "v", false, "show verbose information")
 	c.Flags().IntVarP(&options.page, "page", "p", -1, "specify page to show")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
+	c.Flags().IntVar(&options.blockSize, "block-size", 4096, "file system block size to calculate the disk size of the page compressed pages")
 
 	return c
 }
@@ -43,33 +53,212 @@ func doOverview(cmd *cobra.Command, options *overviewOptions) {
 	}
 	defer f.Close()
 
-	pages, err := parseInnodbDataFile(f, &parsePageOptions{})
+	ts, err := innodb.NewTablespace(f, options.pageSize)
 	if nil != err {
-		fmt.Println("Parse innodb data file error ", err)
+		fmt.Println("Open table space error ", err)
+		return
 	}
-	printInnodbPages(pages, options)
-}
 
-func printInnodbPages(pages []*Page, options *overviewOptions) {
-	for i, page := range pages {
+	var savings compressionSavings
+	err = ts.IteratePages(&innodb.ParsePageOptions{
+		Jobs: options.jobs,
+	}, func(page *innodb.Page, data []byte) error {
 		if options.page >= 0 {
-			if i != options.page {
-				continue
+			if page.No() < options.page {
+				return nil
 			}
+			printInnodbPage(page, options)
+			return innodb.ErrStopIteration
+		}
+		printInnodbPage(page, options)
+		savings.add(page, options.blockSize)
+		return nil
+	})
+	if nil != err {
+		fmt.Println("Parse innodb data file error ", err)
+		return
+	}
+	if options.page < 0 && !isJSONOutput() && 0 != savings.compressed {
+		savings.print()
+	}
+}
+
+// compressionSavings is the space savings of the page compressed pages
+type compressionSavings struct {
+	pages      int
+	compressed int
+	// Logical size and the disk size of all pages
+	logical int64
+	disk    int64
+}
+
+func (s *compressionSavings) add(page *innodb.Page, blockSize int) {
+	s.pages++
+	s.logical += int64(page.Size())
+	if nil == page.Compression {
+		s.disk += int64(page.Size())
+		return
+	}
+	s.compressed++
+	s.disk += int64(page.Compression.Allocated(page.Size(), blockSize))
+}
+
+func (s *compressionSavings) print() {
+	fmt.Printf("==========PAGE COMPRESSION==========\r\n")
+	fmt.Printf("%d page(s), %d page compressed\r\n", s.pages, s.compressed)
+	fmt.Printf("logical size %d bytes, disk size %d bytes, saved %d bytes (%.2f%%)\r\n",
+		s.logical, s.disk, s.logical-s.disk, float64(s.logical-s.disk)*100/float64(s.logical))
+}
+
+// overviewPageDoc is the json document of the page, the headers are written
+// with --verbose
+type overviewPageDoc struct {
+	Page           int                 `json:"page"`
+	Offset         int                 `json:"offset"`
+	Type           string              `json:"type"`
+	Level          *uint16             `json:"level,omitempty"`
+	FileHeader     *fileHeaderDoc      `json:"file_header,omitempty"`
+	PageHeader     *pageIndexHeaderDoc `json:"page_header,omitempty"`
+	FileTrailer    *fileTrailerDoc     `json:"file_trailer,omitempty"`
+	DirectorySlots []uint16            `json:"directory_slots,omitempty"`
+	Compression    *pageCompressionDoc `json:"compression,omitempty"`
+	Error          string              `json:"error,omitempty"`
+}
+
+// pageCompressionDoc is the on-disk size and the logical size of the page
+// compressed page
+type pageCompressionDoc struct {
+	Type        string `json:"type"`
+	Algorithm   string `json:"algorithm"`
+	Compressed  int    `json:"compressed_size"`
+	DiskSize    int    `json:"disk_size"`
+	LogicalSize int    `json:"logical_size"`
+}
+
+func printInnodbPage(page *innodb.Page, options *overviewOptions) {
+	if isJSONOutput() {
+		printJSON(newOverviewPageDoc(page, options))
+		return
+	}
+	fmt.Printf("==========PAGE %d==========\r\n", page.No())
+	fmt.Printf("page num %d, offset 0x%08X, ", page.No(), page.Offset())
+	fmt.Printf("page type <%s> ", innodb.PageTypeToString(int(page.FileHeader.Type)))
+	if page.FileHeader.Type == innodb.PageTypeIndex || page.FileHeader.Type == innodb.PageTypeSDI {
+		printIndexLevel(&page.IndexHeader)
+	}
+	if nil != page.Compression {
+		printPageCompression(page, options.blockSize)
+	}
+	if nil != page.DecompressError {
+		fmt.Printf("unreadable <%v> ", page.DecompressError)
+	}
+	fmt.Printf("\r\n")
+	if options.verbose {
+		printFileHeader(&page.FileHeader)
+		printPageIndexHeader(&page.IndexHeader)
+		printFileTrailer(page)
+		printDirectorySlots(page)
+	}
+	fmt.Printf("\r\n")
+}
+
+func newOverviewPageDoc(page *innodb.Page, options *overviewOptions) *overviewPageDoc {
+	doc := &overviewPageDoc{
+		Page:   page.No(),
+		Offset: page.Offset(),
+		Type:   innodb.PageTypeToString(int(page.FileHeader.Type)),
+	}
+	isIndex := page.FileHeader.Type == innodb.PageTypeIndex || page.FileHeader.Type == innodb.PageTypeSDI
+	if isIndex {
+		level := page.IndexHeader.Level
+		doc.Level = &level
+	}
+	if nil != page.Compression {
+		doc.Compression = &pageCompressionDoc{
+			Type:        innodb.PageTypeToString(int(page.Compression.Type)),
+			Algorithm:   innodb.CompressAlgoToString(page.Compression.Algorithm),
+			Compressed:  page.Compression.Size,
+			DiskSize:    page.Compression.Allocated(page.Size(), options.blockSize),
+			LogicalSize: page.Size(),
+		}
+	}
+	if nil != page.DecompressError {
+		doc.Error = page.DecompressError.Error()
+	}
+	if options.verbose {
+		doc.FileHeader = newFileHeaderDoc(&page.FileHeader)
+		if isIndex {
+			doc.PageHeader = newPageIndexHeaderDoc(&page.IndexHeader)
 		}
-		fmt.Printf("==========PAGE %d==========\r\n", i)
-		fmt.Printf("page num %d, offset 0x%08X, ", i, page.offset)
-		fmt.Printf("page type <%s> ", pageTypeToString(int(page.fheader.typ)))
-		if page.fheader.typ == pageTypeIndex {
-			page.pheader.printIndex()
+		doc.FileTrailer = newFileTrailerDoc(page)
+		for i := 0; i+2 <= len(page.DirectorySlots); i += 2 {
+			doc.DirectorySlots = append(doc.DirectorySlots, binary.BigEndian.Uint16(page.DirectorySlots[i:]))
 		}
-		fmt.Printf("\r\n")
-		if options.verbose {
-			page.fheader.printVerbose()
-			page.pheader.printVerbose()
-			page.printFileTrailer()
-			page.printDirectorySlots()
+	}
+	return doc
+}
+
+func printFileHeader(h *innodb.FileHeader) {
+	fmt.Printf("\t\tFile header:\r\n")
+	fmt.Printf("Type <%d> ", h.Type)
+	fmt.Printf("Checksum <0x%08X> ", h.SpaceOrChecksum)
+	fmt.Printf("Offset <%d> ", h.Offset)
+	fmt.Printf("Prev <0x%08X> ", h.Prev)
+	fmt.Printf("Next <0x%08X> ", h.Next)
+	fmt.Printf("Log sequence number <%d> ", h.LSN)
+	fmt.Printf("Space ID <%d> ", h.ArchLogNoOrSpaceID)
+	fmt.Printf("\r\n")
+}
+
+func printPageIndexHeader(h *innodb.PageIndexHeader) {
+	fmt.Printf("\t\tPage header:\r\n")
+	fmt.Printf("Heap top <0x%04X> ", h.HeapTop)
+	fmt.Printf("N heap <0x%04X> ", h.NHeap)
+	fmt.Printf("Free <0x%04X> ", h.Free)
+	fmt.Printf("Garbage <0x%04X> ", h.Garbage)
+	fmt.Printf("Last insert <0x%04X> ", h.LastInsert)
+	fmt.Printf("Direction <0x%04X> ", h.Direction)
+	fmt.Printf("N direction <0x%04X> ", h.NDirection)
+	fmt.Printf("N recs <0x%04X> ", h.NRecs)
+	fmt.Printf("Index id <0x%016X> ", h.IndexID)
+	fmt.Printf("Leaf inode <0x%08X:0x%04X> ",
+		h.LeafInode.InodePageNumber, h.LeafInode.InodeOffset)
+	fmt.Printf("Non-leaf inode <0x%08X:0x%04X> ",
+		h.NonleafInode.InodePageNumber, h.NonleafInode.InodeOffset)
+	fmt.Printf("\r\n")
+}
+
+func printIndexLevel(h *innodb.PageIndexHeader) {
+	fmt.Printf("level <%d> ", h.Level)
+}
+
+func printPageCompression(p *innodb.Page, blockSize int) {
+	fmt.Printf("compression <%s> ", innodb.CompressAlgoToString(p.Compression.Algorithm))
+	fmt.Printf("disk size <%d/%d> ", p.Compression.Allocated(p.Size(), blockSize), p.Size())
+}
+
+func printFileTrailer(p *innodb.Page) {
+	fmt.Printf("\t\tFile trailer:\r\n")
+	// Lower 4 bytes is checksum
+	checksum := binary.BigEndian.Uint32(p.Trailer[0:4])
+	// Higher 4 bytes is lsn
+	lsn := binary.BigEndian.Uint32(p.Trailer[4:8])
+	fmt.Printf("Check sum<0x%08X> LSN<%d> ", checksum, lsn)
+	fmt.Printf("\r\n")
+}
+
+func printDirectorySlots(p *innodb.Page) {
+	if nil == p.DirectorySlots {
+		return
+	}
+	fmt.Printf("\t\tPage directory slots (%d total):\r\n[", len(p.DirectorySlots)/2)
+	for i := 0; i < len(p.DirectorySlots); i += 2 {
+		v := binary.BigEndian.Uint16(p.DirectorySlots[i:])
+		fmt.Printf("0x%04X", v)
+		if i+2 < len(p.DirectorySlots) {
+			fmt.Printf(" ")
 		}
-		fmt.Printf("\r\n")
 	}
+	fmt.Printf("]")
+	fmt.Printf("\r\n")
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"spf13/cobra"
+	"strings"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type recordsOptions struct {
+	file     string
+	page     int
+	columns  string
+	pk       string
+	schema   string
+	pageSize int
+}
+
+func newRecordsCommand() *cobra.Command {
+	var options recordsOptions
+	c := &cobra.Command{
+		Use:   "records",
+		Short: "show decoded records",
+		Long:  "Show the records of a page or the whole clustered index decoded with the table schema",
+		Run: func(cmd *cobra.Command, args []string) {
+			doRecords(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().IntVarP(&options.page, "page", "p", -1, "specify page to show records, show the whole clustered index if not specified")
+	c.Flags().StringVarP(&options.columns, "columns", "c", "", "column definitions, read from the SDI if no schema specified, e.g. \"id bigint not null, name varchar(32)\"")
+	c.Flags().StringVar(&options.pk, "pk", "", "comma separated primary key columns, DB_ROW_ID is used if not specified")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file, overrides --columns and --pk")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+func doRecords(cmd *cobra.Command, options *recordsOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	table, err := ts.LoadTable(options.schema, options.columns, options.pk)
+	if nil != err {
+		fmt.Println("Load table schema error ", err)
+		return
+	}
+	if nil == table {
+		fmt.Println("No table schema specified and no SDI found")
+		return
+	}
+
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: true,
+		Table:        table,
+	}
+	fmt.Printf("%-10s%-10s%-8s%-10s%s\r\n", "page", "offset", "heap", "deleted", "fields")
+
+	if options.page >= 0 {
+		page, err := ts.ReadPage(options.page, parseOptions)
+		if nil != err {
+			fmt.Printf("Read page %d error %v\r\n", options.page, err)
+			return
+		}
+		if page.FileHeader.Type != innodb.PageTypeIndex {
+			fmt.Printf("Page %d is not index page\r\n", options.page)
+			return
+		}
+		printPageRecords(page)
+		return
+	}
+
+	// Walk through the leaf level of the clustered index
+	rootNo, err := ts.FirstRootPageNo()
+	if nil != err {
+		fmt.Println("Find root index page error ", err)
+		return
+	}
+	page, err := ts.LeftmostLeafPage(rootNo, parseOptions)
+	if nil != err {
+		fmt.Println("Find leftmost leaf page error ", err)
+		return
+	}
+	records := 0
+	for {
+		records += printPageRecords(page)
+		if 0xffffffff == page.FileHeader.Next {
+			break
+		}
+		if page, err = ts.ReadPage(int(page.FileHeader.Next), parseOptions); nil != err {
+			fmt.Printf("Read next page error %v\r\n", err)
+			return
+		}
+	}
+	fmt.Printf("\r\n%d record(s)\r\n", records)
+}
+
+func printPageRecords(page *innodb.Page) int {
+	rcs := page.UserRecorders()
+	for _, rc := range rcs {
+		printRecord(page, rc)
+	}
+	return len(rcs)
+}
+
+func printRecord(page *innodb.Page, rc *innodb.CompactRecorder) {
+	deleted := "N"
+	if rc.Header.DeleteFlag {
+		deleted = "Y"
+	}
+	fmt.Printf("%-10d0x%-8.04X%-8d%-10s%s\r\n",
+		page.No(), rc.FieldDataOffset, rc.Header.HeapNo, deleted, formatRecordFields(rc.Fields))
+}
+
+func formatRecordFields(fields []*innodb.RecordField) string {
+	values := make([]string, 0, len(fields))
+	for _, f := range fields {
+		values = append(values, fmt.Sprintf("%s=%s", f.Column.Name, f.String()))
+	}
+	return strings.Join(values, " ")
+}
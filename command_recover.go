@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"spf13/cobra"
+
+	"github.com/juju/errors"
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type recoverOptions struct {
+	redo     []string
+	applyTo  string
+	space    int
+	pageSize int
+	dryRun   bool
+}
+
+func newRecoverCommand() *cobra.Command {
+	var options recoverOptions
+	c := &cobra.Command{
+		Use:   "recover",
+		Short: "apply redo log records to a copy of a table space",
+		Long:  "Replay the page records of the redo log since the latest checkpoint onto a copy of the table space, like the crash recovery of innodb",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !doRecover(cmd, &options) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	c.Flags().StringSliceVar(&options.redo, "redo", nil, "redo log files or the directory of them, can be specified more than once")
+	c.Flags().StringVar(&options.applyTo, "apply-to", "", "copy of the table space file to apply the records, it is modified in place")
+	c.Flags().IntVarP(&options.space, "space", "s", -1, "space id of the table space, read from page 0 if not specified")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().BoolVar(&options.dryRun, "dry-run", false, "only show the changes without writing the pages")
+
+	return c
+}
+
+// resolveRedoLogPaths expands the directories to the redo log files
+func resolveRedoLogPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		dirFiles, err := findRedoLogFiles(path)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// recoverPage is the page modified by the records, the pages are kept in memory
+// until all records are applied
+type recoverPage struct {
+	no        int
+	data      []byte
+	algo      int
+	lsnBefore uint64
+	applied   int
+	// stopped is the first record which can't be applied, the following records
+	// of the page are skipped
+	stopped *innodb.RedoRecord
+	err     error
+}
+
+// pageRecovery replays the records of the mini-transactions onto the pages of
+// the table space
+type pageRecovery struct {
+	ts        *innodb.Tablespace
+	spaceID   uint32
+	pageCount int
+	fullCrc32 bool
+	pages     map[int]*recoverPage
+	applied   map[int]int
+	skipped   map[int]int
+	// onPage is the records already on the pages
+	onPage int
+	format uint32
+}
+
+func (pr *pageRecovery) page(no int) (*recoverPage, error) {
+	if p, ok := pr.pages[no]; ok {
+		return p, nil
+	}
+	data := make([]byte, pr.ts.PageSize())
+	if err := pr.ts.ReadPageData(no, data); nil != err {
+		return nil, errors.Trace(err)
+	}
+	if innodb.IsPageCompressed(data) {
+		// The redo records are applied to the uncompressed page
+		return nil, errors.Errorf("Page %d is page compressed, not supported", no)
+	}
+	// Keep the checksum algorithm of the page
+	algo := innodb.ChecksumAlgoCrc32
+	if pr.fullCrc32 {
+		algo = innodb.ChecksumAlgoFullCrc32
+	}
+	if result := innodb.VerifyPageChecksum(data, pr.fullCrc32); !result.Corrupt() && !result.Empty {
+		algo = result.Algo
+	}
+	p := &recoverPage{
+		no:        no,
+		data:      data,
+		algo:      algo,
+		lsnBefore: innodb.PageLSN(data),
+	}
+	pr.pages[no] = p
+	return p, nil
+}
+
+// groupPage returns the page of the record in the table space, nil if the
+// record is not of the table space or beyond the file
+func (pr *pageRecovery) groupPage(rec *innodb.RedoRecord) (*recoverPage, error) {
+	if !rec.HasPage() || rec.SpaceID != pr.spaceID {
+		return nil, nil
+	}
+	if int(rec.PageNo) >= pr.pageCount {
+		// The file is extended after the copy
+		pr.skipped[rec.Type]++
+		return nil, nil
+	}
+	return pr.page(int(rec.PageNo))
+}
+
+// applyGroup applies the records of the table space in the mini-transaction,
+// the records of a page are applied all or none, so the page is never left in
+// the middle of the mini-transaction
+func (pr *pageRecovery) applyGroup(group []*innodb.RedoRecord) error {
+	for _, rec := range group {
+		p, err := pr.groupPage(rec)
+		if nil != err {
+			return errors.Trace(err)
+		}
+		if nil == p || nil != p.stopped || rec.LSN < innodb.PageLSN(p.data) {
+			continue
+		}
+		if !innodb.IsRedoTypeApplicable(rec.Type) {
+			p.stopped = rec
+		}
+	}
+
+	// The page data before the mini-transaction and the types applied
+	backups := make(map[int][]byte)
+	applied := make(map[int][]int)
+	for _, rec := range group {
+		p := pr.pages[int(rec.PageNo)]
+		if !rec.HasPage() || rec.SpaceID != pr.spaceID || nil == p {
+			continue
+		}
+		if nil != p.stopped {
+			pr.skipped[rec.Type]++
+			continue
+		}
+		if rec.LSN < innodb.PageLSN(p.data) {
+			pr.onPage++
+			continue
+		}
+		if _, ok := backups[p.no]; !ok {
+			backups[p.no] = append([]byte(nil), p.data...)
+		}
+		if err := innodb.ApplyRedoRecord(rec, p.data); nil != err {
+			copy(p.data, backups[p.no])
+			for _, typ := range applied[p.no] {
+				pr.applied[typ]--
+				pr.skipped[typ]++
+			}
+			p.applied -= len(applied[p.no])
+			delete(applied, p.no)
+			p.stopped = rec
+			p.err = err
+			pr.skipped[rec.Type]++
+			continue
+		}
+		applied[p.no] = append(applied[p.no], rec.Type)
+		p.applied++
+		pr.applied[rec.Type]++
+	}
+
+	// The pages are stamped with the end lsn of the mini-transaction, it is the
+	// end of the MLOG_MULTI_REC_END record
+	endLSN := group[len(group)-1].EndLSN
+	for no := range applied {
+		innodb.SetPageLSN(pr.pages[no].data, endLSN, pr.fullCrc32)
+	}
+	return nil
+}
+
+func doRecover(cmd *cobra.Command, options *recoverOptions) bool {
+	if "" == options.applyTo {
+		fmt.Println("No table space file specified")
+		return false
+	}
+	paths, err := resolveRedoLogPaths(options.redo)
+	if nil != err {
+		fmt.Println("Find redo log files error ", err)
+		return false
+	}
+	if 0 == len(paths) {
+		fmt.Println("No redo log file specified")
+		return false
+	}
+
+	flag := os.O_RDWR
+	if options.dryRun {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(options.applyTo, flag, 0)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return false
+	}
+	defer f.Close()
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return false
+	}
+	if 0 != ts.ZipSize() {
+		// The redo records are applied to the physical pages
+		fmt.Println("Compressed table space is not supported")
+		return false
+	}
+	pageCount, err := ts.PageCount()
+	if nil != err {
+		fmt.Println("Get page count error ", err)
+		return false
+	}
+	spaceID := uint32(options.space)
+	if options.space < 0 {
+		page, err := ts.ReadPage(0, &innodb.ParsePageOptions{HeaderOnly: true})
+		if nil != err {
+			fmt.Println("Read page 0 error ", err)
+			return false
+		}
+		spaceID = page.FileHeader.ArchLogNoOrSpaceID
+	}
+	flags, _ := ts.FSPFlags()
+
+	files := make([]*innodb.RedoLogFile, 0, len(paths))
+	for _, path := range paths {
+		rf, err := os.Open(path)
+		if nil != err {
+			fmt.Println("Open file error ", err)
+			return false
+		}
+		defer rf.Close()
+		redoFile, err := innodb.NewRedoLogFile(rf)
+		if nil != err {
+			fmt.Println("Open redo log file ", path, " error ", err)
+			return false
+		}
+		files = append(files, redoFile)
+	}
+	redo := innodb.NewRedoLog(files)
+	cp := redo.Checkpoint()
+	if nil == cp {
+		fmt.Println("No valid checkpoint found")
+		return false
+	}
+	fmt.Printf("space id %d, %d pages, checkpoint lsn %d\r\n\r\n", spaceID, pageCount, cp.LSN)
+
+	pr := &pageRecovery{
+		ts:        ts,
+		spaceID:   spaceID,
+		pageCount: pageCount,
+		fullCrc32: innodb.IsFullCrc32(flags),
+		pages:     make(map[int]*recoverPage),
+		applied:   make(map[int]int),
+		skipped:   make(map[int]int),
+		format:    files[0].Header.Format,
+	}
+	// The records are applied by the mini-transactions since the checkpoint, the
+	// replay stops if the records are not continuous, the pages are left at the
+	// lsn before the lost records
+	var group []*innodb.RedoRecord
+	var groupLSN, prevEnd, stopLSN uint64
+	started := false
+	_, err = redo.IterateRecords(func(rec *innodb.RedoRecord) error {
+		if rec.Unparsed || (0 != prevEnd && rec.LSN != prevEnd) {
+			group = group[:0]
+			if started {
+				stopLSN = prevEnd
+				return innodb.ErrStopIteration
+			}
+			prevEnd = 0
+			if rec.Unparsed {
+				return nil
+			}
+		}
+		prevEnd = rec.EndLSN
+		if 0 == len(group) {
+			groupLSN = rec.LSN
+		}
+		group = append(group, rec)
+		if !rec.Single && innodb.MlogMultiRecEnd != rec.Type {
+			return nil
+		}
+		if groupLSN >= cp.LSN {
+			started = true
+			if err := pr.applyGroup(group); nil != err {
+				return err
+			}
+		}
+		group = group[:0]
+		return nil
+	})
+	if nil != err {
+		fmt.Println("Apply redo log error ", err)
+		return false
+	}
+
+	written, ok := writeRecoveredPages(f, pr, options.dryRun)
+	printRecoverSummary(pr)
+	if 0 != stopLSN {
+		fmt.Printf("The records after lsn %d are lost or not parsed, the replay stopped\r\n", stopLSN)
+	}
+	if 0 != len(group) && groupLSN >= cp.LSN {
+		fmt.Printf("The incomplete mini-transaction at lsn %d is discarded\r\n", groupLSN)
+	}
+	if options.dryRun {
+		fmt.Println("Dry run, the pages are not written")
+	} else {
+		fmt.Printf("%d page(s) written to %s\r\n", written, options.applyTo)
+	}
+	return ok
+}
+
+// writeRecoveredPages writes the applied pages with the new checksums, and
+// verifies them after written
+func writeRecoveredPages(f *os.File, pr *pageRecovery, dryRun bool) (int, bool) {
+	nos := make([]int, 0, len(pr.pages))
+	for no := range pr.pages {
+		nos = append(nos, no)
+	}
+	sort.Ints(nos)
+
+	fmt.Printf("%-10s%-18s%-18s%-10s%s\r\n", "page", "lsn before", "lsn after", "applied", "status")
+	written := 0
+	ok := true
+	size := int64(pr.ts.PageSize())
+	for _, no := range nos {
+		p := pr.pages[no]
+		if 0 == p.applied && nil == p.stopped {
+			continue
+		}
+		status := "OK"
+		if nil != p.stopped {
+			status = fmt.Sprintf("stopped at lsn %d %s", p.stopped.LSN,
+				innodb.RedoTypeToString(p.stopped.Type, pr.format))
+			if nil != p.err {
+				status += fmt.Sprintf(" (%v)", p.err)
+			}
+		}
+		fmt.Printf("%-10d%-18d%-18d%-10d%s\r\n", no, p.lsnBefore, innodb.PageLSN(p.data), p.applied, status)
+		if 0 == p.applied || dryRun {
+			continue
+		}
+
+		if err := innodb.WritePageChecksum(p.data, p.algo); nil != err {
+			fmt.Println(err)
+			ok = false
+			continue
+		}
+		if _, err := f.WriteAt(p.data, int64(no)*size); nil != err {
+			fmt.Printf("Write page %d error %v\r\n", no, err)
+			ok = false
+			continue
+		}
+		if innodb.VerifyPageChecksum(p.data, pr.fullCrc32).Corrupt() {
+			fmt.Printf("Verify page %d checksum failed\r\n", no)
+			ok = false
+		}
+		written++
+	}
+	if !dryRun {
+		if err := f.Sync(); nil != err {
+			fmt.Println("Sync file error ", err)
+			ok = false
+		}
+	}
+	return written, ok
+}
+
+func printRecoverSummary(pr *pageRecovery) {
+	printTypeCounts := func(title string, counts map[int]int) {
+		if 0 == len(counts) {
+			return
+		}
+		types := make([]int, 0, len(counts))
+		for typ := range counts {
+			types = append(types, typ)
+		}
+		sort.Ints(types)
+		fmt.Printf("\r\n%s\r\n", title)
+		for _, typ := range types {
+			fmt.Printf("    %-36s%d\r\n", innodb.RedoTypeToString(typ, pr.format), counts[typ])
+		}
+	}
+	printTypeCounts("Applied records:", pr.applied)
+	printTypeCounts("Skipped records (not supported, after a skipped record of the page, or beyond the file):", pr.skipped)
+	fmt.Printf("\r\n%d record(s) already on the pages\r\n", pr.onPage)
+}
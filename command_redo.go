@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"spf13/cobra"
+
+	"github.com/juju/errors"
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type redoOptions struct {
+	files          []string
+	dir            string
+	space          int
+	page           int
+	blocks         bool
+	fromCheckpoint bool
+}
+
+func newRedoCommand() *cobra.Command {
+	var options redoOptions
+	c := &cobra.Command{
+		Use:   "redo",
+		Short: "parse redo log files",
+		Long:  "Parse the file header, checkpoints, log blocks and mini-transaction records of the ib_logfile* or #ib_redo* files",
+		Run: func(cmd *cobra.Command, args []string) {
+			doRedo(cmd, &options)
+		},
+	}
+
+	c.Flags().StringSliceVarP(&options.files, "file", "f", nil, "redo log file path, can be specified more than once")
+	c.Flags().StringVarP(&options.dir, "dir", "d", "", "directory of the ib_logfile* files, or the #innodb_redo directory (8.0.30+)")
+	c.Flags().IntVarP(&options.space, "space", "s", -1, "only show the records of the space id")
+	c.Flags().IntVarP(&options.page, "page", "p", -1, "only show the records of the page no")
+	c.Flags().BoolVarP(&options.blocks, "blocks", "b", false, "show the log blocks instead of the records")
+	c.Flags().BoolVar(&options.fromCheckpoint, "from-checkpoint", false, "only show the records since the latest checkpoint")
+
+	return c
+}
+
+// redoFileNameRegexp matches the redo log files, the spare #ib_redo*_tmp files
+// are not matched
+var redoFileNameRegexp = regexp.MustCompile(`^(ib_logfile|#ib_redo)[0-9]+$`)
+
+// findRedoLogFiles returns the redo log files in the directory, the #innodb_redo
+// directory is looked up if the directory is the data directory
+func findRedoLogFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	var files []string
+	for _, fi := range infos {
+		if fi.IsDir() && "#innodb_redo" == fi.Name() {
+			redoFiles, err := findRedoLogFiles(filepath.Join(dir, fi.Name()))
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			files = append(files, redoFiles...)
+			continue
+		}
+		if !fi.IsDir() && redoFileNameRegexp.MatchString(fi.Name()) {
+			files = append(files, filepath.Join(dir, fi.Name()))
+		}
+	}
+	return files, nil
+}
+
+func doRedo(cmd *cobra.Command, options *redoOptions) {
+	paths := options.files
+	if "" != options.dir {
+		dirFiles, err := findRedoLogFiles(options.dir)
+		if nil != err {
+			fmt.Println("Find redo log files error ", err)
+			return
+		}
+		paths = append(paths, dirFiles...)
+	}
+	if 0 == len(paths) {
+		fmt.Println("No redo log file specified")
+		return
+	}
+
+	files := make([]*innodb.RedoLogFile, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if nil != err {
+			fmt.Println("Open file error ", err)
+			return
+		}
+		defer f.Close()
+
+		rf, err := innodb.NewRedoLogFile(f)
+		if nil != err {
+			fmt.Println("Open redo log file ", path, " error ", err)
+			return
+		}
+		printRedoLogFile(path, rf)
+		if options.blocks {
+			fmt.Printf("%-14s%-16s%-20s%-8s%-12s%-14s%-12s%s\r\n",
+				"offset", "lsn", "block no", "data", "first rec", "checkpoint", "checksum", "status")
+			if err = rf.IterateBlocks(printRedoLogBlock); nil != err {
+				fmt.Println("Read redo log blocks error ", err)
+				return
+			}
+		}
+		files = append(files, rf)
+	}
+	if options.blocks {
+		return
+	}
+
+	redo := innodb.NewRedoLog(files)
+	var startLSN uint64
+	if options.fromCheckpoint {
+		cp := redo.Checkpoint()
+		if nil == cp {
+			fmt.Println("No valid checkpoint found")
+			return
+		}
+		startLSN = cp.LSN
+	}
+
+	fmt.Printf("%-16s%-36s%-12s%-12s%s\r\n", "lsn", "type", "space id", "page no", "payload")
+	shown := 0
+	st, err := redo.IterateRecords(func(rec *innodb.RedoRecord) error {
+		if rec.LSN < startLSN {
+			return nil
+		}
+		if options.space >= 0 || options.page >= 0 {
+			if !rec.HasPage() {
+				return nil
+			}
+			if options.space >= 0 && uint32(options.space) != rec.SpaceID {
+				return nil
+			}
+			if options.page >= 0 && uint32(options.page) != rec.PageNo {
+				return nil
+			}
+		}
+		shown++
+		typ := rec.TypeString()
+		if rec.Single {
+			typ += "(single)"
+		}
+		fmt.Printf("%-16d%-36s", rec.LSN, typ)
+		if rec.HasPage() {
+			fmt.Printf("%-12d%-12d", rec.SpaceID, rec.PageNo)
+		} else {
+			fmt.Printf("%-12s%-12s", "-", "-")
+		}
+		fmt.Printf("%s\r\n", rec.Summary())
+		return nil
+	})
+	if nil != err {
+		fmt.Println("Parse redo log error ", err)
+	}
+	fmt.Printf("\r\n%d blocks, %d unwritten, %d stale, %d corrupt, %d encrypted\r\n",
+		st.Blocks, st.Unwritten, st.Stale, st.Corrupt, st.Encrypted)
+	fmt.Printf("%d records in lsn %d-%d, %d not parsed, %d shown\r\n",
+		st.Records, st.StartLSN, st.EndLSN, st.Unparsed, shown)
+}
+
+func checksumStatus(valid bool) string {
+	if valid {
+		return "OK"
+	}
+	return "BAD"
+}
+
+func printRedoLogFile(path string, rf *innodb.RedoLogFile) {
+	fmt.Printf("\t\t\t==========FILE %s==========\r\n", path)
+	fmt.Printf("%-16s%d (%s)\r\n", "format", rf.Header.Format, innodb.RedoFormatToString(rf.Header.Format))
+	fmt.Printf("%-16s%s\r\n", "creator", rf.Header.Creator)
+	fmt.Printf("%-16s%d\r\n", "start lsn", rf.Header.StartLSN)
+	fmt.Printf("%-16s%d\r\n", "size", rf.Size())
+	fmt.Printf("%-16s0x%08X %s\r\n", "checksum", rf.Header.Checksum, checksumStatus(rf.Header.ChecksumValid))
+	for i, cp := range rf.Checkpoints {
+		fmt.Printf("%-16sno %d lsn %d offset 0x%X checksum 0x%08X %s\r\n",
+			fmt.Sprintf("checkpoint %d", i+1), cp.No, cp.LSN, cp.Offset, cp.Checksum, checksumStatus(cp.ChecksumValid))
+	}
+	fmt.Printf("\r\n")
+}
+
+func printRedoLogBlock(b *innodb.RedoLogBlock) error {
+	status := "OK"
+	if b.Unwritten() {
+		status = "unwritten"
+	} else if !b.ChecksumValid {
+		status = "corrupt"
+	} else if b.Stale() {
+		status = "stale"
+	} else if b.Encrypted {
+		status = "encrypted"
+	}
+	no := fmt.Sprintf("%d", b.No)
+	if b.Flush {
+		no += "(flush)"
+	}
+	fmt.Printf("0x%-12.08X%-16d%-20s%-8d%-12d%-14d0x%-10.08X%s\r\n",
+		b.Offset, b.LSN, no, b.DataLen, b.FirstRecGroup, b.CheckpointNo, b.Checksum, status)
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"spf13/cobra"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type scanOptions struct {
+	file     string
+	from     string
+	to       string
+	limit    int
+	reverse  bool
+	pksize   int
+	schema   string
+	columns  string
+	pk       string
+	pageSize int
+}
+
+func newScanCommand() *cobra.Command {
+	var options scanOptions
+	c := &cobra.Command{
+		Use:   "scan",
+		Short: "scan the primary key range",
+		Long:  "Scan the records of the clustered index in the primary key range [from, to] through the leaf page list",
+		Run: func(cmd *cobra.Command, args []string) {
+			doScan(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().StringVar(&options.from, "from", "", "lower bound of the primary key (inclusive), scan from the first record if not specified")
+	c.Flags().StringVar(&options.to, "to", "", "upper bound of the primary key (inclusive), scan to the last record if not specified")
+	c.Flags().IntVarP(&options.limit, "limit", "l", 0, "max records to show, 0 means no limit")
+	c.Flags().BoolVarP(&options.reverse, "reverse", "r", false, "scan from the upper bound to the lower bound")
+	c.Flags().IntVarP(&options.pksize, "pksize", "k", 8, "primary key size (BIGINT=8,INT=4,SINT=2,TINT=1)")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file, read from the SDI if not specified, overrides --pksize")
+	c.Flags().StringVarP(&options.columns, "columns", "c", "", "column definitions to decode the records, overrides --pksize")
+	c.Flags().StringVar(&options.pk, "pk", "", "comma separated primary key columns of --columns")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+func doScan(cmd *cobra.Command, options *scanOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+	if options.pksize != 8 &&
+		options.pksize != 4 &&
+		options.pksize != 2 &&
+		options.pksize != 1 {
+		fmt.Println("invalid pksize")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	table, err := ts.LoadTable(options.schema, options.columns, options.pk)
+	if nil != err {
+		if "" != options.schema || "" != options.columns {
+			fmt.Println("Load table schema error ", err)
+			return
+		}
+		fmt.Println("Read SDI error ", err)
+	}
+	unsigned := false
+	if nil != table {
+		fields := table.ClusteredIndex().RecordFields(true)
+		if !fields[0].IsInteger() && fields[0].Type != innodb.ColumnTypeRowID {
+			fmt.Printf("Scan on %s column %s is not supported\r\n",
+				fields[0].TypeString(), fields[0].Name)
+			return
+		}
+		unsigned = fields[0].Unsigned
+	}
+	from, to, err := parseScanRange(options.from, options.to, unsigned)
+	if nil != err {
+		fmt.Println(err)
+		return
+	}
+
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: true,
+		PKSize:       options.pksize,
+		Table:        table,
+	}
+	rootNo, err := ts.FirstRootPageNo()
+	if nil != err {
+		fmt.Println("Find root index page error ", err)
+		return
+	}
+	bound := from
+	if options.reverse {
+		bound = to
+	}
+	page, err := ts.LeafPageByKey(rootNo, bound, parseOptions)
+	if nil != err {
+		fmt.Println("Find leaf page error ", err)
+		return
+	}
+
+	if nil != table {
+		fmt.Printf("%-10s%-10s%-8s%-10s%s\r\n", "page", "offset", "heap", "deleted", "fields")
+	} else {
+		fmt.Printf("%-10s%-10s%-8s%-10s%s\r\n", "page", "offset", "heap", "deleted", "key")
+	}
+	records := 0
+	pages := 0
+	for {
+		pages++
+		rcs := page.UserRecorders()
+		if options.reverse {
+			for i, j := 0, len(rcs)-1; i < j; i, j = i+1, j-1 {
+				rcs[i], rcs[j] = rcs[j], rcs[i]
+			}
+		}
+		for _, rc := range rcs {
+			below, above := rc.CompareKey(from) < 0, rc.CompareKey(to) > 0
+			if below || above {
+				// Out of range at the end of the scan direction
+				if (options.reverse && below) || (!options.reverse && above) {
+					printScanSummary(records, pages)
+					return
+				}
+				continue
+			}
+			if nil != table {
+				printRecord(page, rc)
+			} else {
+				deleted := "N"
+				if rc.Header.DeleteFlag {
+					deleted = "Y"
+				}
+				fmt.Printf("%-10d0x%-8.04X%-8d%-10s%d\r\n",
+					page.No(), rc.FieldDataOffset, rc.Header.HeapNo, deleted, rc.Key)
+			}
+			records++
+			if options.limit > 0 && records >= options.limit {
+				printScanSummary(records, pages)
+				return
+			}
+		}
+
+		next := page.FileHeader.Next
+		if options.reverse {
+			next = page.FileHeader.Prev
+		}
+		if 0xffffffff == next {
+			break
+		}
+		if page, err = ts.ReadPage(int(next), parseOptions); nil != err {
+			fmt.Printf("Read page %d error %v\r\n", next, err)
+			return
+		}
+	}
+	printScanSummary(records, pages)
+}
+
+// parseScanRange parses the bounds with the signedness of the key column, the
+// unsigned bound is the bits of the uint64 value like the record key
+func parseScanRange(from string, to string, unsigned bool) (int64, int64, error) {
+	lower, upper := int64(math.MinInt64), int64(math.MaxInt64)
+	if unsigned {
+		lower, upper = 0, -1
+	}
+	parse := func(s string, v *int64) error {
+		if "" == s {
+			return nil
+		}
+		if unsigned {
+			u, err := strconv.ParseUint(s, 10, 64)
+			if nil != err {
+				return errors.Errorf("Invalid unsigned key %s", s)
+			}
+			*v = int64(u)
+			return nil
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		if nil != err {
+			return errors.Errorf("Invalid key %s", s)
+		}
+		*v = i
+		return nil
+	}
+	if err := parse(from, &lower); nil != err {
+		return 0, 0, err
+	}
+	if err := parse(to, &upper); nil != err {
+		return 0, 0, err
+	}
+	if innodb.CompareIntegerKey(lower, upper, unsigned) > 0 {
+		return 0, 0, errors.New("Invalid range, from is greater than to")
+	}
+	return lower, upper, nil
+}
+
+func printScanSummary(records int, pages int) {
+	fmt.Printf("\r\n%d record(s) in %d page(s)\r\n", records, pages)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type sdiOptions struct {
+	file     string
+	typ      int
+	pageSize int
+}
+
+func newSDICommand() *cobra.Command {
+	var options sdiOptions
+	c := &cobra.Command{
+		Use:   "sdi",
+		Short: "dump the serialized dictionary information",
+		Long:  "Dump the table and table space json of the mysql 8.0 SDI, the output is the same as ibd2sdi",
+		Run: func(cmd *cobra.Command, args []string) {
+			doSDI(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().IntVarP(&options.typ, "type", "t", 0, "only dump the specified SDI type (1=table, 2=table space)")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+// sdiOutput is the ibd2sdi output format of a SDI record
+type sdiOutput struct {
+	Type   uint32          `json:"type"`
+	ID     uint64          `json:"id"`
+	Object json.RawMessage `json:"object"`
+}
+
+func doSDI(cmd *cobra.Command, options *sdiOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	records, err := ts.SDIRecords()
+	if nil != err {
+		fmt.Println("Read SDI error ", err)
+		return
+	}
+
+	outputs := make([]interface{}, 0, len(records)+1)
+	outputs = append(outputs, "ibd2sdi")
+	for _, record := range records {
+		if 0 != options.typ && uint32(options.typ) != record.Type {
+			continue
+		}
+		if !json.Valid(record.Data) {
+			fmt.Printf("Invalid json of SDI %s id %d\r\n", innodb.SDITypeToString(record.Type), record.ID)
+			return
+		}
+		outputs = append(outputs, &sdiOutput{
+			Type:   record.Type,
+			ID:     record.ID,
+			Object: record.Data,
+		})
+	}
+	data, err := json.MarshalIndent(outputs, "", "\t")
+	if nil != err {
+		fmt.Println("Format SDI error ", err)
+		return
+	}
+	fmt.Println(string(data))
+}
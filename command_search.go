@@ -4,36 +4,45 @@ import (
 	"fmt"
 	"os"
 	"spf13/cobra"
+	"strconv"
+	"strings"
 	"time"
-)
 
-type searchStatistic struct {
-	pageSearched      int
-	indexPageSearched int
-	searchTimes       int
-	startTm           int64
-}
+	"github.com/sryanyuan/innoisp/innodb"
+)
 
 type searchOptions struct {
-	file   string
-	key    int
-	pksize int
+	file     string
+	key      string
+	intKey   int
+	pksize   int
+	schema   string
+	index    string
+	follow   bool
+	limit    int
+	pageSize int
+	table    *innodb.Table
 }
 
 func newSearchCommand() *cobra.Command {
 	var options searchOptions
 	c := &cobra.Command{
 		Use:   "search",
-		Short: "search the primary key in innodb ibd file",
-		Long:  "search the primary key in innodb ibd file",
+		Short: "search the key in innodb ibd file",
+		Long:  "Search the key in the clustered index or the secondary index of innodb ibd file, the key is compared with the column types with the table schema",
 		Run: func(cmd *cobra.Command, args []string) {
 			doSearch(cmd, &options)
 		},
 	}
 
 	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
-	c.Flags().IntVarP(&options.key, "key", "k", -1, "which key to search")
+	c.Flags().StringVarP(&options.key, "key", "k", "", "which key to search, comma separated values of the leading index columns, e.g. 'tom,\"1,2\"'")
 	c.Flags().IntVarP(&options.pksize, "pksize", "p", 8, "primary key size (BIGINT=8,INT=4,SINT=2,TINT=1)")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file, read from the SDI if not specified, overrides --pksize")
+	c.Flags().StringVarP(&options.index, "index", "i", "", "index name or index id to search, the clustered index if not specified, requires the table schema")
+	c.Flags().BoolVar(&options.follow, "follow", false, "read the clustered index records by the primary key of the secondary index records")
+	c.Flags().IntVarP(&options.limit, "limit", "l", 0, "max records to show, 0 means no limit")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
 
 	return c
 }
@@ -43,7 +52,7 @@ func doSearch(cmd *cobra.Command, options *searchOptions) {
 		fmt.Println("No input file specified")
 		return
 	}
-	if options.key < 0 {
+	if "" == options.key {
 		fmt.Println("Invalid search key")
 		return
 	}
@@ -59,66 +68,104 @@ func doSearch(cmd *cobra.Command, options *searchOptions) {
 	}
 	defer f.Close()
 
-	searchKey(f, options)
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	table, err := ts.LoadTable(options.schema, "", "")
+	if nil != err {
+		if "" != options.schema {
+			fmt.Println("Load table schema error ", err)
+			return
+		}
+		fmt.Println("Read SDI error ", err)
+	}
+	if nil != table {
+		options.table = table
+		searchIndexKey(ts, options)
+		return
+	}
+
+	// Without the table schema, the key is compared with the primary key
+	// of pksize
+	if "" != options.index {
+		fmt.Println("No table schema specified and no SDI found to search the index")
+		return
+	}
+	if options.intKey, err = strconv.Atoi(options.key); nil != err || options.intKey < 0 {
+		fmt.Println("Invalid search key")
+		return
+	}
+	searchKey(ts, options)
 }
 
-func searchKey(f *os.File, options *searchOptions) {
+func searchKey(ts *innodb.Tablespace, options *searchOptions) {
 	// Get the file size
-	fst, err := f.Stat()
+	pageCount, err := ts.PageCount()
 	if nil != err {
 		fmt.Printf("Get file info error %v\r\n", err)
 		return
 	}
-	pageCount := int(fst.Size()) / (16 * 1024)
 	if pageCount < 4 {
 		// No index page
 		fmt.Printf("No index page found\r\n")
 		return
 	}
-	fmt.Printf("File %s has %d page(s)\r\n", f.Name(), pageCount)
-	fmt.Println("Searching for file segment inode page ...")
+	var doc *searchDoc
+	if isJSONOutput() {
+		doc = &searchDoc{
+			Pages:   pageCount,
+			Trace:   make([]*searchTraceDoc, 0, 4),
+			Records: make([]*searchRecordDoc, 0, 1),
+		}
+	} else {
+		fmt.Printf("File %s has %d page(s)\r\n", options.file, pageCount)
+		fmt.Println("Searching for file segment inode page ...")
+	}
 
-	inodePage, err := readPageFromFile(f, 2, &parsePageOptions{})
+	inodePage, err := ts.ReadPage(2, &innodb.ParsePageOptions{})
 	if nil != err {
 		fmt.Printf("Read file segment inode page data error %v\r\n", err)
 		return
 	}
-	fmt.Printf("File segment inode page found at index %d\r\n", inodePage.no)
-	// Locate to root index page from inode page
-	// every index occupy one inode as internal (non-leaf) node
-	fmt.Println("Searching for root index page ...")
-	rootIndexInode := inodePage.inode.inodes[0]
-	if nil == rootIndexInode {
-		fmt.Println("Can't find root index inode")
-		return
+	if nil == doc {
+		fmt.Printf("File segment inode page found at index %d\r\n", inodePage.No())
+		// Locate to root index page from inode page
+		// every index occupy one inode as internal (non-leaf) node
+		fmt.Println("Searching for root index page ...")
 	}
-	// Check used
-	if rootIndexInode.magicNumber != inodeEntryMagicNumber {
-		fmt.Println("Inode not initialized")
-		return
-	}
-	if 0xffffffff == rootIndexInode.fragmentArrayEntry[0] {
-		fmt.Println("Index root page not allocated")
+	rootIndexInode, err := ts.FirstIndexInode(inodePage)
+	if nil != err {
+		fmt.Println(err)
 		return
 	}
-	if int(rootIndexInode.fragmentArrayEntry[0]) >= pageCount {
+	if int(rootIndexInode.FragmentArrayEntry[0]) >= pageCount {
 		fmt.Println("Root index page index out of range")
 		return
 	}
 	inodeUsedCnt := 0
-	for _, v := range rootIndexInode.fragmentArrayEntry {
+	for _, v := range rootIndexInode.FragmentArrayEntry {
 		if v != 0xffffffff {
 			inodeUsedCnt++
 		}
 	}
-	fmt.Printf("Root index page found at index %d, %d inode used\r\n",
-		int(rootIndexInode.fragmentArrayEntry[0]), inodeUsedCnt)
-	// Load the root index page
-	fmt.Printf("Loading root index page at index %d\r\n",
-		rootIndexInode.fragmentArrayEntry[0])
-	rootIndexPage, err := readPageFromFile(f, int(rootIndexInode.fragmentArrayEntry[0]), &parsePageOptions{
-		parseRecords: true,
-		pksize:       options.pksize,
+	if nil != doc {
+		doc.InodePage = inodePage.No()
+		doc.InodesUsed = inodeUsedCnt
+		doc.Root = int(rootIndexInode.FragmentArrayEntry[0])
+	} else {
+		fmt.Printf("Root index page found at index %d, %d inode used\r\n",
+			int(rootIndexInode.FragmentArrayEntry[0]), inodeUsedCnt)
+		// Load the root index page
+		fmt.Printf("Loading root index page at index %d\r\n",
+			rootIndexInode.FragmentArrayEntry[0])
+	}
+	rootIndexPage, err := ts.ReadPage(int(rootIndexInode.FragmentArrayEntry[0]), &innodb.ParsePageOptions{
+		ParseRecords: true,
+		PKSize:       options.pksize,
+		Table:        options.table,
 	})
 	if nil != err {
 		fmt.Printf("Read root index page from file error %v\r\n", err)
@@ -126,136 +173,149 @@ func searchKey(f *os.File, options *searchOptions) {
 	}
 
 	// Search for indexes
-	var searchSt searchStatistic
-	searchSt.startTm = time.Now().UnixNano() / 1e6
-	searchIndexes(f, rootIndexPage, options, &searchSt)
+	var searchSt innodb.SearchStatistic
+	searchIndexes(ts, rootIndexPage, options, &searchSt, time.Now().UnixNano()/1e6, doc)
 }
 
-func searchIndexes(f *os.File, page *Page, options *searchOptions, st *searchStatistic) {
-	fmt.Printf("Search directory slots of page %d level %d, directory slots count %d\r\n",
-		page.no, page.pheader.level, len(page.dslots))
-	st.pageSearched++
-	if page.pheader.level != 0 {
-		st.indexPageSearched++
+// searchIndexes searches the integer key from the page to the leaf page, the
+// result is written to the json document if doc is not nil
+func searchIndexes(ts *innodb.Tablespace, page *innodb.Page, options *searchOptions, st *innodb.SearchStatistic,
+	startTm int64, doc *searchDoc) {
+	if nil != doc {
+		doc.Trace = append(doc.Trace, newSearchTraceDoc(page))
+	} else {
+		fmt.Printf("Search directory slots of page %d level %d, directory slots count %d\r\n",
+			page.No(), page.IndexHeader.Level, len(page.DSlots))
+	}
+	st.PageSearched++
+	if page.IndexHeader.Level != 0 {
+		st.IndexPageSearched++
 	}
 	// Search page slots, the first is infimum (min than all records),
 	// and the last if supremum (max than all records)
 	// Get the value array to do binary search
 	// non-leaf page, including root index page and internal page
 	// Just search with range
-	var slot *DSlots
-	if 2 == len(page.dslots) {
+	var slot *innodb.DSlots
+	if 2 == len(page.DSlots) {
 		// Just read the supremum slot own records
-		slot = page.dslots[1]
+		slot = page.DSlots[1]
 	} else {
 		// Search the slots to find the right slot (binary search)
-		slot = searchDslots(page.dslots[:], options.key, st)
+		slot = searchDslots(page.DSlots[:], options.intKey, st)
 	}
-	if 1 == slot.owned && slot.rctype == recorderTypeSupremum {
+	if 1 == slot.Owned && slot.RecorderType == innodb.RecorderTypeSupremum {
 		// Supremum slot not own any record except it self, so no record found
-		fmt.Printf("Record not found\r\n")
+		printSearchNotFound(doc, st, startTm)
 		return
 	}
 	// Search key in the slot owned recorders
-	var rc *compactRecorder
-	if page.pheader.level == 0 {
+	var rc *innodb.CompactRecorder
+	if page.IndexHeader.Level == 0 {
 		// Leaf node, the recorder is the row data
-		rc = searchSlotEqual(slot, options.key, st)
+		rc = searchSlotEqual(slot, options.intKey, st)
 	} else {
 		// Non-leaf node, find the next page
-		rc = searchSlotRange(slot, options.key, st)
+		rc = searchSlotRange(slot, options.intKey, st)
 	}
 	if nil == rc {
 		// Not found in nonleaf index page
-		fmt.Printf("Record not found\r\n")
+		printSearchNotFound(doc, st, startTm)
 		return
 	}
 	// Read the pointer page and search again
-	if page.pheader.level == 0 {
+	if page.IndexHeader.Level == 0 {
 		// We already found the recorder
+		if nil != doc {
+			doc.Records = append(doc.Records, newSearchRecordDoc(page, rc))
+			doc.Statistics = newSearchStatisticDoc(1, st, startTm)
+			printJSON(doc)
+			return
+		}
 		fmt.Printf("Recorder found, page <%d> header offset <0x%04X> data offset<0x%04X>\r\n",
-			page.no, rc.offset, rc.fieldDataOffset)
+			page.No(), rc.Offset, rc.FieldDataOffset)
 		fmt.Printf("Statistics: Page searched <%d> index page searched <%d> search times <%d> cost <%d ms>\r\n",
-			st.pageSearched, st.indexPageSearched, st.searchTimes, time.Now().UnixNano()/1e6-st.startTm)
+			st.PageSearched, st.IndexPageSearched, st.SearchTimes, time.Now().UnixNano()/1e6-startTm)
 	} else {
 		// We should find the recorder in the next page
-		nextPage, err := readPageFromFile(f, int(rc.pageptr), &parsePageOptions{
-			parseRecords: true,
-			pksize:       options.pksize,
+		nextPage, err := ts.ReadPage(int(rc.PagePtr), &innodb.ParsePageOptions{
+			ParseRecords: true,
+			PKSize:       options.pksize,
+			Table:        options.table,
 		})
 		if nil != err {
 			fmt.Printf("Read next page from file error %v\r\n", err)
 			return
 		}
-		searchIndexes(f, nextPage, options, st)
+		searchIndexes(ts, nextPage, options, st, startTm, doc)
 	}
 }
 
 // In range: [,)
-func recordInRangeLeftClosedRightOpen(key int, lrc *compactRecorder, rrc *compactRecorder) bool {
+func recordInRangeLeftClosedRightOpen(key int, lrc *innodb.CompactRecorder, rrc *innodb.CompactRecorder) bool {
 	k64 := int64(key)
 
-	if lrc.header.recordType != recorderTypeInfimum {
+	if lrc.Header.RecordType != innodb.RecorderTypeInfimum {
 		// Infimum system recorder is less than all recorder
-		if k64 < lrc.key {
+		if k64 < lrc.Key {
 			return false
 		}
 	}
-	if rrc.header.recordType == recorderTypeSupremum {
+	if rrc.Header.RecordType == innodb.RecorderTypeSupremum {
 		return true
 	}
-	if k64 < rrc.key {
+	if k64 < rrc.Key {
 		return true
 	}
 	return false
 }
 
 // In range: (,]
-func recordInRangeLeftOpenRightClosed(key int, lrc *compactRecorder, rrc *compactRecorder) bool {
+func recordInRangeLeftOpenRightClosed(key int, lrc *innodb.CompactRecorder, rrc *innodb.CompactRecorder) bool {
 	k64 := int64(key)
 
-	if lrc.header.recordType != recorderTypeInfimum {
+	if lrc.Header.RecordType != innodb.RecorderTypeInfimum {
 		// Infimum system recorder is less than all recorder
-		if k64 <= lrc.key {
+		if k64 <= lrc.Key {
 			return false
 		}
 	}
-	if rrc.header.recordType == recorderTypeSupremum {
+	if rrc.Header.RecordType == innodb.RecorderTypeSupremum {
 		return true
 	}
-	if k64 <= rrc.key {
+	if k64 <= rrc.Key {
 		return true
 	}
 	return false
 }
 
 // In slot referenced node pointer recorders, the range is left closed and right open
-func searchSlotRange(slot *DSlots, key int, st *searchStatistic) *compactRecorder {
-	rc := slot.rcbptr
+func searchSlotRange(slot *innodb.DSlots, key int, st *innodb.SearchStatistic) *innodb.CompactRecorder {
+	rc := slot.BeginRecorder
 	for {
-		st.searchTimes++
-		if nil == rc || nil == rc.next {
+		st.SearchTimes++
+		if nil == rc || nil == rc.Next {
 			break
 		}
-		if recordInRangeLeftClosedRightOpen(key, rc, rc.next) {
+		if recordInRangeLeftClosedRightOpen(key, rc, rc.Next) {
 			return rc
 		}
-		rc = rc.next
+		rc = rc.Next
 	}
 	return nil
 }
 
-func searchSlotEqual(slot *DSlots, key int, st *searchStatistic) *compactRecorder {
-	rc := slot.rcbptr
+func searchSlotEqual(slot *innodb.DSlots, key int, st *innodb.SearchStatistic) *innodb.CompactRecorder {
+	rc := slot.BeginRecorder
 	for {
-		st.searchTimes++
+		st.SearchTimes++
 		if nil == rc {
 			break
 		}
-		if rc.key == int64(key) {
+		if rc.Key == int64(key) {
 			return rc
 		}
-		rc = rc.next
+		rc = rc.Next
 	}
 	return nil
 }
@@ -266,23 +326,23 @@ const (
 	keyGreater
 )
 
-func dslotCompare(ls *DSlots, rs *DSlots, key int) int {
+func dslotCompare(ls *innodb.DSlots, rs *innodb.DSlots, key int) int {
 	k64 := int64(key)
 
-	if ls.rctype != recorderTypeInfimum {
-		if k64 <= ls.rceptr.key {
+	if ls.RecorderType != innodb.RecorderTypeInfimum {
+		if k64 <= ls.EndRecorder.Key {
 			return keyLessEqual
 		}
 	}
-	if rs.rctype != recorderTypeSupremum {
-		if k64 > rs.rceptr.key {
+	if rs.RecorderType != innodb.RecorderTypeSupremum {
+		if k64 > rs.EndRecorder.Key {
 			return keyGreater
 		}
 	}
 	return keyInRange
 }
 
-func searchDslots(dslots []*DSlots, key int, st *searchStatistic) *DSlots {
+func searchDslots(dslots []*innodb.DSlots, key int, st *innodb.SearchStatistic) *innodb.DSlots {
 	if len(dslots) == 2 {
 		// Infimum and supremum, only supremum system recorder can hold
 		// recorders
@@ -291,7 +351,7 @@ func searchDslots(dslots []*DSlots, key int, st *searchStatistic) *DSlots {
 	starti := 0
 	endi := len(dslots) - 1
 	for {
-		st.searchTimes++
+		st.SearchTimes++
 		midi := (starti + endi + 1) / 2
 		mslot := dslots[midi]
 		// Is middle slot in range
@@ -309,3 +369,274 @@ func searchDslots(dslots []*DSlots, key int, st *searchStatistic) *DSlots {
 		}
 	}
 }
+
+// findSearchIndex finds the index by the index name or the index id
+func findSearchIndex(table *innodb.Table, name string) *innodb.Index {
+	if index := table.FindIndexByName(name); nil != index {
+		return index
+	}
+	id, err := strconv.ParseUint(name, 10, 64)
+	if nil != err {
+		return nil
+	}
+	for _, index := range table.Indexes {
+		if index.ID == id {
+			return index
+		}
+	}
+	return nil
+}
+
+// searchIndexKey searches the typed key in the index with the table schema, all
+// records with the leading fields equal to the key are shown
+func searchIndexKey(ts *innodb.Tablespace, options *searchOptions) {
+	table := options.table
+	index := table.ClusteredIndex()
+	if "" != options.index {
+		if index = findSearchIndex(table, options.index); nil == index {
+			fmt.Printf("Index %s not found\r\n", options.index)
+			return
+		}
+	}
+	if index.Fulltext || index.Spatial {
+		fmt.Printf("Search on index %s is not supported\r\n", index.Name)
+		return
+	}
+	if 0 == index.ID {
+		fmt.Printf("Index id of %s is unknown\r\n", index.Name)
+		return
+	}
+	key, err := innodb.ParseSearchKey(index, options.key)
+	if nil != err {
+		fmt.Println("Invalid search key ", err)
+		return
+	}
+
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: true,
+		Table:        table,
+	}
+	rootNo, err := ts.IndexRootPageNo(index.ID)
+	if nil != err {
+		fmt.Println("Find root index page error ", err)
+		return
+	}
+	names := make([]string, 0, len(key))
+	for _, c := range innodb.IndexKeyFields(index)[:len(key)] {
+		names = append(names, c.Name)
+	}
+	var doc *searchDoc
+	if isJSONOutput() {
+		doc = &searchDoc{
+			Index:   index.Name,
+			Fields:  names,
+			Root:    rootNo,
+			Trace:   make([]*searchTraceDoc, 0, 4),
+			Records: make([]*searchRecordDoc, 0, 4),
+		}
+	} else {
+		fmt.Printf("Searching index %s (%s) from root page %d\r\n",
+			index.Name, strings.Join(names, ","), rootNo)
+	}
+
+	var st innodb.SearchStatistic
+	startTm := time.Now().UnixNano() / 1e6
+	page, rc, err := ts.SearchIndex(rootNo, index, key, parseOptions, &st, func(page *innodb.Page) {
+		if nil != doc {
+			doc.Trace = append(doc.Trace, newSearchTraceDoc(page))
+			return
+		}
+		fmt.Printf("Search directory slots of page %d level %d, directory slots count %d\r\n",
+			page.No(), page.IndexHeader.Level, len(page.DSlots))
+	})
+	if nil != err {
+		fmt.Println("Search index error ", err)
+		return
+	}
+	clusteredRootNo := -1
+	found := 0
+	for {
+		if page, rc, err = ts.NextIndexRecord(page, rc, parseOptions, &st); nil != err {
+			fmt.Println("Read next record error ", err)
+			return
+		}
+		if nil == rc {
+			break
+		}
+		st.SearchTimes++
+		cmp, err := innodb.CompareRecordKey(rc.Fields, key)
+		if nil != err {
+			fmt.Println("Compare record error ", err)
+			return
+		}
+		if 0 != cmp {
+			break
+		}
+		found++
+		var rd *searchRecordDoc
+		if nil != doc {
+			rd = newSearchRecordDoc(page, rc)
+			doc.Records = append(doc.Records, rd)
+		} else {
+			fmt.Printf("Recorder found, page <%d> header offset <0x%04X> data offset <0x%04X> deleted <%v>\r\n",
+				page.No(), rc.Offset, rc.FieldDataOffset, rc.Header.DeleteFlag)
+			fmt.Printf("    %s\r\n", formatRecordFields(rc.Fields))
+		}
+		if !index.IsClustered() {
+			pk := innodb.PrimaryKeyFields(index, rc.Fields)
+			if nil != rd {
+				rd.PrimaryKey = newRecordFieldDocs(pk)
+			} else {
+				fmt.Printf("    primary key: %s\r\n", formatRecordFields(pk))
+			}
+			if options.follow {
+				if clusteredRootNo < 0 {
+					if clusteredRootNo, err = ts.IndexRootPageNo(table.ClusteredIndex().ID); nil != err {
+						fmt.Println("Find clustered index root page error ", err)
+						return
+					}
+				}
+				followPrimaryKey(ts, clusteredRootNo, table.ClusteredIndex(), pk, parseOptions, rd)
+			}
+		}
+		if options.limit > 0 && found >= options.limit {
+			break
+		}
+	}
+	if nil != doc {
+		doc.Statistics = newSearchStatisticDoc(found, &st, startTm)
+		printJSON(doc)
+		return
+	}
+	if 0 == found {
+		fmt.Printf("Record not found\r\n")
+	}
+	fmt.Printf("Statistics: Record found <%d> page searched <%d> index page searched <%d> search times <%d> cost <%d ms>\r\n",
+		found, st.PageSearched, st.IndexPageSearched, st.SearchTimes, time.Now().UnixNano()/1e6-startTm)
+}
+
+// followPrimaryKey reads the clustered index record of the primary key, the record
+// is set to the json document of the secondary index record if rd is not nil
+func followPrimaryKey(ts *innodb.Tablespace, rootNo int, index *innodb.Index, pk []*innodb.RecordField,
+	options *innodb.ParsePageOptions, rd *searchRecordDoc) {
+	key := make([]*innodb.KeyValue, 0, len(pk))
+	for _, field := range pk {
+		v, err := innodb.DecodeKeyValue(field)
+		if nil != err {
+			fmt.Println("    decode primary key error ", err)
+			return
+		}
+		key = append(key, v)
+	}
+	var st innodb.SearchStatistic
+	page, rc, err := ts.SearchIndex(rootNo, index, key, options, &st, nil)
+	if nil == err {
+		page, rc, err = ts.NextIndexRecord(page, rc, options, &st)
+	}
+	if nil != err {
+		fmt.Println("    search clustered index error ", err)
+		return
+	}
+	if nil != rc {
+		if cmp, err := innodb.CompareRecordKey(rc.Fields, key); nil == err && 0 == cmp {
+			if nil != rd {
+				rd.Clustered = newSearchRecordDoc(page, rc)
+				return
+			}
+			fmt.Printf("    clustered index record, page <%d> data offset <0x%04X> deleted <%v>\r\n",
+				page.No(), rc.FieldDataOffset, rc.Header.DeleteFlag)
+			fmt.Printf("    %s\r\n", formatRecordFields(rc.Fields))
+			return
+		}
+	}
+	if nil == rd {
+		fmt.Printf("    clustered index record not found\r\n")
+	}
+}
+
+// searchDoc is the json document of the search, the trace is the pages searched
+// from the root page. The page count and the inode are of the search without
+// the table schema
+type searchDoc struct {
+	Pages      int                 `json:"pages,omitempty"`
+	InodePage  int                 `json:"inode_page,omitempty"`
+	InodesUsed int                 `json:"inodes_used,omitempty"`
+	Index      string              `json:"index,omitempty"`
+	Fields     []string            `json:"fields,omitempty"`
+	Root       int                 `json:"root"`
+	Trace      []*searchTraceDoc   `json:"trace"`
+	Records    []*searchRecordDoc  `json:"records"`
+	Statistics *searchStatisticDoc `json:"statistics"`
+}
+
+type searchTraceDoc struct {
+	Page           int    `json:"page"`
+	Level          uint16 `json:"level"`
+	DirectorySlots int    `json:"directory_slots"`
+}
+
+func newSearchTraceDoc(page *innodb.Page) *searchTraceDoc {
+	return &searchTraceDoc{
+		Page:           page.No(),
+		Level:          page.IndexHeader.Level,
+		DirectorySlots: len(page.DSlots),
+	}
+}
+
+// searchRecordDoc is the record found, the fields are decoded with the table
+// schema, otherwise only the integer key is known
+type searchRecordDoc struct {
+	Page       int               `json:"page"`
+	Offset     uint16            `json:"offset"`
+	DataOffset uint16            `json:"data_offset"`
+	Deleted    bool              `json:"deleted"`
+	Key        *int64            `json:"key,omitempty"`
+	Fields     []*recordFieldDoc `json:"fields,omitempty"`
+	PrimaryKey []*recordFieldDoc `json:"primary_key,omitempty"`
+	Clustered  *searchRecordDoc  `json:"clustered,omitempty"`
+}
+
+func newSearchRecordDoc(page *innodb.Page, rc *innodb.CompactRecorder) *searchRecordDoc {
+	doc := &searchRecordDoc{
+		Page:       page.No(),
+		Offset:     rc.Offset,
+		DataOffset: rc.FieldDataOffset,
+		Deleted:    rc.Header.DeleteFlag,
+	}
+	if nil != rc.Fields {
+		doc.Fields = newRecordFieldDocs(rc.Fields)
+	} else if rc.HasKey {
+		key := rc.Key
+		doc.Key = &key
+	}
+	return doc
+}
+
+type searchStatisticDoc struct {
+	Records           int   `json:"records"`
+	PageSearched      int   `json:"page_searched"`
+	IndexPageSearched int   `json:"index_page_searched"`
+	SearchTimes       int   `json:"search_times"`
+	CostMs            int64 `json:"cost_ms"`
+}
+
+func newSearchStatisticDoc(records int, st *innodb.SearchStatistic, startTm int64) *searchStatisticDoc {
+	return &searchStatisticDoc{
+		Records:           records,
+		PageSearched:      st.PageSearched,
+		IndexPageSearched: st.IndexPageSearched,
+		SearchTimes:       st.SearchTimes,
+		CostMs:            time.Now().UnixNano()/1e6 - startTm,
+	}
+}
+
+// printSearchNotFound shows the record is not found by the search without the
+// table schema
+func printSearchNotFound(doc *searchDoc, st *innodb.SearchStatistic, startTm int64) {
+	if nil == doc {
+		fmt.Printf("Record not found\r\n")
+		return
+	}
+	doc.Statistics = newSearchStatisticDoc(0, st, startTm)
+	printJSON(doc)
+}
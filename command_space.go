@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
 )
 
 type spaceOptions struct {
@@ -13,6 +15,8 @@ type spaceOptions struct {
 	pageState bool
 	unused    bool
 	list      bool
+	pageSize  int
+	jobs      int
 }
 
 func newSpaceCommand() *cobra.Command {
@@ -31,6 +35,8 @@ func newSpaceCommand() *cobra.Command {
 	c.Flags().BoolVarP(&options.pageState, "pstate", "p", false, "show page state")
 	c.Flags().BoolVarP(&options.unused, "unused", "u", false, "show unused extend")
 	c.Flags().BoolVarP(&options.list, "list", "l", false, "show extend list")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
 
 	return c
 }
@@ -48,119 +54,235 @@ func doSpace(cmd *cobra.Command, options *spaceOptions) {
 	}
 	defer f.Close()
 
-	pages, err := parseInnodbDataFile(f, &parsePageOptions{})
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	err = ts.IteratePages(&innodb.ParsePageOptions{
+		ParsePageTypeFlag: innodb.ParsePageFSP | innodb.ParsePageXdes,
+		Jobs:              options.jobs,
+	}, func(page *innodb.Page, data []byte) error {
+		printSpacePage(page, options)
+		return nil
+	})
 	if nil != err {
 		fmt.Println("Parse innodb data file error ", err)
 	}
-	// Show the page information
-	pageCnt := 0
-	for pi, page := range pages {
-		if page.fheader.typ != pageTypeFspHDR &&
-			page.fheader.typ != pageTypeXdes {
+}
+
+// printSpacePage shows the file space header and the extent descriptors of the
+// FSP_HDR or XDES page
+func printSpacePage(page *innodb.Page, options *spaceOptions) {
+	if isJSONOutput() {
+		printJSON(newSpacePageDoc(page, options))
+		return
+	}
+	ps := innodb.PageSize(page.Size())
+	extentPages := ps.ExtentPages()
+
+	fmt.Printf("\t\t\t==========PAGE %d OFFSET 0x%04X==========\r\n",
+		page.No(), page.Offset())
+	// FSP
+	if page.FSPHeader.SpaceID != 0 {
+		// Header
+		fmt.Printf("%-10s", "space id")
+		fmt.Printf("%-11s", "page allo")
+		fmt.Printf("%-11s", "page init")
+		fmt.Printf("%-8s", "flags")
+		fmt.Printf("%-15s", "page used(fg)")
+		fmt.Printf("%-51s", "free_frag list")
+		fmt.Printf("%-51s", "free list")
+		fmt.Printf("%-51s", "full_frag list")
+		fmt.Printf("%-17s", "next segment id")
+		fmt.Printf("%-51s", "full inodes")
+		fmt.Printf("%-51s", "free inodes")
+		fmt.Printf("\r\n")
+		// Columns
+		fmt.Printf("%-10d", page.FSPHeader.SpaceID)
+		fmt.Printf("%-11d", page.FSPHeader.HighestPageNumberInFile)
+		fmt.Printf("%-11d", page.FSPHeader.HighestPageNumberInitialized)
+		fmt.Printf("0x%-6.04X", page.FSPHeader.Flags)
+		fmt.Printf("%-15d", page.FSPHeader.PagesUsedInFreeFrag)
+		fmt.Printf("%-51s", page.FSPHeader.FreeFragList.ToString(8))
+		fmt.Printf("%-51s", page.FSPHeader.FreeList.ToString(8))
+		fmt.Printf("%-51s", page.FSPHeader.FullFragList.ToString(8))
+		fmt.Printf("%-17d", page.FSPHeader.NextUnusedSegmentID)
+		fmt.Printf("%-51s", page.FSPHeader.FullInodesList.ToString(38))
+		fmt.Printf("%-51s", page.FSPHeader.FreeInodesList.ToString(38))
+		fmt.Printf("\r\n\r\n")
+	}
+	// Xdes
+	fmt.Printf("%-13s", "extend")
+	fmt.Printf("%-20s", "page range")
+	fmt.Printf("%-20s", "file segment id")
+	fmt.Printf("%-16s", "state")
+	if options.list {
+		fmt.Printf("%-37s", "list")
+	}
+	if options.pageState {
+		fmt.Printf("page state (F)ree or (N)ot free")
+	}
+	fmt.Printf("\r\n")
+	for xi, des := range page.XDeses {
+		if !showExtent(xi, des, options) {
 			continue
 		}
 
-		fmt.Printf("\t\t\t==========PAGE %d OFFSET 0x%04X==========\r\n",
-			pi, page.offset)
-		// FSP
-		if page.fspheader.spaceID != 0 {
-			// Header
-			fmt.Printf("%-10s", "space id")
-			fmt.Printf("%-11s", "page allo")
-			fmt.Printf("%-11s", "page init")
-			fmt.Printf("%-8s", "flags")
-			fmt.Printf("%-15s", "page used(fg)")
-			fmt.Printf("%-51s", "free_frag list")
-			fmt.Printf("%-51s", "free list")
-			fmt.Printf("%-51s", "full_frag list")
-			fmt.Printf("%-17s", "next segment id")
-			fmt.Printf("%-51s", "full inodes")
-			fmt.Printf("%-51s", "free inodes")
-			fmt.Printf("\r\n")
-			// Columns
-			fmt.Printf("%-10d", page.fspheader.spaceID)
-			fmt.Printf("%-11d", page.fspheader.highestPageNumberInFile)
-			fmt.Printf("%-11d", page.fspheader.highestPageNumberInitialized)
-			fmt.Printf("0x%-6.04X", page.fspheader.Flags)
-			fmt.Printf("%-15d", page.fspheader.pagesUsedInFreeFrag)
-			fmt.Printf("%-51s", page.fspheader.freeFragList.toString(8))
-			fmt.Printf("%-51s", page.fspheader.freeList.toString(8))
-			fmt.Printf("%-51s", page.fspheader.fullFragList.toString(8))
-			fmt.Printf("%-17d", page.fspheader.nextUnusedSegmentID)
-			fmt.Printf("%-51s", page.fspheader.fullInodesList.toString(38))
-			fmt.Printf("%-51s", page.fspheader.freeInodesList.toString(38))
-			fmt.Printf("\r\n\r\n")
-		}
-		// Xdes
-		fmt.Printf("%-13s", "extend")
-		fmt.Printf("%-20s", "page range")
-		fmt.Printf("%-20s", "file segment id")
-		fmt.Printf("%-16s", "state")
+		extendID := fmt.Sprintf("%d(0x%04X)", xi, 150+xi*ps.XdesEntrySize())
+		fmt.Printf("%-13s", extendID)
+		// Every xdes page describes the following page size pages
+		pageStart := page.No() + xi*extentPages
+		pageRange := fmt.Sprintf("%d-%d", pageStart, pageStart+extentPages-1)
+		fmt.Printf("%-20s", pageRange)
+		fmt.Printf("0x%-18.16X", des.FileSegmentID)
+		fmt.Printf("0x%-14.08X", des.State)
 		if options.list {
-			fmt.Printf("%-37s", "list")
+			// List ptr is pointer to the prev/next list, so we should adjust the offset
+			// Here is the 8 bytes file segment id
+			prevOffset := des.List.PrevPageOffset
+			nextOffset := des.List.NextPageOffset
+			if des.List.PrevPageNo != 0xffffffff {
+				prevOffset -= 8
+			}
+			if des.List.NextPageNo != 0xffffffff {
+				nextOffset -= 8
+			}
+			liststr := fmt.Sprintf("0x%08X:0x%04X 0x%08X:0x%04X",
+				des.List.PrevPageNo, prevOffset,
+				des.List.NextPageNo, nextOffset)
+			fmt.Printf("%-37s", liststr)
 		}
 		if options.pageState {
-			fmt.Printf("page state (F)ree or (N)ot free")
-		}
-		fmt.Printf("\r\n")
-		for xi, des := range page.XDeses {
-			if options.extend >= 0 {
-				if xi != options.extend {
-					continue
-				}
-			}
-			if !options.unused {
-				if des.fileSegmentID == 0 && xi != 0 {
-					continue
+			var stateBuf bytes.Buffer
+			free := 0
+			// Every bytes represents 4 page state (2bit per page)
+			for i := 0; i < len(des.PageStateBitmap)*4; i++ {
+				if des.IsPageFree(i) {
+					stateBuf.WriteString("F")
+					free++
+				} else {
+					stateBuf.WriteString("N")
 				}
 			}
+			stateBuf.WriteString(fmt.Sprintf("(%d free, %d used)", free, extentPages-free))
+			fmt.Printf(stateBuf.String())
+		}
+		fmt.Printf("\r\n")
+	}
+}
 
-			extendID := fmt.Sprintf("%d(0x%04X)", xi, 150+xi*40)
-			fmt.Printf("%-13s", extendID)
-			pageRange := fmt.Sprintf("%d-%d", pageCnt, pageCnt+63)
-			fmt.Printf("%-20s", pageRange)
-			fmt.Printf("0x%-18.16X", des.fileSegmentID)
-			fmt.Printf("0x%-14.08X", des.state)
-			if options.list {
-				// List ptr is pointer to the prev/next list, so we should adjust the offset
-				// Here is the 8 bytes file segment id
-				prevOffset := des.list.prevPageOffset
-				nextOffset := des.list.nextPageOffset
-				if des.list.prevPageNo != 0xffffffff {
-					prevOffset -= 8
-				}
-				if des.list.nextPageNo != 0xffffffff {
-					nextOffset -= 8
-				}
-				liststr := fmt.Sprintf("0x%08X:0x%04X 0x%08X:0x%04X",
-					des.list.prevPageNo, prevOffset,
-					des.list.nextPageNo, nextOffset)
-				fmt.Printf("%-37s", liststr)
-			}
-			if options.pageState {
-				var stateBuf bytes.Buffer
-				free := 0
-				for i := 0; i < len(des.pageStateBitmap); i++ {
-					// Every bytes represents 4 page state (2bit per page)
-					tst := des.pageStateBitmap[i]
-					for j := 0; j < 4; j++ {
-						var mask byte = 0xC0
-						mask = mask >> (2 * uint(j))
-						lm := 6 - uint(j)*2
-						val := (tst & mask)
-						if ((val >> lm) & xdesPageStateFree) != 0 {
-							stateBuf.WriteString("F")
-							free++
-						} else {
-							stateBuf.WriteString("N")
-						}
-					}
+// showExtent checks the extent filters of the options, the first extent is
+// always shown if the unused extents are not shown
+func showExtent(xi int, des *innodb.XdesEntry, options *spaceOptions) bool {
+	if options.extend >= 0 && xi != options.extend {
+		return false
+	}
+	if !options.unused && des.FileSegmentID == 0 && xi != 0 {
+		return false
+	}
+	return true
+}
+
+// spacePageDoc is the json document of the FSP_HDR or XDES page, the file space
+// header is only on page 0
+type spacePageDoc struct {
+	Page      int           `json:"page"`
+	Offset    int           `json:"offset"`
+	FSPHeader *fspHeaderDoc `json:"fsp_header,omitempty"`
+	Extents   []*extentDoc  `json:"extents"`
+}
+
+type fspHeaderDoc struct {
+	SpaceID         uint32       `json:"space_id"`
+	PageAllocated   uint32       `json:"page_allocated"`
+	PageInitialized uint32       `json:"page_initialized"`
+	Flags           uint32       `json:"flags"`
+	FragPagesUsed   uint32       `json:"frag_pages_used"`
+	FreeFragList    *listNodeDoc `json:"free_frag_list"`
+	FreeList        *listNodeDoc `json:"free_list"`
+	FullFragList    *listNodeDoc `json:"full_frag_list"`
+	NextSegmentID   uint64       `json:"next_segment_id"`
+	FullInodes      *listNodeDoc `json:"full_inodes"`
+	FreeInodes      *listNodeDoc `json:"free_inodes"`
+}
+
+// extentDoc is the extent descriptor, the list and the page states are written
+// with --list and --pstate
+type extentDoc struct {
+	Extent        int            `json:"extent"`
+	EntryOffset   int            `json:"entry_offset"`
+	FirstPage     int            `json:"first_page"`
+	LastPage      int            `json:"last_page"`
+	FileSegmentID uint64         `json:"file_segment_id"`
+	State         uint32         `json:"state"`
+	List          *listNodeDoc   `json:"list,omitempty"`
+	PageStates    *pageStatesDoc `json:"page_states,omitempty"`
+}
+
+// pageStatesDoc is the page state bitmap, one state of every page in the extent
+type pageStatesDoc struct {
+	Free  int      `json:"free"`
+	Used  int      `json:"used"`
+	Pages []string `json:"pages"`
+}
+
+func newSpacePageDoc(page *innodb.Page, options *spaceOptions) *spacePageDoc {
+	ps := innodb.PageSize(page.Size())
+	extentPages := ps.ExtentPages()
+
+	doc := &spacePageDoc{
+		Page:    page.No(),
+		Offset:  page.Offset(),
+		Extents: make([]*extentDoc, 0, len(page.XDeses)),
+	}
+	if page.FSPHeader.SpaceID != 0 {
+		h := &page.FSPHeader
+		doc.FSPHeader = &fspHeaderDoc{
+			SpaceID:         h.SpaceID,
+			PageAllocated:   h.HighestPageNumberInFile,
+			PageInitialized: h.HighestPageNumberInitialized,
+			Flags:           h.Flags,
+			FragPagesUsed:   h.PagesUsedInFreeFrag,
+			FreeFragList:    newListBaseNodeDoc(&h.FreeFragList, 8),
+			FreeList:        newListBaseNodeDoc(&h.FreeList, 8),
+			FullFragList:    newListBaseNodeDoc(&h.FullFragList, 8),
+			NextSegmentID:   h.NextUnusedSegmentID,
+			FullInodes:      newListBaseNodeDoc(&h.FullInodesList, 38),
+			FreeInodes:      newListBaseNodeDoc(&h.FreeInodesList, 38),
+		}
+	}
+	for xi, des := range page.XDeses {
+		if !showExtent(xi, des, options) {
+			continue
+		}
+		pageStart := page.No() + xi*extentPages
+		ed := &extentDoc{
+			Extent:        xi,
+			EntryOffset:   150 + xi*ps.XdesEntrySize(),
+			FirstPage:     pageStart,
+			LastPage:      pageStart + extentPages - 1,
+			FileSegmentID: des.FileSegmentID,
+			State:         des.State,
+		}
+		if options.list {
+			ed.List = newListNodeDoc(&des.List, 8)
+		}
+		if options.pageState {
+			states := &pageStatesDoc{Pages: make([]string, 0, len(des.PageStateBitmap)*4)}
+			for i := 0; i < len(des.PageStateBitmap)*4; i++ {
+				if des.IsPageFree(i) {
+					states.Pages = append(states.Pages, "free")
+					states.Free++
+				} else {
+					states.Pages = append(states.Pages, "used")
 				}
-				stateBuf.WriteString(fmt.Sprintf("(%d free, %d used)", free, 64-free))
-				fmt.Printf(stateBuf.String())
 			}
-			fmt.Printf("\r\n")
-			pageCnt += 64
+			states.Used = extentPages - states.Free
+			ed.PageStates = states
 		}
+		doc.Extents = append(doc.Extents, ed)
 	}
+	return doc
 }
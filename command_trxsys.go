@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"spf13/cobra"
+	"strings"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type trxsysOptions struct {
+	file     string
+	undo     []string
+	slots    bool
+	pageSize int
+}
+
+func newTrxsysCommand() *cobra.Command {
+	var options trxsysOptions
+	c := &cobra.Command{
+		Use:   "trxsys",
+		Short: "show the transaction system and rollback segments",
+		Long:  "Show the transaction system header of the system table space, and the history list and undo slots of the rollback segments",
+		Run: func(cmd *cobra.Command, args []string) {
+			doTrxsys(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb system table space file path (ibdata1)")
+	c.Flags().StringSliceVarP(&options.undo, "undo", "u", nil, "undo table space file path, can be specified more than once")
+	c.Flags().BoolVar(&options.slots, "slots", false, "show the first page of the active undo segments")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+func doTrxsys(cmd *cobra.Command, options *trxsysOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	trxSys, err := ts.TrxSys()
+	if nil != err {
+		fmt.Println("Read transaction system header error ", err)
+		return
+	}
+
+	// The rollback segments of the undo table spaces are read from the files
+	// of the space ids
+	spaces := map[uint32]*innodb.Tablespace{0: ts}
+	for _, path := range options.undo {
+		uf, err := os.Open(path)
+		if nil != err {
+			fmt.Println("Open file error ", err)
+			return
+		}
+		defer uf.Close()
+
+		uts, err := innodb.NewTablespace(uf, options.pageSize)
+		if nil != err {
+			fmt.Println("Open undo table space ", path, " error ", err)
+			return
+		}
+		spaceID, err := uts.SpaceID()
+		if nil != err {
+			fmt.Println("Read space id of ", path, " error ", err)
+			return
+		}
+		spaces[spaceID] = uts
+	}
+
+	printTrxSys(trxSys, innodb.PageSize(ts.PageSize()))
+
+	fmt.Printf("\t\t\t==========ROLLBACK SEGMENTS==========\r\n")
+	fmt.Printf("%-16s%-12s%-10s%-14s%-16s%-14s%s\r\n",
+		"slot", "space", "page", "history size", "history length", "active slots", "max size")
+	var history uint64
+	active := 0
+	for i, slot := range trxSys.Rsegs {
+		if !slot.Used() {
+			continue
+		}
+		h, a := printRsegHeader(fmt.Sprintf("%d", i), spaces[slot.SpaceID], slot.SpaceID, slot.PageNo, options)
+		history += h
+		active += a
+	}
+	// The 8.0 undo table spaces store the rollback segments in the array
+	spaceIDs := make([]int, 0, len(spaces))
+	for spaceID := range spaces {
+		if 0 != spaceID {
+			spaceIDs = append(spaceIDs, int(spaceID))
+		}
+	}
+	sort.Ints(spaceIDs)
+	for _, id := range spaceIDs {
+		spaceID := uint32(id)
+		uts := spaces[spaceID]
+		array, err := uts.RsegArray()
+		if nil != err {
+			fmt.Printf("Read rollback segment array of space %d error %v\r\n", spaceID, err)
+			continue
+		}
+		if nil == array {
+			continue
+		}
+		for i, pageNo := range array.Rsegs {
+			h, a := printRsegHeader(fmt.Sprintf("%d:%d", spaceID, i), uts, spaceID, pageNo, options)
+			history += h
+			active += a
+		}
+	}
+	fmt.Printf("\r\nhistory list length %d, %d active undo segments\r\n", history, active)
+}
+
+func printTrxSys(trxSys *innodb.TrxSys, ps innodb.PageSize) {
+	fmt.Printf("\t\t\t==========TRX SYS==========\r\n")
+	fmt.Printf("%-20s%d\r\n", "max trx id", trxSys.MaxTrxID)
+	if nil != trxSys.Binlog {
+		fmt.Printf("%-20s%s:%d\r\n", "binlog", trxSys.Binlog.Name, trxSys.Binlog.Offset)
+	} else {
+		fmt.Printf("%-20s%s\r\n", "binlog", "not stored")
+	}
+	dw := &trxSys.Doublewrite
+	if dw.Created() {
+		fmt.Printf("%-20spage %d-%d, page %d-%d\r\n", "doublewrite",
+			dw.Block1, int(dw.Block1)+ps.ExtentPages()-1, dw.Block2, int(dw.Block2)+ps.ExtentPages()-1)
+		fmt.Printf("%-20s%v\r\n", "space id stored", dw.SpaceIDStored)
+		if !dw.Consistent() {
+			fmt.Printf("%-20smagic 0x%08X block1 %d block2 %d mismatch\r\n", "repeat",
+				dw.RepeatMagic, dw.RepeatBlock1, dw.RepeatBlock2)
+		}
+	} else {
+		fmt.Printf("%-20s%s\r\n", "doublewrite", "not created")
+	}
+	fmt.Printf("\r\n")
+}
+
+// printRsegHeader shows the rollback segment, returns the history list length
+// and the active undo slots
+func printRsegHeader(slot string, ts *innodb.Tablespace, spaceID uint32, pageNo uint32,
+	options *trxsysOptions) (uint64, int) {
+	fmt.Printf("%-16s%-12d%-10d", slot, spaceID, pageNo)
+	if nil == ts {
+		fmt.Printf("undo table space not specified\r\n")
+		return 0, 0
+	}
+	h, err := ts.RsegHeader(pageNo)
+	if nil != err {
+		fmt.Printf("%v\r\n", err)
+		return 0, 0
+	}
+	active := h.ActiveSlots()
+	fmt.Printf("%-14d%-16d%-14d%d\r\n", h.HistorySize, h.History.Length, active, h.MaxSize)
+	if options.slots && active > 0 {
+		pages := make([]string, 0, active)
+		for _, no := range h.UndoSlots {
+			if 0xffffffff != no {
+				pages = append(pages, fmt.Sprintf("%d", no))
+			}
+		}
+		fmt.Printf("%-38s%s\r\n", "", strings.Join(pages, ","))
+	}
+	return uint64(h.History.Length), active
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"spf13/cobra"
+
+	"github.com/juju/errors"
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type undeleteOptions struct {
+	file     string
+	schema   string
+	columns  string
+	pk       string
+	format   string
+	output   string
+	pageSize int
+	jobs     int
+}
+
+func newUndeleteCommand() *cobra.Command {
+	var options undeleteOptions
+	c := &cobra.Command{
+		Use:   "undelete",
+		Short: "recover the deleted records",
+		Long:  "Recover the delete-marked records and the purged records in the free record list of the clustered index leaf pages, the rows are written as CSV or SQL INSERT statements",
+		Run: func(cmd *cobra.Command, args []string) {
+			doUndelete(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "innodb table space file path")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file, read from the SDI if not specified")
+	c.Flags().StringVarP(&options.columns, "columns", "c", "", "column definitions to decode the records")
+	c.Flags().StringVar(&options.pk, "pk", "", "comma separated primary key columns of --columns")
+	c.Flags().StringVar(&options.format, "rows-format", "csv", "output format, csv or sql")
+	c.Flags().StringVarP(&options.output, "output", "o", "", "output file path, write to stdout if not specified")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+	c.Flags().IntVarP(&options.jobs, "jobs", "j", 1, "workers to parse the pages, the output is still in page order")
+
+	return c
+}
+
+func doUndelete(cmd *cobra.Command, options *undeleteOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+	if innodb.RowFormatCSV != options.format && innodb.RowFormatSQL != options.format {
+		fmt.Println("Invalid rows format, must be csv or sql")
+		return
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	table, err := ts.LoadTable(options.schema, options.columns, options.pk)
+	if nil != err {
+		fmt.Println("Load table schema error ", err)
+		return
+	}
+	if nil == table {
+		fmt.Println("No table schema specified and no SDI found")
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	if "" != options.output {
+		of, err := os.Create(options.output)
+		if nil != err {
+			fmt.Println("Create output file error ", err)
+			return
+		}
+		defer of.Close()
+		out = of
+	}
+	bw := bufio.NewWriter(out)
+	defer bw.Flush()
+	w := innodb.NewRowWriter(bw, table, options.format, true, 1)
+
+	parseOptions := &innodb.ParsePageOptions{
+		ParseRecords: true,
+		Table:        table,
+	}
+	counts := make(map[string]int)
+	unreadable := 0
+	// Only the file header is parsed by the iteration, the page is parsed by
+	// the work to skip the pages with the broken record list
+	err = ts.MapPages(&innodb.ParsePageOptions{
+		ParsePageTypeFlag: innodb.ParsePageIndex,
+		HeaderOnly:        true,
+		Jobs:              options.jobs,
+	}, func(hp *innodb.Page, data []byte) interface{} {
+		if hp.FileHeader.Type != innodb.PageTypeIndex {
+			return nil
+		}
+		page, err := innodb.ParsePage(hp.No(), data, parseOptions)
+		if nil != err {
+			return err
+		}
+		return page
+	}, func(hp *innodb.Page, data []byte, v interface{}) error {
+		if err, ok := v.(error); ok {
+			// The record list of the page is broken
+			fmt.Fprintf(os.Stderr, "Parse page %d error %v\r\n", hp.No(), err)
+			return nil
+		}
+		page, ok := v.(*innodb.Page)
+		if !ok || 0 != page.IndexHeader.Level {
+			return nil
+		}
+		index := table.FindIndex(page.IndexHeader.IndexID)
+		if nil == index || !index.IsClustered() {
+			return nil
+		}
+		rows, n := innodb.FindDeletedRows(page, data, index)
+		unreadable += n
+		for _, row := range rows {
+			if err := w.Write(ts, row); nil != err {
+				return errors.Errorf("Write row error %v", err)
+			}
+			counts[row.State]++
+		}
+		return nil
+	})
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "%v\r\n", err)
+		return
+	}
+	if err := w.Flush(); nil != err {
+		fmt.Fprintf(os.Stderr, "Write row error %v\r\n", err)
+		return
+	}
+	// The summary is not mixed with the rows written to stdout
+	fmt.Fprintf(os.Stderr, "%d %s, %d %s, %d %s row(s) recovered, %d free record(s) unreadable\r\n",
+		counts[innodb.RowStateDeleteMarked], innodb.RowStateDeleteMarked,
+		counts[innodb.RowStatePurged], innodb.RowStatePurged,
+		counts[innodb.RowStateOverwritten], innodb.RowStateOverwritten, unreadable)
+}
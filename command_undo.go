@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"spf13/cobra"
+	"strings"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+type undoOptions struct {
+	file      string
+	schema    string
+	columns   string
+	pk        string
+	tableID   uint64
+	trxID     uint64
+	keyFields int
+	headers   bool
+	pageSize  int
+}
+
+func newUndoCommand() *cobra.Command {
+	var options undoOptions
+	c := &cobra.Command{
+		Use:   "undo",
+		Short: "parse undo logs",
+		Long:  "Parse the undo segment headers, the undo log headers and the undo records of the system table space or the undo table spaces",
+		Run: func(cmd *cobra.Command, args []string) {
+			doUndo(cmd, &options)
+		},
+	}
+
+	c.Flags().StringVarP(&options.file, "file", "f", "", "ibdata1 or undo table space file path")
+	c.Flags().StringVarP(&options.schema, "schema", "s", "", "CREATE TABLE statement file to decode the records of --table-id")
+	c.Flags().StringVarP(&options.columns, "columns", "c", "", "column definitions to decode the records of --table-id")
+	c.Flags().StringVar(&options.pk, "pk", "", "comma separated primary key columns of --columns")
+	c.Flags().Uint64VarP(&options.tableID, "table-id", "t", 0, "only show the records of the table id")
+	c.Flags().Uint64Var(&options.trxID, "trx", 0, "only show the undo logs of the transaction id")
+	c.Flags().IntVar(&options.keyFields, "key-fields", 1, "primary key fields of the records not decoded with the schema")
+	c.Flags().BoolVar(&options.headers, "headers", false, "only show the undo log headers")
+	c.Flags().IntVar(&options.pageSize, "page-size", 0, "page size in bytes, detect from page 0 if not specified")
+
+	return c
+}
+
+func doUndo(cmd *cobra.Command, options *undoOptions) {
+	if "" == options.file {
+		fmt.Println("No input file specified")
+		return
+	}
+
+	table, err := innodb.LoadTableSchema(options.schema, options.columns, options.pk)
+	if nil != err {
+		fmt.Println("Load table schema error ", err)
+		return
+	}
+	decode := &innodb.UndoDecodeOptions{
+		Tables:    make(map[uint64]*innodb.Table),
+		KeyFields: options.keyFields,
+	}
+	if nil != table {
+		// The table id is stored in the records, the schema can't be matched
+		// without it
+		if 0 == options.tableID {
+			fmt.Println("No table id specified for the table schema")
+			return
+		}
+		decode.Tables[options.tableID] = table
+	}
+
+	f, err := os.Open(options.file)
+	if nil != err {
+		fmt.Println("Open file error ", err)
+		return
+	}
+	defer f.Close()
+
+	ts, err := innodb.NewTablespace(f, options.pageSize)
+	if nil != err {
+		fmt.Println("Open table space error ", err)
+		return
+	}
+
+	logs, records := 0, 0
+	err = ts.IterateUndoLogs(decode, func(l *innodb.UndoLog) error {
+		if 0 != options.trxID && options.trxID != l.Header.TrxID {
+			return nil
+		}
+		logs++
+		printUndoLog(l)
+		if !options.headers {
+			records += printUndoRecords(l, options)
+		}
+		if nil != l.Err {
+			fmt.Printf("Parse undo records error %v\r\n", l.Err)
+		}
+		fmt.Printf("\r\n")
+		return nil
+	})
+	if nil != err {
+		fmt.Println("Parse undo logs error ", err)
+	}
+	fmt.Printf("%d undo logs, %d records shown\r\n", logs, records)
+}
+
+func printUndoLog(l *innodb.UndoLog) {
+	seg := l.Page.UndoSegmentHeader
+	h := l.Header
+	fmt.Printf("\t\t\t==========UNDO LOG PAGE %d OFFSET 0x%04X==========\r\n", l.PageNo, h.Offset)
+	fmt.Printf("%-16s%s\r\n", "type", innodb.UndoPageTypeToString(l.Page.UndoPageHeader.Type))
+	state := innodb.UndoStateToString(seg.State)
+	if !l.Latest() {
+		// Only the latest undo log of the segment is in the segment state
+		state = "history"
+	}
+	fmt.Printf("%-16s%s\r\n", "state", state)
+	fmt.Printf("%-16s%d\r\n", "trx id", h.TrxID)
+	fmt.Printf("%-16s%d\r\n", "trx no", h.TrxNo)
+	fmt.Printf("%-16s%d\r\n", "del marks", h.DelMarks)
+	fmt.Printf("%-16s%d\r\n", "dict trans", h.DictTrans)
+	if 0 != h.DictTrans {
+		fmt.Printf("%-16s%d\r\n", "table id", h.TableID)
+	}
+	if nil != h.XID {
+		fmt.Printf("%-16s%s\r\n", "xid", h.XID.String())
+	}
+	pages := make([]string, 0, len(l.Pages))
+	for _, no := range l.Pages {
+		pages = append(pages, fmt.Sprintf("%d", no))
+	}
+	fmt.Printf("%-16s%s\r\n", "pages", strings.Join(pages, ","))
+	fmt.Printf("%-16s%d\r\n", "records", len(l.Records))
+}
+
+// printUndoRecords shows the records of the undo log, returns the count of
+// the shown records
+func printUndoRecords(l *innodb.UndoLog, options *undoOptions) int {
+	shown := 0
+	for _, rec := range l.Records {
+		if 0 != options.tableID && options.tableID != rec.TableID {
+			continue
+		}
+		if 0 == shown {
+			fmt.Printf("\r\n%-10s%-16s%-18s%-10s%-14s%s\r\n",
+				"undo no", "type", "position", "table id", "old trx id", "key / old values")
+		}
+		shown++
+		oldTrx := "-"
+		if innodb.UndoInsertRec != rec.Type {
+			oldTrx = fmt.Sprintf("%d", rec.TrxID)
+		}
+		fmt.Printf("%-10d%-16s%-18s%-10d%-14s%s\r\n", rec.UndoNo, rec.TypeString(),
+			fmt.Sprintf("%d:0x%04X", rec.PageNo, rec.Offset), rec.TableID, oldTrx, formatUndoFields(rec.Key))
+		if len(rec.Fields) > 0 {
+			values := formatUndoFields(rec.Fields)
+			if rec.Partial {
+				values += " ..."
+			}
+			fmt.Printf("%-68s%s\r\n", "", values)
+		}
+	}
+	return shown
+}
+
+func formatUndoFields(fields []*innodb.UndoField) string {
+	values := make([]string, 0, len(fields))
+	for _, f := range fields {
+		values = append(values, fmt.Sprintf("%s=%s", f.Column.Name, f.String()))
+	}
+	return strings.Join(values, " ")
+}
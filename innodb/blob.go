@@ -0,0 +1,180 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// The BLOB page header after the file header, the data of the externally stored
+// field is split into the BLOB page list
+const (
+	blobHeaderPartLen  = 0
+	blobHeaderNextPage = 4
+	blobHeaderSize     = 8
+)
+
+// BlobHeader is the header of the uncompressed BLOB page
+type BlobHeader struct {
+	PartLen  uint32
+	NextPage uint32
+}
+
+func (h *BlobHeader) parse(data []byte) {
+	h.PartLen = binary.BigEndian.Uint32(data[blobHeaderPartLen:])
+	h.NextPage = binary.BigEndian.Uint32(data[blobHeaderNextPage:])
+}
+
+// externFieldRef is the 20 bytes reference to the externally stored part
+type externFieldRef struct {
+	spaceID uint32
+	pageNo  uint32
+	// The LOB version if the first page is LOB first page of mysql 8.0
+	offset uint32
+	// The highest 2 bits are the owner and inherited flags
+	length uint64
+}
+
+func parseExternFieldRef(data []byte) *externFieldRef {
+	return &externFieldRef{
+		spaceID: binary.BigEndian.Uint32(data[0:]),
+		pageNo:  binary.BigEndian.Uint32(data[4:]),
+		offset:  binary.BigEndian.Uint32(data[8:]),
+		length:  binary.BigEndian.Uint64(data[12:]) & 0x3fffffffffffffff,
+	}
+}
+
+// readExternField reads the whole field data, the local prefix and the external
+// part from the BLOB page list
+func readExternField(f io.ReaderAt, field *RecordField, pageSize int) ([]byte, error) {
+	if !field.Extern {
+		return field.Data, nil
+	}
+	local := field.Data[:len(field.Data)-externFieldRefSize]
+	ref := parseExternFieldRef(field.Data[len(local):])
+	ext, err := readExternPart(f, ref, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	data := make([]byte, 0, len(local)+len(ext))
+	data = append(data, local...)
+	return append(data, ext...), nil
+}
+
+// readExternPart reads the external part in the format of the first page
+func readExternPart(f io.ReaderAt, ref *externFieldRef, pageSize int) ([]byte, error) {
+	page := make([]byte, pageSize)
+	if err := readPageImage(f, int(ref.pageNo), page); nil != err {
+		return nil, errors.Trace(err)
+	}
+	typ := binary.BigEndian.Uint16(page[24:])
+	switch typ {
+	case PageTypeBlob, PageTypeSDIBlob:
+		{
+			return readBlobPages(f, ref, pageSize)
+		}
+	case PageTypeZBlob, PageTypeZBlob2, PageTypeSDIZBlob:
+		{
+			// The compressed BLOB pages are read in the physical size
+			if z, ok := f.(*zipReader); ok {
+				return readZblobPages(z.r, ref, z.zipSize)
+			}
+			return readZblobPages(f, ref, pageSize)
+		}
+	case PageTypeLobFirst:
+		{
+			return readLob(f, ref, page)
+		}
+	case PageTypeZLobFirst:
+		{
+			// The compressed LOB pages are read in the physical size
+			if z, ok := f.(*zipReader); ok {
+				return readZLob(z.r, ref, z.zipSize)
+			}
+			return readZLob(f, ref, pageSize)
+		}
+	}
+	return nil, errors.Errorf("Page %d is %s page, not BLOB page", ref.pageNo, PageTypeToString(int(typ)))
+}
+
+// readBlobPages reads the external part through the BLOB page list, every page
+// stores the part length and the next page number before the data
+func readBlobPages(f io.ReaderAt, ref *externFieldRef, pageSize int) ([]byte, error) {
+	data := make([]byte, 0, ref.length)
+	page := make([]byte, pageSize)
+	pageNo := ref.pageNo
+	offset := int(ref.offset)
+
+	for uint64(len(data)) < ref.length {
+		if 0xffffffff == pageNo {
+			return nil, errors.Errorf("BLOB page list ends at %d/%d bytes", len(data), ref.length)
+		}
+		if err := readPageImage(f, int(pageNo), page); nil != err {
+			return nil, errors.Trace(err)
+		}
+		typ := binary.BigEndian.Uint16(page[24:])
+		if typ != PageTypeBlob && typ != PageTypeSDIBlob {
+			return nil, errors.Errorf("Page %d is %s page, not BLOB page", pageNo, PageTypeToString(int(typ)))
+		}
+		if offset+blobHeaderSize > pageSize-8 {
+			return nil, errors.Errorf("Invalid BLOB offset %d of page %d", offset, pageNo)
+		}
+		partLen := int(binary.BigEndian.Uint32(page[offset+blobHeaderPartLen:]))
+		start := offset + blobHeaderSize
+		if start+partLen > pageSize-8 {
+			return nil, errors.Errorf("Invalid BLOB part length %d of page %d", partLen, pageNo)
+		}
+		data = append(data, page[start:start+partLen]...)
+		pageNo = binary.BigEndian.Uint32(page[offset+blobHeaderNextPage:])
+		// The data starts after the file header in the following pages
+		offset = 38
+	}
+	return data, nil
+}
+
+// readZblobPages reads the external part through the compressed BLOB page list,
+// the part is a zlib stream splitted into the pages. The first page stores the
+// next page number at the offset of the reference, FIL_PAGE_NEXT
+func readZblobPages(f io.ReaderAt, ref *externFieldRef, pageSize int) ([]byte, error) {
+	var stream []byte
+	page := make([]byte, pageSize)
+	pageNo := ref.pageNo
+	offset := int(ref.offset)
+
+	for 0xffffffff != pageNo {
+		if err := readPageImage(f, int(pageNo), page); nil != err {
+			return nil, errors.Trace(err)
+		}
+		typ := binary.BigEndian.Uint16(page[24:])
+		if typ != PageTypeZBlob && typ != PageTypeZBlob2 && typ != PageTypeSDIZBlob {
+			return nil, errors.Errorf("Page %d is %s page, not compressed BLOB page", pageNo, PageTypeToString(int(typ)))
+		}
+		if offset+4 > pageSize {
+			return nil, errors.Errorf("Invalid compressed BLOB offset %d of page %d", offset, pageNo)
+		}
+		next := binary.BigEndian.Uint32(page[offset:])
+		// The stream follows the file header, or the next page number if it
+		// is not stored in the file header
+		start := 38
+		if offset >= start {
+			start = offset + 4
+		}
+		stream = append(stream, page[start:]...)
+		pageNo = next
+		offset = 12
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(stream))
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	defer zr.Close()
+	data := make([]byte, ref.length)
+	if _, err = io.ReadFull(zr, data); nil != err {
+		return nil, errors.Annotatef(err, "Inflate compressed BLOB of page %d", ref.pageNo)
+	}
+	return data, nil
+}
@@ -0,0 +1,330 @@
+package innodb
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// readFirstRootPageNo gets the root page of the first index from the inode page,
+// every index has 2 inode entries and the non-leaf one holds the root page. The
+// SDI index of mysql 8.0 is created before the clustered index, so it is skipped
+func readFirstRootPageNo(f io.ReaderAt, options *ParsePageOptions) (int, error) {
+	inodePage, err := readPageFromFile(f, 2, &ParsePageOptions{
+		PageSize: options.PageSize,
+	})
+	if nil != err {
+		return 0, errors.Trace(err)
+	}
+	node, err := findFirstIndexInode(f, inodePage)
+	if nil != err {
+		return 0, errors.Trace(err)
+	}
+	return int(node.FragmentArrayEntry[0]), nil
+}
+
+// findFirstIndexInode finds the non-leaf inode entry of the first index which root
+// page is not SDI page
+func findFirstIndexInode(f io.ReaderAt, inodePage *Page) (*INodeEntry, error) {
+	if inodePage.FileHeader.Type != PageTypeINode {
+		return nil, errors.Errorf("Page %d is not file segment inode page", inodePage.no)
+	}
+	for i := 0; i < len(inodePage.INode.Inodes); i += 2 {
+		node := inodePage.INode.Inodes[i]
+		if 0 == node.FileSegmentID {
+			// Freed slot
+			continue
+		}
+		if node.MagicNumber != inodeEntryMagicNumber {
+			return nil, errors.New("Inode not initialized")
+		}
+		if 0xffffffff == node.FragmentArrayEntry[0] {
+			return nil, errors.New("Index root page not allocated")
+		}
+		var fheader FileHeader
+		data := make([]byte, inodePage.size)
+		if err := readPageImage(f, int(node.FragmentArrayEntry[0]), data); nil != err {
+			return nil, errors.Trace(err)
+		}
+		if err := fheader.parse(bytes.NewReader(data)); nil != err {
+			return nil, errors.Trace(err)
+		}
+		if fheader.Type != PageTypeSDI {
+			return node, nil
+		}
+	}
+	return nil, errors.New("Index root page not found")
+}
+
+// readLeftmostLeafPage descends from the root page to the leftmost leaf page
+// through the first node pointer record of each level
+func readLeftmostLeafPage(f io.ReaderAt, rootNo int, options *ParsePageOptions) (*Page, error) {
+	pageNo := rootNo
+	for {
+		page, err := readPageFromFile(f, pageNo, options)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if page.FileHeader.Type != PageTypeIndex && page.FileHeader.Type != PageTypeSDI {
+			return nil, errors.Errorf("Page %d is not index page", pageNo)
+		}
+		if 0 == page.IndexHeader.Level {
+			return page, nil
+		}
+		rcs := page.UserRecorders()
+		if 0 == len(rcs) || 0xffffffff == rcs[0].PagePtr {
+			return nil, errors.Errorf("No node pointer found in page %d", pageNo)
+		}
+		pageNo = int(rcs[0].PagePtr)
+	}
+}
+
+// resolveIndexIDs reads the root pages of the indexes from the inode page, and
+// assigns the index ids to the table indexes in the creation order. Every index
+// has 2 file segments, the non-leaf segment holds the root page
+func resolveIndexIDs(f io.ReaderAt, table *Table, options *ParsePageOptions) error {
+	inodePage, err := readPageFromFile(f, 2, &ParsePageOptions{
+		PageSize: options.PageSize,
+	})
+	if nil != err {
+		return errors.Trace(err)
+	}
+	if inodePage.FileHeader.Type != PageTypeINode {
+		return errors.Errorf("Page 2 is not file segment inode page")
+	}
+
+	ids := make([]uint64, 0, len(table.Indexes))
+	for i := 0; i+1 < len(inodePage.INode.Inodes); i += 2 {
+		node := inodePage.INode.Inodes[i]
+		if 0 == node.FileSegmentID {
+			// The slot is freed by fsp_free_seg_inode, e.g. the segments of the
+			// dropped index, the live entries may follow
+			continue
+		}
+		if node.MagicNumber != inodeEntryMagicNumber {
+			break
+		}
+		if 0xffffffff == node.FragmentArrayEntry[0] {
+			continue
+		}
+		root, err := readPageFromFile(f, int(node.FragmentArrayEntry[0]), &ParsePageOptions{
+			PageSize: options.PageSize,
+		})
+		if nil != err {
+			return errors.Trace(err)
+		}
+		// R-tree and SDI root pages are skipped
+		if root.FileHeader.Type == PageTypeIndex {
+			ids = append(ids, root.IndexHeader.IndexID)
+		}
+	}
+
+	n := 0
+	for _, index := range table.Indexes {
+		if index.Fulltext || index.Spatial {
+			continue
+		}
+		if n >= len(ids) {
+			break
+		}
+		index.ID = ids[n]
+		n++
+	}
+	return nil
+}
+
+// findIndexRootPageNo finds the root page of the index id from the non-leaf
+// inode entries of the inode page
+func findIndexRootPageNo(f io.ReaderAt, id uint64, options *ParsePageOptions) (int, error) {
+	inodePage, err := readPageFromFile(f, 2, &ParsePageOptions{
+		PageSize: options.PageSize,
+	})
+	if nil != err {
+		return 0, errors.Trace(err)
+	}
+	if inodePage.FileHeader.Type != PageTypeINode {
+		return 0, errors.Errorf("Page 2 is not file segment inode page")
+	}
+
+	for i := 0; i+1 < len(inodePage.INode.Inodes); i += 2 {
+		node := inodePage.INode.Inodes[i]
+		if 0 == node.FileSegmentID {
+			// The slot is freed by fsp_free_seg_inode, e.g. the segments of the
+			// dropped index, the live entries may follow
+			continue
+		}
+		if node.MagicNumber != inodeEntryMagicNumber {
+			break
+		}
+		if 0xffffffff == node.FragmentArrayEntry[0] {
+			continue
+		}
+		root, err := readPageFromFile(f, int(node.FragmentArrayEntry[0]), &ParsePageOptions{
+			PageSize: options.PageSize,
+		})
+		if nil != err {
+			return 0, errors.Trace(err)
+		}
+		if root.FileHeader.Type == PageTypeIndex && root.IndexHeader.IndexID == id {
+			return root.no, nil
+		}
+	}
+	return 0, errors.Errorf("Root page of index %d not found", id)
+}
+
+// BtreeIndex is the B+ tree of an index, the pages are grouped by the index id
+type BtreeIndex struct {
+	ID uint64
+	// Index or SDI
+	Type uint16
+	Root int
+	// Page count of every level, the leaf level is 0
+	LevelPages []int
+	// User records of the leaf level
+	Records          int
+	LeafSegmentID    uint64
+	NonleafSegmentID uint64
+}
+
+func (i *BtreeIndex) Height() int {
+	return len(i.LevelPages)
+}
+
+// indexRootCandidate is the first page without prev and next page of a level
+type indexRootCandidate struct {
+	no           int
+	size         int
+	leafInode    FileSegmentHeader
+	nonleafInode FileSegmentHeader
+}
+
+// indexCollector groups the index pages by the index id page by page, only the
+// counters and the root candidates are kept, not the pages
+type indexCollector struct {
+	indexes  []*BtreeIndex
+	indexMap map[uint64]*BtreeIndex
+	// Root candidates of every level of every index
+	candidates map[uint64]map[int]*indexRootCandidate
+	inodes     map[int]*INode
+}
+
+func newIndexCollector() *indexCollector {
+	return &indexCollector{
+		indexes:    make([]*BtreeIndex, 0, 4),
+		indexMap:   make(map[uint64]*BtreeIndex),
+		candidates: make(map[uint64]map[int]*indexRootCandidate),
+		inodes:     make(map[int]*INode),
+	}
+}
+
+// add counts the index or SDI page, and keeps the inode entries of the inode page
+func (c *indexCollector) add(page *Page) {
+	if page.FileHeader.Type == PageTypeINode {
+		c.inodes[page.no] = &page.INode
+		return
+	}
+	if page.FileHeader.Type != PageTypeIndex && page.FileHeader.Type != PageTypeSDI {
+		return
+	}
+	index, ok := c.indexMap[page.IndexHeader.IndexID]
+	if !ok {
+		index = &BtreeIndex{
+			ID:   page.IndexHeader.IndexID,
+			Type: page.FileHeader.Type,
+			Root: -1,
+		}
+		c.indexMap[index.ID] = index
+		c.indexes = append(c.indexes, index)
+		c.candidates[index.ID] = make(map[int]*indexRootCandidate)
+	}
+	level := int(page.IndexHeader.Level)
+	for len(index.LevelPages) <= level {
+		index.LevelPages = append(index.LevelPages, 0)
+	}
+	index.LevelPages[level]++
+	if 0 == level {
+		index.Records += int(page.IndexHeader.NRecs)
+	}
+	if 0xffffffff != page.FileHeader.Prev || 0xffffffff != page.FileHeader.Next {
+		return
+	}
+	if _, ok := c.candidates[index.ID][level]; ok {
+		// More than one root candidate, keep the first one
+		return
+	}
+	c.candidates[index.ID][level] = &indexRootCandidate{
+		no:           page.no,
+		size:         page.size,
+		leafInode:    page.IndexHeader.LeafInode,
+		nonleafInode: page.IndexHeader.NonleafInode,
+	}
+}
+
+// result returns the indexes sorted by the root page. The root page is the page
+// without prev and next page at the highest level. The file segments are read
+// from the inode entries referenced by the root page
+func (c *indexCollector) result() []*BtreeIndex {
+	for _, index := range c.indexes {
+		root, ok := c.candidates[index.ID][index.Height()-1]
+		if !ok {
+			continue
+		}
+		index.Root = root.no
+		ps := PageSize(root.size)
+		if inode, ok := c.inodes[int(root.leafInode.InodePageNumber)]; ok {
+			if entry := inode.EntryAt(int(root.leafInode.InodeOffset), ps); nil != entry {
+				index.LeafSegmentID = entry.FileSegmentID
+			}
+		}
+		if inode, ok := c.inodes[int(root.nonleafInode.InodePageNumber)]; ok {
+			if entry := inode.EntryAt(int(root.nonleafInode.InodeOffset), ps); nil != entry {
+				index.NonleafSegmentID = entry.FileSegmentID
+			}
+		}
+	}
+
+	indexes := c.indexes
+	sort.SliceStable(indexes, func(i, j int) bool {
+		if indexes[i].Root < 0 || indexes[j].Root < 0 {
+			return indexes[i].Root >= 0 && indexes[j].Root < 0
+		}
+		return indexes[i].Root < indexes[j].Root
+	})
+	return indexes
+}
+
+// readLeafPageByKey descends from the root page to the leaf page which may hold
+// the key, the child of the last node pointer not greater than the key is read.
+// The key of the unsigned column is the bits of the uint64 value
+func readLeafPageByKey(f io.ReaderAt, rootNo int, key int64, options *ParsePageOptions) (*Page, error) {
+	pageNo := rootNo
+	for {
+		page, err := readPageFromFile(f, pageNo, options)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if page.FileHeader.Type != PageTypeIndex {
+			return nil, errors.Errorf("Page %d is not index page", pageNo)
+		}
+		if 0 == page.IndexHeader.Level {
+			return page, nil
+		}
+		rcs := page.UserRecorders()
+		if 0 == len(rcs) {
+			return nil, errors.Errorf("No node pointer found in page %d", pageNo)
+		}
+		child := rcs[0]
+		for _, rc := range rcs[1:] {
+			if rc.CompareKey(key) > 0 {
+				break
+			}
+			child = rc
+		}
+		if 0xffffffff == child.PagePtr {
+			return nil, errors.Errorf("Invalid node pointer in page %d", pageNo)
+		}
+		pageNo = int(child.PagePtr)
+	}
+}
@@ -0,0 +1,134 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// The index page header starts after the file header, and the origins of the
+// infimum and supremum of the COMPACT index page
+const (
+	pageHeaderOffset = 38
+	pageNewInfimum   = 0x63
+	pageNewSupremum  = 0x70
+)
+
+// Max level of the B+ tree
+const btreeMaxLevel = 50
+
+var (
+	infimumData  = []byte("infimum\x00")
+	supremumData = []byte("supremum")
+)
+
+// looksLikeIndexPage checks the file header, page header, directory slots, the
+// infimum and supremum of the COMPACT index page, so the page can be parsed
+// safely. The record list is walked from the infimum to the supremum
+func looksLikeIndexPage(data []byte) bool {
+	size := len(data)
+	if binary.BigEndian.Uint16(data[24:]) != PageTypeIndex {
+		return false
+	}
+	if !bytes.Equal(data[pageNewInfimum:pageNewInfimum+8], infimumData) ||
+		!bytes.Equal(data[pageNewSupremum:pageNewSupremum+8], supremumData) ||
+		data[pageNewInfimum-3]&0x07 != RecorderTypeInfimum ||
+		data[pageNewSupremum-3]&0x07 != RecorderTypeSupremum {
+		return false
+	}
+
+	ph := data[pageHeaderOffset:]
+	nDirSlots := int(binary.BigEndian.Uint16(ph[0:]))
+	heapTop := int(binary.BigEndian.Uint16(ph[2:]))
+	nHeap := binary.BigEndian.Uint16(ph[4:])
+	nRecs := int(binary.BigEndian.Uint16(ph[16:]))
+	level := int(binary.BigEndian.Uint16(ph[26:]))
+	if nHeap&0x8000 == 0 {
+		// REDUNDANT page
+		return false
+	}
+	dirStart := size - 8 - 2*nDirSlots
+	if nDirSlots < 2 || dirStart < pageNewSupremumEnd ||
+		heapTop < pageNewSupremumEnd || heapTop > dirStart ||
+		int(nHeap&0x7fff) < 2 || nRecs > int(nHeap&0x7fff)-2 || level > btreeMaxLevel {
+		return false
+	}
+	if binary.BigEndian.Uint16(data[size-10:]) != pageNewInfimum ||
+		binary.BigEndian.Uint16(data[dirStart:]) != pageNewSupremum {
+		return false
+	}
+	for i := dirStart; i < size-8; i += 2 {
+		slot := int(binary.BigEndian.Uint16(data[i:]))
+		if slot < pageNewInfimum || slot >= heapTop {
+			return false
+		}
+	}
+
+	origin := pageNewInfimum
+	for n := 0; origin != pageNewSupremum; n++ {
+		if n > nRecs {
+			return false
+		}
+		next := int(binary.BigEndian.Uint16(data[origin-2:]))
+		origin = (origin + next) & (size - 1)
+		if origin != pageNewSupremum && (origin < pageNewSupremumEnd+5 || origin >= heapTop) {
+			return false
+		}
+	}
+	return true
+}
+
+// CarveIndexPages scans the file for the COMPACT index pages ignoring the file
+// space structure. The page aligned blocks are checked, and with unaligned, every
+// offset of the infimum is checked for the raw disk images
+func CarveIndexPages(f io.ReaderAt, size int, unaligned bool, fn func(offset int64, data []byte) error) error {
+	if !unaligned {
+		return iteratePages(f, &ParsePageOptions{
+			PageSize:   size,
+			HeaderOnly: true,
+		}, func(page *Page, data []byte) error {
+			if !looksLikeIndexPage(data) {
+				return nil
+			}
+			return fn(int64(page.offset), data)
+		})
+	}
+
+	data := make([]byte, size)
+	const chunkSize = 4 << 20
+	buf := make([]byte, chunkSize+len(infimumData)-1)
+	for pos := int64(0); ; pos += chunkSize {
+		n, err := f.ReadAt(buf, pos)
+		if nil != err && io.EOF != err {
+			return err
+		}
+		for i := 0; i < n; {
+			j := bytes.Index(buf[i:n], infimumData)
+			if j < 0 {
+				break
+			}
+			k := i + j
+			i = k + 1
+			if k >= chunkSize {
+				// Found again in the next chunk
+				break
+			}
+			offset := pos + int64(k) - pageNewInfimum
+			if offset < 0 {
+				continue
+			}
+			if _, err := f.ReadAt(data, offset); nil != err {
+				continue
+			}
+			if !looksLikeIndexPage(data) {
+				continue
+			}
+			if err := fn(offset, data); nil != err {
+				return err
+			}
+		}
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/pkg/errors"
+)
+
+// Page checksum algorithms, reference to buf0checksum.cc
+const (
+	ChecksumAlgoCrc32 = iota
+	ChecksumAlgoCrc32LegacyBigEndian
+	ChecksumAlgoInnodb
+	ChecksumAlgoNone
+	ChecksumAlgoFullCrc32
+)
+
+var ChecksumAlgoStrs = []string{
+	"crc32",
+	"crc32(legacy big endian)",
+	"innodb",
+	"none",
+	"full_crc32",
+}
+
+func ChecksumAlgoToString(algo int) string {
+	if algo < 0 || algo >= len(ChecksumAlgoStrs) {
+		return "corrupt"
+	}
+	return ChecksumAlgoStrs[algo]
+}
+
+// Magic checksum value written with innodb_checksum_algorithm=none
+const checksumMagicNone = 0xDEADBEEF
+
+// Checksum ranges, the checksum field (0-4), the flush lsn and space id
+// (26-38) are excluded, and the trailer (last 8 bytes)
+const (
+	checksumFieldOffset    = 4
+	checksumFlushLSNOffset = 26
+	checksumDataOffset     = 38
+	checksumTrailerSize    = 8
+)
+
+// ut_fold_binary masks
+const (
+	utHashRandomMask  = 1463735687
+	utHashRandomMask2 = 1653893711
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func utFoldUlintPair(n1, n2 uint64) uint64 {
+	return (((((n1 ^ n2 ^ utHashRandomMask2) << 8) + n1) ^ utHashRandomMask) + n2)
+}
+
+func utFoldBinary(data []byte) uint64 {
+	var fold uint64
+	for _, b := range data {
+		fold = utFoldUlintPair(fold, uint64(b))
+	}
+	return fold
+}
+
+// crc32LegacyBigEndian calculates the crc32 written by the old mysql crc32 implementation
+// on big endian machines, every 8 bytes aligned (to the page start) word is processed in the
+// reversed byte order
+func crc32LegacyBigEndian(crc uint32, data []byte, pageOffset int) uint32 {
+	var word [8]byte
+	i := 0
+	// Byte by byte until 8 bytes aligned
+	for ; i < len(data) && (pageOffset+i)%8 != 0; i++ {
+		crc = crc32.Update(crc, crc32cTable, data[i:i+1])
+	}
+	for ; i+8 <= len(data); i += 8 {
+		for j := 0; j < 8; j++ {
+			word[j] = data[i+7-j]
+		}
+		crc = crc32.Update(crc, crc32cTable, word[:])
+	}
+	return crc32.Update(crc, crc32cTable, data[i:])
+}
+
+// calcPageCrc32 calculates the crc32 checksum stored in both the header and the trailer
+func calcPageCrc32(data []byte, legacyBigEndian bool) uint32 {
+	header := data[checksumFieldOffset:checksumFlushLSNOffset]
+	body := data[checksumDataOffset : len(data)-checksumTrailerSize]
+	if legacyBigEndian {
+		return crc32LegacyBigEndian(0, header, checksumFieldOffset) ^
+			crc32LegacyBigEndian(0, body, checksumDataOffset)
+	}
+	return crc32.Checksum(header, crc32cTable) ^ crc32.Checksum(body, crc32cTable)
+}
+
+// calcPageInnodbChecksum calculates the innodb checksum stored in the header
+func calcPageInnodbChecksum(data []byte) uint32 {
+	checksum := utFoldBinary(data[checksumFieldOffset:checksumFlushLSNOffset]) +
+		utFoldBinary(data[checksumDataOffset:len(data)-checksumTrailerSize])
+	return uint32(checksum & 0xffffffff)
+}
+
+// calcPageOldInnodbChecksum calculates the innodb checksum stored in the trailer
+func calcPageOldInnodbChecksum(data []byte) uint32 {
+	return uint32(utFoldBinary(data[0:checksumFlushLSNOffset]) & 0xffffffff)
+}
+
+// calcPageFullCrc32 calculates the MariaDB full_crc32 checksum stored in the last 4 bytes
+func calcPageFullCrc32(data []byte) uint32 {
+	return crc32.Checksum(data[:len(data)-4], crc32cTable)
+}
+
+// IsFullCrc32 checks the MariaDB full_crc32 marker in the fsp flags
+func IsFullCrc32(flags uint32) bool {
+	return flags&fspFlagsFcrc32Marker != 0
+}
+
+func IsEmptyPage(data []byte) bool {
+	for _, b := range data {
+		if 0 != b {
+			return false
+		}
+	}
+	return true
+}
+
+type PageChecksumResult struct {
+	// Matched algorithm, -1 if the page is corrupt
+	Algo  int
+	Empty bool
+	// Stored checksums
+	HeaderChecksum  uint32
+	TrailerChecksum uint32
+	// Calculated checksums
+	Crc32          uint32
+	Crc32BigEndian uint32
+	Innodb         uint32
+	InnodbOld      uint32
+	FullCrc32      uint32
+	// Low 32 bits of the lsn in the trailer
+	TrailerLSN uint32
+	LSNMatch   bool
+}
+
+func (r *PageChecksumResult) Corrupt() bool {
+	return r.Algo < 0 || !r.LSNMatch
+}
+
+// VerifyPageChecksum checks the page with every checksum algorithm, full_crc32 table
+// space pages have different trailer layout, so only full_crc32 is checked for them
+func VerifyPageChecksum(data []byte, fullCrc32 bool) *PageChecksumResult {
+	r := &PageChecksumResult{
+		Algo: -1,
+	}
+	size := len(data)
+	lsn := binary.BigEndian.Uint64(data[16:])
+
+	if IsEmptyPage(data) {
+		r.Empty = true
+		r.Algo = ChecksumAlgoNone
+		r.LSNMatch = true
+		return r
+	}
+
+	if fullCrc32 {
+		r.TrailerChecksum = binary.BigEndian.Uint32(data[size-4:])
+		r.TrailerLSN = binary.BigEndian.Uint32(data[size-8:])
+		r.FullCrc32 = calcPageFullCrc32(data)
+		if r.FullCrc32 == r.TrailerChecksum {
+			r.Algo = ChecksumAlgoFullCrc32
+		}
+		r.LSNMatch = r.TrailerLSN == uint32(lsn)
+		return r
+	}
+
+	r.HeaderChecksum = binary.BigEndian.Uint32(data[0:])
+	r.TrailerChecksum = binary.BigEndian.Uint32(data[size-8:])
+	r.TrailerLSN = binary.BigEndian.Uint32(data[size-4:])
+	r.LSNMatch = r.TrailerLSN == uint32(lsn)
+	r.Crc32 = calcPageCrc32(data, false)
+	r.Crc32BigEndian = calcPageCrc32(data, true)
+	r.Innodb = calcPageInnodbChecksum(data)
+	r.InnodbOld = calcPageOldInnodbChecksum(data)
+
+	if r.HeaderChecksum == checksumMagicNone &&
+		r.TrailerChecksum == checksumMagicNone {
+		r.Algo = ChecksumAlgoNone
+	} else if r.HeaderChecksum == r.Crc32 &&
+		r.TrailerChecksum == r.Crc32 {
+		r.Algo = ChecksumAlgoCrc32
+	} else if r.HeaderChecksum == r.Crc32BigEndian &&
+		r.TrailerChecksum == r.Crc32BigEndian {
+		r.Algo = ChecksumAlgoCrc32LegacyBigEndian
+	} else if (r.HeaderChecksum == r.Innodb || 0 == r.HeaderChecksum) &&
+		(r.TrailerChecksum == r.InnodbOld || r.TrailerChecksum == uint32(lsn>>32)) {
+		// Versions < 4.0.14 stored zero in the header and the high 32 bits of
+		// the lsn in the trailer checksum field
+		r.Algo = ChecksumAlgoInnodb
+	}
+
+	return r
+}
+
+func ChecksumAlgoFromString(s string) (int, error) {
+	switch s {
+	case "crc32":
+		{
+			return ChecksumAlgoCrc32, nil
+		}
+	case "innodb":
+		{
+			return ChecksumAlgoInnodb, nil
+		}
+	case "none":
+		{
+			return ChecksumAlgoNone, nil
+		}
+	case "full_crc32":
+		{
+			return ChecksumAlgoFullCrc32, nil
+		}
+	}
+	return -1, errors.Errorf("Unsupported checksum algorithm %s", s)
+}
+
+// WritePageChecksum calculates the checksum with the algorithm and writes it into
+// the header and the trailer of the page data
+func WritePageChecksum(data []byte, algo int) error {
+	size := len(data)
+
+	switch algo {
+	case ChecksumAlgoCrc32:
+		{
+			checksum := calcPageCrc32(data, false)
+			binary.BigEndian.PutUint32(data[0:], checksum)
+			binary.BigEndian.PutUint32(data[size-8:], checksum)
+		}
+	case ChecksumAlgoInnodb:
+		{
+			// The old checksum covers the header checksum field, so calculate it
+			// after the new checksum is written
+			binary.BigEndian.PutUint32(data[0:], calcPageInnodbChecksum(data))
+			binary.BigEndian.PutUint32(data[size-8:], calcPageOldInnodbChecksum(data))
+		}
+	case ChecksumAlgoNone:
+		{
+			binary.BigEndian.PutUint32(data[0:], checksumMagicNone)
+			binary.BigEndian.PutUint32(data[size-8:], checksumMagicNone)
+		}
+	case ChecksumAlgoFullCrc32:
+		{
+			binary.BigEndian.PutUint32(data[size-4:], calcPageFullCrc32(data))
+		}
+	default:
+		{
+			return errors.Errorf("Unsupported checksum algorithm %d", algo)
+		}
+	}
+
+	return nil
+}
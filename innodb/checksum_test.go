@@ -0,0 +1,128 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// newTestChecksumPage returns the index page 5 of space 9 at lsn 0xA12345678,
+// the low 32 bits of the lsn are in the trailer
+func newTestChecksumPage(size int, fullCrc32 bool) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*13 + i>>9)
+	}
+	binary.BigEndian.PutUint32(data[4:], 5)
+	binary.BigEndian.PutUint64(data[16:], 0xA12345678)
+	binary.BigEndian.PutUint16(data[24:], PageTypeIndex)
+	binary.BigEndian.PutUint32(data[34:], 9)
+	if fullCrc32 {
+		binary.BigEndian.PutUint32(data[size-8:], 0x12345678)
+	} else {
+		binary.BigEndian.PutUint32(data[size-4:], 0x12345678)
+	}
+	return data
+}
+
+func TestPageChecksumKnownAnswer(t *testing.T) {
+	if v := crc32.Checksum([]byte("123456789"), crc32cTable); 0xE3069283 != v {
+		t.Fatalf("crc32c check value is 0x%08X", v)
+	}
+
+	// The checksums calculated by the bitwise crc32c and ut_fold_binary of
+	// buf0checksum.cc
+	cases := []struct {
+		size      int
+		crc32     uint32
+		legacy    uint32
+		innodb    uint32
+		innodbOld uint32
+		fullCrc32 uint32
+	}{
+		{4096, 0xF382B7F3, 0xA2327159, 0x14C6BAA6, 0xAC490A1F, 0x00B1F2F0},
+		{16384, 0xB8B55758, 0xECFCB9B9, 0x29D8E1E6, 0x75D77EB5, 0x09EC38AC},
+	}
+	for _, c := range cases {
+		data := newTestChecksumPage(c.size, false)
+		r := VerifyPageChecksum(data, false)
+		if !r.Corrupt() || c.crc32 != r.Crc32 || c.legacy != r.Crc32BigEndian || c.innodb != r.Innodb {
+			t.Fatalf("checksums of %d bytes page are %+v", c.size, r)
+		}
+
+		if err := WritePageChecksum(data, ChecksumAlgoCrc32); nil != err {
+			t.Fatal(err)
+		}
+		if c.crc32 != binary.BigEndian.Uint32(data) || c.crc32 != binary.BigEndian.Uint32(data[c.size-8:]) {
+			t.Fatalf("crc32 of %d bytes page is 0x%08X", c.size, binary.BigEndian.Uint32(data))
+		}
+		if r := VerifyPageChecksum(data, false); ChecksumAlgoCrc32 != r.Algo || r.Corrupt() {
+			t.Fatalf("crc32 page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+
+		binary.BigEndian.PutUint32(data, c.legacy)
+		binary.BigEndian.PutUint32(data[c.size-8:], c.legacy)
+		if r := VerifyPageChecksum(data, false); ChecksumAlgoCrc32LegacyBigEndian != r.Algo {
+			t.Fatalf("legacy crc32 page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+
+		if err := WritePageChecksum(data, ChecksumAlgoInnodb); nil != err {
+			t.Fatal(err)
+		}
+		if c.innodb != binary.BigEndian.Uint32(data) || c.innodbOld != binary.BigEndian.Uint32(data[c.size-8:]) {
+			t.Fatalf("innodb checksums of %d bytes page are 0x%08X 0x%08X", c.size,
+				binary.BigEndian.Uint32(data), binary.BigEndian.Uint32(data[c.size-8:]))
+		}
+		if r := VerifyPageChecksum(data, false); ChecksumAlgoInnodb != r.Algo || c.innodbOld != r.InnodbOld {
+			t.Fatalf("innodb page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+		// Before 4.0.14 the header is zero and the trailer is the high 32 bits
+		// of the lsn
+		binary.BigEndian.PutUint32(data, 0)
+		binary.BigEndian.PutUint32(data[c.size-8:], 0xA)
+		if r := VerifyPageChecksum(data, false); ChecksumAlgoInnodb != r.Algo {
+			t.Fatalf("old innodb page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+
+		if err := WritePageChecksum(data, ChecksumAlgoNone); nil != err {
+			t.Fatal(err)
+		}
+		if r := VerifyPageChecksum(data, false); ChecksumAlgoNone != r.Algo || 0xDEADBEEF != r.HeaderChecksum {
+			t.Fatalf("none page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+
+		// The torn page
+		WritePageChecksum(data, ChecksumAlgoCrc32)
+		data[c.size/2] ^= 1
+		if r := VerifyPageChecksum(data, false); !r.Corrupt() {
+			t.Fatalf("corrupt page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+		data[c.size/2] ^= 1
+		binary.BigEndian.PutUint32(data[c.size-4:], 0x12345679)
+		if r := VerifyPageChecksum(data, false); ChecksumAlgoCrc32 != r.Algo || !r.Corrupt() {
+			t.Fatal("page of the different trailer lsn is not corrupt")
+		}
+
+		data = newTestChecksumPage(c.size, true)
+		if err := WritePageChecksum(data, ChecksumAlgoFullCrc32); nil != err {
+			t.Fatal(err)
+		}
+		if c.fullCrc32 != binary.BigEndian.Uint32(data[c.size-4:]) {
+			t.Fatalf("full_crc32 of %d bytes page is 0x%08X", c.size, binary.BigEndian.Uint32(data[c.size-4:]))
+		}
+		if r := VerifyPageChecksum(data, true); ChecksumAlgoFullCrc32 != r.Algo || r.Corrupt() {
+			t.Fatalf("full_crc32 page is verified as %s", ChecksumAlgoToString(r.Algo))
+		}
+		data[100] ^= 1
+		if r := VerifyPageChecksum(data, true); !r.Corrupt() {
+			t.Fatal("corrupt full_crc32 page is verified")
+		}
+	}
+
+	if r := VerifyPageChecksum(make([]byte, 16384), false); !r.Empty || r.Corrupt() {
+		t.Fatal("empty page is corrupt")
+	}
+	if err := WritePageChecksum(make([]byte, 16384), ChecksumAlgoCrc32LegacyBigEndian); nil == err {
+		t.Fatal("legacy crc32 is written")
+	}
+}
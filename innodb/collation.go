@@ -0,0 +1,410 @@
+package innodb
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+)
+
+// collationRange maps the collation ids to the charset, the ids of a charset
+// are not always continuous
+type collationRange struct {
+	first   int
+	last    int
+	charset string
+}
+
+var collationRanges = []collationRange{
+	{1, 1, "big5"},
+	{8, 8, "latin1"},
+	{11, 11, "ascii"},
+	{12, 12, "ujis"},
+	{13, 13, "sjis"},
+	{15, 15, "latin1"},
+	{19, 19, "euckr"},
+	{24, 24, "gb2312"},
+	{28, 28, "gbk"},
+	{31, 31, "latin1"},
+	{33, 33, "utf8"},
+	{35, 35, "ucs2"},
+	{45, 46, "utf8mb4"},
+	{47, 49, "latin1"},
+	{54, 55, "utf16"},
+	{56, 56, "utf16le"},
+	{60, 61, "utf32"},
+	{62, 62, "utf16le"},
+	{63, 63, "binary"},
+	{65, 65, "ascii"},
+	{76, 76, "utf8"},
+	{83, 83, "utf8"},
+	{84, 84, "big5"},
+	{85, 85, "euckr"},
+	{86, 86, "gb2312"},
+	{87, 87, "gbk"},
+	{88, 88, "sjis"},
+	{90, 90, "ucs2"},
+	{91, 91, "ujis"},
+	{94, 94, "latin1"},
+	{95, 96, "cp932"},
+	{97, 98, "eucjpms"},
+	{101, 124, "utf16"},
+	{128, 151, "ucs2"},
+	{159, 159, "ucs2"},
+	{160, 183, "utf32"},
+	{192, 215, "utf8"},
+	{223, 223, "utf8"},
+	{224, 247, "utf8mb4"},
+	{248, 250, "gb18030"},
+	{255, 323, "utf8mb4"},
+}
+
+// collationCharset returns the charset of the mysql collation id, the collation
+// id is stored in the data dictionary, returns empty string if unknown
+func collationCharset(id int) string {
+	for _, r := range collationRanges {
+		if id >= r.first && id <= r.last {
+			return r.charset
+		}
+	}
+	return ""
+}
+
+// Names of the common collation ids, the other collations are treated as the
+// default collation of the charset
+var collationNames = map[int]string{
+	8:   "latin1_swedish_ci",
+	11:  "ascii_general_ci",
+	28:  "gbk_chinese_ci",
+	33:  "utf8_general_ci",
+	45:  "utf8mb4_general_ci",
+	46:  "utf8mb4_bin",
+	47:  "latin1_bin",
+	48:  "latin1_general_ci",
+	49:  "latin1_general_cs",
+	63:  "binary",
+	65:  "ascii_bin",
+	83:  "utf8_bin",
+	87:  "gbk_bin",
+	192: "utf8_unicode_ci",
+	224: "utf8mb4_unicode_ci",
+	246: "utf8mb4_unicode_520_ci",
+	255: "utf8mb4_0900_ai_ci",
+	278: "utf8mb4_0900_as_cs",
+	305: "utf8mb4_0900_as_ci",
+	309: "utf8mb4_0900_bin",
+}
+
+// collationName returns the collation name of the mysql collation id
+func collationName(id int) string {
+	if name, ok := collationNames[id]; ok {
+		return name
+	}
+	if charset := collationCharset(id); "" != charset {
+		return defaultCollation(charset)
+	}
+	return ""
+}
+
+// defaultCollation returns the default collation of the charset, same as mysql 8.0
+func defaultCollation(charset string) string {
+	switch charset {
+	case "binary":
+		{
+			return "binary"
+		}
+	case "utf8mb4":
+		{
+			return "utf8mb4_0900_ai_ci"
+		}
+	case "utf8", "utf8mb3":
+		{
+			return "utf8_general_ci"
+		}
+	case "latin1":
+		{
+			return "latin1_swedish_ci"
+		}
+	case "gbk", "gb2312", "gb18030", "big5":
+		{
+			return charset + "_chinese_ci"
+		}
+	case "sjis", "ujis", "cp932", "eucjpms":
+		{
+			return charset + "_japanese_ci"
+		}
+	case "euckr":
+		{
+			return "euckr_korean_ci"
+		}
+	}
+	return charset + "_general_ci"
+}
+
+// collationRule is how the strings are compared in a collation. The *_general_ci
+// collations compare the characters after removing the accent and converting to
+// upper case, latin1_swedish_ci compares the bytes in the sort order, and the
+// utf8mb4_0900 collations compare the UCA weights. Other collations are not
+// supported since the order is different, e.g. utf8mb4_unicode_ci and
+// utf8mb4_unicode_520_ci use the weights of UCA 4.0.0 and 5.2.0
+type collationRule struct {
+	binary            bool
+	padSpace          bool
+	caseInsensitive   bool
+	accentInsensitive bool
+	// Supplementary characters are all equal in the *_general_ci collations
+	bmpOnly bool
+	// Weights of the latin1 bytes
+	sortOrder []byte
+	// Levels of the UCA weights to compare, 0 if not the UCA collation
+	ucaLevels int
+}
+
+// sortOrderLatin1Swedish is the sort order of latin1_swedish_ci, the letters are
+// case insensitive, and Å, Ä and Ö are after Z like the swedish alphabet
+var sortOrderLatin1Swedish = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F,
+	0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F,
+	0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F,
+	0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F,
+	0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
+	0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
+	0x60, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
+	0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
+	0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89, 0x8A, 0x8B, 0x8C, 0x8D, 0x8E, 0x8F,
+	0x90, 0x91, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9A, 0x9B, 0x9C, 0x9D, 0x9E, 0x9F,
+	0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5, 0xA6, 0xA7, 0xA8, 0xA9, 0xAA, 0xAB, 0xAC, 0xAD, 0xAE, 0xAF,
+	0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7, 0xB8, 0xB9, 0xBA, 0xBB, 0xBC, 0xBD, 0xBE, 0xBF,
+	0x41, 0x41, 0x41, 0x41, 0x5C, 0x5B, 0x5C, 0x43, 0x45, 0x45, 0x45, 0x45, 0x49, 0x49, 0x49, 0x49,
+	0x44, 0x4E, 0x4F, 0x4F, 0x4F, 0x4F, 0x5D, 0xD7, 0xD8, 0x55, 0x55, 0x55, 0x59, 0x59, 0xDE, 0xDF,
+	0x41, 0x41, 0x41, 0x41, 0x5C, 0x5B, 0x5C, 0x43, 0x45, 0x45, 0x45, 0x45, 0x49, 0x49, 0x49, 0x49,
+	0x44, 0x4E, 0x4F, 0x4F, 0x4F, 0x4F, 0x5D, 0xF7, 0xD8, 0x55, 0x55, 0x55, 0x59, 0x59, 0xDE, 0xFF,
+}
+
+func getCollationRule(name string) (collationRule, error) {
+	var rule collationRule
+	if "binary" == name {
+		rule.binary = true
+		return rule, nil
+	}
+	// The 0900 collations of mysql 8.0 are NO PAD, others are PAD SPACE
+	rule.padSpace = !strings.Contains(name, "_0900_")
+	switch {
+	case strings.HasSuffix(name, "_bin"):
+		{
+			rule.binary = true
+		}
+	case "latin1_swedish_ci" == name:
+		{
+			rule.sortOrder = sortOrderLatin1Swedish
+		}
+	case strings.HasSuffix(name, "_general_ci"):
+		{
+			rule.caseInsensitive = true
+			rule.accentInsensitive = true
+			rule.bmpOnly = true
+		}
+	case "utf8mb4_0900_ai_ci" == name:
+		{
+			rule.ucaLevels = 1
+		}
+	case "utf8mb4_0900_as_ci" == name:
+		{
+			rule.ucaLevels = 2
+		}
+	case "utf8mb4_0900_as_cs" == name:
+		{
+			rule.ucaLevels = 3
+		}
+	default:
+		{
+			return rule, errors.Errorf("Collation %s is not supported", name)
+		}
+	}
+	return rule, nil
+}
+
+// ucaElement is the primary, secondary and tertiary weight of the character
+type ucaElement [3]uint16
+
+// ucaImplicitElements returns the implicit weights of UCA 9.0.0 of the character
+// not in the table. The CJK ideographs are in the code point order after the
+// characters of the table, and the unassigned characters are after them
+func ucaImplicitElements(r rune) []ucaElement {
+	if r >= 0x17000 && r <= 0x18AFF {
+		// Tangut
+		return []ucaElement{{0xFB00, 0x0020, 0x0002}, {uint16(r-0x17000) | 0x8000, 0, 0}}
+	}
+	base := rune(0xFBC0)
+	switch {
+	case (r >= 0x4E00 && r <= 0x9FD5) || (r >= 0xFA0E && r <= 0xFA29):
+		{
+			// CJK Unified Ideographs and the unified ones of CJK Compatibility
+			// Ideographs, the others are in the table
+			base = 0xFB40
+		}
+	case (r >= 0x3400 && r <= 0x4DB5) || (r >= 0x20000 && r <= 0x2A6D6) ||
+		(r >= 0x2A700 && r <= 0x2B734) || (r >= 0x2B740 && r <= 0x2B81D) ||
+		(r >= 0x2B820 && r <= 0x2CEA1):
+		{
+			// Extension A to E
+			base = 0xFB80
+		}
+	}
+	return []ucaElement{{uint16(base + r>>15), 0x0020, 0x0002}, {uint16(r&0x7FFF) | 0x8000, 0, 0}}
+}
+
+// ucaCharacterElements returns the weights of the character, the hangul syllable
+// is weighted as the jamos it is decomposed into
+func ucaCharacterElements(r rune) []ucaElement {
+	if es, ok := ucaElements[r]; ok {
+		return es
+	}
+	if r >= 0xAC00 && r <= 0xD7A3 {
+		s := r - 0xAC00
+		es := append(append([]ucaElement(nil), ucaElements[0x1100+s/588]...), ucaElements[0x1161+s%588/28]...)
+		if 0 != s%28 {
+			es = append(es, ucaElements[0x11A7+s%28]...)
+		}
+		return es
+	}
+	return ucaImplicitElements(r)
+}
+
+// ucaSortKey returns the UCA sort key of the string, the weights of every level
+// are separated by 0. The variable characters like spaces and punctuations are
+// not ignorable in mysql, they have the primary weights
+func (rule collationRule) ucaSortKey(rs []rune) ([]uint16, error) {
+	elements := make([]ucaElement, 0, len(rs))
+	for i := 0; i < len(rs); i++ {
+		if i+2 < len(rs) {
+			if es, ok := ucaContractions[[3]rune{rs[i], rs[i+1], rs[i+2]}]; ok {
+				elements = append(elements, es...)
+				i += 2
+				continue
+			}
+		}
+		if i+1 < len(rs) {
+			if es, ok := ucaContractions[[3]rune{rs[i], rs[i+1]}]; ok {
+				elements = append(elements, es...)
+				i++
+				continue
+			}
+		}
+		if rs[i] > unicode.MaxRune || (rs[i] >= 0xD800 && rs[i] <= 0xDFFF) {
+			return nil, errors.Errorf("Invalid character U+%04X", rs[i])
+		}
+		elements = append(elements, ucaCharacterElements(rs[i])...)
+	}
+
+	key := make([]uint16, 0, (len(elements)+1)*rule.ucaLevels)
+	for level := 0; level < rule.ucaLevels; level++ {
+		if level > 0 {
+			key = append(key, 0)
+		}
+		for _, e := range elements {
+			if 0 != e[level] {
+				key = append(key, e[level])
+			}
+		}
+	}
+	return key, nil
+}
+
+// compareWeights compares the UCA sort keys, returns -1, 0 or 1
+func compareWeights(a []uint16, b []uint16) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// Base letters of U+00C0 - U+017F
+var accentFolding = []rune("AAAAAAACEEEEIIIIDNOOOOO×OUUUUYÞs" +
+	"aaaaaaaceeeeiiiidnooooo÷ouuuuyþy" +
+	"AaAaAaCcCcCcCcDdDdEeEeEeEeEeGgGgGgGgHhHhIiIiIiIiIiIiJjKkkLlLlLlLlLlNnNnNnnNnOoOoOoOoRrRrRrSsSsSsSsTtTtTtUuUuUuUuUuUuWwYyYZzZzZzs")
+
+func foldAccent(r rune) rune {
+	if r >= 0xc0 && r < 0xc0+rune(len(accentFolding)) {
+		return accentFolding[r-0xc0]
+	}
+	return r
+}
+
+func (rule collationRule) weight(r rune) rune {
+	if nil != rule.sortOrder && r < rune(len(rule.sortOrder)) {
+		return rune(rule.sortOrder[r])
+	}
+	if rule.bmpOnly && r > 0xffff {
+		return 0xfffd
+	}
+	if rule.accentInsensitive {
+		r = foldAccent(r)
+	}
+	if rule.caseInsensitive {
+		r = unicode.ToUpper(r)
+	}
+	return r
+}
+
+// decodeCharsetRunes decodes the string of the charset, utf8 and latin1 are
+// decoded to characters, other charsets are compared by bytes
+func decodeCharsetRunes(data []byte, charset string) []rune {
+	switch charset {
+	case "utf8", "utf8mb3", "utf8mb4":
+		{
+			return []rune(string(data))
+		}
+	}
+	rs := make([]rune, len(data))
+	for i, b := range data {
+		rs[i] = rune(b)
+	}
+	return rs
+}
+
+// compareCollated compares the strings in the collation, returns -1, 0 or 1
+func compareCollated(a []rune, b []rune, rule collationRule) int {
+	if rule.padSpace {
+		a = trimTrailingSpaces(a)
+		b = trimTrailingSpaces(b)
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		wa, wb := a[i], b[i]
+		if !rule.binary {
+			wa, wb = rule.weight(wa), rule.weight(wb)
+		}
+		if wa != wb {
+			if wa < wb {
+				return -1
+			}
+			return 1
+		}
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func trimTrailingSpaces(rs []rune) []rune {
+	n := len(rs)
+	for n > 0 && ' ' == rs[n-1] {
+		n--
+	}
+	return rs[:n]
+}
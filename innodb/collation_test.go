@@ -0,0 +1,144 @@
+package innodb
+
+import "testing"
+
+// compareCollationStrings compares the strings like the string key values
+func compareCollationStrings(t *testing.T, collation string, a string, b string) int {
+	rule, err := getCollationRule(collation)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 0 == rule.ucaLevels {
+		return compareCollated([]rune(a), []rune(b), rule)
+	}
+	wa, err := rule.ucaSortKey([]rune(a))
+	if nil != err {
+		t.Fatal(err)
+	}
+	wb, err := rule.ucaSortKey([]rune(b))
+	if nil != err {
+		t.Fatal(err)
+	}
+	return compareWeights(wa, wb)
+}
+
+func TestCollationOrder(t *testing.T) {
+	cases := []struct {
+		collation string
+		a         string
+		b         string
+		expect    int
+	}{
+		{"utf8mb4_0900_ai_ci", "a", "A", 0},
+		{"utf8mb4_0900_ai_ci", "a", "á", 0},
+		{"utf8mb4_0900_ai_ci", "a", "b", -1},
+		{"utf8mb4_0900_ai_ci", "ß", "ss", 0},
+		// NO PAD
+		{"utf8mb4_0900_ai_ci", "a", "a ", -1},
+		// The symbols are before the letters, and the letters are before the
+		// implicit weights
+		{"utf8mb4_0900_ai_ci", "😀", "a", -1},
+		{"utf8mb4_0900_ai_ci", "z", "𗀀", -1},
+		{"utf8mb4_0900_ai_ci", "𗀀", "一", -1},
+		{"utf8mb4_0900_ai_ci", "一", "丁", -1},
+		{"utf8mb4_0900_ai_ci", "鿕", "㐀", -1},
+		{"utf8mb4_0900_ai_ci", "㐀", "𠀀", -1},
+		{"utf8mb4_0900_ai_ci", "𠀀", "\u0378", -1},
+		// The hangul syllables are weighted as the jamos
+		{"utf8mb4_0900_ai_ci", "가", "\u1100\u1161", 0},
+		{"utf8mb4_0900_ai_ci", "가", "각", -1},
+		{"utf8mb4_0900_ai_ci", "각", "나", -1},
+		{"utf8mb4_0900_as_ci", "a", "A", 0},
+		{"utf8mb4_0900_as_ci", "a", "á", -1},
+		{"utf8mb4_0900_as_cs", "a", "A", -1},
+		{"utf8mb4_0900_as_cs", "A", "b", -1},
+		// PAD SPACE
+		{"utf8mb4_general_ci", "a", "A  ", 0},
+		{"utf8mb4_general_ci", "é", "E", 0},
+		{"utf8mb4_general_ci", "😀", "😁", 0},
+		{"utf8mb4_bin", "a", "a ", 0},
+		{"utf8mb4_bin", "A", "a", -1},
+		{"binary", "a", "a ", -1},
+		{"latin1_swedish_ci", "a", "A ", 0},
+		{"latin1_swedish_ci", "é", "E", 0},
+		{"latin1_swedish_ci", "Z", "Å", -1},
+		{"latin1_swedish_ci", "Å", "Ä", -1},
+		{"latin1_swedish_ci", "Ä", "Ö", -1},
+		{"latin1_swedish_ci", "Æ", "Ä", 0},
+		{"latin1_swedish_ci", "ü", "Y", 0},
+		{"latin1_swedish_ci", "Ö", "Ø", -1},
+	}
+	for _, c := range cases {
+		if v := compareCollationStrings(t, c.collation, c.a, c.b); c.expect != v {
+			t.Fatalf("%s compares %q and %q %d, expect %d", c.collation, c.a, c.b, v, c.expect)
+		}
+		if v := compareCollationStrings(t, c.collation, c.b, c.a); -c.expect != v {
+			t.Fatalf("%s compares %q and %q %d, expect %d", c.collation, c.b, c.a, v, -c.expect)
+		}
+	}
+
+	for _, collation := range []string{"utf8mb4_unicode_ci", "utf8mb4_unicode_520_ci", "latin1_german2_ci"} {
+		if _, err := getCollationRule(collation); nil == err {
+			t.Fatalf("collation %s is supported", collation)
+		}
+	}
+}
+
+func TestUCASortKey(t *testing.T) {
+	cases := []struct {
+		collation string
+		s         string
+		expect    []uint16
+	}{
+		{"utf8mb4_0900_ai_ci", "a", []uint16{0x1FA2}},
+		{"utf8mb4_0900_ai_ci", "一", []uint16{0xFB40, 0xCE00}},
+		{"utf8mb4_0900_ai_ci", "㐀", []uint16{0xFB80, 0xB400}},
+		{"utf8mb4_0900_ai_ci", "𠀀", []uint16{0xFB84, 0x8000}},
+		{"utf8mb4_0900_ai_ci", "𗀀", []uint16{0xFB00, 0x8000}},
+		{"utf8mb4_0900_ai_ci", "\u0378", []uint16{0xFBC0, 0x8378}},
+		// The contraction of 3 characters
+		{"utf8mb4_0900_ai_ci", "\u0CC6\u0CC2\u0CD5", []uint16{0x2C01}},
+		{"utf8mb4_0900_as_ci", "á", []uint16{0x1FA2, 0, 0x0020, 0x0024}},
+		{"utf8mb4_0900_as_cs", "Ab", []uint16{0x1FA2, 0x1FBC, 0, 0x0020, 0x0020, 0, 0x0008, 0x0002}},
+		{"utf8mb4_0900_as_cs", "一", []uint16{0xFB40, 0xCE00, 0, 0x0020, 0, 0x0002}},
+	}
+	for _, c := range cases {
+		rule, _ := getCollationRule(c.collation)
+		key, err := rule.ucaSortKey([]rune(c.s))
+		if nil != err {
+			t.Fatal(err)
+		}
+		if 0 != compareWeights(c.expect, key) {
+			t.Fatalf("%s sort key of %q is %04X, expect %04X", c.collation, c.s, key, c.expect)
+		}
+	}
+}
+
+func TestCompareKeyValueCollation(t *testing.T) {
+	cases := []struct {
+		column *Column
+		data   string
+		key    string
+		expect int
+	}{
+		{&Column{Type: ColumnTypeVarchar, Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci"}, "Café", "cafe", 0},
+		{&Column{Type: ColumnTypeVarchar, Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci"}, "中文", "中", 1},
+		{&Column{Type: ColumnTypeVarchar, Charset: "utf8mb4", Collation: "utf8mb4_0900_as_cs"}, "Café", "cafe", 1},
+		{&Column{Type: ColumnTypeChar, Charset: "utf8mb4", Collation: "utf8mb4_general_ci"}, "tom  ", "TOM", 0},
+		{&Column{Type: ColumnTypeChar, Charset: "latin1", Collation: "latin1_swedish_ci"}, "\xc5sa  ", "åsa", 0},
+		{&Column{Type: ColumnTypeVarchar, Charset: "latin1", Collation: "latin1_swedish_ci"}, "\xc5sa", "Zorro", 1},
+	}
+	for _, c := range cases {
+		a, err := DecodeKeyValue(&RecordField{Column: c.column, Data: []byte(c.data)})
+		if nil != err {
+			t.Fatal(err)
+		}
+		b, err := parseKeyValue(c.column, c.key)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if v := compareKeyValue(c.column, a, b); c.expect != v {
+			t.Fatalf("%s compares %q and %q %d, expect %d", c.column.Collation, c.data, c.key, v, c.expect)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package innodb
+
+import "fmt"
+
+const (
+	// B+ tree leaf node (store data)
+	PageTypeIndex        = 0x45BF
+	PageTypeAllocated    = 0x0000
+	PageTypeUndoLog      = 0x0002
+	PageTypeINode        = 0x0003
+	PageTypeIBufFreeList = 0x0004
+	PageTypeIBufBitmap   = 0x0005
+	PageTypeSys          = 0x0006
+	PageTypeTrxSys       = 0x0007
+	PageTypeFspHDR       = 0x0008
+	PageTypeXdes         = 0x0009
+	PageTypeBlob         = 0x000a
+	// Compressed BLOB pages of ROW_FORMAT=COMPRESSED
+	PageTypeZBlob  = 0x000b
+	PageTypeZBlob2 = 0x000c
+	// Serialized dictionary information of mysql 8.0
+	PageTypeSDI      = 0x45BD
+	PageTypeSDIBlob  = 0x0012
+	PageTypeSDIZBlob = 0x0013
+	// Large object pages of mysql 8.0
+	PageTypeLobIndex      = 0x0016
+	PageTypeLobData       = 0x0017
+	PageTypeLobFirst      = 0x0018
+	PageTypeZLobFirst     = 0x0019
+	PageTypeZLobData      = 0x001a
+	PageTypeZLobIndex     = 0x001b
+	PageTypeZLobFrag      = 0x001c
+	PageTypeZLobFragEntry = 0x001d
+	// Page compressed pages of COMPRESSION='zlib' or 'lz4' of mysql
+	PageTypeCompressed          = 0x000e
+	PageTypeEncrypted           = 0x000f
+	PageTypeCompressedEncrypted = 0x0010
+	// Page compressed pages of PAGE_COMPRESSED=1 of MariaDB
+	PageTypePageCompressed          = 0x8632
+	PageTypePageCompressedEncrypted = 0x9219
+)
+
+// Recorder type
+const (
+	RecorderTypeConventional = 0x00
+	RecorderTypeBTreeNode    = 0x01
+	RecorderTypeInfimum      = 0x02
+	RecorderTypeSupremum     = 0x03
+)
+
+// Recorder format
+const (
+	recorderFormatCompact = iota
+	recorderFormatRedundant
+)
+
+var pageTypeStrs = []string{
+	"Index",
+	"Allocated",
+	"Undo log",
+	"File segment inode",
+	"Insert buffer free list",
+	"Insert Buffer bit map",
+	"Sys",
+	"Trx sys",
+	"File space header",
+	"Xdes",
+	"Blob",
+	"SDI",
+	"SDI blob",
+	"SDI compressed blob",
+	"Compressed blob",
+	"Compressed blob2",
+	"LOB index",
+	"LOB data",
+	"LOB first",
+	"Compressed LOB first",
+	"Compressed LOB data",
+	"Compressed LOB index",
+	"Compressed LOB fragment",
+	"Compressed LOB fragment entry",
+	"Page compressed",
+	"Encrypted",
+	"Page compressed encrypted",
+	"MariaDB page compressed",
+	"MariaDB page compressed encrypted",
+}
+
+const (
+	XdesPageStateFree = 0x01
+)
+
+func PageTypeToString(typ int) string {
+	idx := -1
+
+	switch typ {
+	case PageTypeIndex:
+		{
+			idx = 0
+		}
+	case PageTypeAllocated:
+		{
+			idx = 1
+		}
+	case PageTypeUndoLog:
+		{
+			idx = 2
+		}
+	case PageTypeINode:
+		{
+			idx = 3
+		}
+	case PageTypeIBufFreeList:
+		{
+			idx = 4
+		}
+	case PageTypeIBufBitmap:
+		{
+			idx = 5
+		}
+	case PageTypeSys:
+		{
+			idx = 6
+		}
+	case PageTypeTrxSys:
+		{
+			idx = 7
+		}
+	case PageTypeFspHDR:
+		{
+			idx = 8
+		}
+	case PageTypeXdes:
+		{
+			idx = 9
+		}
+	case PageTypeBlob:
+		{
+			idx = 10
+		}
+	case PageTypeSDI:
+		{
+			idx = 11
+		}
+	case PageTypeSDIBlob:
+		{
+			idx = 12
+		}
+	case PageTypeSDIZBlob:
+		{
+			idx = 13
+		}
+	case PageTypeZBlob:
+		{
+			idx = 14
+		}
+	case PageTypeZBlob2:
+		{
+			idx = 15
+		}
+	case PageTypeLobIndex:
+		{
+			idx = 16
+		}
+	case PageTypeLobData:
+		{
+			idx = 17
+		}
+	case PageTypeLobFirst:
+		{
+			idx = 18
+		}
+	case PageTypeZLobFirst:
+		{
+			idx = 19
+		}
+	case PageTypeZLobData:
+		{
+			idx = 20
+		}
+	case PageTypeZLobIndex:
+		{
+			idx = 21
+		}
+	case PageTypeZLobFrag:
+		{
+			idx = 22
+		}
+	case PageTypeZLobFragEntry:
+		{
+			idx = 23
+		}
+	case PageTypeCompressed:
+		{
+			idx = 24
+		}
+	case PageTypeEncrypted:
+		{
+			idx = 25
+		}
+	case PageTypeCompressedEncrypted:
+		{
+			idx = 26
+		}
+	case PageTypePageCompressed:
+		{
+			idx = 27
+		}
+	case PageTypePageCompressedEncrypted:
+		{
+			idx = 28
+		}
+	}
+
+	if idx < 0 {
+		return fmt.Sprintf("UNKNOWN(0x%04X)", typ)
+	}
+	return pageTypeStrs[idx]
+}
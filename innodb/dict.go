@@ -0,0 +1,377 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// The data dictionary header page of the system table space, the roots of the
+// SYS_* clustered indexes are stored in it
+const (
+	dictHeaderPageNo      = 7
+	dictHeaderOffset      = 38
+	dictHeaderRowID       = 0
+	dictHeaderTableID     = 8
+	dictHeaderIndexID     = 16
+	dictHeaderMaxSpaceID  = 24
+	dictHeaderTablesRoot  = 32
+	dictHeaderColumnsRoot = 40
+	dictHeaderIndexesRoot = 44
+	dictHeaderFieldsRoot  = 48
+)
+
+// Main types of the column (mtype)
+var dictMainTypeStrs = map[uint32]string{
+	1:  "VARCHAR",
+	2:  "CHAR",
+	3:  "FIXBINARY",
+	4:  "BINARY",
+	5:  "BLOB",
+	6:  "INT",
+	7:  "SYS_CHILD",
+	8:  "SYS",
+	9:  "FLOAT",
+	10: "DOUBLE",
+	11: "DECIMAL",
+	12: "VARMYSQL",
+	13: "MYSQL",
+	14: "GEOMETRY",
+	15: "POINT",
+	16: "VAR_POINT",
+}
+
+// Precise type flags of the column (prtype), the lowest byte is the mysql type,
+// and the charset collation id is in the highest 2 bytes
+const (
+	dictPrtypeNotNull  = 256
+	dictPrtypeUnsigned = 512
+	dictPrtypeBinary   = 1024
+	dictPrtypeVirtual  = 8192
+)
+
+// Index type flags
+const (
+	dictIndexClustered = 1
+	dictIndexUnique    = 2
+	dictIndexIbuf      = 8
+	dictIndexCorrupt   = 16
+	dictIndexFts       = 32
+	dictIndexSpatial   = 64
+	dictIndexVirtual   = 128
+)
+
+// The high bit of SYS_TABLES.N_COLS is set if the table is not REDUNDANT
+const DictNColsCompact = 0x80000000
+
+type DictColumn struct {
+	Pos    uint32
+	Name   string
+	MType  uint32
+	PRType uint32
+	Length uint32
+}
+
+type DictIndex struct {
+	ID      uint64
+	TableID uint64
+	Name    string
+	NFields uint32
+	Type    uint32
+	Space   uint32
+	PageNo  uint32
+	Fields  []string
+}
+
+type DictTable struct {
+	ID      uint64
+	Name    string
+	Space   uint32
+	NCols   uint32
+	Flags   uint32
+	Columns []*DictColumn
+	Indexes []*DictIndex
+}
+
+// DataDict is the legacy data dictionary of mysql 5.x
+type DataDict struct {
+	MaxTableID uint64
+	MaxIndexID uint64
+	MaxSpaceID uint32
+	Tables     []*DictTable
+}
+
+// readDataDict reads the SYS_TABLES, SYS_COLUMNS, SYS_INDEXES and SYS_FIELDS from
+// the system table space, they are all in the REDUNDANT format
+func readDataDict(f io.ReaderAt, pageSize int) (*DataDict, error) {
+	size, err := resolvePageSize(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	data := make([]byte, size)
+	if err = readPageData(f, dictHeaderPageNo, data); nil != err {
+		return nil, errors.Trace(err)
+	}
+	if binary.BigEndian.Uint16(data[24:]) != PageTypeSys {
+		return nil, errors.Errorf("Page %d is not data dictionary header page", dictHeaderPageNo)
+	}
+	header := data[dictHeaderOffset:]
+	dict := &DataDict{
+		MaxTableID: binary.BigEndian.Uint64(header[dictHeaderTableID:]),
+		MaxIndexID: binary.BigEndian.Uint64(header[dictHeaderIndexID:]),
+		MaxSpaceID: binary.BigEndian.Uint32(header[dictHeaderMaxSpaceID:]),
+	}
+	tablesRoot := binary.BigEndian.Uint32(header[dictHeaderTablesRoot:])
+	columnsRoot := binary.BigEndian.Uint32(header[dictHeaderColumnsRoot:])
+	indexesRoot := binary.BigEndian.Uint32(header[dictHeaderIndexesRoot:])
+	fieldsRoot := binary.BigEndian.Uint32(header[dictHeaderFieldsRoot:])
+
+	// SYS_TABLES: NAME, DB_TRX_ID, DB_ROLL_PTR, ID, N_COLS, TYPE, MIX_ID, MIX_LEN,
+	// CLUSTER_NAME, SPACE
+	tables := make(map[uint64]*DictTable)
+	err = walkRedundantIndex(f, tablesRoot, size, func(rec *redundantRecord) error {
+		if len(rec.fields) < 10 {
+			return errors.New("Invalid SYS_TABLES record")
+		}
+		t := &DictTable{
+			Name:  string(rec.fields[0]),
+			ID:    bigEndianUint(rec.fields[3]),
+			NCols: uint32(bigEndianUint(rec.fields[4])),
+			Flags: uint32(bigEndianUint(rec.fields[5])),
+			Space: uint32(bigEndianUint(rec.fields[9])),
+		}
+		tables[t.ID] = t
+		dict.Tables = append(dict.Tables, t)
+		return nil
+	})
+	if nil != err {
+		return nil, errors.Annotate(err, "Read SYS_TABLES")
+	}
+
+	// SYS_COLUMNS: TABLE_ID, POS, DB_TRX_ID, DB_ROLL_PTR, NAME, MTYPE, PRTYPE, LEN, PREC
+	err = walkRedundantIndex(f, columnsRoot, size, func(rec *redundantRecord) error {
+		if len(rec.fields) < 9 {
+			return errors.New("Invalid SYS_COLUMNS record")
+		}
+		t, ok := tables[bigEndianUint(rec.fields[0])]
+		if !ok {
+			return nil
+		}
+		t.Columns = append(t.Columns, &DictColumn{
+			Pos:    uint32(bigEndianUint(rec.fields[1])),
+			Name:   string(rec.fields[4]),
+			MType:  uint32(bigEndianUint(rec.fields[5])),
+			PRType: uint32(bigEndianUint(rec.fields[6])),
+			Length: uint32(bigEndianUint(rec.fields[7])),
+		})
+		return nil
+	})
+	if nil != err {
+		return nil, errors.Annotate(err, "Read SYS_COLUMNS")
+	}
+
+	// SYS_INDEXES: TABLE_ID, ID, DB_TRX_ID, DB_ROLL_PTR, NAME, N_FIELDS, TYPE, SPACE,
+	// PAGE_NO and MERGE_THRESHOLD of 5.7
+	indexes := make(map[uint64]*DictIndex)
+	err = walkRedundantIndex(f, indexesRoot, size, func(rec *redundantRecord) error {
+		if len(rec.fields) < 9 {
+			return errors.New("Invalid SYS_INDEXES record")
+		}
+		index := &DictIndex{
+			TableID: bigEndianUint(rec.fields[0]),
+			ID:      bigEndianUint(rec.fields[1]),
+			Name:    string(rec.fields[4]),
+			NFields: uint32(bigEndianUint(rec.fields[5])),
+			Type:    uint32(bigEndianUint(rec.fields[6])),
+			Space:   uint32(bigEndianUint(rec.fields[7])),
+			PageNo:  uint32(bigEndianUint(rec.fields[8])),
+		}
+		// The uncommitted index name starts with 0xff
+		index.Name = strings.Replace(index.Name, "\xff", "?", 1)
+		indexes[index.ID] = index
+		if t, ok := tables[index.TableID]; ok {
+			t.Indexes = append(t.Indexes, index)
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, errors.Annotate(err, "Read SYS_INDEXES")
+	}
+
+	// SYS_FIELDS: INDEX_ID, POS, DB_TRX_ID, DB_ROLL_PTR, COL_NAME. If any field of
+	// the index has prefix, POS is the position in the high 2 bytes and the prefix
+	// length in the low 2 bytes, reference to dict_load_field_low. The first field
+	// is at position 0, so its POS is the prefix length only
+	err = walkRedundantIndex(f, fieldsRoot, size, func(rec *redundantRecord) error {
+		if len(rec.fields) < 5 {
+			return errors.New("Invalid SYS_FIELDS record")
+		}
+		index, ok := indexes[bigEndianUint(rec.fields[0])]
+		if !ok {
+			return nil
+		}
+		name := string(rec.fields[4])
+		pos := uint32(bigEndianUint(rec.fields[1]))
+		first := 0 == len(index.Fields)
+		if prefix := pos & 0xffff; ((first && pos > 0) || pos > 0xffff) && 0 != prefix {
+			name = fmt.Sprintf("%s(%d)", name, prefix)
+		}
+		index.Fields = append(index.Fields, name)
+		return nil
+	})
+	if nil != err {
+		return nil, errors.Annotate(err, "Read SYS_FIELDS")
+	}
+
+	for _, t := range dict.Tables {
+		sort.Slice(t.Columns, func(i, j int) bool {
+			return t.Columns[i].Pos < t.Columns[j].Pos
+		})
+	}
+	return dict, nil
+}
+
+// walkRedundantIndex descends to the leftmost leaf page of the REDUNDANT index, and
+// calls fn with every record which is not delete marked
+func walkRedundantIndex(f io.ReaderAt, rootNo uint32, pageSize int, fn func(*redundantRecord) error) error {
+	data := make([]byte, pageSize)
+	pageNo := rootNo
+	for {
+		if err := readPageData(f, int(pageNo), data); nil != err {
+			return errors.Trace(err)
+		}
+		if binary.BigEndian.Uint16(data[24:]) != PageTypeIndex {
+			return errors.Errorf("Page %d is not index page", pageNo)
+		}
+		if binary.BigEndian.Uint16(data[38+4:])&0x8000 != 0 {
+			return errors.Errorf("Page %d is not REDUNDANT page", pageNo)
+		}
+		// PAGE_LEVEL
+		if 0 == binary.BigEndian.Uint16(data[38+26:]) {
+			break
+		}
+		records, err := redundantUserRecords(data)
+		if nil != err {
+			return errors.Annotatef(err, "Page %d", pageNo)
+		}
+		if 0 == len(records) {
+			return errors.Errorf("No node pointer found in page %d", pageNo)
+		}
+		// The child page number is the last field of the node pointer
+		fields := records[0].fields
+		pageNo = uint32(bigEndianUint(fields[len(fields)-1]))
+	}
+
+	for {
+		records, err := redundantUserRecords(data)
+		if nil != err {
+			return errors.Annotatef(err, "Page %d", pageNo)
+		}
+		for _, rec := range records {
+			if rec.deleted() {
+				continue
+			}
+			if err = fn(rec); nil != err {
+				return errors.Annotatef(err, "Page %d offset 0x%04X", pageNo, rec.origin)
+			}
+		}
+		pageNo = binary.BigEndian.Uint32(data[12:])
+		if 0xffffffff == pageNo {
+			return nil
+		}
+		if err = readPageData(f, int(pageNo), data); nil != err {
+			return errors.Trace(err)
+		}
+	}
+}
+
+func (d *DataDict) FindTableBySpace(space uint32) *DictTable {
+	for _, t := range d.Tables {
+		if t.Space == space {
+			return t
+		}
+	}
+	return nil
+}
+
+func (d *DataDict) FindIndex(id uint64) (*DictTable, *DictIndex) {
+	for _, t := range d.Tables {
+		for _, i := range t.Indexes {
+			if i.ID == id {
+				return t, i
+			}
+		}
+	}
+	return nil, nil
+}
+
+// RowFormat returns the row format from the table flags
+func (t *DictTable) RowFormat() string {
+	if 0 == t.NCols&DictNColsCompact {
+		return "REDUNDANT"
+	}
+	// DICT_TF_COMPACT (1bit) + ZIP_SSIZE (4bits) + ATOMIC_BLOBS (1bit)
+	if 0 != (t.Flags>>1)&0x0f {
+		return "COMPRESSED"
+	}
+	if 0 != t.Flags&(1<<5) {
+		return "DYNAMIC"
+	}
+	return "COMPACT"
+}
+
+func (c *DictColumn) TypeString() string {
+	s, ok := dictMainTypeStrs[c.MType]
+	if !ok {
+		s = fmt.Sprintf("UNKNOWN(%d)", c.MType)
+	}
+	s = fmt.Sprintf("%s(%d)", s, c.Length)
+	if 0 != c.PRType&dictPrtypeUnsigned {
+		s += " UNSIGNED"
+	}
+	if 0 != c.PRType&dictPrtypeNotNull {
+		s += " NOT NULL"
+	}
+	if 0 != c.PRType&dictPrtypeVirtual {
+		s += " VIRTUAL"
+	}
+	if collation := int(c.PRType >> 16); 0 != collation {
+		if cs := collationCharset(collation); "" != cs {
+			s += " " + cs
+		}
+	}
+	return s
+}
+
+func (i *DictIndex) TypeString() string {
+	flags := make([]string, 0, 2)
+	if 0 != i.Type&dictIndexClustered {
+		flags = append(flags, "CLUSTERED")
+	}
+	if 0 != i.Type&dictIndexUnique {
+		flags = append(flags, "UNIQUE")
+	}
+	if 0 != i.Type&dictIndexIbuf {
+		flags = append(flags, "IBUF")
+	}
+	if 0 != i.Type&dictIndexFts {
+		flags = append(flags, "FULLTEXT")
+	}
+	if 0 != i.Type&dictIndexSpatial {
+		flags = append(flags, "SPATIAL")
+	}
+	if 0 != i.Type&dictIndexVirtual {
+		flags = append(flags, "VIRTUAL")
+	}
+	if 0 != i.Type&dictIndexCorrupt {
+		flags = append(flags, "CORRUPT")
+	}
+	if 0 == len(flags) {
+		return "SECONDARY"
+	}
+	return strings.Join(flags, ",")
+}
@@ -0,0 +1,184 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// DoublewritePage is a page copy of the doublewrite buffer, the page is written
+// to the doublewrite buffer before it is written to the table space, so the torn
+// page of the table space can be restored from the copy
+type DoublewritePage struct {
+	// Page number of the copy in the doublewrite file
+	Slot    int
+	SpaceID uint32
+	PageNo  uint32
+	LSN     uint64
+	Type    uint16
+	// The full_crc32 checksum is tried if the other algorithms don't match
+	Checksum *PageChecksumResult
+	Data     []byte
+}
+
+// Valid returns true if the copy is intact
+func (p *DoublewritePage) Valid() bool {
+	return !p.Checksum.Corrupt()
+}
+
+func newDoublewritePage(slot int, data []byte) *DoublewritePage {
+	p := &DoublewritePage{
+		Slot:    slot,
+		PageNo:  binary.BigEndian.Uint32(data[4:]),
+		LSN:     binary.BigEndian.Uint64(data[16:]),
+		Type:    binary.BigEndian.Uint16(data[24:]),
+		SpaceID: binary.BigEndian.Uint32(data[34:]),
+		Data:    data,
+	}
+	// The space flags of the page are unknown
+	p.Checksum = VerifyPageChecksum(data, false)
+	if p.Checksum.Corrupt() {
+		if r := VerifyPageChecksum(data, true); !r.Corrupt() {
+			p.Checksum = r
+		}
+	}
+	return p
+}
+
+// readDoublewritePages reads the page copies of the pages, the empty pages are
+// skipped
+func readDoublewritePages(f io.ReaderAt, pages []int, pageSize int) ([]*DoublewritePage, error) {
+	copies := make([]*DoublewritePage, 0, len(pages))
+	for _, no := range pages {
+		data := make([]byte, pageSize)
+		if err := readPageData(f, no, data); nil != err {
+			return nil, errors.Trace(err)
+		}
+		if IsEmptyPage(data) {
+			continue
+		}
+		copies = append(copies, newDoublewritePage(no, data))
+	}
+	return copies, nil
+}
+
+// readSysDoublewritePages reads the two blocks of the doublewrite buffer in the
+// system table space, it is used before 8.0.20
+func readSysDoublewritePages(f io.ReaderAt, pageSize int) ([]*DoublewritePage, error) {
+	size, err := resolvePageSize(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	trxSys, err := readTrxSys(f, size)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	dw := &trxSys.Doublewrite
+	if !dw.Created() {
+		return nil, errors.New("Doublewrite buffer is not created")
+	}
+	n := PageSize(size).ExtentPages()
+	pages := make([]int, 0, 2*n)
+	for _, block := range []uint32{dw.Block1, dw.Block2} {
+		for i := 0; i < n; i++ {
+			pages = append(pages, int(block)+i)
+		}
+	}
+	return readDoublewritePages(f, pages, size)
+}
+
+// ReadDoublewriteFile reads the page copies of the #ib_<page size>_<n>.dblwr
+// file of 8.0.20+, the file is an array of the pages
+func ReadDoublewriteFile(f io.ReaderAt, pageSize int) ([]*DoublewritePage, error) {
+	if !PageSize(pageSize).Valid() {
+		return nil, errors.Errorf("Invalid page size %d", pageSize)
+	}
+	size, err := readerSize(f)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	pages := make([]int, int(size/int64(pageSize)))
+	for i := range pages {
+		pages[i] = i
+	}
+	return readDoublewritePages(f, pages, pageSize)
+}
+
+// DoublewriteMatch is a page of the table space with the page copies in the
+// doublewrite buffer
+type DoublewriteMatch struct {
+	PageNo uint32
+	// The intact copy of the greatest lsn, or the first copy if none intact
+	Copy *DoublewritePage
+	// Checksum of the page in the table space, nil if the page is beyond the file
+	Checksum *PageChecksumResult
+	LSN      uint64
+	Data     []byte
+}
+
+// Torn returns true if the page of the table space is corrupt, the empty page
+// is also torn if it has a copy
+func (m *DoublewriteMatch) Torn() bool {
+	return nil != m.Checksum && (m.Checksum.Corrupt() || m.Checksum.Empty)
+}
+
+// Restorable returns true if the torn page can be restored from the copy
+func (m *DoublewriteMatch) Restorable() bool {
+	return m.Torn() && m.Copy.Valid()
+}
+
+// matchDoublewritePages finds the pages of the table space in the copies, the
+// copies of the other page sizes are ignored
+func matchDoublewritePages(f io.ReaderAt, pageSize int, copies []*DoublewritePage) ([]*DoublewriteMatch, error) {
+	spaceData := make([]byte, 4)
+	if _, err := f.ReadAt(spaceData, fspHeaderOffset); nil != err {
+		return nil, errors.Trace(err)
+	}
+	spaceID := binary.BigEndian.Uint32(spaceData)
+	flags, err := readFSPFlags(f)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	fullCrc32 := IsFullCrc32(flags)
+	fileSize, err := readerSize(f)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+
+	matches := make(map[uint32]*DoublewriteMatch)
+	for _, c := range copies {
+		if c.SpaceID != spaceID || len(c.Data) != pageSize {
+			continue
+		}
+		m, ok := matches[c.PageNo]
+		if !ok {
+			matches[c.PageNo] = &DoublewriteMatch{PageNo: c.PageNo, Copy: c}
+			continue
+		}
+		if !c.Valid() {
+			continue
+		}
+		if !m.Copy.Valid() || c.LSN > m.Copy.LSN {
+			m.Copy = c
+		}
+	}
+
+	result := make([]*DoublewriteMatch, 0, len(matches))
+	for no, m := range matches {
+		if int64(no+1)*int64(pageSize) <= fileSize {
+			m.Data = make([]byte, pageSize)
+			if err = readPageData(f, int(no), m.Data); nil != err {
+				return nil, errors.Trace(err)
+			}
+			m.Checksum = VerifyPageChecksum(m.Data, fullCrc32)
+			m.LSN = binary.BigEndian.Uint64(m.Data[16:])
+		}
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PageNo < result[j].PageNo
+	})
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadSysDoublewritePages(t *testing.T) {
+	const size = 16384
+	file := newTestSysSpace()
+	// The copies of page 3 and 7 of space 5 in the first and second block, the
+	// copy of page 7 is torn
+	for i, slot := range []int{64, 130} {
+		page := file[slot*size : (slot+1)*size]
+		no := uint32(3 + 4*i)
+		binary.BigEndian.PutUint32(page[4:], no)
+		binary.BigEndian.PutUint64(page[16:], 0x5000+uint64(no))
+		binary.BigEndian.PutUint16(page[24:], PageTypeIndex)
+		binary.BigEndian.PutUint32(page[34:], 5)
+		binary.BigEndian.PutUint32(page[size-4:], 0x5000+no)
+		if err := WritePageChecksum(page, ChecksumAlgoCrc32); nil != err {
+			t.Fatal(err)
+		}
+	}
+	file[130*size+100] ^= 0xff
+
+	copies, err := readSysDoublewritePages(bytes.NewReader(file), size)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(copies) {
+		t.Fatalf("%d copies found, expect 2", len(copies))
+	}
+	if 64 != copies[0].Slot || 5 != copies[0].SpaceID || 3 != copies[0].PageNo || !copies[0].Valid() {
+		t.Fatalf("copy of slot %d is page %d:%d", copies[0].Slot, copies[0].SpaceID, copies[0].PageNo)
+	}
+	if 130 != copies[1].Slot || 7 != copies[1].PageNo || copies[1].Valid() {
+		t.Fatalf("copy of slot %d is page %d, expect the torn page 7", copies[1].Slot, copies[1].PageNo)
+	}
+}
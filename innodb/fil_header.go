@@ -0,0 +1,61 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// FileHeader size 38bytes
+type FileHeader struct {
+	// If mysql < 4.0.14, it is table space id because all table file is in the single file
+	// If mysql >= 4.0.14, it is checksum
+	SpaceOrChecksum uint32
+	// Page offset
+	Offset uint32
+	// prev and next has value if the page is index page
+	// note the prev and next pointer is point to the SAME LEVEL of the index
+	// Previous page pointer
+	Prev uint32
+	// Next page pointer
+	Next uint32
+	// Log sequence number
+	LSN uint64
+	// Page type
+	Type uint16
+	// Flush log sequence number
+	FileFlushLSN uint64
+	// Arch log no or space id
+	// For mysql >= 4.0.14 is space id
+	ArchLogNoOrSpaceID uint32
+}
+
+func (h *FileHeader) parse(r io.Reader) error {
+	var err error
+
+	if err = binary.Read(r, binary.BigEndian, &h.SpaceOrChecksum); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Offset); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Prev); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Next); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.LSN); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Type); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.FileFlushLSN); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.ArchLogNoOrSpaceID); nil != err {
+		return err
+	}
+
+	return nil
+}
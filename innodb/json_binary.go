@@ -0,0 +1,371 @@
+package innodb
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Types of the mysql binary JSON value, see json_binary.h
+const (
+	jsonbTypeSmallObject = 0x00
+	jsonbTypeLargeObject = 0x01
+	jsonbTypeSmallArray  = 0x02
+	jsonbTypeLargeArray  = 0x03
+	jsonbTypeLiteral     = 0x04
+	jsonbTypeInt16       = 0x05
+	jsonbTypeUint16      = 0x06
+	jsonbTypeInt32       = 0x07
+	jsonbTypeUint32      = 0x08
+	jsonbTypeInt64       = 0x09
+	jsonbTypeUint64      = 0x0a
+	jsonbTypeDouble      = 0x0b
+	jsonbTypeString      = 0x0c
+	jsonbTypeOpaque      = 0x0f
+)
+
+// Literals of the binary JSON
+const (
+	jsonbLiteralNull  = 0x00
+	jsonbLiteralTrue  = 0x01
+	jsonbLiteralFalse = 0x02
+)
+
+// Field types of the opaque values printed as the JSON scalars
+const (
+	jsonbOpaqueTimestamp  = 7
+	jsonbOpaqueDate       = 10
+	jsonbOpaqueTime       = 11
+	jsonbOpaqueDatetime   = 12
+	jsonbOpaqueNewDecimal = 246
+)
+
+// decodeBinaryJSON decodes the binary JSON value of the JSON column into the
+// JSON text the way mysql prints it, the empty value is the JSON null. The
+// date and time values are strings, other opaque values are base64 strings
+func decodeBinaryJSON(data []byte) (string, error) {
+	if 0 == len(data) {
+		return "null", nil
+	}
+	var b strings.Builder
+	if err := writeBinaryJSONValue(&b, data[0], data[1:]); nil != err {
+		return "", errors.Trace(err)
+	}
+	return b.String(), nil
+}
+
+func writeBinaryJSONValue(b *strings.Builder, typ byte, data []byte) error {
+	need := func(n int) error {
+		if len(data) < n {
+			return errors.Errorf("JSON value of type 0x%02x is truncated, %d bytes", typ, len(data))
+		}
+		return nil
+	}
+
+	switch typ {
+	case jsonbTypeSmallObject, jsonbTypeLargeObject:
+		{
+			return writeBinaryJSONContainer(b, data, jsonbTypeLargeObject == typ, true)
+		}
+	case jsonbTypeSmallArray, jsonbTypeLargeArray:
+		{
+			return writeBinaryJSONContainer(b, data, jsonbTypeLargeArray == typ, false)
+		}
+	case jsonbTypeLiteral:
+		{
+			if err := need(1); nil != err {
+				return err
+			}
+			switch data[0] {
+			case jsonbLiteralNull:
+				{
+					b.WriteString("null")
+				}
+			case jsonbLiteralTrue:
+				{
+					b.WriteString("true")
+				}
+			case jsonbLiteralFalse:
+				{
+					b.WriteString("false")
+				}
+			default:
+				{
+					return errors.Errorf("Invalid JSON literal 0x%02x", data[0])
+				}
+			}
+		}
+	case jsonbTypeInt16, jsonbTypeUint16:
+		{
+			if err := need(2); nil != err {
+				return err
+			}
+			v := binary.LittleEndian.Uint16(data)
+			if jsonbTypeInt16 == typ {
+				b.WriteString(strconv.FormatInt(int64(int16(v)), 10))
+			} else {
+				b.WriteString(strconv.FormatUint(uint64(v), 10))
+			}
+		}
+	case jsonbTypeInt32, jsonbTypeUint32:
+		{
+			if err := need(4); nil != err {
+				return err
+			}
+			v := binary.LittleEndian.Uint32(data)
+			if jsonbTypeInt32 == typ {
+				b.WriteString(strconv.FormatInt(int64(int32(v)), 10))
+			} else {
+				b.WriteString(strconv.FormatUint(uint64(v), 10))
+			}
+		}
+	case jsonbTypeInt64, jsonbTypeUint64:
+		{
+			if err := need(8); nil != err {
+				return err
+			}
+			v := binary.LittleEndian.Uint64(data)
+			if jsonbTypeInt64 == typ {
+				b.WriteString(strconv.FormatInt(int64(v), 10))
+			} else {
+				b.WriteString(strconv.FormatUint(v, 10))
+			}
+		}
+	case jsonbTypeDouble:
+		{
+			if err := need(8); nil != err {
+				return err
+			}
+			s := strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64)
+			if !strings.ContainsAny(s, ".e") {
+				// Keep the value a double when it is loaded back
+				s += ".0"
+			}
+			b.WriteString(s)
+		}
+	case jsonbTypeString:
+		{
+			s, err := readBinaryJSONBytes(data)
+			if nil != err {
+				return err
+			}
+			writeJSONString(b, s)
+		}
+	case jsonbTypeOpaque:
+		{
+			if err := need(1); nil != err {
+				return err
+			}
+			s, err := readBinaryJSONBytes(data[1:])
+			if nil != err {
+				return err
+			}
+			return writeBinaryJSONOpaque(b, data[0], s)
+		}
+	default:
+		{
+			return errors.Errorf("Unknown JSON value type 0x%02x", typ)
+		}
+	}
+	return nil
+}
+
+// writeBinaryJSONContainer writes the object or array. The element count and the
+// size are followed by the key entries of the object and the value entries, the
+// offsets are 2 bytes in the small container and 4 bytes in the large one, and
+// are from the start of the container. The small scalars are inlined in the
+// value entries
+func writeBinaryJSONContainer(b *strings.Builder, data []byte, large bool, object bool) error {
+	offsetSize := 2
+	readOffset := func(data []byte) int {
+		return int(binary.LittleEndian.Uint16(data))
+	}
+	if large {
+		offsetSize = 4
+		readOffset = func(data []byte) int {
+			return int(binary.LittleEndian.Uint32(data))
+		}
+	}
+	if len(data) < 2*offsetSize {
+		return errors.Errorf("JSON container is truncated, %d bytes", len(data))
+	}
+	count := readOffset(data)
+	size := readOffset(data[offsetSize:])
+	if size > len(data) {
+		return errors.Errorf("JSON container of %d bytes is truncated, %d bytes", size, len(data))
+	}
+	data = data[:size]
+
+	keyEntrySize := offsetSize + 2
+	valueEntrySize := 1 + offsetSize
+	keyEntries := 2 * offsetSize
+	valueEntries := keyEntries
+	if object {
+		valueEntries += count * keyEntrySize
+	}
+	if count > size || valueEntries+count*valueEntrySize > size {
+		return errors.Errorf("%d elements of JSON container exceed %d bytes", count, size)
+	}
+
+	open, close := "[", "]"
+	if object {
+		open, close = "{", "}"
+	}
+	b.WriteString(open)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if object {
+			e := data[keyEntries+i*keyEntrySize:]
+			offset := readOffset(e)
+			length := int(binary.LittleEndian.Uint16(e[offsetSize:]))
+			if offset+length > size {
+				return errors.Errorf("Key %d of JSON object at %d exceeds %d bytes", i, offset, size)
+			}
+			writeJSONString(b, data[offset:offset+length])
+			b.WriteString(": ")
+		}
+
+		e := data[valueEntries+i*valueEntrySize:]
+		typ := e[0]
+		var value []byte
+		switch typ {
+		case jsonbTypeLiteral, jsonbTypeInt16, jsonbTypeUint16:
+			{
+				value = e[1 : 1+offsetSize]
+			}
+		case jsonbTypeInt32, jsonbTypeUint32:
+			{
+				if large {
+					value = e[1 : 1+offsetSize]
+				}
+			}
+		}
+		if nil == value {
+			offset := readOffset(e[1:])
+			if offset >= size {
+				return errors.Errorf("Value %d of JSON container at %d exceeds %d bytes", i, offset, size)
+			}
+			value = data[offset:]
+		}
+		if err := writeBinaryJSONValue(b, typ, value); nil != err {
+			return err
+		}
+	}
+	b.WriteString(close)
+	return nil
+}
+
+// readBinaryJSONBytes reads the data prefixed with the variable length, 7 bits
+// in each byte from the lowest, the high bit is set if more bytes follow
+func readBinaryJSONBytes(data []byte) ([]byte, error) {
+	length := 0
+	for i := 0; i < 5; i++ {
+		if i >= len(data) {
+			break
+		}
+		length |= int(data[i]&0x7f) << uint(7*i)
+		if 0 == data[i]&0x80 {
+			if length > len(data)-i-1 {
+				return nil, errors.Errorf("JSON data of %d bytes is truncated, %d bytes", length, len(data)-i-1)
+			}
+			return data[i+1 : i+1+length], nil
+		}
+	}
+	return nil, errors.New("Invalid JSON data length")
+}
+
+func writeBinaryJSONOpaque(b *strings.Builder, fieldType byte, data []byte) error {
+	switch fieldType {
+	case jsonbOpaqueNewDecimal:
+		{
+			if len(data) < 2 || len(data)-2 != decimalSize(int(data[0]), int(data[1])) {
+				return errors.Errorf("Invalid JSON decimal of %d bytes", len(data))
+			}
+			b.WriteString(formatDecimal(data[2:], int(data[0]), int(data[1])))
+		}
+	case jsonbOpaqueDate, jsonbOpaqueTime, jsonbOpaqueDatetime, jsonbOpaqueTimestamp:
+		{
+			if len(data) < 8 {
+				return errors.Errorf("Invalid JSON time of %d bytes", len(data))
+			}
+			// The packed value of TIME_to_longlong_packed
+			v := int64(binary.LittleEndian.Uint64(data))
+			sign := ""
+			if v < 0 {
+				sign = "-"
+				v = -v
+			}
+			frac := v % (1 << 24)
+			v >>= 24
+			if jsonbOpaqueTime == fieldType {
+				writeJSONString(b, []byte(fmt.Sprintf("%s%02d:%02d:%02d.%06d",
+					sign, (v>>12)%(1<<10), (v>>6)%(1<<6), v%(1<<6), frac)))
+				return nil
+			}
+			ymd, hms := v>>17, v%(1<<17)
+			date := fmt.Sprintf("%04d-%02d-%02d", (ymd>>5)/13, (ymd>>5)%13, ymd%(1<<5))
+			if jsonbOpaqueDate == fieldType {
+				writeJSONString(b, []byte(date))
+				return nil
+			}
+			writeJSONString(b, []byte(fmt.Sprintf("%s %02d:%02d:%02d.%06d",
+				date, hms>>12, (hms>>6)%(1<<6), hms%(1<<6), frac)))
+		}
+	default:
+		{
+			writeJSONString(b, []byte(fmt.Sprintf("base64:type%d:%s",
+				fieldType, base64.StdEncoding.EncodeToString(data))))
+		}
+	}
+	return nil
+}
+
+// writeJSONString writes the utf8 string quoted and escaped like mysql
+func writeJSONString(b *strings.Builder, s []byte) {
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"', '\\':
+			{
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			}
+		case '\b':
+			{
+				b.WriteString(`\b`)
+			}
+		case '\f':
+			{
+				b.WriteString(`\f`)
+			}
+		case '\n':
+			{
+				b.WriteString(`\n`)
+			}
+		case '\r':
+			{
+				b.WriteString(`\r`)
+			}
+		case '\t':
+			{
+				b.WriteString(`\t`)
+			}
+		default:
+			{
+				if c < 0x20 {
+					fmt.Fprintf(b, `\u%04x`, c)
+				} else {
+					b.WriteByte(c)
+				}
+			}
+		}
+	}
+	b.WriteByte('"')
+}
@@ -0,0 +1,251 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+type jsonbTestObject struct {
+	keys   []string
+	values []interface{}
+}
+
+type jsonbTestUint32 uint32
+
+type jsonbTestOpaque struct {
+	fieldType byte
+	data      []byte
+}
+
+// jsonbTestEncode encodes the value in the binary JSON format of json_binary.cc,
+// the containers are large if large is true
+func jsonbTestEncode(v interface{}, large bool) (byte, []byte) {
+	switch v := v.(type) {
+	case nil:
+		{
+			return jsonbTypeLiteral, []byte{jsonbLiteralNull}
+		}
+	case bool:
+		{
+			if v {
+				return jsonbTypeLiteral, []byte{jsonbLiteralTrue}
+			}
+			return jsonbTypeLiteral, []byte{jsonbLiteralFalse}
+		}
+	case int:
+		{
+			if v >= math.MinInt16 && v <= math.MaxInt16 {
+				data := make([]byte, 2)
+				binary.LittleEndian.PutUint16(data, uint16(v))
+				return jsonbTypeInt16, data
+			}
+			data := make([]byte, 8)
+			binary.LittleEndian.PutUint64(data, uint64(v))
+			return jsonbTypeInt64, data
+		}
+	case jsonbTestUint32:
+		{
+			data := make([]byte, 4)
+			binary.LittleEndian.PutUint32(data, uint32(v))
+			return jsonbTypeUint32, data
+		}
+	case float64:
+		{
+			data := make([]byte, 8)
+			binary.LittleEndian.PutUint64(data, math.Float64bits(v))
+			return jsonbTypeDouble, data
+		}
+	case string:
+		{
+			return jsonbTypeString, jsonbTestBytes([]byte(v))
+		}
+	case jsonbTestOpaque:
+		{
+			return jsonbTypeOpaque, append([]byte{v.fieldType}, jsonbTestBytes(v.data)...)
+		}
+	case []interface{}:
+		{
+			if large {
+				return jsonbTypeLargeArray, jsonbTestContainer(nil, v, large)
+			}
+			return jsonbTypeSmallArray, jsonbTestContainer(nil, v, large)
+		}
+	case jsonbTestObject:
+		{
+			if large {
+				return jsonbTypeLargeObject, jsonbTestContainer(v.keys, v.values, large)
+			}
+			return jsonbTypeSmallObject, jsonbTestContainer(v.keys, v.values, large)
+		}
+	}
+	panic("unknown value")
+}
+
+func jsonbTestBytes(data []byte) []byte {
+	var b []byte
+	n := len(data)
+	for ; n >= 0x80; n >>= 7 {
+		b = append(b, byte(n)|0x80)
+	}
+	return append(append(b, byte(n)), data...)
+}
+
+func jsonbTestContainer(keys []string, values []interface{}, large bool) []byte {
+	offsetSize := 2
+	put := func(b []byte, v int) {
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	}
+	if large {
+		offsetSize = 4
+		put = func(b []byte, v int) {
+			binary.LittleEndian.PutUint32(b, uint32(v))
+		}
+	}
+	entries := 2*offsetSize + len(keys)*(offsetSize+2)
+	data := make([]byte, entries+len(values)*(1+offsetSize))
+	put(data, len(values))
+	for i, k := range keys {
+		e := 2*offsetSize + i*(offsetSize+2)
+		put(data[e:], len(data))
+		binary.LittleEndian.PutUint16(data[e+offsetSize:], uint16(len(k)))
+		data = append(data, k...)
+	}
+	for i, v := range values {
+		e := entries + i*(1+offsetSize)
+		typ, value := jsonbTestEncode(v, large)
+		data[e] = typ
+		inlined := jsonbTypeLiteral == typ || jsonbTypeInt16 == typ ||
+			(large && jsonbTypeUint32 == typ)
+		if inlined {
+			copy(data[e+1:], value)
+			continue
+		}
+		put(data[e+1:], len(data))
+		data = append(data, value...)
+	}
+	put(data[offsetSize:], len(data))
+	return data
+}
+
+func jsonbTestValue(v interface{}, large bool) []byte {
+	typ, data := jsonbTestEncode(v, large)
+	return append([]byte{typ}, data...)
+}
+
+func TestDecodeBinaryJSON(t *testing.T) {
+	// 2015-01-15 23:24:25 and -12:34:56.5 packed by TIME_to_longlong_packed
+	ymd := (2015*13+1)<<5 | 15
+	datetime := make([]byte, 8)
+	binary.LittleEndian.PutUint64(datetime, uint64(ymd<<17|23<<12|24<<6|25)<<24)
+	date := make([]byte, 8)
+	binary.LittleEndian.PutUint64(date, uint64(ymd<<17)<<24)
+	packed := -int64((12<<12|34<<6|56)<<24 | 500000)
+	tm := make([]byte, 8)
+	binary.LittleEndian.PutUint64(tm, uint64(packed))
+
+	doc := jsonbTestObject{
+		keys: []string{"a", "b", "c", "long"},
+		values: []interface{}{
+			1,
+			[]interface{}{true, false, nil, "x"},
+			3.5,
+			jsonbTestObject{keys: []string{"n"}, values: []interface{}{-1 << 40}},
+		},
+	}
+	cases := []struct {
+		data   []byte
+		expect string
+	}{
+		{nil, `null`},
+		{jsonbTestValue(doc, false), `{"a": 1, "b": [true, false, null, "x"], "c": 3.5, "long": {"n": -1099511627776}}`},
+		{jsonbTestValue(doc, true), `{"a": 1, "b": [true, false, null, "x"], "c": 3.5, "long": {"n": -1099511627776}}`},
+		{jsonbTestValue([]interface{}{jsonbTestUint32(4000000000), -32768, 2.0, 1e300}, true), `[4000000000, -32768, 2.0, 1e+300]`},
+		{jsonbTestValue([]interface{}{jsonbTestUint32(7)}, false), `[7]`},
+		{jsonbTestValue([]interface{}{}, false), `[]`},
+		{jsonbTestValue(jsonbTestObject{}, false), `{}`},
+		{jsonbTestValue("a\"b\\c\n\x01中", false), `"a\"b\\c\n\u0001中"`},
+		{jsonbTestValue(string(make([]byte, 200)), false)[:3], ``},
+		{jsonbTestValue(jsonbTestOpaque{jsonbOpaqueNewDecimal, []byte{4, 2, 0x8c, 0x22}}, false), `12.34`},
+		{jsonbTestValue(jsonbTestOpaque{jsonbOpaqueDatetime, datetime}, false), `"2015-01-15 23:24:25.000000"`},
+		{jsonbTestValue(jsonbTestOpaque{jsonbOpaqueDate, date}, false), `"2015-01-15"`},
+		{jsonbTestValue(jsonbTestOpaque{jsonbOpaqueTime, tm}, false), `"-12:34:56.500000"`},
+		{jsonbTestValue(jsonbTestOpaque{255, []byte{1, 2}}, false), `"base64:type255:AQI="`},
+	}
+	for _, c := range cases {
+		s, err := decodeBinaryJSON(c.data)
+		if "" == c.expect {
+			if nil == err {
+				t.Fatalf("truncated value 0x%x is decoded to %s", c.data, s)
+			}
+			continue
+		}
+		if nil != err {
+			t.Fatal(err)
+		}
+		if c.expect != s {
+			t.Fatalf("decoded %s, expect %s", s, c.expect)
+		}
+	}
+
+	long := string(make([]byte, 300))
+	s, err := decodeBinaryJSON(jsonbTestValue(long, false))
+	if nil != err || 2+6*300 != len(s) {
+		t.Fatalf("string of 300 bytes is decoded to %d bytes, %v", len(s), err)
+	}
+}
+
+func TestDecodeBinaryJSONCorrupt(t *testing.T) {
+	doc := jsonbTestValue(jsonbTestObject{
+		keys:   []string{"a", "b"},
+		values: []interface{}{"x", []interface{}{1.5}},
+	}, false)
+	corrupt := func(f func(data []byte)) []byte {
+		data := append([]byte(nil), doc...)
+		f(data)
+		return data
+	}
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", doc[:len(doc)-4]},
+		{"truncated header", doc[:3]},
+		{"unknown type", []byte{0x0d, 1}},
+		{"invalid literal", []byte{jsonbTypeLiteral, 3}},
+		{"truncated double", []byte{jsonbTypeDouble, 0, 0}},
+		{"string length", []byte{jsonbTypeString, 5, 'a'}},
+		{"unterminated string length", []byte{jsonbTypeString, 0x80, 0x80}},
+		{"decimal", jsonbTestValue(jsonbTestOpaque{jsonbOpaqueNewDecimal, []byte{10, 2, 1}}, false)},
+		{"element count", corrupt(func(data []byte) {
+			binary.LittleEndian.PutUint16(data[1:], 100)
+		})},
+		{"size", corrupt(func(data []byte) {
+			binary.LittleEndian.PutUint16(data[3:], 0xff00)
+		})},
+		{"key offset", corrupt(func(data []byte) {
+			binary.LittleEndian.PutUint16(data[5:], 0x1000)
+		})},
+		{"value offset", corrupt(func(data []byte) {
+			binary.LittleEndian.PutUint16(data[5+8+1:], 0x1000)
+		})},
+		{"value type", corrupt(func(data []byte) {
+			data[5+8+3] = 0x0e
+		})},
+	} {
+		if s, err := decodeBinaryJSON(c.data); nil == err {
+			t.Fatalf("%s JSON is decoded to %s", c.name, s)
+		}
+	}
+}
+
+func TestSQLLiteralJSON(t *testing.T) {
+	c := &Column{Name: "doc", Type: ColumnTypeJSON}
+	data := jsonbTestValue(jsonbTestObject{keys: []string{"k"}, values: []interface{}{"it's"}}, false)
+	if s := sqlLiteral(c, data); `'{\"k\": \"it\'s\"}'` != s {
+		t.Fatalf("SQL literal of JSON is %s", s)
+	}
+	if s := sqlLiteral(c, []byte{}); `'null'` != s {
+		t.Fatalf("SQL literal of empty JSON is %s", s)
+	}
+}
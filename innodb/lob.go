@@ -0,0 +1,232 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// The LOB first page of mysql 8.0, reference to lob0first.h. The index entries
+// and the data follow the header
+const (
+	lobFirstVersion    = 38
+	lobFirstFlags      = 39
+	lobFirstLobVersion = 40
+	lobFirstLastTrxID  = 44
+	lobFirstLastUndoNo = 50
+	lobFirstDataLen    = 54
+	lobFirstTrxID      = 58
+	lobFirstIndexList  = 64
+	lobFirstFreeList   = 80
+	lobFirstIndexBegin = 96
+	lobIndexEntrySize  = 60
+	lobDataPageDataLen = 39
+	lobDataPageDataBeg = 49
+)
+
+// The index entry of the LOB, reference to lob0index.h. The entries of the
+// older versions are linked in the versions list of the entry. The data length
+// is 2 bytes, followed by 2 unused bytes
+const (
+	lobEntryPrev          = 0
+	lobEntryNext          = 6
+	lobEntryVersions      = 12
+	lobEntryTrxID         = 28
+	lobEntryModifierTrxID = 34
+	lobEntryUndoNo        = 40
+	lobEntryModifierUndo  = 44
+	lobEntryPageNo        = 48
+	lobEntryDataLen       = 52
+	lobEntryLobVersion    = 56
+)
+
+// LobFirstPageHeader is the header of the LOB first page
+type LobFirstPageHeader struct {
+	Version    uint8
+	Flags      uint8
+	LobVersion uint32
+	LastTrxID  uint64
+	LastUndoNo uint32
+	// Data length stored in the first page
+	DataLen uint32
+	TrxID   uint64
+	// The index entries of the LOB, each entry points to a data page
+	IndexList ListBaseNode
+	FreeList  ListBaseNode
+}
+
+func (h *LobFirstPageHeader) parse(data []byte) error {
+	h.Version = data[lobFirstVersion]
+	h.Flags = data[lobFirstFlags]
+	h.LobVersion = binary.BigEndian.Uint32(data[lobFirstLobVersion:])
+	h.LastTrxID = bigEndianUint(data[lobFirstLastTrxID : lobFirstLastTrxID+6])
+	h.LastUndoNo = binary.BigEndian.Uint32(data[lobFirstLastUndoNo:])
+	h.DataLen = binary.BigEndian.Uint32(data[lobFirstDataLen:])
+	h.TrxID = bigEndianUint(data[lobFirstTrxID : lobFirstTrxID+6])
+	if err := h.IndexList.parse(bytes.NewReader(data[lobFirstIndexList:])); nil != err {
+		return errors.Trace(err)
+	}
+	return errors.Trace(h.FreeList.parse(bytes.NewReader(data[lobFirstFreeList:])))
+}
+
+// lobIndexEntries returns the count of the index entries in the first page
+func lobIndexEntries(pageSize int) int {
+	switch pageSize {
+	case 4096:
+		{
+			return 1
+		}
+	case 8192:
+		{
+			return 5
+		}
+	case 32768:
+		{
+			return 20
+		}
+	case 65536:
+		{
+			return 40
+		}
+	}
+	return 10
+}
+
+// lobIndexEntry is the index entry of the LOB
+type lobIndexEntry struct {
+	nextPageNo uint32
+	nextOffset uint16
+	// The first entry of the older versions
+	versionsPageNo uint32
+	versionsOffset uint16
+	pageNo         uint32
+	dataLen        uint32
+	lobVersion     uint32
+}
+
+// lobReader reads the LOB pages, the index pages are cached since the entries
+// of the index pages are visited one by one
+type lobReader struct {
+	f        io.ReaderAt
+	pageSize int
+	pages    map[uint32][]byte
+}
+
+func (r *lobReader) page(pageNo uint32) ([]byte, error) {
+	if data, ok := r.pages[pageNo]; ok {
+		return data, nil
+	}
+	data := make([]byte, r.pageSize)
+	if err := readPageImage(r.f, int(pageNo), data); nil != err {
+		return nil, errors.Trace(err)
+	}
+	r.pages[pageNo] = data
+	return data, nil
+}
+
+func (r *lobReader) entry(pageNo uint32, offset uint16) (*lobIndexEntry, error) {
+	page, err := r.page(pageNo)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	if int(offset)+lobIndexEntrySize > r.pageSize-8 {
+		return nil, errors.Errorf("Invalid LOB index entry offset %d of page %d", offset, pageNo)
+	}
+	data := page[offset:]
+	return &lobIndexEntry{
+		nextPageNo:     binary.BigEndian.Uint32(data[lobEntryNext:]),
+		nextOffset:     binary.BigEndian.Uint16(data[lobEntryNext+4:]),
+		versionsPageNo: binary.BigEndian.Uint32(data[lobEntryVersions+4:]),
+		versionsOffset: binary.BigEndian.Uint16(data[lobEntryVersions+8:]),
+		pageNo:         binary.BigEndian.Uint32(data[lobEntryPageNo:]),
+		dataLen:        uint32(binary.BigEndian.Uint16(data[lobEntryDataLen:])),
+		lobVersion:     binary.BigEndian.Uint32(data[lobEntryLobVersion:]),
+	}, nil
+}
+
+// version returns the entry visible to the LOB version, the entry is replaced
+// by the partial update of the later version if its version is greater. nil is
+// returned if the data is inserted after the version
+func (r *lobReader) version(e *lobIndexEntry, version uint32) (*lobIndexEntry, error) {
+	if 0 == version || e.lobVersion <= version {
+		return e, nil
+	}
+	pageNo, offset := e.versionsPageNo, e.versionsOffset
+	for i := 0; 0xffffffff != pageNo; i++ {
+		if i > r.pageSize {
+			return nil, errors.Errorf("LOB versions list of page %d is too long", e.pageNo)
+		}
+		v, err := r.entry(pageNo, offset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if v.lobVersion <= version {
+			return v, nil
+		}
+		pageNo, offset = v.nextPageNo, v.nextOffset
+	}
+	return nil, nil
+}
+
+// data returns the data of the entry, the first page stores the data after the
+// index entries
+func (r *lobReader) data(e *lobIndexEntry, firstPageNo uint32) ([]byte, error) {
+	page, err := r.page(e.pageNo)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	start := lobDataPageDataBeg
+	if e.pageNo == firstPageNo {
+		start = lobFirstIndexBegin + lobIndexEntries(r.pageSize)*lobIndexEntrySize
+	} else if typ := binary.BigEndian.Uint16(page[24:]); typ != PageTypeLobData {
+		return nil, errors.Errorf("Page %d is %s page, not LOB data page", e.pageNo, PageTypeToString(int(typ)))
+	}
+	if start+int(e.dataLen) > r.pageSize-8 {
+		return nil, errors.Errorf("Invalid LOB data length %d of page %d", e.dataLen, e.pageNo)
+	}
+	return page[start : start+int(e.dataLen)], nil
+}
+
+// readLob reads the LOB of mysql 8.0 through the index entries of the first
+// page, the data of the LOB version in the reference is rebuilt
+func readLob(f io.ReaderAt, ref *externFieldRef, first []byte) ([]byte, error) {
+	var h LobFirstPageHeader
+	if err := h.parse(first); nil != err {
+		return nil, errors.Trace(err)
+	}
+	r := &lobReader{
+		f:        f,
+		pageSize: len(first),
+		pages:    map[uint32][]byte{ref.pageNo: first},
+	}
+
+	data := make([]byte, 0, ref.length)
+	pageNo, offset := h.IndexList.PrevPageNo, h.IndexList.PrevPageOffset
+	for i := uint32(0); 0xffffffff != pageNo; i++ {
+		if i > h.IndexList.Length {
+			return nil, errors.Errorf("LOB index list of page %d is longer than %d", ref.pageNo, h.IndexList.Length)
+		}
+		e, err := r.entry(pageNo, offset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		v, err := r.version(e, ref.offset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if nil != v {
+			part, err := r.data(v, ref.pageNo)
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			data = append(data, part...)
+		}
+		pageNo, offset = e.nextPageNo, e.nextOffset
+	}
+	if uint64(len(data)) != ref.length {
+		return nil, errors.Errorf("LOB of page %d has %d bytes, expect %d", ref.pageNo, len(data), ref.length)
+	}
+	return data, nil
+}
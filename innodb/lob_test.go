@@ -0,0 +1,66 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadLob(t *testing.T) {
+	const size = 16384
+	file := make([]byte, 8*size)
+	first := file[3*size : 4*size]
+	binary.BigEndian.PutUint16(first[24:], PageTypeLobFirst)
+	binary.BigEndian.PutUint32(first[12:], 0xffffffff)
+	// The data of the first page follows the 10 index entries
+	head := bytes.Repeat([]byte("a"), 300)
+	copy(first[96+10*60:], head)
+	binary.BigEndian.PutUint32(first[54:], uint32(len(head)))
+
+	// The second part is updated by LOB version 2, the old part is in page 5
+	parts := [][]byte{bytes.Repeat([]byte("b"), 1000), bytes.Repeat([]byte("c"), 1000)}
+	for i, part := range parts {
+		page := file[(4+i)*size : (5+i)*size]
+		binary.BigEndian.PutUint16(page[24:], PageTypeLobData)
+		binary.BigEndian.PutUint32(page[39:], uint32(len(part)))
+		copy(page[49:], part)
+	}
+
+	// The index entries per lob0index.h, the data length is 2 bytes, and the
+	// unused bytes before the LOB version are zero
+	entry := func(offset int, next int, versions int, pageNo uint32, dataLen int, version uint32) {
+		e := first[offset:]
+		binary.BigEndian.PutUint32(e[6:], 0xffffffff)
+		if 0 != next {
+			binary.BigEndian.PutUint32(e[6:], 3)
+			binary.BigEndian.PutUint16(e[10:], uint16(next))
+		}
+		binary.BigEndian.PutUint32(e[16:], 0xffffffff)
+		if 0 != versions {
+			binary.BigEndian.PutUint32(e[12:], 1)
+			binary.BigEndian.PutUint32(e[16:], 3)
+			binary.BigEndian.PutUint16(e[20:], uint16(versions))
+		}
+		binary.BigEndian.PutUint32(e[48:], pageNo)
+		binary.BigEndian.PutUint16(e[52:], uint16(dataLen))
+		binary.BigEndian.PutUint32(e[56:], version)
+	}
+	entry(96, 156, 0, 3, len(head), 1)
+	entry(156, 0, 216, 5, len(parts[1]), 2)
+	entry(216, 0, 0, 4, len(parts[0]), 1)
+	binary.BigEndian.PutUint32(first[64:], 2)
+	binary.BigEndian.PutUint32(first[68:], 3)
+	binary.BigEndian.PutUint16(first[72:], 96)
+
+	for _, version := range []uint32{1, 2} {
+		expect := append(append([]byte(nil), head...), parts[version-1]...)
+		ref := &externFieldRef{pageNo: 3, offset: version, length: uint64(len(expect))}
+		data, err := readExternPart(bytes.NewReader(file), ref, size)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expect, data) {
+			t.Fatalf("LOB version %d is not rebuilt", version)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package innodb
+
+import (
+	"bytes"
+
+	"github.com/juju/errors"
+)
+
+type Page struct {
+	// Start with file header
+	FileHeader FileHeader
+	// File space header parts (xdes)
+	FSPHeader FSPHeader
+	XDeses    []*XdesEntry
+	// Index page part
+	// page directory slots
+	IndexHeader    PageIndexHeader
+	DirectorySlots []byte
+	DSlots         []*DSlots
+	// Inode page part
+	INode INode
+	// Undo page part, the segment header and the undo log headers are only
+	// stored in the first page of the undo segment
+	UndoPageHeader    UndoPageHeader
+	UndoSegmentHeader *UndoSegmentHeader
+	UndoLogHeaders    []*UndoLogHeader
+	// BLOB page part, the header of the first page is at the offset of the
+	// extern reference
+	BlobHeader BlobHeader
+	// LOB first page part of mysql 8.0
+	LobFirstHeader LobFirstPageHeader
+	// Page compression of the page compressed page, nil if the page is not
+	// compressed on disk
+	Compression *PageCompression
+	// The error of the page compressed page can't be decompressed, the page is
+	// kept as on disk and only the file header is parsed
+	DecompressError error
+	// checksum && lsn
+	Trailer [8]byte
+	// not innodb data
+	no     int
+	offset int
+	pksize int
+	size   int
+}
+
+// No returns the page number
+func (p *Page) No() int {
+	return p.no
+}
+
+// Offset returns the offset of the page in the file
+func (p *Page) Offset() int {
+	return p.offset
+}
+
+// Size returns the page size
+func (p *Page) Size() int {
+	return p.size
+}
+
+func (p *Page) setPageNo(no int) {
+	p.no = no
+	p.offset = p.size * no
+}
+
+// parse parses the page data, the page compressed page is decompressed in data
+// before parsing
+func (p *Page) parse(data []byte, options *ParsePageOptions) error {
+	var err error
+	if err = p.decompress(data); nil != err {
+		return err
+	}
+	r := bytes.NewReader(data)
+	p.size = len(data)
+	// Parse file header
+	if err = p.FileHeader.parse(r); nil != err {
+		return err
+	}
+
+	if p.FileHeader.Type == PageTypeIndex || p.FileHeader.Type == PageTypeSDI {
+		// Page page header
+		if err = p.IndexHeader.parse(r); nil != err {
+			return err
+		}
+
+		if err = p.parseDirectorySlot(data); nil != err {
+			return err
+		}
+
+		if options.ParseRecords {
+			if err = p.parseRecorders(data, options); nil != err {
+				return err
+			}
+		}
+	} else if p.FileHeader.Type == PageTypeFspHDR || p.FileHeader.Type == PageTypeXdes {
+		// The XDES page has the same layout, the FSP header part is unused
+		if err = p.FSPHeader.parse(r); nil != err {
+			return errors.Trace(err)
+		}
+		if err = p.parseXdeses(r, PageSize(p.size), options.physicalSize(p.size)); nil != err {
+			return errors.Trace(err)
+		}
+	} else if p.FileHeader.Type == PageTypeINode {
+		if err = p.INode.parse(r, PageSize(p.size), options.physicalSize(p.size)); nil != err {
+			return errors.Trace(err)
+		}
+	} else if p.FileHeader.Type == PageTypeUndoLog {
+		if err = p.parseUndoPage(r, data); nil != err {
+			return errors.Trace(err)
+		}
+	} else if p.FileHeader.Type == PageTypeBlob || p.FileHeader.Type == PageTypeSDIBlob {
+		p.BlobHeader.parse(data[38:])
+	} else if p.FileHeader.Type == PageTypeLobFirst {
+		if err = p.LobFirstHeader.parse(data); nil != err {
+			return errors.Trace(err)
+		}
+	}
+
+	// Parse file trailer, last 8 bytes
+	copy(p.Trailer[:], data[len(data)-8:])
+
+	return nil
+}
+
+// decompress decompresses the page compressed page in data, the compression is
+// kept if the page is decompressed already
+func (p *Page) decompress(data []byte) error {
+	c, err := decompressPage(data)
+	if nil != err {
+		return errors.Annotate(err, "Decompress page")
+	}
+	if nil != c {
+		p.Compression = c
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/juju/errors"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Page compression algorithms, the ids are the algorithms stored in the page,
+// mysql only uses zlib and lz4. zstd has no id, it is detected from the frame
+// magic of the payload
+const (
+	CompressAlgoNone = iota
+	CompressAlgoZlib
+	CompressAlgoLZ4
+	CompressAlgoLZO
+	CompressAlgoLZMA
+	CompressAlgoBzip2
+	CompressAlgoSnappy
+	CompressAlgoZstd
+)
+
+var compressAlgoStrs = []string{
+	"none",
+	"zlib",
+	"lz4",
+	"lzo",
+	"lzma",
+	"bzip2",
+	"snappy",
+	"zstd",
+}
+
+func CompressAlgoToString(algo int) string {
+	if algo < 0 || algo >= len(compressAlgoStrs) {
+		return "unknown"
+	}
+	return compressAlgoStrs[algo]
+}
+
+// The transparent page compression of mysql (COMPRESSION='zlib'), reference to
+// os0file.cc. The payload after the file header is compressed, the file header
+// keeps the original page type and size
+const (
+	pageCompressAlgorithm    = 27
+	pageCompressOriginalType = 28
+	pageCompressOriginalSize = 30
+	pageCompressSize         = 32
+	pageCompressData         = 38
+)
+
+// The page compression of MariaDB (PAGE_COMPRESSED=1), reference to
+// fil0pagecompress.cc. The whole page is compressed, the algorithm is stored in
+// the flush lsn field and the payload length follows the file header
+const (
+	pageCompressedAlgorithm = 26
+	pageCompressedSize      = 38
+	pageCompressedData      = 40
+)
+
+// zstd frame magic, little endian
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// PageCompression is the compression of the page compressed page, the page is
+// decompressed before parsing
+type PageCompression struct {
+	// Page type on disk, PageTypeCompressed or PageTypePageCompressed
+	Type      uint16
+	Algorithm int
+	// Bytes written to the disk, the header and the compressed payload
+	Size int
+}
+
+// Allocated returns the bytes allocated in the sparse file, the rest of the page
+// after the compressed data is punched out in blockSize
+func (c *PageCompression) Allocated(pageSize int, blockSize int) int {
+	if blockSize <= 0 {
+		return c.Size
+	}
+	size := (c.Size + blockSize - 1) / blockSize * blockSize
+	if size > pageSize {
+		size = pageSize
+	}
+	return size
+}
+
+// IsPageCompressed returns true if the raw page is page compressed on disk
+func IsPageCompressed(data []byte) bool {
+	switch binary.BigEndian.Uint16(data[24:]) {
+	case PageTypeCompressed, PageTypeCompressedEncrypted,
+		PageTypePageCompressed, PageTypePageCompressedEncrypted:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// decompressPage decompresses the page compressed page in data, data is the
+// normal page after decompressing. nil is returned if the page is not page
+// compressed
+func decompressPage(data []byte) (*PageCompression, error) {
+	typ := binary.BigEndian.Uint16(data[24:])
+	switch typ {
+	case PageTypeCompressed:
+		{
+			return decompressMysqlPage(data)
+		}
+	case PageTypePageCompressed:
+		{
+			return decompressMariadbPage(data)
+		}
+	case PageTypeCompressedEncrypted, PageTypePageCompressedEncrypted:
+		{
+			return nil, errors.Errorf("%s page is not supported", PageTypeToString(int(typ)))
+		}
+	}
+	return nil, nil
+}
+
+func decompressMysqlPage(data []byte) (*PageCompression, error) {
+	c := &PageCompression{
+		Type:      PageTypeCompressed,
+		Algorithm: int(data[pageCompressAlgorithm]),
+	}
+	origType := binary.BigEndian.Uint16(data[pageCompressOriginalType:])
+	origSize := int(binary.BigEndian.Uint16(data[pageCompressOriginalSize:]))
+	size := int(binary.BigEndian.Uint16(data[pageCompressSize:]))
+	c.Size = pageCompressData + size
+	if c.Algorithm != CompressAlgoZlib && c.Algorithm != CompressAlgoLZ4 {
+		c.Algorithm = -1
+	}
+	if c.Size > len(data) || pageCompressData+origSize > len(data) {
+		return nil, errors.Errorf("Invalid compressed size %d, original size %d", size, origSize)
+	}
+
+	out := make([]byte, origSize)
+	var err error
+	if c.Algorithm, err = decompressPayload(c.Algorithm, data[pageCompressData:c.Size], out); nil != err {
+		return nil, errors.Trace(err)
+	}
+	copy(data[pageCompressData:], out)
+	binary.BigEndian.PutUint16(data[24:], origType)
+	return c, nil
+}
+
+func decompressMariadbPage(data []byte) (*PageCompression, error) {
+	algo := binary.BigEndian.Uint64(data[pageCompressedAlgorithm:])
+	size := int(binary.BigEndian.Uint16(data[pageCompressedSize:]))
+	c := &PageCompression{
+		Type:      PageTypePageCompressed,
+		Algorithm: int(algo),
+		Size:      pageCompressedData + size,
+	}
+	if algo > CompressAlgoSnappy {
+		c.Algorithm = -1
+	}
+	if c.Size > len(data) {
+		return nil, errors.Errorf("Invalid compressed size %d", size)
+	}
+
+	out := make([]byte, len(data))
+	var err error
+	if c.Algorithm, err = decompressPayload(c.Algorithm, data[pageCompressedData:c.Size], out); nil != err {
+		return nil, errors.Trace(err)
+	}
+	copy(data, out)
+	return c, nil
+}
+
+// decompressPayload decompresses src into dst, dst must be filled. The unknown
+// algorithm is negative, it is zstd if the payload is a zstd frame
+func decompressPayload(algo int, src []byte, dst []byte) (int, error) {
+	if algo < 0 && bytes.HasPrefix(src, zstdMagic) {
+		algo = CompressAlgoZstd
+	}
+
+	var err error
+	n := 0
+	switch algo {
+	case CompressAlgoZlib:
+		{
+			var zr io.ReadCloser
+			if zr, err = zlib.NewReader(bytes.NewReader(src)); nil == err {
+				n, err = io.ReadFull(zr, dst)
+				zr.Close()
+			}
+		}
+	case CompressAlgoLZ4:
+		{
+			n, err = lz4DecodeBlock(dst, src)
+		}
+	case CompressAlgoBzip2:
+		{
+			n, err = io.ReadFull(bzip2.NewReader(bytes.NewReader(src)), dst)
+		}
+	case CompressAlgoSnappy:
+		{
+			var out []byte
+			if n, err = snappy.DecodedLen(src); nil == err && n == len(dst) {
+				out, err = snappy.Decode(dst, src)
+				n = len(out)
+			}
+		}
+	case CompressAlgoZstd:
+		{
+			var out []byte
+			if out, err = zstdDecoder().DecodeAll(src, dst[:0]); nil == err {
+				n = len(out)
+				if n <= len(dst) {
+					copy(dst, out)
+				}
+			}
+		}
+	default:
+		{
+			return algo, errors.Errorf("Compression algorithm %s is not supported", CompressAlgoToString(algo))
+		}
+	}
+	if nil != err {
+		return algo, errors.Errorf("Decompress %s error %v", CompressAlgoToString(algo), err)
+	}
+	if n != len(dst) {
+		return algo, errors.Errorf("Decompress %s got %d bytes, expect %d", CompressAlgoToString(algo), n, len(dst))
+	}
+	return algo, nil
+}
+
+var (
+	zstdOnce sync.Once
+	zstdDec  *zstd.Decoder
+)
+
+// zstdDecoder returns the shared zstd decoder, DecodeAll can be called by the
+// parsing workers concurrently
+func zstdDecoder() *zstd.Decoder {
+	zstdOnce.Do(func() {
+		zstdDec, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+	return zstdDec
+}
+
+// lz4DecodeBlock decodes the lz4 block without the frame into dst, reference
+// to LZ4_decompress_safe of lz4.c
+func lz4DecodeBlock(dst []byte, src []byte) (int, error) {
+	si, di := 0, 0
+	for si < len(src) {
+		token := src[si]
+		si++
+		literals := int(token >> 4)
+		if 15 == literals {
+			for {
+				if si >= len(src) {
+					return di, errors.New("Truncated lz4 literal length")
+				}
+				b := src[si]
+				si++
+				literals += int(b)
+				if 255 != b {
+					break
+				}
+			}
+		}
+		if si+literals > len(src) || di+literals > len(dst) {
+			return di, errors.New("Invalid lz4 literal length")
+		}
+		copy(dst[di:], src[si:si+literals])
+		si += literals
+		di += literals
+		if si == len(src) {
+			// The last sequence only has the literals
+			break
+		}
+
+		if si+2 > len(src) {
+			return di, errors.New("Truncated lz4 match offset")
+		}
+		offset := int(binary.LittleEndian.Uint16(src[si:]))
+		si += 2
+		if 0 == offset || offset > di {
+			return di, errors.Errorf("Invalid lz4 match offset %d", offset)
+		}
+		match := int(token & 0x0f)
+		if 15 == match {
+			for {
+				if si >= len(src) {
+					return di, errors.New("Truncated lz4 match length")
+				}
+				b := src[si]
+				si++
+				match += int(b)
+				if 255 != b {
+					break
+				}
+			}
+		}
+		match += 4
+		if di+match > len(dst) {
+			return di, errors.New("Invalid lz4 match length")
+		}
+		// The match may overlap the output
+		for i := 0; i < match; i++ {
+			dst[di] = dst[di-offset]
+			di++
+		}
+	}
+	return di, nil
+}
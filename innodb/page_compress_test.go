@@ -0,0 +1,302 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// lz4TestEncode compresses src into the lz4 block greedily, the last match
+// starts 12 bytes before the end and the last 5 bytes are literals like
+// LZ4_compress_default
+func lz4TestEncode(src []byte) []byte {
+	var dst []byte
+	length := func(n int) {
+		for ; n >= 255; n -= 255 {
+			dst = append(dst, 255)
+		}
+		dst = append(dst, byte(n))
+	}
+	emit := func(literals []byte, offset int, match int) {
+		token := byte(0)
+		if len(literals) >= 15 {
+			token = 15 << 4
+		} else {
+			token = byte(len(literals)) << 4
+		}
+		if 0 != offset {
+			if match-4 >= 15 {
+				token |= 15
+			} else {
+				token |= byte(match - 4)
+			}
+		}
+		dst = append(dst, token)
+		if len(literals) >= 15 {
+			length(len(literals) - 15)
+		}
+		dst = append(dst, literals...)
+		if 0 != offset {
+			dst = append(dst, byte(offset), byte(offset>>8))
+			if match-4 >= 15 {
+				length(match - 4 - 15)
+			}
+		}
+	}
+
+	table := make(map[uint32]int)
+	anchor := 0
+	for i := 0; i+12 <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		j, ok := table[seq]
+		table[seq] = i
+		if !ok || i-j > 0xffff {
+			i++
+			continue
+		}
+		n := 4
+		for i+n < len(src)-5 && src[j+n] == src[i+n] {
+			n++
+		}
+		emit(src[anchor:i], i-j, n)
+		i += n
+		anchor = i
+	}
+	emit(src[anchor:], 0, 0)
+	return dst
+}
+
+// newTestCompressPage returns the index page with the compressible records and
+// the random bytes
+func newTestCompressPage(size int) []byte {
+	page := make([]byte, size)
+	binary.BigEndian.PutUint32(page[4:], 3)
+	binary.BigEndian.PutUint64(page[16:], 0x123456)
+	binary.BigEndian.PutUint16(page[24:], PageTypeIndex)
+	binary.BigEndian.PutUint32(page[34:], 9)
+	rnd := rand.New(rand.NewSource(int64(size)))
+	for i := 120; i < size/2; i += 32 {
+		copy(page[i:], "record of the compressed page ")
+		binary.BigEndian.PutUint16(page[i+30:], uint16(i))
+	}
+	rnd.Read(page[size/2 : size/2+512])
+	binary.BigEndian.PutUint32(page[size-4:], 0x123456)
+	return page
+}
+
+func testZlib(src []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(src)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestLz4DecodeBlock(t *testing.T) {
+	// 3 literals, the match of 10 bytes overlapping the output at offset 3,
+	// and the last 5 literals
+	block := []byte{0x36, 'a', 'b', 'c', 3, 0, 0x50, 'x', 'y', 'z', 'z', 'y'}
+	dst := make([]byte, 18)
+	n, err := lz4DecodeBlock(dst, block)
+	if nil != err || 18 != n || "abcabcabcabcaxyzzy" != string(dst) {
+		t.Fatalf("decoded %q %d %v", dst[:n], n, err)
+	}
+
+	for _, src := range [][]byte{
+		newTestCompressPage(16384)[38:],
+		bytes.Repeat([]byte{0}, 4096),
+		[]byte("short"),
+	} {
+		dst := make([]byte, len(src))
+		n, err := lz4DecodeBlock(dst, lz4TestEncode(src))
+		if nil != err || n != len(src) || !bytes.Equal(src, dst) {
+			t.Fatalf("%d bytes are decoded to %d bytes, %v", len(src), n, err)
+		}
+	}
+
+	for _, c := range []struct {
+		name  string
+		block []byte
+	}{
+		{"truncated literal length", []byte{0xf0}},
+		{"literals beyond the block", []byte{0x50, 'a', 'b'}},
+		{"truncated offset", []byte{0x14, 'a', 3}},
+		{"zero offset", []byte{0x14, 'a', 0, 0, 0x10, 'a'}},
+		{"offset beyond the output", []byte{0x14, 'a', 2, 0, 0x10, 'a'}},
+		{"truncated match length", []byte{0x1f, 'a', 1, 0}},
+		{"match beyond the output", []byte{0x1f, 'a', 1, 0, 200, 0x10, 'a'}},
+	} {
+		if _, err := lz4DecodeBlock(make([]byte, 32), c.block); nil == err {
+			t.Fatalf("%s is decoded", c.name)
+		}
+	}
+}
+
+// newTestMysqlPage compresses the payload after the file header like
+// os_file_compress_page, the algorithm is the one of the page
+func newTestMysqlPage(page []byte, algo int) []byte {
+	var payload []byte
+	switch algo {
+	case CompressAlgoZlib:
+		{
+			payload = testZlib(page[38:])
+		}
+	case CompressAlgoLZ4:
+		{
+			payload = lz4TestEncode(page[38:])
+		}
+	}
+	disk := make([]byte, len(page))
+	copy(disk, page[:38])
+	binary.BigEndian.PutUint16(disk[24:], PageTypeCompressed)
+	disk[26] = 1
+	disk[pageCompressAlgorithm] = byte(algo)
+	binary.BigEndian.PutUint16(disk[pageCompressOriginalType:], binary.BigEndian.Uint16(page[24:]))
+	binary.BigEndian.PutUint16(disk[pageCompressOriginalSize:], uint16(len(page)-38))
+	binary.BigEndian.PutUint16(disk[pageCompressSize:], uint16(len(payload)))
+	copy(disk[pageCompressData:], payload)
+	return disk
+}
+
+// newTestMariadbPage compresses the whole page like fil_page_compress, the
+// payload length follows the file header
+func newTestMariadbPage(page []byte, algo int) []byte {
+	var payload []byte
+	switch algo {
+	case CompressAlgoZlib:
+		{
+			payload = testZlib(page)
+		}
+	case CompressAlgoLZ4:
+		{
+			payload = lz4TestEncode(page)
+		}
+	case CompressAlgoSnappy:
+		{
+			payload = snappy.Encode(nil, page)
+		}
+	case CompressAlgoZstd:
+		{
+			enc, _ := zstd.NewWriter(nil)
+			payload = enc.EncodeAll(page, nil)
+			// zstd has no id
+			algo = 0xff
+		}
+	}
+	disk := make([]byte, len(page))
+	copy(disk, page[:38])
+	binary.BigEndian.PutUint16(disk[24:], PageTypePageCompressed)
+	binary.BigEndian.PutUint64(disk[pageCompressedAlgorithm:], uint64(algo))
+	binary.BigEndian.PutUint16(disk[pageCompressedSize:], uint16(len(payload)))
+	copy(disk[pageCompressedData:], payload)
+	return disk
+}
+
+func TestDecompressPage(t *testing.T) {
+	for _, size := range []int{4096, 16384} {
+		page := newTestCompressPage(size)
+		for _, algo := range []int{CompressAlgoZlib, CompressAlgoLZ4} {
+			disk := newTestMysqlPage(page, algo)
+			c, err := decompressPage(disk)
+			if nil != err {
+				t.Fatal(err)
+			}
+			if PageTypeCompressed != c.Type || algo != c.Algorithm || c.Size >= size {
+				t.Fatalf("mysql %s page compression is %+v", CompressAlgoToString(algo), c)
+			}
+			if !bytes.Equal(page[38:], disk[38:]) || PageTypeIndex != binary.BigEndian.Uint16(disk[24:]) {
+				t.Fatalf("mysql %s page is not decompressed", CompressAlgoToString(algo))
+			}
+		}
+		for _, algo := range []int{CompressAlgoZlib, CompressAlgoLZ4, CompressAlgoSnappy, CompressAlgoZstd} {
+			disk := newTestMariadbPage(page, algo)
+			c, err := decompressPage(disk)
+			if nil != err {
+				t.Fatal(err)
+			}
+			if PageTypePageCompressed != c.Type || algo != c.Algorithm {
+				t.Fatalf("MariaDB %s page compression is %+v", CompressAlgoToString(algo), c)
+			}
+			if !bytes.Equal(page, disk) {
+				t.Fatalf("MariaDB %s page is not decompressed", CompressAlgoToString(algo))
+			}
+		}
+	}
+
+	if c, err := decompressPage(newTestCompressPage(16384)); nil != c || nil != err {
+		t.Fatalf("normal page is decompressed")
+	}
+}
+
+func TestDecompressPageCorrupt(t *testing.T) {
+	page := newTestCompressPage(16384)
+	cases := []struct {
+		name string
+		disk func() []byte
+	}{
+		{"mysql compressed size", func() []byte {
+			disk := newTestMysqlPage(page, CompressAlgoZlib)
+			binary.BigEndian.PutUint16(disk[pageCompressSize:], 0xffff)
+			return disk
+		}},
+		{"mysql truncated zlib", func() []byte {
+			disk := newTestMysqlPage(page, CompressAlgoZlib)
+			n := binary.BigEndian.Uint16(disk[pageCompressSize:])
+			binary.BigEndian.PutUint16(disk[pageCompressSize:], n/2)
+			return disk
+		}},
+		{"mysql original size", func() []byte {
+			disk := newTestMysqlPage(page, CompressAlgoLZ4)
+			binary.BigEndian.PutUint16(disk[pageCompressOriginalSize:], 16000)
+			return disk
+		}},
+		{"mysql corrupt lz4", func() []byte {
+			disk := newTestMysqlPage(page, CompressAlgoLZ4)
+			for i := pageCompressData + 20; i < pageCompressData+60; i++ {
+				disk[i] = 0xff
+			}
+			return disk
+		}},
+		{"mysql unknown algorithm", func() []byte {
+			disk := newTestMysqlPage(page, CompressAlgoZlib)
+			disk[pageCompressAlgorithm] = CompressAlgoLZO
+			return disk
+		}},
+		{"MariaDB compressed size", func() []byte {
+			disk := newTestMariadbPage(page, CompressAlgoSnappy)
+			binary.BigEndian.PutUint16(disk[pageCompressedSize:], 0xfff0)
+			return disk
+		}},
+		{"MariaDB truncated lz4", func() []byte {
+			disk := newTestMariadbPage(page, CompressAlgoLZ4)
+			n := binary.BigEndian.Uint16(disk[pageCompressedSize:])
+			binary.BigEndian.PutUint16(disk[pageCompressedSize:], n-100)
+			return disk
+		}},
+		{"MariaDB lzo", func() []byte {
+			disk := newTestMariadbPage(page, CompressAlgoZlib)
+			binary.BigEndian.PutUint64(disk[pageCompressedAlgorithm:], CompressAlgoLZO)
+			return disk
+		}},
+		{"MariaDB encrypted", func() []byte {
+			disk := newTestMariadbPage(page, CompressAlgoZlib)
+			binary.BigEndian.PutUint16(disk[24:], PageTypePageCompressedEncrypted)
+			return disk
+		}},
+	}
+	for _, c := range cases {
+		disk := c.disk()
+		orig := append([]byte(nil), disk...)
+		if _, err := decompressPage(disk); nil == err {
+			t.Fatalf("%s page is decompressed", c.name)
+		}
+		if !bytes.Equal(orig, disk) {
+			t.Fatalf("%s page is changed", c.name)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+type FileSegmentHeader struct {
+	InodeSpaceID    uint32
+	InodePageNumber uint32
+	InodeOffset     uint16
+}
+
+func (f *FileSegmentHeader) parse(r io.Reader) error {
+	if err := binary.Read(r, binary.BigEndian, &f.InodeSpaceID); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &f.InodePageNumber); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &f.InodeOffset); nil != err {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+type PageIndexHeader struct {
+	NDirSlots    uint16
+	HeapTop      uint16
+	NHeap        uint16
+	Free         uint16
+	Garbage      uint16
+	LastInsert   uint16
+	Direction    uint16
+	NDirection   uint16
+	NRecs        uint16
+	MaxTrxID     uint64
+	Level        uint16
+	IndexID      uint64
+	LeafInode    FileSegmentHeader
+	NonleafInode FileSegmentHeader
+}
+
+func (h *PageIndexHeader) parse(r io.Reader) error {
+	var err error
+
+	if err = binary.Read(r, binary.BigEndian, &h.NDirSlots); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.HeapTop); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.NHeap); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Free); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Garbage); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.LastInsert); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Direction); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.NDirection); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.NRecs); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.MaxTrxID); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.Level); nil != err {
+		return err
+	}
+	if err = binary.Read(r, binary.BigEndian, &h.IndexID); nil != err {
+		return err
+	}
+	if err = h.LeafInode.parse(r); nil != err {
+		return err
+	}
+	if err = h.NonleafInode.parse(r); nil != err {
+		return err
+	}
+
+	return nil
+}
+
+type DSlots struct {
+	Index         int
+	Value         uint16
+	Owned         uint8
+	Type          string
+	RecorderType  uint8
+	BeginRecorder *CompactRecorder
+	EndRecorder   *CompactRecorder
+}
+
+// Previous bytes is variable length and null flag masks
+// 40bit = 5bytes
+// Flags (4 bits) + Number of records owned (4 bits) = 1 byte
+// Order (13 bits) + record type (3 bits) = 2 bytes
+// Next record offset (2 bytes)
+type CompactRecorderHeader struct {
+	// deleted (2) meaning the record is delete-marked
+	// (and will be actually deleted by a purge operation in the future).
+	DeleteFlag bool
+	// min_rec (1) meaning this record is the minimum record in a non-leaf level of the B+Tree
+	MinRecFlag bool
+	// The number of records “owned” by the current record in the page directory.
+	Owned uint8
+	// The order in which this record was inserted into the heap.
+	// Heap records (which include infimum and supremum) are numbered from 0.
+	// Infimum is always order 0, supremum is always order 1.
+	// User records inserted will be numbered from 2.
+	HeapNo uint16
+	// The type of the record, where currently only 4 values are supported:
+	// conventional (0), node pointer (1), infimum (2), and supremum (3).
+	RecordType uint8
+	// A relative offset from the current record to the
+	// origin of the next record within the page in ascending order by key.
+	NextRecorder uint16
+}
+
+func (h *CompactRecorderHeader) parse(data []byte) error {
+	if len(data) < 5 {
+		return io.EOF
+	}
+
+	h.DeleteFlag = (data[0] & 0x20) != 0
+	h.MinRecFlag = (data[0] & 0x10) != 0
+	h.Owned = data[0] & 0x0f
+	h.HeapNo |= uint16(data[1]) << 5
+	h.HeapNo |= uint16(data[2]&0xf8) >> 3
+	h.RecordType = data[2] & 0x07
+	h.NextRecorder = binary.BigEndian.Uint16(data[3:])
+
+	return nil
+}
+
+// Variable length table and null masks are only parsed with the table schema
+type CompactRecorder struct {
+	// Variable length table
+	// Null masks
+	// Header
+	Header CompactRecorderHeader
+	// Field datas ...
+	FieldDataOffset uint16
+	// Decoded fields with the table schema
+	Fields []*RecordField
+
+	Next   *CompactRecorder
+	Offset uint16 // Offset relative to the page
+	HasKey bool
+	Key    int64 // Only support bigint as primary key
+	// If is root page, the node should pointer to internal or leaf node
+	PagePtr uint32
+}
+
+// CompareKey compares the record key with key, the key of the unsigned column
+// is compared as uint64 since it is negative in Key if greater than MaxInt64
+func (rc *CompactRecorder) CompareKey(key int64) int {
+	unsigned := len(rc.Fields) > 0 && rc.Fields[0].Column.Unsigned
+	return CompareIntegerKey(rc.Key, key, unsigned)
+}
+
+// CompareIntegerKey compares the integer keys, returns -1, 0 or 1
+func CompareIntegerKey(a int64, b int64, unsigned bool) int {
+	if unsigned {
+		if uint64(a) < uint64(b) {
+			return -1
+		} else if uint64(a) > uint64(b) {
+			return 1
+		}
+		return 0
+	}
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func (p *Page) parseDirectorySlot(data []byte) error {
+	// Parse directory slots
+	if p.IndexHeader.NDirSlots != 0 &&
+		(p.FileHeader.Type == PageTypeIndex || p.FileHeader.Type == PageTypeSDI) {
+		// Every slot occupy 2 bytes
+		p.DirectorySlots = make([]byte, p.IndexHeader.NDirSlots*2)
+		p.DSlots = make([]*DSlots, 0, p.IndexHeader.NDirSlots)
+		copy(p.DirectorySlots, data[len(data)-8-len(p.DirectorySlots):])
+	}
+	if nil == p.DirectorySlots {
+		return nil
+	}
+
+	index := 0
+	for i := len(p.DirectorySlots) - 2; i >= 0; i -= 2 {
+		var ds DSlots
+		ds.Index = index
+		ds.Value = binary.BigEndian.Uint16(p.DirectorySlots[i:])
+		// Check the record
+		var crh CompactRecorderHeader
+		// Record data offset by slot value is the row data, we need the previous head data to get the
+		// header
+		recordData := data[ds.Value-5:]
+		if err := crh.parse(recordData); nil != err {
+			return err
+		}
+		ds.Owned = crh.Owned
+		// Get record type
+		ds.RecorderType = crh.RecordType
+		ds.Type = "normal"
+		if RecorderTypeBTreeNode == crh.RecordType {
+			ds.Type = "node ptr"
+		} else if RecorderTypeInfimum == crh.RecordType {
+			ds.Type = "infimum"
+		} else if RecorderTypeSupremum == crh.RecordType {
+			ds.Type = "supremum"
+		}
+		p.DSlots = append(p.DSlots, &ds)
+		index++
+	}
+
+	return nil
+}
+
+func (p *Page) parseRecorders(data []byte, options *ParsePageOptions) error {
+	if nil == p.DSlots || len(p.DSlots) == 0 {
+		return nil
+	}
+
+	var prevRecorder *CompactRecorder
+
+	for _, slot := range p.DSlots {
+		if slot.RecorderType == RecorderTypeInfimum {
+			// Infimum recorder, only own it self, process the next slot
+			recorderHeadOffset := slot.Value - 5
+			prevRecorder = &CompactRecorder{}
+			prevRecorder.PagePtr = 0xffffffff
+			if err := prevRecorder.Header.parse(data[recorderHeadOffset:]); nil != err {
+				return err
+			}
+			prevRecorder.FieldDataOffset = slot.Value
+			prevRecorder.Offset = recorderHeadOffset
+			slot.BeginRecorder = prevRecorder
+			slot.EndRecorder = prevRecorder
+		} else {
+			// Normal recorders, find the previous slot
+			recorderHeadOffset := prevRecorder.Header.NextRecorder + prevRecorder.Offset
+
+			for i := 0; i < int(slot.Owned); i++ {
+				rc := &CompactRecorder{}
+				rc.PagePtr = 0xffffffff
+				rc.Offset = recorderHeadOffset
+				if err := rc.Header.parse(data[recorderHeadOffset:]); nil != err {
+					return err
+				}
+				rc.FieldDataOffset = recorderHeadOffset + 5
+				if rc.Header.RecordType != RecorderTypeInfimum &&
+					rc.Header.RecordType != RecorderTypeSupremum &&
+					nil != options.Table {
+					// Decode all fields with the table schema
+					if err := p.parseRecorderFields(data, rc, options.Table); nil != err {
+						return err
+					}
+				} else if rc.Header.RecordType != RecorderTypeInfimum &&
+					rc.Header.RecordType != RecorderTypeSupremum {
+					// Get value
+					if p.pksize == 8 {
+						rc.Key = int64(binary.BigEndian.Uint64(data[rc.FieldDataOffset:]))
+						rc.Key &= 0x7fffffffffffffff
+					} else if p.pksize == 4 {
+						rc.Key = int64(binary.BigEndian.Uint32(data[rc.FieldDataOffset:]))
+						rc.Key &= 0x7fffffff
+					} else if p.pksize == 2 {
+						rc.Key = int64(binary.BigEndian.Uint16(data[rc.FieldDataOffset:]))
+						rc.Key &= 0x7fff
+					} else if p.pksize == 1 {
+						rc.Key = int64(data[rc.FieldDataOffset])
+						rc.Key &= 0x7f
+					}
+
+					if p.IndexHeader.Level != 0 {
+						// root or internal page
+						rc.PagePtr = binary.BigEndian.Uint32(data[int(rc.FieldDataOffset)+p.pksize:])
+					}
+
+					rc.HasKey = true
+				}
+				if nil == slot.BeginRecorder {
+					slot.BeginRecorder = rc
+				}
+				prevRecorder.Next = rc
+				prevRecorder = rc
+				recorderHeadOffset = rc.Offset + rc.Header.NextRecorder
+			}
+
+			slot.EndRecorder = prevRecorder
+		}
+	}
+
+	return nil
+}
+
+// parseRecorderFields decodes the record fields, the first integer field is the key
+// and the last field of the node pointer record is the child page number
+func (p *Page) parseRecorderFields(data []byte, rc *CompactRecorder, table *Table) error {
+	index := table.FindIndex(p.IndexHeader.IndexID)
+	if nil == index {
+		// Pages of other indexes or tables are not decoded
+		return nil
+	}
+	fields, err := parseRecordFields(data, int(rc.FieldDataOffset),
+		index.RecordFields(0 == p.IndexHeader.Level), index.nullableCount())
+	if nil != err {
+		return err
+	}
+	rc.Fields = fields
+	if key, ok := fields[0].integer(); ok {
+		rc.Key = key
+		rc.HasKey = true
+	}
+	if p.IndexHeader.Level != 0 {
+		rc.PagePtr = uint32(bigEndianUint(fields[len(fields)-1].Data))
+	}
+	return nil
+}
+
+// userRecorders returns the user records in the key order, infimum and
+// supremum are excluded
+func (p *Page) UserRecorders() []*CompactRecorder {
+	rcs := make([]*CompactRecorder, 0, p.IndexHeader.NRecs)
+	if 0 == len(p.DSlots) || nil == p.DSlots[0].BeginRecorder {
+		return rcs
+	}
+	for rc := p.DSlots[0].BeginRecorder.Next; nil != rc; rc = rc.Next {
+		if rc.Header.RecordType == RecorderTypeSupremum {
+			break
+		}
+		rcs = append(rcs, rc)
+	}
+	return rcs
+}
@@ -0,0 +1,99 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+const (
+	inodeEntryMagicNumber = 97937874
+)
+
+type INodeEntry struct {
+	// The ID of the file segment (FSEG) described by this
+	// file segment INODE entry. If the ID is 0, the entry is unused.
+	FileSegmentID uint64
+	// Exactly like the space’s FREE_FRAG list (in the FSP header),
+	// this field stores the number of pages used in the NOT_FULL list as an
+	// optimization to be able to quickly calculate the number of free pages
+	// in the list without iterating through all extents in the list.
+	UsedPagesInNotFullList uint32
+	// Extents that are completely unused and are allocated to this file segment.
+	FreeList ListBaseNode
+	// Extents with at least one used page allocated to this file
+	// segment. When the last free page is used, the extent is moved to the FULL list.
+	NotFullList ListBaseNode
+	// Extents with no free pages allocated to this file segment.
+	// If a page becomes free, the extent is moved to the NOT_FULL list.
+	FullList ListBaseNode
+	// The value 97937874 is stored as a marker that this
+	// file segment INODE entry has been properly initialized.
+	MagicNumber uint32
+	// An array of page numbers (half of the extent size, 32 for 16k page) of pages
+	// allocated individually from extents in the space’s FREE_FRAG or FULL_FRAG list of
+	// “fragment” extents.
+	// Once this array becomes full, only full extents can be allocated to the file segment.
+	FragmentArrayEntry []uint32
+}
+
+func (n *INodeEntry) parse(r io.Reader, ps PageSize) error {
+	if err := binary.Read(r, binary.BigEndian, &n.FileSegmentID); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &n.UsedPagesInNotFullList); nil != err {
+		return errors.Trace(err)
+	}
+	if err := n.FreeList.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	if err := n.NotFullList.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	if err := n.FullList.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &n.MagicNumber); nil != err {
+		return errors.Trace(err)
+	}
+	n.FragmentArrayEntry = make([]uint32, ps.FragmentSlots())
+	for i := 0; i < len(n.FragmentArrayEntry); i++ {
+		if err := binary.Read(r, binary.BigEndian, &n.FragmentArrayEntry[i]); nil != err {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+type INode struct {
+	InodePageList ListNode
+	Inodes        []*INodeEntry
+}
+
+// parse parses the inode entries filling the physical page
+func (n *INode) parse(r io.Reader, ps PageSize, physical int) error {
+	if err := n.InodePageList.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	n.Inodes = make([]*INodeEntry, ps.ZipInodesPerPage(physical))
+	for i := 0; i < len(n.Inodes); i++ {
+		var entry INodeEntry
+		if err := entry.parse(r, ps); nil != err {
+			return errors.Trace(err)
+		}
+		n.Inodes[i] = &entry
+	}
+	return nil
+}
+
+// entryAt returns the inode entry at the offset of the inode page, returns nil if
+// the offset is not the start of an entry
+func (n *INode) EntryAt(offset int, ps PageSize) *INodeEntry {
+	// File header (38) + page list node (12)
+	pos := offset - 38 - 12
+	if pos < 0 || pos%ps.InodeEntrySize() != 0 || pos/ps.InodeEntrySize() >= len(n.Inodes) {
+		return nil
+	}
+	return n.Inodes[pos/ps.InodeEntrySize()]
+}
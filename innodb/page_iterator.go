@@ -0,0 +1,226 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Chunk size of the parallel page iteration, the aligned pages of a chunk are
+// read at once and parsed by one worker
+const iterateChunkSize = 1 << 20
+
+// ErrStopIteration is returned by the callback of the page iteration to stop
+// the iteration early, it is not returned by the iteration
+var ErrStopIteration = errors.New("Stop iteration")
+
+// PageWorkFunc is the work of the page done with the parsing, it may be called
+// by the workers in any page order
+type PageWorkFunc func(page *Page, data []byte) interface{}
+
+// iteratePages reads the pages one by one from the beginning, and calls fn with
+// the parsed page and the page data. The page type in the file header is checked
+// with canParse before parsing, so the pages not needed are skipped without the
+// parsing work. The page data is reused and the record fields refer to it, so
+// they must not be kept after fn returns. fn can return ErrStopIteration to stop
+// early
+func iteratePages(r io.ReaderAt, options *ParsePageOptions, fn func(page *Page, data []byte) error) error {
+	return mapPages(r, options, nil, func(page *Page, data []byte, v interface{}) error {
+		return fn(page, data)
+	})
+}
+
+// mapPages is iteratePages with the work of every page, fn is called with the
+// value returned by work. If options.Jobs is greater than 1, the parsing and the
+// work are spread across the workers, fn is still called in the page order
+func mapPages(r io.ReaderAt, options *ParsePageOptions, work PageWorkFunc,
+	fn func(page *Page, data []byte, v interface{}) error) error {
+	size, err := resolvePageSize(r, options.PageSize)
+	if nil != err {
+		return err
+	}
+	options.PageSize = size
+	if 0 == options.ParsePageTypeFlag {
+		options.ParsePageTypeFlag = ParsePageAll
+	}
+	if options.Jobs > 1 {
+		return mapPagesParallel(r, options, work, fn)
+	}
+
+	data := make([]byte, size)
+	for pageNo := 0; ; pageNo++ {
+		n, err := r.ReadAt(data, int64(pageNo)*int64(size))
+		if n < size {
+			if nil == err || io.EOF == err {
+				// End of file, the incomplete page is ignored
+				return nil
+			}
+			return errors.Errorf("Read page %d error %v", pageNo, err)
+		}
+		page, err := parseIteratedPage(pageNo, data, options)
+		if nil != err {
+			return err
+		}
+		if nil == page {
+			continue
+		}
+		var v interface{}
+		if nil != work {
+			v = work(page, data)
+		}
+		if err = fn(page, data, v); nil != err {
+			if ErrStopIteration == err {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// parseIteratedPage parses the page data, returns nil if the page type can't
+// be parsed with the options. The page compressed page is decompressed first to
+// get the page type. The page can't be decompressed is reported with its error
+// instead of stopping the iteration, the blocks of a raw disk image may look
+// like the page compressed pages
+func parseIteratedPage(pageNo int, data []byte, options *ParsePageOptions) (*Page, error) {
+	page := &Page{}
+	if err := page.decompress(data); nil != err {
+		page.DecompressError = err
+	}
+	if !options.canParse(int(binary.BigEndian.Uint16(data[24:]))) {
+		return nil, nil
+	}
+
+	page.pksize = options.PKSize
+	if 0 == page.pksize {
+		page.pksize = 8
+	}
+	var err error
+	if options.HeaderOnly || nil != page.DecompressError {
+		page.size = len(data)
+		err = page.FileHeader.parse(bytes.NewReader(data))
+	} else {
+		err = page.parse(data, options)
+	}
+	if nil != err {
+		return nil, errors.Errorf("Parse page %d error %v", pageNo, err)
+	}
+	page.setPageNo(pageNo)
+	return page, nil
+}
+
+// pageChunk is the aligned pages read at once, the pages and the values are
+// ready after done is closed
+type pageChunk struct {
+	first  int
+	buf    []byte
+	data   []byte
+	pages  []*Page
+	values []interface{}
+	// Read or parse error after the pages
+	err  error
+	done chan struct{}
+}
+
+func (c *pageChunk) parse(options *ParsePageOptions, work PageWorkFunc) {
+	defer close(c.done)
+
+	size := options.PageSize
+	n := len(c.data) / size
+	c.pages = make([]*Page, 0, n)
+	c.values = make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		data := c.data[i*size : (i+1)*size]
+		page, err := parseIteratedPage(c.first+i, data, options)
+		if nil != err {
+			c.err = err
+			return
+		}
+		var v interface{}
+		if nil != page && nil != work {
+			v = work(page, data)
+		}
+		c.pages = append(c.pages, page)
+		c.values = append(c.values, v)
+	}
+}
+
+// mapPagesParallel reads the file in aligned chunks, the chunks are parsed by
+// options.Jobs workers and handed to fn in the read order. The chunk buffers
+// are reused, so the memory is limited by the number of the workers
+func mapPagesParallel(r io.ReaderAt, options *ParsePageOptions, work PageWorkFunc,
+	fn func(page *Page, data []byte, v interface{}) error) error {
+	size := options.PageSize
+	chunkPages := iterateChunkSize / size
+	if chunkPages < 1 {
+		chunkPages = 1
+	}
+	jobs := options.Jobs
+	buffers := make(chan []byte, jobs*2)
+	for i := 0; i < cap(buffers); i++ {
+		buffers <- make([]byte, chunkPages*size)
+	}
+	// The chunks in flight are no more than the buffers, the sends never block
+	tasks := make(chan *pageChunk, cap(buffers))
+	ordered := make(chan *pageChunk, cap(buffers))
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(ordered)
+		defer close(tasks)
+		for first := 0; ; first += chunkPages {
+			var buf []byte
+			select {
+			case buf = <-buffers:
+			case <-quit:
+				return
+			}
+			n, err := r.ReadAt(buf, int64(first)*int64(size))
+			chunk := &pageChunk{
+				first: first,
+				buf:   buf,
+				data:  buf[:n/size*size],
+				done:  make(chan struct{}),
+			}
+			if n < len(buf) && nil != err && io.EOF != err {
+				chunk.err = errors.Errorf("Read page %d error %v", first+n/size, err)
+			}
+			tasks <- chunk
+			ordered <- chunk
+			if n < len(buf) {
+				// End of file, the incomplete page is ignored
+				return
+			}
+		}
+	}()
+	for i := 0; i < jobs; i++ {
+		go func() {
+			for chunk := range tasks {
+				chunk.parse(options, work)
+			}
+		}()
+	}
+
+	for chunk := range ordered {
+		<-chunk.done
+		for i, page := range chunk.pages {
+			if nil == page {
+				continue
+			}
+			if err := fn(page, chunk.data[i*size:(i+1)*size], chunk.values[i]); nil != err {
+				if ErrStopIteration == err {
+					return nil
+				}
+				return err
+			}
+		}
+		if nil != chunk.err {
+			return chunk.err
+		}
+		buffers <- chunk.buf
+	}
+	return nil
+}
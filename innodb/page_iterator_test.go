@@ -0,0 +1,49 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestIteratePagesUnreadable(t *testing.T) {
+	const size = 16384
+	file := make([]byte, 4*size)
+	for i := 0; i < 4; i++ {
+		page := file[i*size : (i+1)*size]
+		binary.BigEndian.PutUint32(page[4:], uint32(i))
+		binary.BigEndian.PutUint16(page[24:], PageTypeAllocated)
+	}
+	// The block of page 1 looks like the mysql page compressed page, and page 2
+	// like the MariaDB one with an unknown algorithm
+	binary.BigEndian.PutUint16(file[size+24:], PageTypeCompressed)
+	binary.BigEndian.PutUint16(file[size+32:], 0xffff)
+	binary.BigEndian.PutUint16(file[2*size+24:], PageTypePageCompressed)
+	binary.BigEndian.PutUint64(file[2*size+26:], 0x1234)
+
+	for _, headerOnly := range []bool{false, true} {
+		var pages []*Page
+		err := iteratePages(bytes.NewReader(file), &ParsePageOptions{
+			PageSize:   size,
+			HeaderOnly: headerOnly,
+		}, func(page *Page, data []byte) error {
+			pages = append(pages, page)
+			return nil
+		})
+		if nil != err {
+			t.Fatal(err)
+		}
+		if 4 != len(pages) {
+			t.Fatalf("%d pages iterated, expect 4", len(pages))
+		}
+		for i, page := range pages {
+			unreadable := 1 == i || 2 == i
+			if unreadable != (nil != page.DecompressError) || i != page.No() {
+				t.Fatalf("page %d error %v", page.No(), page.DecompressError)
+			}
+		}
+		if PageTypeCompressed != pages[1].FileHeader.Type {
+			t.Fatalf("type of page 1 is %d, expect the type on disk", pages[1].FileHeader.Type)
+		}
+	}
+}
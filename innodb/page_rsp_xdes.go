@@ -1,4 +1,4 @@
-package main
+package innodb
 
 import (
 	"encoding/binary"
@@ -13,17 +13,17 @@ import (
 // ListBaseNode stores the double-linked list length and the first node as prev
 // , the last node as next
 type ListBaseNode struct {
-	length uint32
+	Length uint32
 	ListNode
 }
 
 // 49 max
-func (n *ListBaseNode) toString(offset uint16) string {
-	return fmt.Sprintf("len<%d> %s", n.length, n.ListNode.toString(offset))
+func (n *ListBaseNode) ToString(offset uint16) string {
+	return fmt.Sprintf("len<%d> %s", n.Length, n.ListNode.ToString(offset))
 }
 
 func (n *ListBaseNode) parse(r io.Reader) error {
-	if err := binary.Read(r, binary.BigEndian, &n.length); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &n.Length); nil != err {
 		return errors.Trace(err)
 	}
 	if err := n.ListNode.parse(r); nil != err {
@@ -34,37 +34,37 @@ func (n *ListBaseNode) parse(r io.Reader) error {
 
 // ListNode stores the prev and next pointer
 type ListNode struct {
-	prevPageNo     uint32
-	prevPageOffset uint16
-	nextPageNo     uint32
-	nextPageOffset uint16
+	PrevPageNo     uint32
+	PrevPageOffset uint16
+	NextPageNo     uint32
+	NextPageOffset uint16
 }
 
 // 49 max
-func (n *ListNode) toString(offset uint16) string {
-	prevo := n.prevPageOffset
-	nexto := n.nextPageOffset
-	if n.prevPageNo != 0xffffffff {
+func (n *ListNode) ToString(offset uint16) string {
+	prevo := n.PrevPageOffset
+	nexto := n.NextPageOffset
+	if n.PrevPageNo != 0xffffffff {
 		prevo -= offset
 	}
-	if n.nextPageNo != 0xffffffff {
+	if n.NextPageNo != 0xffffffff {
 		nexto -= offset
 	}
 	return fmt.Sprintf("0x%08X:0x%04X 0x%08X:0x%04X",
-		n.prevPageNo, prevo, n.nextPageNo, nexto)
+		n.PrevPageNo, prevo, n.NextPageNo, nexto)
 }
 
 func (n *ListNode) parse(r io.Reader) error {
-	if err := binary.Read(r, binary.BigEndian, &n.prevPageNo); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &n.PrevPageNo); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &n.prevPageOffset); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &n.PrevPageOffset); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &n.nextPageNo); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &n.NextPageNo); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &n.nextPageOffset); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &n.NextPageOffset); nil != err {
 		return errors.Trace(err)
 	}
 	return nil
@@ -72,77 +72,77 @@ func (n *ListNode) parse(r io.Reader) error {
 
 type FSPHeader struct {
 	// The space ID of the current space.
-	spaceID uint32
-	unused  uint32
+	SpaceID uint32
+	Unused  uint32
 	// The “size” is the highest valid page number, and is incremented
 	// when the file is grown. However, not all of these pages are initialized
 	// (some may be zero-filled), as extending a space is a multi-step process.
-	highestPageNumberInFile uint32
+	HighestPageNumberInFile uint32
 	// The “free limit” is the highest page number for which the FIL header has
 	// been initialized, storing the page number in the page itself, amongst other things.
 	// The free limit will always be less than or equal to the size.
-	highestPageNumberInitialized uint32
+	HighestPageNumberInitialized uint32
 	// Storage of flags related to the space.
 	Flags uint32
 	//
-	pagesUsedInFreeFrag uint32
+	PagesUsedInFreeFrag uint32
 	// Extents that are completely unused and available to be allocated in
 	// whole to some purpose. A FREE extent could be allocated to a file
 	// segment (and placed on the appropriate INODE list), or moved
 	// to the FREE_FRAG list for individual page use.
-	freeList ListBaseNode
+	FreeList ListBaseNode
 	// Extents with free pages remaining that are allocated to be used in “fragments”,
 	// having individual pages allocated to different purposes rather than allocating
 	// the entire extent. For example, every extent with an FSP_HDR or XDES page will be
 	// placed on the FREE_FRAG list so that the remaining free pages in the extent can be
 	// allocated for other uses.
-	freeFragList ListBaseNode
+	FreeFragList ListBaseNode
 	// Exactly like FREE_FRAG but for extents with no free pages remaining. Extents are
 	// moved from FREE_FRAG to FULL_FRAG when they become full, and moved back to FREE_FRAG
 	// if a page is released so that they are no longer full.
-	fullFragList ListBaseNode
+	FullFragList ListBaseNode
 	// The file segment ID that will be used for the next allocated file segment.
 	// (This is essentially an auto-increment integer.)
-	nextUnusedSegmentID uint64
-	fullInodesList      ListBaseNode
-	freeInodesList      ListBaseNode
+	NextUnusedSegmentID uint64
+	FullInodesList      ListBaseNode
+	FreeInodesList      ListBaseNode
 }
 
 func (h *FSPHeader) parse(r io.Reader) error {
-	if err := binary.Read(r, binary.BigEndian, &h.spaceID); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &h.SpaceID); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &h.unused); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &h.Unused); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &h.highestPageNumberInFile); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &h.HighestPageNumberInFile); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &h.highestPageNumberInitialized); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &h.HighestPageNumberInitialized); nil != err {
 		return errors.Trace(err)
 	}
 	if err := binary.Read(r, binary.BigEndian, &h.Flags); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &h.pagesUsedInFreeFrag); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &h.PagesUsedInFreeFrag); nil != err {
 		return errors.Trace(err)
 	}
-	if err := h.freeList.parse(r); nil != err {
+	if err := h.FreeList.parse(r); nil != err {
 		return errors.Trace(err)
 	}
-	if err := h.freeFragList.parse(r); nil != err {
+	if err := h.FreeFragList.parse(r); nil != err {
 		return errors.Trace(err)
 	}
-	if err := h.fullFragList.parse(r); nil != err {
+	if err := h.FullFragList.parse(r); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &h.nextUnusedSegmentID); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &h.NextUnusedSegmentID); nil != err {
 		return errors.Trace(err)
 	}
-	if err := h.fullInodesList.parse(r); nil != err {
+	if err := h.FullInodesList.parse(r); nil != err {
 		return errors.Trace(err)
 	}
-	if err := h.freeInodesList.parse(r); nil != err {
+	if err := h.FreeInodesList.parse(r); nil != err {
 		return errors.Trace(err)
 	}
 
@@ -153,47 +153,61 @@ func (h *FSPHeader) parse(r io.Reader) error {
 type XdesEntry struct {
 	// The ID of the file segment to which the extent belongs,
 	// if it belongs to a file segment.
-	fileSegmentID uint64
+	FileSegmentID uint64
 	// Pointers to previous and next extents in a doubly-linked extent descriptor list.
 	// 6bytes
-	list ListNode
+	List ListNode
 	// State: The current state of the extent, for which only four values are currently
 	// defined: FREE, FREE_FRAG, and FULL_FRAG, meaning this extent belongs to the
 	// space’s list with the same name; and FSEG, meaning this extent belongs to
 	// the file segment with the ID stored in the File Segment ID field. (More on these lists below.)
 	// TODO: get the definition of state
-	state uint32
-	// Page State Bitmap: A bitmap of 2 bits per page in the extent (64 x 2 = 128 bits, or 16 bytes).
+	State uint32
+	// Page State Bitmap: A bitmap of 2 bits per page in the extent (64 x 2 = 128 bits, or 16 bytes
+	// for 16k page).
 	// The first bit indicates whether the page is free. The second bit is reserved to indicate whether
 	// the page is clean (has no un-flushed data), but this bit is currently unused and is always set to 1.
-	pageStateBitmap [16]byte
+	PageStateBitmap []byte
 }
 
+// GetPageState returns the 2 bits state of the page in the extent, every byte of
+// the bitmap holds 4 pages from the high bits
 func (e *XdesEntry) GetPageState(pn int) byte {
-	return 0
+	if pn < 0 || pn/4 >= len(e.PageStateBitmap) {
+		return 0
+	}
+	shift := 6 - uint(pn%4)*2
+	return (e.PageStateBitmap[pn/4] >> shift) & 0x03
+}
+
+// IsPageFree checks the free bit of the page state
+func (e *XdesEntry) IsPageFree(pn int) bool {
+	return e.GetPageState(pn)&XdesPageStateFree != 0
 }
 
-func (e *XdesEntry) parse(r io.Reader) error {
-	if err := binary.Read(r, binary.BigEndian, &e.fileSegmentID); nil != err {
+func (e *XdesEntry) parse(r io.Reader, ps PageSize) error {
+	if err := binary.Read(r, binary.BigEndian, &e.FileSegmentID); nil != err {
 		return errors.Trace(err)
 	}
-	if err := e.list.parse(r); nil != err {
+	if err := e.List.parse(r); nil != err {
 		return errors.Trace(err)
 	}
-	if err := binary.Read(r, binary.BigEndian, &e.state); nil != err {
+	if err := binary.Read(r, binary.BigEndian, &e.State); nil != err {
 		return errors.Trace(err)
 	}
-	if _, err := r.Read(e.pageStateBitmap[:]); nil != err {
+	e.PageStateBitmap = make([]byte, ps.XdesBitmapSize())
+	if _, err := io.ReadFull(r, e.PageStateBitmap); nil != err {
 		return errors.Trace(err)
 	}
 	return nil
 }
 
-func (p *Page) parseXdeses(r io.Reader) error {
-	p.XDeses = make([]*XdesEntry, 0, 256)
+// parseXdeses parses the extent descriptors filling the physical page
+func (p *Page) parseXdeses(r io.Reader, ps PageSize, physical int) error {
+	p.XDeses = make([]*XdesEntry, 0, ps.ZipXdesEntries(physical))
 	for i := 0; i < cap(p.XDeses); i++ {
 		var des XdesEntry
-		if err := des.parse(r); nil != err {
+		if err := des.parse(r, ps); nil != err {
 			return errors.Trace(err)
 		}
 		p.XDeses = append(p.XDeses, &des)
@@ -0,0 +1,151 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Page size limits, innodb_page_size can be 4k, 8k, 16k, 32k or 64k
+const (
+	minPageSize     = 4 * 1024
+	defaultPageSize = 16 * 1024
+	maxPageSize     = 64 * 1024
+)
+
+// FSP header flags layout, reference to fsp0types.h
+const (
+	fspFlagsPosZipSsize  = 1
+	fspFlagsPosPageSsize = 6
+	fspFlagsMaskSsize    = 0x0f
+	// MariaDB full_crc32 format stores page ssize in the lowest 4 bits
+	// and set the marker bit
+	fspFlagsFcrc32Marker = 0x10
+)
+
+// Offset of the flags field in page 0, file header (38) + space id (4) +
+// unused (4) + size (4) + free limit (4)
+const fspHeaderFlagsOffset = 38 + 16
+
+// PageSize is the physical page size in bytes of a table space, all layout
+// depends on it can be calculated from it
+type PageSize int
+
+func (s PageSize) Valid() bool {
+	switch s {
+	case 4 * 1024, 8 * 1024, 16 * 1024, 32 * 1024, 64 * 1024:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// Extent is 1MB for page size <= 16k, and 64 pages for 32k and 64k
+func (s PageSize) ExtentPages() int {
+	if s <= defaultPageSize {
+		return (1024 * 1024) / int(s)
+	}
+	return 64
+}
+
+// Every xdes (or fsp) page describes the following page size pages
+func (s PageSize) XdesEntries() int {
+	return s.ZipXdesEntries(int(s))
+}
+
+// ZipXdesEntries is XdesEntries of the compressed table space, the extent is of
+// the logical page size, and the xdes page describes the zip size pages,
+// reference to xdes_arr_size
+func (s PageSize) ZipXdesEntries(zipSize int) int {
+	return zipSize / s.ExtentPages()
+}
+
+// 2 bits per page in the extent
+func (s PageSize) XdesBitmapSize() int {
+	return s.ExtentPages() * 2 / 8
+}
+
+// file segment id (8) + list node (12) + state (4) + page state bitmap
+func (s PageSize) XdesEntrySize() int {
+	return 8 + 12 + 4 + s.XdesBitmapSize()
+}
+
+func (s PageSize) FragmentSlots() int {
+	return s.ExtentPages() / 2
+}
+
+// segment id (8) + used (4) + 3 list base node (16 each) + magic (4) + fragment array
+func (s PageSize) InodeEntrySize() int {
+	return 8 + 4 + 16*3 + 4 + 4*s.FragmentSlots()
+}
+
+// File header (38) + page list node (12) are before the inodes, and 10 bytes reserved
+// at the end of the page
+func (s PageSize) InodesPerPage() int {
+	return s.ZipInodesPerPage(int(s))
+}
+
+// ZipInodesPerPage is InodesPerPage of the compressed table space, the inodes of
+// the logical page size fill the page of the zip size
+func (s PageSize) ZipInodesPerPage(zipSize int) int {
+	return (zipSize - 38 - 12 - 10) / s.InodeEntrySize()
+}
+
+func ssizeToPageSize(ssize uint32) int {
+	if ssize == 0 {
+		return 0
+	}
+	return 512 << ssize
+}
+
+// pageSizeFromFlags decodes the page size from FSPHeader.Flags
+func pageSizeFromFlags(flags uint32) (int, error) {
+	var size int
+	if flags&fspFlagsFcrc32Marker != 0 {
+		// MariaDB full_crc32, the marker bit overlaps the highest bit of zip ssize
+		// which can't be set in mysql format (zip ssize <= 5)
+		size = ssizeToPageSize(flags & fspFlagsMaskSsize)
+	} else {
+		size = ssizeToPageSize((flags >> fspFlagsPosPageSsize) & fspFlagsMaskSsize)
+		if 0 == size {
+			// Zero means the original 16k page size
+			size = defaultPageSize
+		}
+	}
+	if !PageSize(size).Valid() {
+		return 0, errors.Errorf("Invalid page size %d in fsp flags 0x%08X", size, flags)
+	}
+	return size, nil
+}
+
+// readFSPFlags reads the FSP header flags from page 0
+func readFSPFlags(f io.ReaderAt) (uint32, error) {
+	var buf [4]byte
+	if _, err := f.ReadAt(buf[:], fspHeaderFlagsOffset); nil != err {
+		return 0, errors.Errorf("Read fsp header flags error %v", err)
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+// detectPageSize reads the FSP header flags from page 0 to get the page size
+func detectPageSize(f io.ReaderAt) (int, error) {
+	flags, err := readFSPFlags(f)
+	if nil != err {
+		return 0, err
+	}
+	return pageSizeFromFlags(flags)
+}
+
+// resolvePageSize uses the specified page size if not zero, otherwise
+// detects it from page 0
+func resolvePageSize(f io.ReaderAt, size int) (int, error) {
+	if 0 == size {
+		return detectPageSize(f)
+	}
+	if !PageSize(size).Valid() {
+		return 0, errors.Errorf("Invalid page size %d", size)
+	}
+	return size, nil
+}
@@ -0,0 +1,274 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// Undo log page layout, reference to trx0undo.h. Every undo page starts with
+// the undo page header, the first page of the undo segment stores the undo
+// segment header and the undo log headers after it
+const (
+	undoPageHeaderSize    = 18
+	undoSegmentHeaderSize = 30
+	// The records of the other pages start after the page header
+	undoPageDataOffset = undoPageHeaderOffset + undoPageHeaderSize
+	// The first undo log header of the segment header page
+	undoLogHeaderOffset = undoPageDataOffset + undoSegmentHeaderSize
+	// Undo log header without the XID
+	undoLogOldHeaderSize = 46
+	undoXIDDataSize      = 128
+)
+
+// Undo page types
+const (
+	UndoPageTypeInsert = 1
+	UndoPageTypeUpdate = 2
+)
+
+// Undo segment states
+const (
+	UndoStateActive   = 1
+	UndoStateCached   = 2
+	UndoStateToFree   = 3
+	UndoStateToPurge  = 4
+	UndoStatePrepared = 5
+	// 8.0.29+ prepared in the transaction coordinator
+	UndoStatePreparedInTC = 6
+)
+
+// Undo log header flags, the XID flag is the XID_EXISTS byte before 8.0
+const (
+	UndoFlagXID           = 0x01
+	UndoFlagGTID          = 0x02
+	UndoFlagXAPrepareGTID = 0x04
+)
+
+var undoPageTypeStrs = map[uint16]string{
+	UndoPageTypeInsert: "insert",
+	UndoPageTypeUpdate: "update",
+}
+
+var undoStateStrs = map[uint16]string{
+	UndoStateActive:       "active",
+	UndoStateCached:       "cached",
+	UndoStateToFree:       "to free",
+	UndoStateToPurge:      "to purge",
+	UndoStatePrepared:     "prepared",
+	UndoStatePreparedInTC: "prepared in tc",
+}
+
+func UndoPageTypeToString(tp uint16) string {
+	if s, ok := undoPageTypeStrs[tp]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown(%d)", tp)
+}
+
+func UndoStateToString(state uint16) string {
+	if s, ok := undoStateStrs[state]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown(%d)", state)
+}
+
+// UndoPageHeader is stored in all undo pages
+type UndoPageHeader struct {
+	// Insert or update undo log
+	Type uint16
+	// Offset of the first undo record of the latest undo log on the page
+	Start uint16
+	// Offset of the first free byte, the next record is appended there
+	Free uint16
+	// Node of the page list of the undo segment
+	PageList ListNode
+}
+
+func (h *UndoPageHeader) parse(r io.Reader) error {
+	if err := binary.Read(r, binary.BigEndian, &h.Type); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.Start); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.Free); nil != err {
+		return errors.Trace(err)
+	}
+	if err := h.PageList.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// UndoSegmentHeader is stored in the first page of the undo segment
+type UndoSegmentHeader struct {
+	State uint16
+	// Offset of the last undo log header on the page
+	LastLog uint16
+	// The file segment of the undo pages
+	FileSegment FileSegmentHeader
+	// All pages of the undo segment, the first page is the segment header page
+	PageList ListBaseNode
+}
+
+func (h *UndoSegmentHeader) parse(r io.Reader) error {
+	if err := binary.Read(r, binary.BigEndian, &h.State); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.LastLog); nil != err {
+		return errors.Trace(err)
+	}
+	if err := h.FileSegment.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	if err := h.PageList.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// XID is the XA transaction id of the prepared transaction
+type XID struct {
+	FormatID    int32
+	GtridLength uint32
+	BqualLength uint32
+	Data        [undoXIDDataSize]byte
+}
+
+// Gtrid returns the global transaction id
+func (x *XID) Gtrid() []byte {
+	n := int(x.GtridLength)
+	if n > len(x.Data) {
+		n = len(x.Data)
+	}
+	return x.Data[:n]
+}
+
+// Bqual returns the branch qualifier
+func (x *XID) Bqual() []byte {
+	start := int(x.GtridLength)
+	end := start + int(x.BqualLength)
+	if end > len(x.Data) {
+		return nil
+	}
+	return x.Data[start:end]
+}
+
+// String formats the XID like XA RECOVER CONVERT XID
+func (x *XID) String() string {
+	if x.FormatID < 0 {
+		return "NULL"
+	}
+	return fmt.Sprintf("X'%X',X'%X',%d", x.Gtrid(), x.Bqual(), x.FormatID)
+}
+
+// UndoLogHeader describes an undo log of a transaction, the header of the cached
+// undo segment is reused and the new headers are appended on the page
+type UndoLogHeader struct {
+	// Offset of the header in the page
+	Offset int
+	TrxID  uint64
+	// Transaction number of the committed transaction
+	TrxNo uint64
+	// The undo log contains delete marks
+	DelMarks uint16
+	// Offset of the first undo record of the undo log
+	LogStart uint16
+	Flags    uint8
+	// DDL transaction
+	DictTrans uint8
+	TableID   uint64
+	// Offset of the next and the previous undo log headers on the page
+	NextLog uint16
+	PrevLog uint16
+	// Node of the history list of the rollback segment
+	HistoryNode ListNode
+	// XID of the XA transaction, nil if not exists
+	XID *XID
+}
+
+func (h *UndoLogHeader) parse(r io.Reader) error {
+	if err := binary.Read(r, binary.BigEndian, &h.TrxID); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.TrxNo); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.DelMarks); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.LogStart); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.Flags); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.DictTrans); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.TableID); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.NextLog); nil != err {
+		return errors.Trace(err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.PrevLog); nil != err {
+		return errors.Trace(err)
+	}
+	if err := h.HistoryNode.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	if 0 == h.Flags&UndoFlagXID {
+		return nil
+	}
+	h.XID = &XID{}
+	if err := binary.Read(r, binary.BigEndian, h.XID); nil != err {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// IsUndoSegmentHeaderPage returns true if the page stores the undo segment header,
+// the records of the other pages start right after the undo page header
+func (p *Page) IsUndoSegmentHeaderPage() bool {
+	return p.FileHeader.Type == PageTypeUndoLog && nil != p.UndoSegmentHeader
+}
+
+// parseUndoPage parses the undo page header, and the undo segment header and
+// the undo log headers of the segment header page
+func (p *Page) parseUndoPage(r io.Reader, data []byte) error {
+	if err := p.UndoPageHeader.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	if int(p.UndoPageHeader.Start) < undoLogHeaderOffset+undoLogOldHeaderSize {
+		return nil
+	}
+
+	var seg UndoSegmentHeader
+	if err := seg.parse(r); nil != err {
+		return errors.Trace(err)
+	}
+	p.UndoSegmentHeader = &seg
+	// The headers are linked from the first one at the end of the segment
+	// header, the last one is the latest undo log
+	offset := undoLogHeaderOffset
+	for 0 != offset && len(p.UndoLogHeaders) < len(data)/undoLogOldHeaderSize {
+		if offset+undoLogOldHeaderSize+12+undoXIDDataSize > len(data)-8 {
+			return errors.Errorf("Undo log header offset 0x%04X out of page", offset)
+		}
+		h := &UndoLogHeader{Offset: offset}
+		if err := h.parse(bytes.NewReader(data[offset:])); nil != err {
+			return errors.Trace(err)
+		}
+		p.UndoLogHeaders = append(p.UndoLogHeaders, h)
+		if offset == int(seg.LastLog) {
+			break
+		}
+		offset = int(h.NextLog)
+	}
+	return nil
+}
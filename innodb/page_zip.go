@@ -0,0 +1,794 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// The compressed page of ROW_FORMAT=COMPRESSED, reference to page0zip.cc. The
+// page header is stored uncompressed, then the zlib stream of the index fields
+// and the records, the modification log, and the uncompressed columns and the
+// dense page directory at the end of the page
+const (
+	// FIL header (38) + index page header (36) + 2 file segment headers (20)
+	pageZipHeaderSize = 94
+	// The infimum and supremum records are not stored
+	pageZipStart       = 120
+	pageZipInfimum     = 99
+	pageZipSupremum    = 112
+	pageZipDirSlotSize = 2
+	pageZipDirSlotMask = 0x3fff
+	pageZipDirOwned    = 0x4000
+	pageZipDirDel      = 0x8000
+	// DB_TRX_ID (6) + DB_ROLL_PTR (7) of the clustered index leaf records
+	pageZipTrxRollSize = 13
+	pageZipNodePtrSize = 4
+	recNewExtraBytes   = 5
+	recHeapNoShift     = 3
+	recStatusNodePtr   = 1
+	recInfoMinRecFlag  = 0x10
+	recInfoDeletedFlag = 0x20
+)
+
+// Offsets of the index page header fields used to decompress the page
+const (
+	pageHeaderNDirSlots = 38 + 0
+	pageHeaderHeapTop   = 38 + 2
+	pageHeaderNHeap     = 38 + 4
+	pageHeaderNRecs     = 38 + 16
+	pageHeaderLevel     = 38 + 26
+)
+
+// zipSizeFromFlags decodes the compressed page size from FSPHeader.Flags, 0 if
+// the table space is not compressed
+func zipSizeFromFlags(flags uint32) int {
+	if flags&fspFlagsFcrc32Marker != 0 {
+		// MariaDB full_crc32 format doesn't support ROW_FORMAT=COMPRESSED
+		return 0
+	}
+	return ssizeToPageSize((flags >> fspFlagsPosZipSsize) & fspFlagsMaskSsize)
+}
+
+// zipReader reads the compressed table space as the uncompressed one. The
+// physical pages of the zip size are decompressed into the logical pages if
+// they are index pages, the other pages are stored uncompressed and only
+// padded to the logical page size. The FSP_HDR, XDES and INODE pages keep the
+// layout of the zip size, they are parsed with ParsePageOptions.ZipSize
+type zipReader struct {
+	r        io.ReaderAt
+	zipSize  int
+	pageSize int
+	size     int64
+}
+
+func newZipReader(r io.ReaderAt, zipSize int, pageSize int) (*zipReader, error) {
+	size, err := readerSize(r)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	return &zipReader{
+		r:        r,
+		zipSize:  zipSize,
+		pageSize: pageSize,
+		size:     size / int64(zipSize) * int64(pageSize),
+	}, nil
+}
+
+// Size returns the logical size of the table space
+func (z *zipReader) Size() int64 {
+	return z.size
+}
+
+func (z *zipReader) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	page := make([]byte, z.pageSize)
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= z.size {
+			return n, io.EOF
+		}
+		pageNo := int(pos / int64(z.pageSize))
+		if err := z.readPage(pageNo, page); nil != err {
+			return n, errors.Trace(err)
+		}
+		n += copy(p[n:], page[pos%int64(z.pageSize):])
+	}
+	return n, nil
+}
+
+// readPage reads the physical page and converts it to the logical page
+func (z *zipReader) readPage(pageNo int, page []byte) error {
+	zip := make([]byte, z.zipSize)
+	if err := readPageData(z.r, pageNo, zip); nil != err {
+		return errors.Trace(err)
+	}
+	typ := binary.BigEndian.Uint16(zip[24:])
+	if (PageTypeIndex == typ || PageTypeSDI == typ) && !IsEmptyPage(zip) {
+		if err := decompressZipPage(zip, page); nil != err {
+			return errors.Annotatef(err, "Decompress page %d", pageNo)
+		}
+		return nil
+	}
+	for i := range page {
+		page[i] = 0
+	}
+	copy(page, zip)
+	// Keep the trailer at the end of the page
+	copy(page[len(page)-8:], zip[len(zip)-8:])
+	return nil
+}
+
+// zipField is the field of the index decoded from the compressed page, the
+// adjacent fixed length not null columns are merged into one field
+type zipField struct {
+	// 0 if variable length
+	fixedLen int
+	notNull  bool
+	// The variable length field with max length > 255 bytes, the length may
+	// be stored in 2 bytes
+	big bool
+}
+
+type zipIndex struct {
+	fields    []zipField
+	nNullable int
+	// The field of DB_TRX_ID and DB_ROLL_PTR of the clustered index leaf page,
+	// -1 if not clustered index leaf page
+	trxIDCol int
+}
+
+// decodeZipFields decodes the index fields stored at the beginning of the zlib
+// stream
+func decodeZipFields(buf []byte, leaf bool) (*zipIndex, error) {
+	n := 0
+	for b := 0; b < len(buf); n++ {
+		if 0 != buf[b]&0x80 {
+			b++
+		}
+		b++
+	}
+	// The last one is the trx id field or the nullable fields count
+	n--
+	if n <= 0 || n > 1023 {
+		return nil, errors.Errorf("Invalid compressed index fields count %d", n)
+	}
+
+	b := 0
+	next := func() (int, error) {
+		if b >= len(buf) {
+			return 0, errors.New("Compressed index fields are truncated")
+		}
+		val := int(buf[b])
+		b++
+		if 0 != val&0x80 {
+			if b >= len(buf) {
+				return 0, errors.New("Compressed index fields are truncated")
+			}
+			val = (val&0x7f)<<8 | int(buf[b])
+			b++
+			// Mark the value of 2 bytes, it is always fixed length
+			val |= 0x10000
+		}
+		return val, nil
+	}
+	idx := &zipIndex{trxIDCol: -1}
+	for i := 0; i < n; i++ {
+		val, err := next()
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		f := zipField{notNull: 0 != val&1}
+		switch {
+		case 0 != val&0x10000:
+			{
+				f.fixedLen = (val & 0xffff) >> 1
+			}
+		case val >= 126:
+			{
+				f.big = true
+			}
+		case val > 1:
+			{
+				f.fixedLen = val >> 1
+			}
+		}
+		if !f.notNull {
+			idx.nNullable++
+		}
+		idx.fields = append(idx.fields, f)
+	}
+	val, err := next()
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	val &= 0xffff
+	if leaf {
+		if 0 != val {
+			if val >= n {
+				return nil, errors.Errorf("Invalid compressed trx id field %d of %d fields", val, n)
+			}
+			idx.trxIDCol = val
+		}
+	} else {
+		// The null bitmap of the node pointer records covers all fields of
+		// the index
+		if idx.nNullable > val {
+			return nil, errors.Errorf("Invalid compressed nullable fields %d", val)
+		}
+		idx.nNullable = val
+	}
+	return idx, nil
+}
+
+// zipRecOffsets is the field offsets of the record relative to the origin
+type zipRecOffsets struct {
+	ends    []int
+	externs []bool
+	extern  bool
+	// Extra bytes before the origin, including the 5 bytes header
+	extra int
+}
+
+func (o *zipRecOffsets) start(i int) int {
+	if 0 == i {
+		return 0
+	}
+	return o.ends[i-1]
+}
+
+func (o *zipRecOffsets) dataSize() int {
+	return o.ends[len(o.ends)-1]
+}
+
+// offsets decodes the null bitmap and the lengths, ext is the extra bytes from
+// the one before the 5 bytes header backwards, which is also the order of the
+// modification log
+func (idx *zipIndex) offsets(ext []byte, nodePtr bool) (*zipRecOffsets, error) {
+	n := len(idx.fields)
+	if nodePtr {
+		n++
+	}
+	o := &zipRecOffsets{
+		ends:    make([]int, n),
+		externs: make([]bool, n),
+	}
+	nulls := 0
+	nullMask := 1
+	lens := (idx.nNullable + 7) / 8
+	offs := 0
+	get := func(k int) (int, error) {
+		if k >= len(ext) {
+			return 0, errors.New("Compressed record header is truncated")
+		}
+		return int(ext[k]), nil
+	}
+	for i := 0; i < n; i++ {
+		if i == len(idx.fields) {
+			offs += pageZipNodePtrSize
+			o.ends[i] = offs
+			continue
+		}
+		f := &idx.fields[i]
+		if !f.notNull {
+			if 0x100 == nullMask {
+				nulls++
+				nullMask = 1
+			}
+			v, err := get(nulls)
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			isNull := 0 != v&nullMask
+			nullMask <<= 1
+			if isNull {
+				o.ends[i] = offs
+				continue
+			}
+		}
+		if 0 != f.fixedLen {
+			offs += f.fixedLen
+			o.ends[i] = offs
+			continue
+		}
+		l, err := get(lens)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		lens++
+		if f.big && 0 != l&0x80 {
+			v, err := get(lens)
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			lens++
+			l = l<<8 | v
+			if 0 != l&0x4000 {
+				o.externs[i] = true
+				o.extern = true
+			}
+			l &= 0x3fff
+		}
+		offs += l
+		o.ends[i] = offs
+	}
+	o.extra = recNewExtraBytes + lens
+	return o, nil
+}
+
+// zipPageDecoder rebuilds the logical page from the compressed page
+type zipPageDecoder struct {
+	zip  []byte
+	page []byte
+	idx  *zipIndex
+	// The records in the heap order, the user records first in the dense
+	// directory, then the free records
+	recs       []int
+	nRecs      int
+	leaf       bool
+	heapStatus int
+	// The inflated stream and the read position, and the page offset to write
+	stream []byte
+	in     int
+	out    int
+}
+
+func (d *zipPageDecoder) dir(i int) int {
+	return int(binary.BigEndian.Uint16(d.zip[len(d.zip)-(i+1)*pageZipDirSlotSize:]))
+}
+
+// recOffsets returns the offsets of the record on the page
+func (d *zipPageDecoder) recOffsets(rec int) (*zipRecOffsets, error) {
+	// The null bitmap and at most 2 bytes length of every field
+	limit := (d.idx.nNullable+7)/8 + 2*len(d.idx.fields)
+	ext := make([]byte, 0, limit)
+	for k := rec - recNewExtraBytes - 1; k >= pageZipStart && len(ext) < limit; k-- {
+		ext = append(ext, d.page[k])
+	}
+	nodePtr := 0 != binary.BigEndian.Uint16(d.page[rec-4:])&recStatusNodePtr
+	o, err := d.idx.offsets(ext, nodePtr)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	if err = d.checkRecord(rec, o); nil != err {
+		return nil, errors.Trace(err)
+	}
+	return o, nil
+}
+
+func (d *zipPageDecoder) checkRecord(rec int, o *zipRecOffsets) error {
+	if rec-o.extra < pageZipStart || rec+o.dataSize() > len(d.page)-8 {
+		return errors.Errorf("Compressed record 0x%04X out of page", rec)
+	}
+	return nil
+}
+
+// fill inflates the stream to the page until the offset, returns false if the
+// stream ends before the offset
+func (d *zipPageDecoder) fill(end int) (bool, error) {
+	n := end - d.out
+	if n < 0 || end > len(d.page) {
+		return false, errors.Errorf("Invalid compressed stream output offset 0x%04X", end)
+	}
+	avail := len(d.stream) - d.in
+	if avail <= n {
+		d.out += copy(d.page[d.out:end], d.stream[d.in:])
+		d.in = len(d.stream)
+		return false, nil
+	}
+	d.out += copy(d.page[d.out:end], d.stream[d.in:d.in+n])
+	d.in += n
+	return true, nil
+}
+
+// fillData inflates the data bytes of the record, the stream must not end
+func (d *zipPageDecoder) fillData(end int) error {
+	if end-d.out > len(d.stream)-d.in {
+		return errors.Errorf("Compressed stream ends in the record before 0x%04X", end)
+	}
+	_, err := d.fill(end)
+	return errors.Trace(err)
+}
+
+// heapNo skips the 5 bytes header and sets the heap number of the record
+func (d *zipPageDecoder) heapNo(rec int) {
+	if d.out != rec-recNewExtraBytes {
+		// The record is added after the page is compressed
+		return
+	}
+	d.out = rec
+	binary.BigEndian.PutUint16(d.page[rec-4:], uint16(d.heapStatus))
+	d.heapStatus += 1 << recHeapNoShift
+}
+
+// decompressRecords inflates the records in the heap order, the stored columns
+// are skipped and they are left zero
+func (d *zipPageDecoder) decompressRecords() error {
+	for _, rec := range d.recs {
+		more, err := d.fill(rec - recNewExtraBytes)
+		if nil != err {
+			return errors.Trace(err)
+		}
+		d.heapNo(rec)
+		if !more {
+			return nil
+		}
+		if !d.leaf {
+			o, err := d.recOffsets(rec)
+			if nil != err {
+				return errors.Trace(err)
+			}
+			// The child page number is stored uncompressed
+			if err = d.fillData(rec + o.dataSize() - pageZipNodePtrSize); nil != err {
+				return errors.Trace(err)
+			}
+			d.out += pageZipNodePtrSize
+			continue
+		}
+		if d.idx.trxIDCol < 0 {
+			// The secondary index records are inflated with the following
+			// record header
+			continue
+		}
+		o, err := d.recOffsets(rec)
+		if nil != err {
+			return errors.Trace(err)
+		}
+		for i := range o.ends {
+			if i == d.idx.trxIDCol {
+				if o.ends[i]-o.start(i) < pageZipTrxRollSize {
+					return errors.Errorf("Invalid trx id length of compressed record 0x%04X", rec)
+				}
+				if err = d.fillData(rec + o.start(i)); nil != err {
+					return errors.Trace(err)
+				}
+				d.out += pageZipTrxRollSize
+			} else if o.externs[i] {
+				if o.ends[i]-o.start(i) < externFieldRefSize {
+					return errors.Errorf("Invalid extern field length of compressed record 0x%04X", rec)
+				}
+				if err = d.fillData(rec + o.ends[i] - externFieldRefSize); nil != err {
+					return errors.Trace(err)
+				}
+				d.out += externFieldRefSize
+			}
+		}
+		if err = d.fillData(rec + o.dataSize()); nil != err {
+			return errors.Trace(err)
+		}
+	}
+
+	// The garbage after the last record up to the heap top
+	heapTop := int(binary.BigEndian.Uint16(d.page[pageHeaderHeapTop:]))
+	if heapTop < d.out || heapTop > len(d.page)-8 {
+		return errors.Errorf("Invalid heap top 0x%04X of compressed page", heapTop)
+	}
+	if more, err := d.fill(heapTop); nil != err {
+		return errors.Trace(err)
+	} else if more {
+		return errors.New("Compressed stream is longer than the heap top")
+	}
+	return nil
+}
+
+// applyLog applies the modification log from the offset before the end, every
+// entry is the heap number and the record written after the page compressed.
+// The offset of the log end is returned
+func (d *zipPageDecoder) applyLog(pos int, end int) (int, error) {
+	for {
+		if pos >= end {
+			return 0, errors.New("Modification log is not terminated")
+		}
+		val := int(d.zip[pos])
+		pos++
+		if 0 == val {
+			return pos - 1, nil
+		}
+		if 0 != val&0x80 {
+			val = (val&0x7f)<<8 | int(d.zip[pos])
+			pos++
+			if 0 == val {
+				return 0, errors.New("Invalid modification log entry")
+			}
+		}
+		if pos >= end || 0 == val>>1 || val>>1 > len(d.recs) {
+			return 0, errors.Errorf("Invalid modification log entry of heap no %d", val>>1+1)
+		}
+		rec := d.recs[val>>1-1]
+		hs := (val>>1+1)<<recHeapNoShift | d.heapStatus&(1<<recHeapNoShift-1)
+		if hs > d.heapStatus {
+			return 0, errors.Errorf("Modification log heap no %d out of the heap", val>>1+1)
+		} else if hs == d.heapStatus {
+			// A new record allocated from the heap
+			if 0 != val&1 {
+				return 0, errors.New("Modification log clears the new record")
+			}
+			d.heapStatus += 1 << recHeapNoShift
+		}
+		binary.BigEndian.PutUint16(d.page[rec-4:], uint16(hs))
+		nodePtr := 0 != hs&recStatusNodePtr
+
+		if 0 != val&1 {
+			// The record is cleared
+			o, err := d.recOffsets(rec)
+			if nil != err {
+				return 0, errors.Trace(err)
+			}
+			for i := rec; i < rec+o.dataSize(); i++ {
+				d.page[i] = 0
+			}
+			continue
+		}
+
+		o, err := d.idx.offsets(d.zip[pos:end], nodePtr)
+		if nil != err {
+			return 0, errors.Trace(err)
+		}
+		if err = d.checkRecord(rec, o); nil != err {
+			return 0, errors.Trace(err)
+		}
+		// The extra bytes are stored backwards
+		for b := rec - recNewExtraBytes; b != rec-o.extra; pos++ {
+			b--
+			d.page[b] = d.zip[pos]
+		}
+		copyLog := func(dst int, n int) error {
+			if n < 0 || pos+n >= end {
+				return errors.Errorf("Modification log of record 0x%04X is truncated", rec)
+			}
+			pos += copy(d.page[dst:dst+n], d.zip[pos:pos+n])
+			return nil
+		}
+		switch {
+		case nodePtr:
+			{
+				err = copyLog(rec, o.dataSize()-pageZipNodePtrSize)
+			}
+		case d.idx.trxIDCol < 0:
+			{
+				err = copyLog(rec, o.dataSize())
+			}
+		default:
+			{
+				// The trx id, roll ptr and the extern references are stored
+				// uncompressed
+				next := rec
+				for i := range o.ends {
+					if i == d.idx.trxIDCol {
+						dst := rec + o.start(i)
+						if err = copyLog(next, dst-next); nil != err {
+							break
+						}
+						next = dst + pageZipTrxRollSize
+					} else if o.externs[i] {
+						dst := rec + o.ends[i] - externFieldRefSize
+						if err = copyLog(next, dst-next); nil != err {
+							break
+						}
+						next = dst + externFieldRefSize
+					}
+				}
+				if nil == err {
+					err = copyLog(next, rec+o.dataSize()-next)
+				}
+			}
+		}
+		if nil != err {
+			return 0, errors.Trace(err)
+		}
+	}
+}
+
+// restoreColumns copies the uncompressed columns in the heap order, they are
+// stored before the dense directory backwards
+func (d *zipPageDecoder) restoreColumns(logEnd int) error {
+	storage := len(d.zip) - len(d.recs)*pageZipDirSlotSize
+	if !d.leaf {
+		for _, rec := range d.recs {
+			o, err := d.recOffsets(rec)
+			if nil != err {
+				return errors.Trace(err)
+			}
+			storage -= pageZipNodePtrSize
+			copy(d.page[rec+o.dataSize()-pageZipNodePtrSize:], d.zip[storage:storage+pageZipNodePtrSize])
+		}
+		return nil
+	}
+	if d.idx.trxIDCol < 0 {
+		return nil
+	}
+
+	externs := storage - len(d.recs)*pageZipTrxRollSize
+	free := make(map[int]bool)
+	for i := d.nRecs; i < len(d.recs); i++ {
+		free[d.dir(i)] = true
+	}
+	for _, rec := range d.recs {
+		o, err := d.recOffsets(rec)
+		if nil != err {
+			return errors.Trace(err)
+		}
+		storage -= pageZipTrxRollSize
+		copy(d.page[rec+o.start(d.idx.trxIDCol):], d.zip[storage:storage+pageZipTrxRollSize])
+		for i := range o.ends {
+			if !o.externs[i] {
+				continue
+			}
+			if free[rec] {
+				// The references of the deleted records are cleared
+				continue
+			}
+			dst := rec + o.ends[i] - externFieldRefSize
+			externs -= externFieldRefSize
+			if externs < logEnd {
+				return errors.New("Extern references of compressed page overlap the modification log")
+			}
+			copy(d.page[dst:dst+externFieldRefSize], d.zip[externs:])
+		}
+	}
+	return nil
+}
+
+// setExtraBytes sets the record list, the owned records and the deleted flags
+// from the dense directory, and links the free records
+func (d *zipPageDecoder) setExtraBytes(infoBits byte) {
+	setNext := func(rec int, next int) {
+		v := 0
+		if 0 != next {
+			v = (next - rec) & 0xffff
+		}
+		binary.BigEndian.PutUint16(d.page[rec-2:], uint16(v))
+	}
+	rec := pageZipInfimum
+	nOwned := byte(1)
+	for i := 0; i < d.nRecs; i++ {
+		offs := d.dir(i)
+		if 0 != offs&pageZipDirDel {
+			infoBits |= recInfoDeletedFlag
+		}
+		if 0 != offs&pageZipDirOwned {
+			infoBits |= nOwned
+			nOwned = 1
+		} else {
+			nOwned++
+		}
+		offs &= pageZipDirSlotMask
+		setNext(rec, offs)
+		rec = offs
+		d.page[rec-recNewExtraBytes] = infoBits
+		infoBits = 0
+	}
+	setNext(rec, pageZipSupremum)
+	d.page[pageZipSupremum-recNewExtraBytes] = nOwned
+
+	var prev int
+	for i := d.nRecs; i < len(d.recs); i++ {
+		offs := d.dir(i)
+		d.page[offs-recNewExtraBytes] = 0
+		if 0 != prev {
+			setNext(prev, offs)
+		}
+		prev = offs
+	}
+	if 0 != prev {
+		setNext(prev, 0)
+	}
+}
+
+// decompressZipPage decompresses the compressed index page into the page, the
+// length of page is the logical page size
+func decompressZipPage(zip []byte, page []byte) error {
+	for i := range page {
+		page[i] = 0
+	}
+	copy(page, zip[:pageZipHeaderSize])
+	nHeap := int(binary.BigEndian.Uint16(page[pageHeaderNHeap:]) & 0x7fff)
+	nRecs := int(binary.BigEndian.Uint16(page[pageHeaderNRecs:]))
+	nSlots := int(binary.BigEndian.Uint16(page[pageHeaderNDirSlots:]))
+	nDense := nHeap - 2
+	if nDense < 0 || nRecs > nDense || pageZipHeaderSize+nDense*pageZipDirSlotSize >= len(zip) ||
+		nSlots < 2 || 8+nSlots*2 > len(page)-pageZipStart {
+		return errors.Errorf("Invalid compressed page header, %d heap records, %d records, %d slots",
+			nHeap, nRecs, nSlots)
+	}
+	d := &zipPageDecoder{
+		zip:        zip,
+		page:       page,
+		nRecs:      nRecs,
+		leaf:       0 == binary.BigEndian.Uint16(page[pageHeaderLevel:]),
+		heapStatus: 2 << recHeapNoShift,
+		out:        pageZipStart,
+	}
+	if !d.leaf {
+		d.heapStatus |= recStatusNodePtr
+	}
+
+	// The infimum and supremum records
+	copy(page[pageZipInfimum-recNewExtraBytes:], []byte{0x01, 0x00, 0x02})
+	copy(page[pageZipInfimum:], "infimum\x00")
+	copy(page[pageZipSupremum-recNewExtraBytes+1:], []byte{0x00, 0x0b, 0x00, 0x00})
+	copy(page[pageZipSupremum:], "supremum")
+
+	// The sparse directory is rebuilt from the owned records of the dense one
+	slot := len(page) - 8 - 2
+	binary.BigEndian.PutUint16(page[slot:], pageZipInfimum)
+	slot -= 2
+	d.recs = make([]int, nDense)
+	for i := 0; i < nDense; i++ {
+		offs := d.dir(i)
+		if i < nRecs {
+			if 0 != offs&pageZipDirOwned {
+				if slot <= len(page)-8-nSlots*2 {
+					return errors.New("Owned records of the compressed page exceed the slots")
+				}
+				binary.BigEndian.PutUint16(page[slot:], uint16(offs&pageZipDirSlotMask))
+				slot -= 2
+			}
+			offs &= pageZipDirSlotMask
+		} else if 0 != offs&^pageZipDirSlotMask {
+			return errors.Errorf("Invalid free record 0x%04X of compressed page", offs)
+		}
+		if offs < pageZipStart+recNewExtraBytes || offs >= len(page)-8-nSlots*2 {
+			return errors.Errorf("Invalid record 0x%04X of compressed page", offs)
+		}
+		d.recs[i] = offs
+	}
+	binary.BigEndian.PutUint16(page[slot:], pageZipSupremum)
+	if slot != len(page)-8-nSlots*2 {
+		return errors.Errorf("Owned records of the compressed page mismatch %d slots", nSlots)
+	}
+	sort.Ints(d.recs)
+
+	// The index fields are flushed before the records, the first read returns
+	// them only
+	br := bytes.NewReader(zip[pageZipHeaderSize:])
+	zr, err := zlib.NewReader(br)
+	if nil != err {
+		return errors.Trace(err)
+	}
+	defer zr.Close()
+	fields := make([]byte, len(page))
+	n, err := zr.Read(fields)
+	if nil != err && io.EOF != err {
+		return errors.Trace(err)
+	}
+	if d.idx, err = decodeZipFields(fields[:n], d.leaf); nil != err {
+		return errors.Trace(err)
+	}
+	if d.stream, err = ioutil.ReadAll(zr); nil != err {
+		return errors.Trace(err)
+	}
+	if err = d.decompressRecords(); nil != err {
+		return errors.Trace(err)
+	}
+
+	// The modification log follows the stream, the uncompressed columns are
+	// before the dense directory
+	trailer := nDense * pageZipDirSlotSize
+	if !d.leaf {
+		trailer += nDense * pageZipNodePtrSize
+	} else if d.idx.trxIDCol >= 0 {
+		trailer += nDense * pageZipTrxRollSize
+	}
+	logStart := len(zip) - br.Len()
+	logEnd, err := d.applyLog(logStart, len(zip)-trailer)
+	if nil != err {
+		return errors.Trace(err)
+	}
+	if err = d.restoreColumns(logEnd); nil != err {
+		return errors.Trace(err)
+	}
+
+	var infoBits byte
+	if !d.leaf && 0xffffffff == binary.BigEndian.Uint32(page[8:]) {
+		// The first record of the leftmost non-leaf page is the minimum record
+		infoBits = recInfoMinRecFlag
+	}
+	d.setExtraBytes(infoBits)
+	return nil
+}
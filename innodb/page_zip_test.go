@@ -0,0 +1,439 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+)
+
+// newTestZipSpace returns the compressed table space of 16KB logical pages and
+// the zip size, page 0 is FSP_HDR and page 2 is INODE. Every page is filled with
+// the pattern after the structures, so the padding is never parsed as them
+func newTestZipSpace(zipSize int, pages int) []byte {
+	file := bytes.Repeat([]byte{0xa5}, pages*zipSize)
+	for i := 0; i < pages; i++ {
+		page := file[i*zipSize : (i+1)*zipSize]
+		for j := 0; j < 38; j++ {
+			page[j] = 0
+		}
+		binary.BigEndian.PutUint32(page[4:], uint32(i))
+		binary.BigEndian.PutUint16(page[24:], PageTypeAllocated)
+		binary.BigEndian.PutUint32(page[34:], 9)
+	}
+
+	// FSP_HDR, the zip ssize of the flags is the zip size, 512 << ssize
+	fsp := file[:zipSize]
+	binary.BigEndian.PutUint16(fsp[24:], PageTypeFspHDR)
+	for j := 38; j < zipSize-8; j++ {
+		fsp[j] = 0
+	}
+	ssize := 0
+	for 512<<uint(ssize) < zipSize {
+		ssize++
+	}
+	binary.BigEndian.PutUint32(fsp[38:], 9)
+	binary.BigEndian.PutUint32(fsp[38+16:], uint32(ssize)<<fspFlagsPosZipSsize)
+	// The extents of 64 pages fill the page of the zip size from offset 150,
+	// every descriptor is 40 bytes, the state follows the segment id and the
+	// list node, XDES_FREE (1) and XDES_FULL_FRAG (3) of the last extent
+	n := zipSize / 64
+	for i := 0; i < n; i++ {
+		binary.BigEndian.PutUint32(fsp[150+i*40+20:], 1)
+	}
+	binary.BigEndian.PutUint32(fsp[150+(n-1)*40+20:], 3)
+
+	// INODE, the entries of 192 bytes follow the page list node
+	inode := file[2*zipSize : 3*zipSize]
+	binary.BigEndian.PutUint16(inode[24:], PageTypeINode)
+	for j := 38; j < zipSize-8; j++ {
+		inode[j] = 0
+	}
+	m := (zipSize - 60) / 192
+	binary.BigEndian.PutUint64(inode[50+(m-1)*192:], 0x77)
+	return file
+}
+
+func TestZipSpaceGeometry(t *testing.T) {
+	for _, zipSize := range []int{1024, 2048, 4096, 8192} {
+		ts, err := NewTablespace(bytes.NewReader(newTestZipSpace(zipSize, 4)), 16384)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if zipSize != ts.ZipSize() {
+			t.Fatalf("zip size is %d, expect %d", ts.ZipSize(), zipSize)
+		}
+		var fsp, inode *Page
+		err = ts.IteratePages(&ParsePageOptions{
+			ParsePageTypeFlag: ParsePageFSP | ParsePageInode,
+		}, func(page *Page, data []byte) error {
+			if PageTypeFspHDR == page.FileHeader.Type {
+				fsp = page
+			} else if PageTypeINode == page.FileHeader.Type {
+				inode = page
+			}
+			return nil
+		})
+		if nil != err {
+			t.Fatal(err)
+		}
+		if nil == fsp || nil == inode {
+			t.Fatalf("FSP_HDR or INODE page of zip size %d is not found", zipSize)
+		}
+		n := zipSize / 64
+		if n != len(fsp.XDeses) || 3 != fsp.XDeses[n-1].State {
+			t.Fatalf("zip size %d has %d extent descriptors, expect %d", zipSize, len(fsp.XDeses), n)
+		}
+		m := (zipSize - 60) / 192
+		if m != len(inode.INode.Inodes) || 0x77 != inode.INode.Inodes[m-1].FileSegmentID {
+			t.Fatalf("zip size %d has %d inodes, expect %d", zipSize, len(inode.INode.Inodes), m)
+		}
+	}
+}
+
+// zipTestRecord is the record of the clustered index (id bigint not null, name
+// varchar(300) nullable, age int nullable), DB_TRX_ID and DB_ROLL_PTR follow id
+type zipTestRecord struct {
+	id      uint64
+	name    []byte
+	extern  bool
+	age     int
+	ageNull bool
+	deleted bool
+	// The record is purged into the free list
+	free bool
+	// Layout on the logical page
+	rec    int
+	extra  int
+	size   int
+	refPos int
+}
+
+// zipTestFields is the index fields of page_zip_fields_encode: id (8 not null)
+// before the trx id, DB_TRX_ID and DB_ROLL_PTR merged (13 not null), name (big
+// nullable), age (4 nullable), and the trx id column 1
+var zipTestFields = []byte{8<<1 | 1, 13<<1 | 1, 0x7e, 4 << 1, 1}
+
+// zipTestPage is the logical leaf page of the records and its compressed page
+type zipTestPage struct {
+	page  []byte
+	recs  []*zipTestRecord
+	dense []uint16
+	nRecs int
+}
+
+// newZipTestPage lays out the records in the heap order from offset 120, the
+// user records are in the list order of the slice, every second one owns a slot
+func newZipTestPage(records []*zipTestRecord) *zipTestPage {
+	const size = 16384
+	p := &zipTestPage{page: make([]byte, size), recs: records}
+	page := p.page
+	binary.BigEndian.PutUint32(page[4:], 3)
+	binary.BigEndian.PutUint32(page[8:], 0xffffffff)
+	binary.BigEndian.PutUint32(page[12:], 0xffffffff)
+	binary.BigEndian.PutUint16(page[24:], PageTypeIndex)
+	binary.BigEndian.PutUint64(page[38+28:], 0x42)
+
+	offs := pageZipStart
+	for i, r := range records {
+		var lens []byte
+		if nil != r.name {
+			n := len(r.name)
+			if r.extern || n > 127 {
+				b := byte(0x80 | n>>8)
+				if r.extern {
+					b |= 0x40
+				}
+				lens = append(lens, b, byte(n))
+			} else {
+				lens = append(lens, byte(n))
+			}
+		}
+		r.extra = recNewExtraBytes + 1 + len(lens)
+		r.rec = offs + r.extra
+		var nulls byte
+		if nil == r.name {
+			nulls |= 1
+		}
+		if r.ageNull {
+			nulls |= 2
+		}
+		page[r.rec-6] = nulls
+		for k, b := range lens {
+			page[r.rec-7-k] = b
+		}
+		binary.BigEndian.PutUint16(page[r.rec-4:], uint16((i+2)<<recHeapNoShift))
+
+		d := r.rec
+		binary.BigEndian.PutUint64(page[d:], r.id|1<<63)
+		d += 8
+		// DB_TRX_ID and DB_ROLL_PTR
+		binary.BigEndian.PutUint64(page[d-2:], 0x1000+r.id)
+		page[d+6] = 0x80
+		binary.BigEndian.PutUint32(page[d+9:], uint32(r.id))
+		d += 13
+		d += copy(page[d:], r.name)
+		if r.extern {
+			r.refPos = d - externFieldRefSize
+		}
+		if !r.ageNull {
+			binary.BigEndian.PutUint32(page[d:], uint32(r.age)|1<<31)
+			d += 4
+		}
+		r.size = d - r.rec
+		offs = d
+	}
+
+	// The record list, the slots and the free list
+	setNext := func(rec int, next int) {
+		v := 0
+		if 0 != next {
+			v = (next - rec) & 0xffff
+		}
+		binary.BigEndian.PutUint16(page[rec-2:], uint16(v))
+	}
+	copy(page[pageZipInfimum-recNewExtraBytes:], []byte{0x01, 0x00, 0x02})
+	copy(page[pageZipInfimum:], "infimum\x00")
+	copy(page[pageZipSupremum-recNewExtraBytes+1:], []byte{0x00, 0x0b, 0x00, 0x00})
+	copy(page[pageZipSupremum:], "supremum")
+	slots := []int{pageZipInfimum}
+	prev, owned := pageZipInfimum, 0
+	var user, free []*zipTestRecord
+	for _, r := range records {
+		if r.free {
+			free = append(free, r)
+		} else {
+			user = append(user, r)
+		}
+	}
+	for i, r := range user {
+		setNext(prev, r.rec)
+		prev = r.rec
+		owned++
+		v := uint16(r.rec)
+		if r.deleted {
+			page[r.rec-5] |= recInfoDeletedFlag
+			v |= pageZipDirDel
+		}
+		if 1 == i%2 && i != len(user)-1 {
+			page[r.rec-5] |= byte(owned)
+			slots = append(slots, r.rec)
+			owned = 0
+			v |= pageZipDirOwned
+		}
+		p.dense = append(p.dense, v)
+	}
+	setNext(prev, pageZipSupremum)
+	page[pageZipSupremum-recNewExtraBytes] = byte(owned + 1)
+	slots = append(slots, pageZipSupremum)
+	for i, s := range slots {
+		binary.BigEndian.PutUint16(page[size-10-2*i:], uint16(s))
+	}
+	for i, r := range free {
+		next := 0
+		if i+1 < len(free) {
+			next = free[i+1].rec
+		}
+		setNext(r.rec, next)
+		p.dense = append(p.dense, uint16(r.rec))
+	}
+	p.nRecs = len(user)
+
+	binary.BigEndian.PutUint16(page[pageHeaderNDirSlots:], uint16(len(slots)))
+	binary.BigEndian.PutUint16(page[pageHeaderHeapTop:], uint16(offs))
+	binary.BigEndian.PutUint16(page[pageHeaderNHeap:], uint16(0x8000|(len(records)+2)))
+	if 0 != len(free) {
+		binary.BigEndian.PutUint16(page[38+6:], uint16(free[0].rec))
+	}
+	binary.BigEndian.PutUint16(page[pageHeaderNRecs:], uint16(len(user)))
+	return p
+}
+
+// compress writes the compressed page like page_zip_compress, the records from
+// logFrom in the heap order are written to the modification log like
+// page_zip_write_rec, they are inserted after the page is compressed. The end
+// of the log is returned with the page
+func (p *zipTestPage) compress(zipSize int, logFrom int) ([]byte, int) {
+	page := p.page
+	zip := make([]byte, zipSize)
+	copy(zip, page[:pageZipHeaderSize])
+
+	// The uncompressed columns are skipped in the stream and the log
+	skip := make(map[int]int)
+	for _, r := range p.recs {
+		skip[r.rec-recNewExtraBytes] = recNewExtraBytes
+		skip[r.rec+8] = pageZipTrxRollSize
+		if r.extern {
+			skip[r.refPos] = externFieldRefSize
+		}
+	}
+	data := func(from int, to int) []byte {
+		var b []byte
+		for i := from; i < to; {
+			if n, ok := skip[i]; ok {
+				i += n
+				continue
+			}
+			b = append(b, page[i])
+			i++
+		}
+		return b
+	}
+
+	end := int(binary.BigEndian.Uint16(page[pageHeaderHeapTop:]))
+	if logFrom < len(p.recs) {
+		end = p.recs[logFrom].rec - p.recs[logFrom].extra
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(zipTestFields)
+	zw.Flush()
+	zw.Write(data(pageZipStart, end))
+	zw.Close()
+	pos := pageZipHeaderSize + copy(zip[pageZipHeaderSize:], buf.Bytes())
+
+	for h := logFrom; h < len(p.recs); h++ {
+		r := p.recs[h]
+		zip[pos] = byte((h + 1) << 1)
+		pos++
+		for b := r.rec - recNewExtraBytes - 1; b >= r.rec-r.extra; b-- {
+			zip[pos] = page[b]
+			pos++
+		}
+		pos += copy(zip[pos:], data(r.rec, r.rec+r.size))
+	}
+	logEnd := pos
+
+	// The dense directory, DB_TRX_ID and DB_ROLL_PTR and the extern references
+	// backwards from the end in the heap order
+	for i, v := range p.dense {
+		binary.BigEndian.PutUint16(zip[zipSize-(i+1)*pageZipDirSlotSize:], v)
+	}
+	storage := zipSize - len(p.dense)*pageZipDirSlotSize
+	externs := storage - len(p.recs)*pageZipTrxRollSize
+	for _, r := range p.recs {
+		storage -= pageZipTrxRollSize
+		copy(zip[storage:], page[r.rec+8:r.rec+8+pageZipTrxRollSize])
+		if r.extern {
+			externs -= externFieldRefSize
+			copy(zip[externs:], page[r.refPos:r.refPos+externFieldRefSize])
+		}
+	}
+	return zip, logEnd
+}
+
+func newZipTestRecords() []*zipTestRecord {
+	ref := make([]byte, externFieldRefSize)
+	binary.BigEndian.PutUint32(ref[4:], 7)
+	binary.BigEndian.PutUint64(ref[12:], 5000)
+	return []*zipTestRecord{
+		{id: 1, name: []byte("alice"), age: 30},
+		{id: 2, name: nil, age: 31},
+		{id: 3, name: bytes.Repeat([]byte("b"), 200), ageNull: true},
+		{id: 4, name: append(bytes.Repeat([]byte("c"), 10), ref...), extern: true, age: 33},
+		{id: 5, name: []byte("eve"), age: 34, deleted: true},
+		{id: 6, name: []byte("frank"), age: 35, free: true},
+		{id: 7, name: []byte("grace"), age: 36},
+		{id: 8, name: []byte("heidi"), age: 37},
+	}
+}
+
+func TestDecompressZipPage(t *testing.T) {
+	for _, logFrom := range []int{8, 6, 0} {
+		p := newZipTestPage(newZipTestRecords())
+		zip, _ := p.compress(8192, logFrom)
+		page := make([]byte, len(p.page))
+		if err := decompressZipPage(zip, page); nil != err {
+			t.Fatalf("records from %d in log: %v", logFrom, err)
+		}
+		if !bytes.Equal(p.page, page) {
+			for i := range page {
+				if page[i] != p.page[i] {
+					t.Fatalf("records from %d in log: byte 0x%04X is 0x%02X, expect 0x%02X",
+						logFrom, i, page[i], p.page[i])
+				}
+			}
+		}
+	}
+}
+
+func TestDecompressZipPageCorrupt(t *testing.T) {
+	p := newZipTestPage(newZipTestRecords())
+	cases := []struct {
+		name    string
+		corrupt func(zip []byte, logEnd int)
+	}{
+		{"truncated stream", func(zip []byte, logEnd int) {
+			for i := logEnd - 10; i < logEnd; i++ {
+				zip[i] = 0
+			}
+		}},
+		{"corrupt stream", func(zip []byte, logEnd int) {
+			for i := pageZipHeaderSize + 40; i < pageZipHeaderSize+120; i++ {
+				zip[i] = 0
+			}
+		}},
+		{"n heap", func(zip []byte, logEnd int) {
+			binary.BigEndian.PutUint16(zip[pageHeaderNHeap:], 0x8000|4000)
+		}},
+		{"dense directory", func(zip []byte, logEnd int) {
+			binary.BigEndian.PutUint16(zip[len(zip)-2:], 0x3ff0)
+		}},
+		{"free record flags", func(zip []byte, logEnd int) {
+			binary.BigEndian.PutUint16(zip[len(zip)-16:], 0xc000|binary.BigEndian.Uint16(zip[len(zip)-16:]))
+		}},
+		{"owned records", func(zip []byte, logEnd int) {
+			binary.BigEndian.PutUint16(zip[pageHeaderNDirSlots:], 9)
+		}},
+		{"unterminated log", func(zip []byte, logEnd int) {
+			// The terminator and the extern reference before the columns
+			for i := logEnd; i < len(zip)-8*pageZipDirSlotSize-8*pageZipTrxRollSize; i++ {
+				zip[i] = 0x02
+			}
+		}},
+	}
+	for _, c := range cases {
+		logFrom := 8
+		if "unterminated log" == c.name {
+			logFrom = 6
+		}
+		zip, logEnd := p.compress(8192, logFrom)
+		c.corrupt(zip, logEnd)
+		page := make([]byte, len(p.page))
+		if err := decompressZipPage(zip, page); nil == err {
+			t.Fatalf("corrupt %s is decompressed", c.name)
+		}
+	}
+}
+
+func TestDecodeZipFields(t *testing.T) {
+	idx, err := decodeZipFields(zipTestFields, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+	expect := []zipField{{fixedLen: 8, notNull: true}, {fixedLen: 13, notNull: true}, {big: true}, {fixedLen: 4}}
+	if 4 != len(idx.fields) || 1 != idx.trxIDCol || 2 != idx.nNullable {
+		t.Fatalf("%d fields, trx id column %d, %d nullable", len(idx.fields), idx.trxIDCol, idx.nNullable)
+	}
+	for i, f := range expect {
+		if f != idx.fields[i] {
+			t.Fatalf("field %d is %+v, expect %+v", i, idx.fields[i], f)
+		}
+	}
+
+	// The fixed length of 2 bytes, and the node pointer page with the count
+	// of the nullable fields
+	idx, err = decodeZipFields([]byte{0x80 | 601>>8, 601 & 0xff, 0, 0x80, 1}, false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 300 != idx.fields[0].fixedLen || 1 != idx.nNullable || -1 != idx.trxIDCol {
+		t.Fatalf("field 0 is %+v, %d nullable", idx.fields[0], idx.nNullable)
+	}
+
+	for _, buf := range [][]byte{nil, {1}, {0x80}, {8<<1 | 1, 0x80}, {8<<1 | 1, 5}} {
+		if _, err = decodeZipFields(buf, true); nil == err {
+			t.Fatalf("invalid fields %v are decoded", buf)
+		}
+	}
+}
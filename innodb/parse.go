@@ -0,0 +1,139 @@
+package innodb
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ParsePageAllocated = 1 << iota
+	ParsePageUndoLog
+	ParsePageInode
+	ParsePageIndex
+	ParsePageFSP
+	ParsePageXdes
+	ParsePageBlob
+)
+
+const ParsePageAll = ParsePageFSP |
+	ParsePageInode |
+	ParsePageIndex |
+	ParsePageAllocated |
+	ParsePageUndoLog |
+	ParsePageXdes |
+	ParsePageBlob
+
+// ParsePageOptions controls which pages and records are parsed
+type ParsePageOptions struct {
+	ParseRecords      bool
+	ParsePageTypeFlag uint64
+	PKSize            int
+	// Page size in bytes, detect from page 0 if zero
+	PageSize int
+	// Decode the record fields if the table schema is specified
+	Table *Table
+	// Only parse the file header, the page data is handled by the caller
+	HeaderOnly bool
+	// Physical page size of the ROW_FORMAT=COMPRESSED table space, the FSP_HDR,
+	// XDES and INODE pages are laid out in it
+	ZipSize int
+	// Workers to parse the pages of the iteration, the pages are parsed one by
+	// one if not greater than 1
+	Jobs int
+}
+
+// physicalSize returns the page size on disk of the page of the size
+func (o *ParsePageOptions) physicalSize(size int) int {
+	if 0 != o.ZipSize && o.ZipSize < size {
+		return o.ZipSize
+	}
+	return size
+}
+
+func (o *ParsePageOptions) canParse(tp int) bool {
+	if o.ParsePageTypeFlag == ParsePageAll {
+		return true
+	}
+
+	utp := uint64(tp)
+	tv := uint64(0)
+	switch utp {
+	case PageTypeFspHDR:
+		{
+			tv = ParsePageFSP
+		}
+	case PageTypeINode:
+		{
+			tv = ParsePageInode
+		}
+	case PageTypeIndex, PageTypeSDI:
+		{
+			tv = ParsePageIndex
+		}
+	case PageTypeAllocated:
+		{
+			tv = ParsePageAllocated
+		}
+	case PageTypeXdes:
+		{
+			tv = ParsePageXdes
+		}
+	case PageTypeBlob:
+		{
+			tv = ParsePageBlob
+		}
+	case PageTypeUndoLog:
+		{
+			tv = ParsePageUndoLog
+		}
+	}
+
+	return (tv & o.ParsePageTypeFlag) != 0
+}
+
+// readPageData reads the page into data, the length of data is the page size
+func readPageData(r io.ReaderAt, page int, data []byte) error {
+	n, err := r.ReadAt(data, int64(len(data))*int64(page))
+	if n != len(data) {
+		return errors.Errorf("Read page %d failed %v", page, err)
+	}
+	return nil
+}
+
+// readPageImage reads the page like readPageData, the page compressed page is
+// decompressed into the normal page
+func readPageImage(r io.ReaderAt, page int, data []byte) error {
+	if err := readPageData(r, page, data); nil != err {
+		return err
+	}
+	if _, err := decompressPage(data); nil != err {
+		return errors.Errorf("Decompress page %d error %v", page, err)
+	}
+	return nil
+}
+
+func readPageFromFile(r io.ReaderAt, pageNo int, options *ParsePageOptions) (*Page, error) {
+	size, err := resolvePageSize(r, options.PageSize)
+	if nil != err {
+		return nil, err
+	}
+	options.PageSize = size
+	data := make([]byte, options.PageSize)
+	if err := readPageData(r, pageNo, data); nil != err {
+		return nil, err
+	}
+	return ParsePage(pageNo, data, options)
+}
+
+// ParsePage parses the page data read from the page number, the page compressed
+// page is decompressed in data
+func ParsePage(pageNo int, data []byte, options *ParsePageOptions) (*Page, error) {
+	var page Page
+	page.pksize = options.PKSize
+	if err := page.parse(data, options); nil != err {
+		return nil, err
+	}
+	page.setPageNo(pageNo)
+	return &page, nil
+}
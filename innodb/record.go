@@ -0,0 +1,383 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Size of the external field reference stored at the end of the local prefix
+const externFieldRefSize = 20
+
+// RecordField is a decoded field of the record
+type RecordField struct {
+	Column *Column
+	Null   bool
+	// The field is stored externally, data is the local prefix and the
+	// 20 bytes external reference
+	Extern bool
+	// Offset relative to the page
+	Offset int
+	Data   []byte
+}
+
+// parseRecordFields decodes the fields of the COMPACT/DYNAMIC record, origin is the
+// offset of the field data. Before the 5 bytes record header is the null bitmap,
+// and the variable length field lengths are before the null bitmap, both are
+// stored in the reversed order
+func parseRecordFields(data []byte, origin int, fields []*Column, nullable int) ([]*RecordField, error) {
+	nullPos := origin - 5 - 1
+	lenPos := origin - 5 - (nullable+7)/8 - 1
+	nullBit := uint(0)
+	pos := origin
+	values := make([]*RecordField, 0, len(fields))
+
+	for _, c := range fields {
+		if c.Nullable {
+			bytePos := nullPos - int(nullBit/8)
+			if bytePos < 0 {
+				return nil, errors.Errorf("Null bitmap out of page at 0x%04X", origin)
+			}
+			isNull := data[bytePos]&(1<<(nullBit%8)) != 0
+			nullBit++
+			if isNull {
+				values = append(values, &RecordField{
+					Column: c,
+					Null:   true,
+					Offset: pos,
+				})
+				continue
+			}
+		}
+
+		size := c.FixedSize()
+		extern := false
+		if 0 == size {
+			if lenPos < 0 {
+				return nil, errors.Errorf("Variable field lengths out of page at 0x%04X", origin)
+			}
+			size = int(data[lenPos])
+			lenPos--
+			if c.isBig() && size&0x80 != 0 {
+				if lenPos < 0 {
+					return nil, errors.Errorf("Variable field lengths out of page at 0x%04X", origin)
+				}
+				size = (size << 8) | int(data[lenPos])
+				lenPos--
+				extern = size&0x4000 != 0
+				size &= 0x3fff
+			}
+		}
+		if pos+size > len(data)-8 {
+			return nil, errors.Errorf("Field %s out of page at 0x%04X", c.Name, origin)
+		}
+
+		values = append(values, &RecordField{
+			Column: c,
+			Extern: extern,
+			Offset: pos,
+			Data:   data[pos : pos+size],
+		})
+		pos += size
+	}
+
+	return values, nil
+}
+
+// recordExtent returns the range of the record in the page, from the first byte
+// of the variable field lengths to the end of the field data
+func recordExtent(origin int, fields []*RecordField, nullable int) (int, int) {
+	start := origin - 5 - (nullable+7)/8
+	end := origin
+	for _, f := range fields {
+		if f.Null {
+			continue
+		}
+		if 0 == f.Column.FixedSize() {
+			start--
+			if f.Column.isBig() && (len(f.Data) > 127 || f.Extern) {
+				start--
+			}
+		}
+		end = f.Offset + len(f.Data)
+	}
+	return start, end
+}
+
+// integer returns the integer value of the integer type fields
+func (f *RecordField) integer() (int64, bool) {
+	if f.Null || !f.Column.IsInteger() && f.Column.Type != ColumnTypeChildPage &&
+		f.Column.Type != ColumnTypeRowID && f.Column.Type != ColumnTypeTrxID {
+		return 0, false
+	}
+	var v uint64
+	for _, b := range f.Data {
+		v = (v << 8) | uint64(b)
+	}
+	if f.Column.IsInteger() && !f.Column.Unsigned {
+		// Signed integer is stored with the sign bit flipped
+		bits := uint(len(f.Data) * 8)
+		v ^= 1 << (bits - 1)
+		if v&(1<<(bits-1)) != 0 {
+			v |= ^uint64(0) << bits
+		}
+	}
+	return int64(v), true
+}
+
+// String formats the field value for display, strings are quoted and
+// binary data is in hex
+func (f *RecordField) String() string {
+	if f.Null {
+		return "NULL"
+	}
+	if f.Extern {
+		return f.externString()
+	}
+	return formatColumnValue(f.Column, f.Data)
+}
+
+func (f *RecordField) externString() string {
+	ref := parseExternFieldRef(f.Data[len(f.Data)-externFieldRefSize:])
+	return fmt.Sprintf("<extern space %d page %d offset %d length %d, local %d bytes>",
+		ref.spaceID, ref.pageNo, ref.offset, ref.length, len(f.Data)-externFieldRefSize)
+}
+
+func bigEndianUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = (v << 8) | uint64(b)
+	}
+	return v
+}
+
+func formatColumnValue(c *Column, data []byte) string {
+	switch c.Type {
+	case ColumnTypeTinyInt, ColumnTypeSmallInt, ColumnTypeMediumInt, ColumnTypeInt, ColumnTypeBigInt:
+		{
+			f := RecordField{Column: c, Data: data}
+			v, _ := f.integer()
+			if c.Unsigned {
+				return strconv.FormatUint(uint64(v), 10)
+			}
+			return strconv.FormatInt(v, 10)
+		}
+	case ColumnTypeRowID, ColumnTypeTrxID, ColumnTypeChildPage:
+		{
+			return strconv.FormatUint(bigEndianUint(data), 10)
+		}
+	case ColumnTypeRollPtr:
+		{
+			// insert flag (1bit) + rollback segment id (7bits) + page no (4bytes) + offset (2bytes)
+			return fmt.Sprintf("0x%014X", bigEndianUint(data))
+		}
+	case ColumnTypeFloat:
+		{
+			// Float and double are stored in little endian
+			return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32)
+		}
+	case ColumnTypeDouble:
+		{
+			return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64)
+		}
+	case ColumnTypeDecimal:
+		{
+			return formatDecimal(data, c.Precision, c.Scale)
+		}
+	case ColumnTypeDate:
+		{
+			v := bigEndianUint(data) ^ 0x800000
+			return fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)&15, v&31)
+		}
+	case ColumnTypeDatetime:
+		{
+			return formatDatetime(data, c.Scale)
+		}
+	case ColumnTypeTimestamp:
+		{
+			sec := int64(binary.BigEndian.Uint32(data))
+			s := time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05")
+			return s + formatFraction(data[4:], c.Scale)
+		}
+	case ColumnTypeTime:
+		{
+			return formatTime(data, c.Scale)
+		}
+	case ColumnTypeYear:
+		{
+			if 0 == data[0] {
+				return "0000"
+			}
+			return strconv.Itoa(1900 + int(data[0]))
+		}
+	case ColumnTypeEnum:
+		{
+			v := int(bigEndianUint(data))
+			if v > 0 && v <= len(c.Elements) {
+				return strconv.Quote(c.Elements[v-1])
+			}
+			return strconv.Quote("")
+		}
+	case ColumnTypeSet:
+		{
+			v := bigEndianUint(data)
+			members := make([]string, 0, len(c.Elements))
+			for i, e := range c.Elements {
+				if v&(1<<uint(i)) != 0 {
+					members = append(members, e)
+				}
+			}
+			return strconv.Quote(strings.Join(members, ","))
+		}
+	case ColumnTypeBit:
+		{
+			return fmt.Sprintf("b'%b'", bigEndianUint(data))
+		}
+	case ColumnTypeChar:
+		{
+			// CHAR is padded with spaces
+			return strconv.Quote(strings.TrimRight(string(data), " "))
+		}
+	}
+
+	if c.IsBinary() {
+		return "0x" + strings.ToUpper(hex.EncodeToString(data))
+	}
+	return strconv.Quote(string(data))
+}
+
+// formatDecimal decodes the mysql binary decimal format, the integer and fractional
+// parts are stored separately in groups of 9 digits (4 bytes), the leftover digits
+// use the least bytes. The sign bit is flipped, and all bytes are inverted for
+// negative values
+func formatDecimal(data []byte, precision int, scale int) string {
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	negative := buf[0]&0x80 == 0
+	buf[0] ^= 0x80
+	if negative {
+		for i := range buf {
+			buf[i] = ^buf[i]
+		}
+	}
+
+	intg := precision - scale
+	pos := 0
+	readDigits := func(digits int) uint64 {
+		n := decimalDigitsToBytes[digits]
+		v := bigEndianUint(buf[pos : pos+n])
+		pos += n
+		return v
+	}
+
+	var ip strings.Builder
+	if intg%9 != 0 {
+		ip.WriteString(strconv.FormatUint(readDigits(intg%9), 10))
+	}
+	for i := 0; i < intg/9; i++ {
+		v := readDigits(9)
+		if ip.Len() == 0 {
+			ip.WriteString(strconv.FormatUint(v, 10))
+		} else {
+			ip.WriteString(fmt.Sprintf("%09d", v))
+		}
+	}
+	is := strings.TrimLeft(ip.String(), "0")
+	if "" == is {
+		is = "0"
+	}
+
+	var fp strings.Builder
+	for i := 0; i < scale/9; i++ {
+		fp.WriteString(fmt.Sprintf("%09d", readDigits(9)))
+	}
+	if scale%9 != 0 {
+		fp.WriteString(fmt.Sprintf("%0*d", scale%9, readDigits(scale%9)))
+	}
+
+	s := is
+	if scale > 0 {
+		s += "." + fp.String()
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s
+}
+
+// fractionMicros decodes the fractional seconds part stored in big endian to
+// microseconds
+func fractionMicros(data []byte, fsp int) int64 {
+	if 0 == fsp {
+		return 0
+	}
+	v := int64(bigEndianUint(data[:fspSize(fsp)]))
+	switch fspSize(fsp) {
+	case 1:
+		{
+			v *= 10000
+		}
+	case 2:
+		{
+			v *= 100
+		}
+	}
+	return v
+}
+
+// formatFraction formats the fractional seconds part stored in big endian
+func formatFraction(data []byte, fsp int) string {
+	if 0 == fsp {
+		return ""
+	}
+	return fmt.Sprintf(".%06d", fractionMicros(data, fsp))[:fsp+1]
+}
+
+// DATETIME is stored as 5 bytes integer with 0x8000000000 offset:
+// sign (1bit) + year*13+month (17bits) + day (5bits) + hour (5bits) +
+// minute (6bits) + second (6bits), and the fractional seconds
+func formatDatetime(data []byte, fsp int) string {
+	v := int64(bigEndianUint(data[:5])) - 0x8000000000
+	if v < 0 {
+		v = -v
+	}
+	ymd := v >> 17
+	ym := ymd >> 5
+	hms := v & 0x1ffff
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d",
+		ym/13, ym%13, ymd&31, hms>>12, (hms>>6)&63, hms&63)
+	return s + formatFraction(data[5:], fsp)
+}
+
+// TIME is stored as 3 bytes integer with 0x800000 offset:
+// sign (1bit) + hour (10bits) + minute (6bits) + second (6bits), and the
+// fractional seconds
+func formatTime(data []byte, fsp int) string {
+	n := 3 + fspSize(fsp)
+	// Combine the integer and fractional part, negative values are stored as
+	// the complement of the whole value
+	v := int64(bigEndianUint(data[:n])) - (int64(0x80) << uint(8*(n-1)))
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	frac := v & (int64(1)<<uint(8*(n-3)) - 1)
+	hms := v >> uint(8*(n-3))
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, (hms>>12)&0x3ff, (hms>>6)&63, hms&63)
+	if fsp > 0 {
+		var fb [3]byte
+		size := fspSize(fsp)
+		for i := 0; i < size; i++ {
+			fb[i] = byte(frac >> uint(8*(size-1-i)))
+		}
+		s += formatFraction(fb[:size], fsp)
+	}
+	return s
+}
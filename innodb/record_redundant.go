@@ -0,0 +1,105 @@
+package innodb
+
+import (
+	"encoding/binary"
+
+	"github.com/juju/errors"
+)
+
+// Infimum and supremum of the REDUNDANT (old style) index page
+const (
+	redundantInfimumOrigin  = 0x65
+	redundantSupremumOrigin = 0x74
+)
+
+// redundantRecord is the REDUNDANT record, the 6 bytes record header is:
+// info bits (4bits) + owned (4bits) + heap no (13bits) + field count (10bits) +
+// 1 byte offsets flag (1bit) + next record offset (2bytes, absolute)
+type redundantRecord struct {
+	origin   int
+	infoBits uint8
+	owned    uint8
+	heapNo   int
+	next     int
+	// NULL field is nil
+	fields [][]byte
+	extern []bool
+}
+
+func (r *redundantRecord) deleted() bool {
+	return r.infoBits&0x02 != 0
+}
+
+// parseRedundantRecord decodes the REDUNDANT record at origin, the field end
+// offsets are stored before the record header in the reversed order
+func parseRedundantRecord(data []byte, origin int) (*redundantRecord, error) {
+	if origin < 6 || origin >= len(data)-8 {
+		return nil, errors.Errorf("Invalid record offset 0x%04X", origin)
+	}
+	rec := &redundantRecord{
+		origin:   origin,
+		infoBits: data[origin-6] >> 4,
+		owned:    data[origin-6] & 0x0f,
+		heapNo:   int(binary.BigEndian.Uint16(data[origin-5:]) >> 3),
+		next:     int(binary.BigEndian.Uint16(data[origin-2:])),
+	}
+	v := binary.BigEndian.Uint16(data[origin-4:])
+	nFields := int((v >> 1) & 0x3ff)
+	short := v&0x01 != 0
+
+	rec.fields = make([][]byte, nFields)
+	rec.extern = make([]bool, nFields)
+	start := 0
+	for i := 0; i < nFields; i++ {
+		var end int
+		null := false
+		if short {
+			pos := origin - 6 - (i + 1)
+			if pos < 0 {
+				return nil, errors.Errorf("Field offsets out of page at 0x%04X", origin)
+			}
+			end = int(data[pos] & 0x7f)
+			null = data[pos]&0x80 != 0
+		} else {
+			pos := origin - 6 - 2*(i+1)
+			if pos < 0 {
+				return nil, errors.Errorf("Field offsets out of page at 0x%04X", origin)
+			}
+			ov := binary.BigEndian.Uint16(data[pos:])
+			end = int(ov & 0x3fff)
+			null = ov&0x8000 != 0
+			rec.extern[i] = ov&0x4000 != 0
+		}
+		if end < start || origin+end > len(data)-8 {
+			return nil, errors.Errorf("Invalid field %d end offset %d at 0x%04X", i, end, origin)
+		}
+		if !null {
+			rec.fields[i] = data[origin+start : origin+end]
+		}
+		start = end
+	}
+	return rec, nil
+}
+
+// redundantUserRecords returns the user records of the REDUNDANT page in the
+// key order
+func redundantUserRecords(data []byte) ([]*redundantRecord, error) {
+	records := make([]*redundantRecord, 0, 16)
+	infimum, err := parseRedundantRecord(data, redundantInfimumOrigin)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	origin := infimum.next
+	for origin != redundantSupremumOrigin {
+		if 0 == origin || len(records) > len(data)/6 {
+			return nil, errors.New("Broken record list")
+		}
+		rec, err := parseRedundantRecord(data, origin)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		records = append(records, rec)
+		origin = rec.next
+	}
+	return records, nil
+}
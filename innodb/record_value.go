@@ -0,0 +1,160 @@
+package innodb
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// columnText returns the text of the column value without quotes, it is the
+// value to load back into mysql. Binary values are in hex with the 0x prefix
+func columnText(c *Column, data []byte) string {
+	switch c.Type {
+	case ColumnTypeEnum:
+		{
+			v := int(bigEndianUint(data))
+			if v > 0 && v <= len(c.Elements) {
+				return c.Elements[v-1]
+			}
+			return ""
+		}
+	case ColumnTypeSet:
+		{
+			v := bigEndianUint(data)
+			members := make([]string, 0, len(c.Elements))
+			for i, e := range c.Elements {
+				if v&(1<<uint(i)) != 0 {
+					members = append(members, e)
+				}
+			}
+			return strings.Join(members, ",")
+		}
+	case ColumnTypeBit:
+		{
+			return strconv.FormatUint(bigEndianUint(data), 10)
+		}
+	case ColumnTypeChar:
+		{
+			return strings.TrimRight(string(data), " ")
+		}
+	case ColumnTypeJSON:
+		{
+			// The binary JSON is decoded into the JSON text, the value can't be
+			// decoded is in hex
+			s, err := decodeBinaryJSON(data)
+			if nil != err {
+				return "0x" + strings.ToUpper(hex.EncodeToString(data))
+			}
+			return s
+		}
+	}
+	if c.IsBinary() {
+		return "0x" + strings.ToUpper(hex.EncodeToString(data))
+	}
+	if c.IsString() {
+		return string(data)
+	}
+	return formatColumnValue(c, data)
+}
+
+// isNumericColumn returns true if the value of the column is not quoted in SQL
+func isNumericColumn(c *Column) bool {
+	switch c.Type {
+	case ColumnTypeTinyInt, ColumnTypeSmallInt, ColumnTypeMediumInt, ColumnTypeInt, ColumnTypeBigInt,
+		ColumnTypeFloat, ColumnTypeDouble, ColumnTypeDecimal, ColumnTypeYear, ColumnTypeBit,
+		ColumnTypeRowID, ColumnTypeTrxID:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// sqlLiteral formats the column value as the SQL literal, NULL is nil data
+func sqlLiteral(c *Column, data []byte) string {
+	if nil == data {
+		return "NULL"
+	}
+	if c.IsBinary() {
+		if 0 == len(data) {
+			return "''"
+		}
+		return columnText(c, data)
+	}
+	if isNumericColumn(c) {
+		return columnText(c, data)
+	}
+	return quoteSQLString(columnText(c, data))
+}
+
+// jsonValue formats the column value as the JSON value, NULL is nil data. The
+// DECIMAL values are strings to keep the precision, and the values of the JSON
+// columns are nested
+func jsonValue(c *Column, data []byte) string {
+	if nil == data {
+		return "null"
+	}
+	if c.Type == ColumnTypeJSON {
+		if s, err := decodeBinaryJSON(data); nil == err {
+			return s
+		}
+	}
+	if isNumericColumn(c) && c.Type != ColumnTypeDecimal {
+		return columnText(c, data)
+	}
+	s := columnText(c, data)
+	if c.IsString() && ("latin1" == c.Charset || "ascii" == c.Charset) {
+		// JSON strings are utf8
+		s = string(decodeCharsetRunes(data, c.Charset))
+		if c.Type == ColumnTypeChar {
+			s = strings.TrimRight(s, " ")
+		}
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// quoteSQLString quotes the string with single quotes and escapes the special
+// characters like mysqldump
+func quoteSQLString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 0:
+			{
+				b.WriteString(`\0`)
+			}
+		case '\n':
+			{
+				b.WriteString(`\n`)
+			}
+		case '\r':
+			{
+				b.WriteString(`\r`)
+			}
+		case 0x1a:
+			{
+				b.WriteString(`\Z`)
+			}
+		case '\\', '\'', '"':
+			{
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			{
+				b.WriteByte(s[i])
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteSQLIdent quotes the identifier with backticks
+func quoteSQLIdent(s string) string {
+	return "`" + strings.Replace(s, "`", "``", -1) + "`"
+}
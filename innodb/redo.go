@@ -0,0 +1,488 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Redo log file layout, reference to log0log.h. The file starts with 4 header
+// blocks, the file header, checkpoint 1, unused (encryption info since 8.0) and
+// checkpoint 2, the log blocks follow
+const (
+	RedoBlockSize        = 512
+	redoFileHeaderSize   = 4 * RedoBlockSize
+	redoBlockHeaderSize  = 12
+	redoBlockTrailerSize = 4
+	// File header
+	redoHeaderFormatOffset   = 0
+	redoHeaderStartLSNOffset = 8
+	redoHeaderCreatorOffset  = 16
+	redoHeaderCreatorEnd     = 48
+	// Checkpoint blocks, the checkpoint no and offset are not used since 8.0
+	redoCheckpoint1Offset      = RedoBlockSize
+	redoCheckpoint2Offset      = 3 * RedoBlockSize
+	redoCheckpointNoOffset     = 0
+	redoCheckpointLSNOffset    = 8
+	redoCheckpointOffsetOffset = 16
+	// Log block header and trailer
+	redoBlockNoOffset            = 0
+	redoBlockDataLenOffset       = 4
+	redoBlockFirstRecGroupOffset = 6
+	redoBlockCheckpointNoOffset  = 8
+	redoBlockChecksumOffset      = RedoBlockSize - redoBlockTrailerSize
+	redoBlockFlushBitMask        = 0x80000000
+	redoBlockEncryptBitMask      = 0x8000
+)
+
+// Redo log formats in the file header
+const (
+	RedoFormat57   = 1
+	RedoFormat801  = 2
+	RedoFormat803  = 3
+	RedoFormat8019 = 4
+	RedoFormat8028 = 5
+	RedoFormat8030 = 6
+)
+
+// RedoFormatToString returns the mysql version introducing the format
+func RedoFormatToString(format uint32) string {
+	switch format {
+	case RedoFormat57:
+		{
+			return "5.7.9"
+		}
+	case RedoFormat801:
+		{
+			return "8.0.1"
+		}
+	case RedoFormat803:
+		{
+			return "8.0.3"
+		}
+	case RedoFormat8019:
+		{
+			return "8.0.19"
+		}
+	case RedoFormat8028:
+		{
+			return "8.0.28"
+		}
+	case RedoFormat8030:
+		{
+			return "8.0.30"
+		}
+	}
+	return "unknown"
+}
+
+// RedoLogHeader is the header block of the redo log file
+type RedoLogHeader struct {
+	Format uint32
+	// StartLSN is the lsn of the first log block of the file
+	StartLSN      uint64
+	Creator       string
+	Checksum      uint32
+	ChecksumValid bool
+}
+
+// RedoCheckpoint is the checkpoint block, the recovery starts from the lsn of
+// the latest valid checkpoint
+type RedoCheckpoint struct {
+	No            uint64
+	LSN           uint64
+	Offset        uint64
+	Checksum      uint32
+	ChecksumValid bool
+}
+
+// RedoLogBlock is the 512 bytes log block, the data of the mini-transaction
+// records is between the header and the trailer
+type RedoLogBlock struct {
+	// Offset is the file offset of the block
+	Offset int64
+	// LSN is the lsn of the block start calculated from the file position
+	LSN   uint64
+	No    uint32
+	Flush bool
+	// DataLen is the bytes used including the header, 512 if the block is full
+	DataLen       uint16
+	Encrypted     bool
+	FirstRecGroup uint16
+	// CheckpointNo is the epoch no since 8.0
+	CheckpointNo  uint32
+	Checksum      uint32
+	ChecksumValid bool
+	Data          []byte
+}
+
+// Unwritten reports whether the block is never written
+func (b *RedoLogBlock) Unwritten() bool {
+	for _, v := range b.Data {
+		if 0 != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Stale reports whether the block is left by the previous round of the circular
+// log, its block no doesn't match the lsn of the position
+func (b *RedoLogBlock) Stale() bool {
+	return redoBlockNo(b.LSN) != b.No
+}
+
+// Valid reports whether the data of the block can be parsed
+func (b *RedoLogBlock) Valid() bool {
+	return b.ChecksumValid && !b.Stale() && !b.Encrypted && b.DataLen >= redoBlockHeaderSize
+}
+
+// dataEnd returns the end offset of the record data in the block
+func (b *RedoLogBlock) dataEnd() int {
+	if int(b.DataLen) > redoBlockChecksumOffset {
+		return redoBlockChecksumOffset
+	}
+	return int(b.DataLen)
+}
+
+// redoBlockNo converts the lsn to the block no, reference to
+// log_block_convert_lsn_to_no
+func redoBlockNo(lsn uint64) uint32 {
+	return uint32((lsn/RedoBlockSize)&0x3FFFFFFF) + 1
+}
+
+// calcRedoBlockInnodbChecksum is the checksum of innodb_log_checksum_algorithm=innodb,
+// reference to log_block_calc_checksum_innodb
+func calcRedoBlockInnodbChecksum(data []byte) uint32 {
+	sum := uint32(1)
+	sh := uint(0)
+	for _, b := range data {
+		v := uint32(b)
+		sum &= 0x7FFFFFFF
+		sum += v
+		sum += v << sh
+		sh++
+		if sh > 24 {
+			sh = 0
+		}
+	}
+	return sum
+}
+
+// redoBlockChecksumValid verifies the block checksum with the crc32, innodb and
+// none algorithms
+func redoBlockChecksumValid(data []byte) bool {
+	stored := binary.BigEndian.Uint32(data[redoBlockChecksumOffset:])
+	body := data[:redoBlockChecksumOffset]
+	return stored == crc32.Checksum(body, crc32cTable) ||
+		stored == calcRedoBlockInnodbChecksum(body) ||
+		stored == checksumMagicNone
+}
+
+func parseRedoLogBlock(data []byte) *RedoLogBlock {
+	no := binary.BigEndian.Uint32(data[redoBlockNoOffset:])
+	dataLen := binary.BigEndian.Uint16(data[redoBlockDataLenOffset:])
+	return &RedoLogBlock{
+		No:            no &^ redoBlockFlushBitMask,
+		Flush:         0 != no&redoBlockFlushBitMask,
+		DataLen:       dataLen &^ redoBlockEncryptBitMask,
+		Encrypted:     0 != dataLen&redoBlockEncryptBitMask,
+		FirstRecGroup: binary.BigEndian.Uint16(data[redoBlockFirstRecGroupOffset:]),
+		CheckpointNo:  binary.BigEndian.Uint32(data[redoBlockCheckpointNoOffset:]),
+		Checksum:      binary.BigEndian.Uint32(data[redoBlockChecksumOffset:]),
+		ChecksumValid: redoBlockChecksumValid(data),
+		Data:          data,
+	}
+}
+
+func parseRedoCheckpoint(data []byte) *RedoCheckpoint {
+	return &RedoCheckpoint{
+		No:            binary.BigEndian.Uint64(data[redoCheckpointNoOffset:]),
+		LSN:           binary.BigEndian.Uint64(data[redoCheckpointLSNOffset:]),
+		Offset:        binary.BigEndian.Uint64(data[redoCheckpointOffsetOffset:]),
+		Checksum:      binary.BigEndian.Uint32(data[redoBlockChecksumOffset:]),
+		ChecksumValid: redoBlockChecksumValid(data),
+	}
+}
+
+// RedoLogFile is the ib_logfile* or #ib_redo* file, the log blocks are read
+// from the reader on demand
+type RedoLogFile struct {
+	r           io.ReaderAt
+	size        int64
+	Header      RedoLogHeader
+	Checkpoints [2]*RedoCheckpoint
+}
+
+// NewRedoLogFile reads the file header and the checkpoints, the reader must have
+// the Size or Stat method like bytes.Reader and os.File
+func NewRedoLogFile(r io.ReaderAt) (*RedoLogFile, error) {
+	size, err := readerSize(r)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	if size < redoFileHeaderSize {
+		return nil, errors.Errorf("Redo log file size %d is too small", size)
+	}
+	data := make([]byte, redoFileHeaderSize)
+	if _, err = r.ReadAt(data, 0); nil != err {
+		return nil, errors.Trace(err)
+	}
+	f := &RedoLogFile{
+		r:    r,
+		size: size,
+		Header: RedoLogHeader{
+			Format:        binary.BigEndian.Uint32(data[redoHeaderFormatOffset:]),
+			StartLSN:      binary.BigEndian.Uint64(data[redoHeaderStartLSNOffset:]),
+			Creator:       strings.TrimRight(string(data[redoHeaderCreatorOffset:redoHeaderCreatorEnd]), "\x00 "),
+			Checksum:      binary.BigEndian.Uint32(data[redoBlockChecksumOffset:]),
+			ChecksumValid: redoBlockChecksumValid(data[:RedoBlockSize]),
+		},
+	}
+	// The log block format of mysql 5.6 and earlier is not supported
+	if f.Header.Format < RedoFormat57 || f.Header.Format > RedoFormat8030 {
+		return nil, errors.Errorf("Unsupported redo log format %d", f.Header.Format)
+	}
+	f.Checkpoints[0] = parseRedoCheckpoint(data[redoCheckpoint1Offset : redoCheckpoint1Offset+RedoBlockSize])
+	f.Checkpoints[1] = parseRedoCheckpoint(data[redoCheckpoint2Offset : redoCheckpoint2Offset+RedoBlockSize])
+	return f, nil
+}
+
+// Size returns the file size in bytes
+func (f *RedoLogFile) Size() int64 {
+	return f.size
+}
+
+// Checkpoint returns the latest valid checkpoint of the file, nil if none
+func (f *RedoLogFile) Checkpoint() *RedoCheckpoint {
+	var latest *RedoCheckpoint
+	for _, cp := range f.Checkpoints {
+		if !cp.ChecksumValid || 0 == cp.LSN {
+			continue
+		}
+		if nil == latest || cp.LSN > latest.LSN {
+			latest = cp
+		}
+	}
+	return latest
+}
+
+// IterateBlocks calls fn with the log blocks after the file header, the block
+// data is reused so it must not be kept after fn returns. fn can return
+// ErrStopIteration to stop early
+func (f *RedoLogFile) IterateBlocks(fn func(b *RedoLogBlock) error) error {
+	chunk := make([]byte, iterateChunkSize)
+	for offset := int64(redoFileHeaderSize); offset+RedoBlockSize <= f.size; {
+		n := int64(len(chunk))
+		if left := (f.size - offset) / RedoBlockSize * RedoBlockSize; left < n {
+			n = left
+		}
+		if rn, err := f.r.ReadAt(chunk[:n], offset); int64(rn) < n {
+			return errors.Errorf("Read redo log block at 0x%X error %v", offset, err)
+		}
+		for i := int64(0); i < n; i += RedoBlockSize {
+			b := parseRedoLogBlock(chunk[i : i+RedoBlockSize])
+			b.Offset = offset + i
+			b.LSN = f.Header.StartLSN + uint64(b.Offset-redoFileHeaderSize)
+			if err := fn(b); nil != err {
+				if ErrStopIteration == err {
+					return nil
+				}
+				return err
+			}
+		}
+		offset += n
+	}
+	return nil
+}
+
+// RedoParseStatistic is the summary of the records iteration
+type RedoParseStatistic struct {
+	Blocks    int
+	Unwritten int
+	Stale     int
+	Corrupt   int
+	Encrypted int
+	Records   int
+	// Unparsed is the records of unsupported formats, the rest of their record
+	// groups are skipped
+	Unparsed int
+	StartLSN uint64
+	EndLSN   uint64
+}
+
+// RedoLog is the redo log files of the instance ordered by the start lsn, the
+// files of the circular ib_logfile* group may start with any of them
+type RedoLog struct {
+	Files []*RedoLogFile
+}
+
+// NewRedoLog orders the files by the start lsn
+func NewRedoLog(files []*RedoLogFile) *RedoLog {
+	sorted := make([]*RedoLogFile, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Header.StartLSN < sorted[j].Header.StartLSN
+	})
+	return &RedoLog{Files: sorted}
+}
+
+// Checkpoint returns the latest valid checkpoint of all files, since 8.0.30
+// every file has its checkpoints
+func (l *RedoLog) Checkpoint() *RedoCheckpoint {
+	var latest *RedoCheckpoint
+	for _, f := range l.Files {
+		cp := f.Checkpoint()
+		if nil != cp && (nil == latest || cp.LSN > latest.LSN) {
+			latest = cp
+		}
+	}
+	return latest
+}
+
+// redoLSNAdd returns the lsn after n bytes of the record data, the headers and
+// the trailers of the blocks are counted, reference to recv_calc_lsn_on_data_add
+func redoLSNAdd(lsn uint64, n int) uint64 {
+	payload := uint64(RedoBlockSize - redoBlockHeaderSize - redoBlockTrailerSize)
+	frag := lsn%RedoBlockSize - redoBlockHeaderSize
+	return lsn + uint64(n) + (uint64(n)+frag)/payload*(redoBlockHeaderSize+redoBlockTrailerSize)
+}
+
+// redoDataStart maps the position of the record stream to the lsn, the record
+// data of a block is continuous
+type redoDataStart struct {
+	pos int
+	lsn uint64
+}
+
+// redoStream is the record data of the continuous valid blocks, the parsing
+// starts from the first record group of a block
+type redoStream struct {
+	buf     []byte
+	starts  []redoDataStart
+	synced  bool
+	nextLSN uint64
+}
+
+func (s *redoStream) reset() {
+	s.buf = s.buf[:0]
+	s.starts = s.starts[:0]
+	s.synced = false
+}
+
+func (s *redoStream) append(b *RedoLogBlock, start int) {
+	s.starts = append(s.starts, redoDataStart{pos: len(s.buf), lsn: b.LSN + uint64(start)})
+	s.buf = append(s.buf, b.Data[start:b.dataEnd()]...)
+}
+
+func (s *redoStream) lsnAt(pos int) uint64 {
+	i := len(s.starts) - 1
+	for i > 0 && s.starts[i].pos > pos {
+		i--
+	}
+	return s.starts[i].lsn + uint64(pos-s.starts[i].pos)
+}
+
+// consume drops the parsed data of the stream
+func (s *redoStream) consume(n int) {
+	if 0 == n {
+		return
+	}
+	// Keep the last start before the position
+	i := len(s.starts) - 1
+	for i > 0 && s.starts[i].pos > n {
+		i--
+	}
+	s.starts[i].lsn += uint64(n - s.starts[i].pos)
+	s.starts[i].pos = n
+	s.starts = append(s.starts[:0], s.starts[i:]...)
+	for i := range s.starts {
+		s.starts[i].pos -= n
+	}
+	s.buf = s.buf[:copy(s.buf, s.buf[n:])]
+}
+
+// IterateRecords parses the mini-transaction records of the valid blocks in the
+// lsn order, and calls fn with the records. The parsing starts from the first
+// record group of a block, and restarts after the invalid blocks and the records
+// which can't be parsed. fn can return ErrStopIteration to stop early
+func (l *RedoLog) IterateRecords(fn func(rec *RedoRecord) error) (*RedoParseStatistic, error) {
+	st := &RedoParseStatistic{}
+	s := &redoStream{}
+	stopped := false
+	for _, f := range l.Files {
+		err := f.IterateBlocks(func(b *RedoLogBlock) error {
+			st.Blocks++
+			if !b.Valid() {
+				if b.Unwritten() {
+					st.Unwritten++
+				} else if !b.ChecksumValid {
+					st.Corrupt++
+				} else if b.Encrypted {
+					st.Encrypted++
+				} else {
+					st.Stale++
+				}
+				s.reset()
+				return nil
+			}
+			if s.synced && b.LSN != s.nextLSN {
+				s.reset()
+			}
+			s.nextLSN = b.LSN + RedoBlockSize
+			start := redoBlockHeaderSize
+			if !s.synced {
+				if int(b.FirstRecGroup) < redoBlockHeaderSize || int(b.FirstRecGroup) >= b.dataEnd() {
+					return nil
+				}
+				start = int(b.FirstRecGroup)
+				s.synced = true
+			}
+			s.append(b, start)
+
+			pos := 0
+			for pos < len(s.buf) {
+				rec, n, err := parseRedoRecord(s.buf[pos:], f.Header.Format)
+				if errRedoShort == err {
+					break
+				}
+				rec.LSN = s.lsnAt(pos)
+				rec.EndLSN = redoLSNAdd(rec.LSN, n)
+				if nil != err {
+					// The length of the record is unknown, skip to the next record group
+					rec.Unparsed = true
+					st.Unparsed++
+				}
+				st.Records++
+				if 0 == st.StartLSN {
+					st.StartLSN = rec.LSN
+				}
+				st.EndLSN = rec.LSN
+				if ferr := fn(rec); nil != ferr {
+					if ErrStopIteration == ferr {
+						stopped = true
+					}
+					return ferr
+				}
+				if nil != err {
+					s.reset()
+					return nil
+				}
+				pos += n
+			}
+			s.consume(pos)
+			return nil
+		})
+		if nil != err {
+			return st, errors.Trace(err)
+		}
+		if stopped {
+			break
+		}
+	}
+	return st, nil
+}
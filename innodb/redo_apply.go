@@ -0,0 +1,165 @@
+package innodb
+
+import (
+	"encoding/binary"
+
+	"github.com/juju/errors"
+)
+
+// Undo page header, reference to trx0undo.h. The undo records are appended at
+// the free offset
+const (
+	undoPageHeaderOffset = 38
+	undoPageFreeOffset   = undoPageHeaderOffset + 4
+)
+
+// IsRedoTypeApplicable reports whether the record can be applied without the
+// index information, they are the physical writes, the page initialization and
+// the undo page appends
+func IsRedoTypeApplicable(typ int) bool {
+	switch typ {
+	case Mlog1Byte, Mlog2Bytes, Mlog4Bytes, Mlog8Bytes, MlogWriteString,
+		MlogInitFilePage, MlogInitFilePage2, MlogUndoInsert, MlogUndoEraseEnd:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyRedoRecord applies the record to the page data like the recovery of
+// innodb, reference to recv_parse_or_apply_log_rec_body. The page lsn is not
+// changed, see SetPageLSN
+func ApplyRedoRecord(rec *RedoRecord, data []byte) error {
+	if rec.Unparsed || !IsRedoTypeApplicable(rec.Type) {
+		return errors.Errorf("Redo record %s can't be applied", rec.TypeString())
+	}
+	r := &redoReader{data: rec.Body}
+	size := len(data)
+	var err error
+	switch rec.Type {
+	case Mlog1Byte, Mlog2Bytes, Mlog4Bytes:
+		{
+			offset := int(r.uint16())
+			value := r.compressed()
+			// The type is the width of the value
+			width := rec.Type
+			if nil != r.err {
+				break
+			}
+			if offset+width > size {
+				err = errors.Errorf("Offset 0x%04X out of page", offset)
+				break
+			}
+			switch rec.Type {
+			case Mlog1Byte:
+				{
+					if value > 0xFF {
+						err = errors.Errorf("Value 0x%X out of 1 byte", value)
+						break
+					}
+					data[offset] = byte(value)
+				}
+			case Mlog2Bytes:
+				{
+					if value > 0xFFFF {
+						err = errors.Errorf("Value 0x%X out of 2 bytes", value)
+						break
+					}
+					binary.BigEndian.PutUint16(data[offset:], uint16(value))
+				}
+			default:
+				{
+					binary.BigEndian.PutUint32(data[offset:], value)
+				}
+			}
+		}
+	case Mlog8Bytes:
+		{
+			offset := int(r.uint16())
+			value := r.u64Compressed()
+			if nil != r.err {
+				break
+			}
+			if offset+8 > size {
+				err = errors.Errorf("Offset 0x%04X out of page", offset)
+				break
+			}
+			binary.BigEndian.PutUint64(data[offset:], value)
+		}
+	case MlogWriteString:
+		{
+			offset := int(r.uint16())
+			value := r.bytes(int(r.uint16()))
+			if nil != r.err {
+				break
+			}
+			if offset+len(value) > size {
+				err = errors.Errorf("Offset 0x%04X length %d out of page", offset, len(value))
+				break
+			}
+			copy(data[offset:], value)
+		}
+	case MlogInitFilePage, MlogInitFilePage2:
+		{
+			// fsp_init_file_page_low
+			for i := range data {
+				data[i] = 0
+			}
+			binary.BigEndian.PutUint32(data[4:], rec.PageNo)
+			binary.BigEndian.PutUint32(data[34:], rec.SpaceID)
+		}
+	case MlogUndoInsert:
+		{
+			// trx_undo_parse_add_undo_rec, the record is linked by the next offset
+			// before it and the previous offset after it
+			value := r.bytes(int(r.uint16()))
+			free := int(binary.BigEndian.Uint16(data[undoPageFreeOffset:]))
+			newFree := free + 4 + len(value)
+			if nil != r.err {
+				break
+			}
+			if free < undoPageFreeOffset || newFree > size-8 {
+				err = errors.Errorf("Undo free offset 0x%04X length %d out of page", free, len(value))
+				break
+			}
+			binary.BigEndian.PutUint16(data[free:], uint16(newFree))
+			copy(data[free+2:], value)
+			binary.BigEndian.PutUint16(data[free+2+len(value):], uint16(free))
+			binary.BigEndian.PutUint16(data[undoPageFreeOffset:], uint16(newFree))
+		}
+	case MlogUndoEraseEnd:
+		{
+			// trx_undo_erase_page_end
+			free := int(binary.BigEndian.Uint16(data[undoPageFreeOffset:]))
+			if free < undoPageFreeOffset || free > size-8 {
+				err = errors.Errorf("Undo free offset 0x%04X out of page", free)
+				break
+			}
+			for i := free; i < size-8; i++ {
+				data[i] = 0xFF
+			}
+		}
+	}
+	if nil != r.err {
+		return errors.Trace(r.err)
+	}
+	return err
+}
+
+// PageLSN returns the lsn of the page file header
+func PageLSN(data []byte) uint64 {
+	return binary.BigEndian.Uint64(data[16:])
+}
+
+// SetPageLSN writes the lsn into the file header and the low 32 bits into the
+// trailer, the checksum should be written after
+func SetPageLSN(data []byte, lsn uint64, fullCrc32 bool) {
+	size := len(data)
+	binary.BigEndian.PutUint64(data[16:], lsn)
+	if fullCrc32 {
+		binary.BigEndian.PutUint32(data[size-8:], uint32(lsn))
+	} else {
+		binary.BigEndian.PutUint32(data[size-4:], uint32(lsn))
+	}
+}
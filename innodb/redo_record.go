@@ -0,0 +1,677 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/juju/errors"
+)
+
+// Redo log record types, reference to mtr0types.h. The types are kept by the
+// later versions except the renamed ones
+const (
+	MlogSingleRecFlag          = 0x80
+	Mlog1Byte                  = 1
+	Mlog2Bytes                 = 2
+	Mlog4Bytes                 = 4
+	Mlog8Bytes                 = 8
+	MlogRecInsert              = 9
+	MlogRecClustDeleteMark     = 10
+	MlogRecSecDeleteMark       = 11
+	MlogRecUpdateInPlace       = 13
+	MlogRecDelete              = 14
+	MlogListEndDelete          = 15
+	MlogListStartDelete        = 16
+	MlogListEndCopyCreated     = 17
+	MlogPageReorganize         = 18
+	MlogPageCreate             = 19
+	MlogUndoInsert             = 20
+	MlogUndoEraseEnd           = 21
+	MlogUndoInit               = 22
+	MlogUndoHdrDiscard         = 23
+	MlogUndoHdrReuse           = 24
+	MlogUndoHdrCreate          = 25
+	MlogRecMinMark             = 26
+	MlogIbufBitmapInit         = 27
+	MlogLSN                    = 28
+	MlogInitFilePage           = 29
+	MlogWriteString            = 30
+	MlogMultiRecEnd            = 31
+	MlogDummyRecord            = 32
+	MlogFileCreate             = 33
+	MlogFileRename             = 34
+	MlogFileDelete             = 35
+	MlogCompRecMinMark         = 36
+	MlogCompPageCreate         = 37
+	MlogCompRecInsert          = 38
+	MlogCompRecClustDeleteMark = 39
+	MlogCompRecSecDeleteMark   = 40
+	MlogCompRecUpdateInPlace   = 41
+	MlogCompRecDelete          = 42
+	MlogCompListEndDelete      = 43
+	MlogCompListStartDelete    = 44
+	MlogCompListEndCopyCreated = 45
+	MlogCompPageReorganize     = 46
+	MlogFileCreate2            = 47
+	MlogZipWriteNodePtr        = 48
+	MlogZipWriteBlobPtr        = 49
+	MlogZipWriteHeader         = 50
+	MlogZipPageCompress        = 51
+	MlogZipPageCompressNoData  = 52
+	MlogZipPageReorganize      = 53
+	MlogFileRename2            = 54
+	MlogFileName               = 55
+	MlogCheckpoint             = 56
+	MlogPageCreateRtree        = 57
+	MlogCompPageCreateRtree    = 58
+	MlogInitFilePage2          = 59
+	MlogTruncate               = 60
+	MlogIndexLoad              = 61
+	// Since mysql 8.0
+	MlogTableDynamicMeta  = 62
+	MlogPageCreateSDI     = 63
+	MlogCompPageCreateSDI = 64
+	MlogFileExtend        = 65
+	MlogTest              = 66
+	// Since mysql 8.0.28, the index records log the index of the new format
+	MlogRecInsert8028             = 67
+	MlogRecClustDeleteMark8028    = 68
+	MlogRecDelete8028             = 69
+	MlogRecUpdateInPlace8028      = 70
+	MlogListEndCopyCreated8028    = 71
+	MlogPageReorganize8028        = 72
+	MlogZipPageReorganize8028     = 73
+	MlogZipPageCompressNoData8028 = 74
+	MlogListEndDelete8028         = 75
+	MlogListStartDelete8028       = 76
+)
+
+var redoTypeNames = map[int]string{
+	Mlog1Byte:                  "MLOG_1BYTE",
+	Mlog2Bytes:                 "MLOG_2BYTES",
+	Mlog4Bytes:                 "MLOG_4BYTES",
+	Mlog8Bytes:                 "MLOG_8BYTES",
+	MlogRecInsert:              "MLOG_REC_INSERT",
+	MlogRecClustDeleteMark:     "MLOG_REC_CLUST_DELETE_MARK",
+	MlogRecSecDeleteMark:       "MLOG_REC_SEC_DELETE_MARK",
+	MlogRecUpdateInPlace:       "MLOG_REC_UPDATE_IN_PLACE",
+	MlogRecDelete:              "MLOG_REC_DELETE",
+	MlogListEndDelete:          "MLOG_LIST_END_DELETE",
+	MlogListStartDelete:        "MLOG_LIST_START_DELETE",
+	MlogListEndCopyCreated:     "MLOG_LIST_END_COPY_CREATED",
+	MlogPageReorganize:         "MLOG_PAGE_REORGANIZE",
+	MlogPageCreate:             "MLOG_PAGE_CREATE",
+	MlogUndoInsert:             "MLOG_UNDO_INSERT",
+	MlogUndoEraseEnd:           "MLOG_UNDO_ERASE_END",
+	MlogUndoInit:               "MLOG_UNDO_INIT",
+	MlogUndoHdrDiscard:         "MLOG_UNDO_HDR_DISCARD",
+	MlogUndoHdrReuse:           "MLOG_UNDO_HDR_REUSE",
+	MlogUndoHdrCreate:          "MLOG_UNDO_HDR_CREATE",
+	MlogRecMinMark:             "MLOG_REC_MIN_MARK",
+	MlogIbufBitmapInit:         "MLOG_IBUF_BITMAP_INIT",
+	MlogLSN:                    "MLOG_LSN",
+	MlogInitFilePage:           "MLOG_INIT_FILE_PAGE",
+	MlogWriteString:            "MLOG_WRITE_STRING",
+	MlogMultiRecEnd:            "MLOG_MULTI_REC_END",
+	MlogDummyRecord:            "MLOG_DUMMY_RECORD",
+	MlogFileCreate:             "MLOG_FILE_CREATE",
+	MlogFileRename:             "MLOG_FILE_RENAME",
+	MlogFileDelete:             "MLOG_FILE_DELETE",
+	MlogCompRecMinMark:         "MLOG_COMP_REC_MIN_MARK",
+	MlogCompPageCreate:         "MLOG_COMP_PAGE_CREATE",
+	MlogCompRecInsert:          "MLOG_COMP_REC_INSERT",
+	MlogCompRecClustDeleteMark: "MLOG_COMP_REC_CLUST_DELETE_MARK",
+	MlogCompRecSecDeleteMark:   "MLOG_COMP_REC_SEC_DELETE_MARK",
+	MlogCompRecUpdateInPlace:   "MLOG_COMP_REC_UPDATE_IN_PLACE",
+	MlogCompRecDelete:          "MLOG_COMP_REC_DELETE",
+	MlogCompListEndDelete:      "MLOG_COMP_LIST_END_DELETE",
+	MlogCompListStartDelete:    "MLOG_COMP_LIST_START_DELETE",
+	MlogCompListEndCopyCreated: "MLOG_COMP_LIST_END_COPY_CREATED",
+	MlogCompPageReorganize:     "MLOG_COMP_PAGE_REORGANIZE",
+	MlogFileCreate2:            "MLOG_FILE_CREATE2",
+	MlogZipWriteNodePtr:        "MLOG_ZIP_WRITE_NODE_PTR",
+	MlogZipWriteBlobPtr:        "MLOG_ZIP_WRITE_BLOB_PTR",
+	MlogZipWriteHeader:         "MLOG_ZIP_WRITE_HEADER",
+	MlogZipPageCompress:        "MLOG_ZIP_PAGE_COMPRESS",
+	MlogZipPageCompressNoData:  "MLOG_ZIP_PAGE_COMPRESS_NO_DATA",
+	MlogZipPageReorganize:      "MLOG_ZIP_PAGE_REORGANIZE",
+	MlogFileRename2:            "MLOG_FILE_RENAME2",
+	MlogFileName:               "MLOG_FILE_NAME",
+	MlogCheckpoint:             "MLOG_CHECKPOINT",
+	MlogPageCreateRtree:        "MLOG_PAGE_CREATE_RTREE",
+	MlogCompPageCreateRtree:    "MLOG_COMP_PAGE_CREATE_RTREE",
+	MlogInitFilePage2:          "MLOG_INIT_FILE_PAGE2",
+	MlogTruncate:               "MLOG_TRUNCATE",
+	MlogIndexLoad:              "MLOG_INDEX_LOAD",
+	MlogTableDynamicMeta:       "MLOG_TABLE_DYNAMIC_META",
+	MlogPageCreateSDI:          "MLOG_PAGE_CREATE_SDI",
+	MlogCompPageCreateSDI:      "MLOG_COMP_PAGE_CREATE_SDI",
+	MlogFileExtend:             "MLOG_FILE_EXTEND",
+	MlogTest:                   "MLOG_TEST",
+}
+
+// redoTypeNames80 are the names renamed by mysql 8.0, the index records before
+// 8.0.28 have the suffix _8027 since 8.0.28
+var redoTypeNames80 = map[int]string{
+	MlogFileCreate2: "MLOG_FILE_CREATE",
+	MlogFileRename2: "MLOG_FILE_RENAME",
+}
+
+var redoTypeNames8028 = map[int]string{
+	MlogRecInsert8028:             "MLOG_REC_INSERT",
+	MlogRecClustDeleteMark8028:    "MLOG_REC_CLUST_DELETE_MARK",
+	MlogRecDelete8028:             "MLOG_REC_DELETE",
+	MlogRecUpdateInPlace8028:      "MLOG_REC_UPDATE_IN_PLACE",
+	MlogListEndCopyCreated8028:    "MLOG_LIST_END_COPY_CREATED",
+	MlogPageReorganize8028:        "MLOG_PAGE_REORGANIZE",
+	MlogZipPageReorganize8028:     "MLOG_ZIP_PAGE_REORGANIZE",
+	MlogZipPageCompressNoData8028: "MLOG_ZIP_PAGE_COMPRESS_NO_DATA",
+	MlogListEndDelete8028:         "MLOG_LIST_END_DELETE",
+	MlogListStartDelete8028:       "MLOG_LIST_START_DELETE",
+}
+
+var redoTypesRenamed8028 = []int{
+	MlogRecInsert, MlogRecClustDeleteMark, MlogRecUpdateInPlace, MlogRecDelete,
+	MlogListEndDelete, MlogListStartDelete, MlogListEndCopyCreated, MlogPageReorganize,
+	MlogCompRecInsert, MlogCompRecClustDeleteMark, MlogCompRecUpdateInPlace,
+	MlogCompRecDelete, MlogCompListEndDelete, MlogCompListStartDelete,
+	MlogCompListEndCopyCreated, MlogCompPageReorganize, MlogZipPageCompressNoData,
+	MlogZipPageReorganize,
+}
+
+// RedoTypeToString returns the name of the record type in the mysql version of
+// the redo log format
+func RedoTypeToString(typ int, format uint32) string {
+	if format >= RedoFormat8028 {
+		if name, ok := redoTypeNames8028[typ]; ok {
+			return name
+		}
+		for _, v := range redoTypesRenamed8028 {
+			if v == typ {
+				return redoTypeNames[typ] + "_8027"
+			}
+		}
+	}
+	if format >= RedoFormat801 {
+		if name, ok := redoTypeNames80[typ]; ok {
+			return name
+		}
+	}
+	if name, ok := redoTypeNames[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("MLOG_UNKNOWN_%d", typ)
+}
+
+// redoTypeHasPage reports whether the record starts with the space id and the
+// page no
+func redoTypeHasPage(typ int) bool {
+	switch typ {
+	case MlogMultiRecEnd, MlogDummyRecord, MlogCheckpoint, MlogTableDynamicMeta:
+		{
+			return false
+		}
+	}
+	return true
+}
+
+// Sanity limits of the record lengths, the records of a page never exceed
+// twice of the max page size
+const redoMaxRecordLen = 2 * maxPageSize
+
+// The compact index flag of the index information since 8.0.28, the instant and
+// versioned indexes are logged with the extra fields
+const redoIndexFlagCompact = 0x01
+
+var (
+	// errRedoShort is returned if the record continues in the next block
+	errRedoShort = errors.New("Redo record is incomplete")
+	// errRedoUnsupported is returned if the length of the record is unknown
+	errRedoUnsupported = errors.New("Redo record format is not supported")
+	errRedoCorrupt     = errors.New("Redo record is corrupt")
+)
+
+// RedoRecord is the mini-transaction log record, the body is the data after the
+// space id and the page no
+type RedoRecord struct {
+	LSN uint64
+	// EndLSN is the lsn after the record, it is the page lsn after applying
+	EndLSN uint64
+	Type   int
+	// Single is set on the only record of the mini-transaction
+	Single  bool
+	SpaceID uint32
+	PageNo  uint32
+	Body    []byte
+	// Unparsed is set if the record can't be parsed, the body is empty
+	Unparsed bool
+	format   uint32
+}
+
+// HasPage reports whether the record modifies a page
+func (rec *RedoRecord) HasPage() bool {
+	return redoTypeHasPage(rec.Type)
+}
+
+// TypeString returns the name of the record type
+func (rec *RedoRecord) TypeString() string {
+	return RedoTypeToString(rec.Type, rec.format)
+}
+
+// Summary describes the payload of the record
+func (rec *RedoRecord) Summary() string {
+	if rec.Unparsed {
+		return "not parsed, the rest of the record group is skipped"
+	}
+	r := &redoReader{data: rec.Body, describe: true}
+	parseRedoBody(r, rec.Type)
+	return r.summary
+}
+
+// parseRedoRecord parses the record at the beginning of the data, and returns
+// the record and the length. The record with the space id and the page no is
+// returned with errRedoUnsupported
+func parseRedoRecord(data []byte, format uint32) (*RedoRecord, int, error) {
+	r := &redoReader{data: data}
+	b := r.uint8()
+	if nil != r.err {
+		return nil, 0, r.err
+	}
+	rec := &RedoRecord{
+		Type:   int(b &^ MlogSingleRecFlag),
+		Single: 0 != b&MlogSingleRecFlag,
+		format: format,
+	}
+	if redoTypeHasPage(rec.Type) {
+		rec.SpaceID = r.compressed()
+		rec.PageNo = r.compressed()
+		if nil != r.err {
+			return rec, 0, r.err
+		}
+	}
+	start := r.pos
+	parseRedoBody(r, rec.Type)
+	if nil != r.err {
+		return rec, 0, r.err
+	}
+	rec.Body = append([]byte(nil), data[start:r.pos]...)
+	return rec, r.pos, nil
+}
+
+// redoReader reads the record fields, the first error is kept and the following
+// reads return zero values
+type redoReader struct {
+	data     []byte
+	pos      int
+	err      error
+	describe bool
+	summary  string
+}
+
+func (r *redoReader) fail(err error) {
+	if nil == r.err {
+		r.err = err
+	}
+}
+
+func (r *redoReader) describef(format string, args ...interface{}) {
+	if r.describe && nil == r.err {
+		r.summary = fmt.Sprintf(format, args...)
+	}
+}
+
+func (r *redoReader) bytes(n int) []byte {
+	if nil != r.err {
+		return nil
+	}
+	if n < 0 || n > redoMaxRecordLen {
+		r.fail(errRedoCorrupt)
+		return nil
+	}
+	if r.pos+n > len(r.data) {
+		r.fail(errRedoShort)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *redoReader) uint8() uint8 {
+	if b := r.bytes(1); nil != b {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *redoReader) uint16() uint16 {
+	if b := r.bytes(2); nil != b {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *redoReader) uint32() uint32 {
+	if b := r.bytes(4); nil != b {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *redoReader) uint64() uint64 {
+	if b := r.bytes(8); nil != b {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// compressed reads the compressed integer, reference to mach_parse_compressed
+func (r *redoReader) compressed() uint32 {
+	if nil != r.err {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.fail(errRedoShort)
+		return 0
+	}
+	flag := r.data[r.pos]
+	switch {
+	case flag < 0x80:
+		{
+			return uint32(r.uint8())
+		}
+	case flag < 0xC0:
+		{
+			return uint32(r.uint16()) & 0x7FFF
+		}
+	case flag < 0xE0:
+		{
+			b := r.bytes(3)
+			if nil == b {
+				return 0
+			}
+			return (uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])) & 0x3FFFFF
+		}
+	case flag < 0xF0:
+		{
+			return r.uint32() & 0x1FFFFFFF
+		}
+	}
+	r.uint8()
+	return r.uint32()
+}
+
+// u64Compressed reads the compressed high 32 bits and the low 32 bits, reference
+// to mach_u64_parse_compressed
+func (r *redoReader) u64Compressed() uint64 {
+	high := r.compressed()
+	return uint64(high)<<32 | uint64(r.uint32())
+}
+
+// index skips the index information of the index records, reference to
+// mlog_parse_index. The redundant indexes are logged without the fields before
+// 8.0.28
+func (r *redoReader) index(typ int) int {
+	switch typ {
+	case MlogRecInsert8028, MlogRecClustDeleteMark8028, MlogRecDelete8028,
+		MlogRecUpdateInPlace8028, MlogListEndCopyCreated8028, MlogPageReorganize8028,
+		MlogZipPageReorganize8028, MlogZipPageCompressNoData8028, MlogListEndDelete8028,
+		MlogListStartDelete8028:
+		{
+			// Index log version and flag
+			r.uint8()
+			if flag := r.uint8(); 0 != flag&^redoIndexFlagCompact {
+				r.fail(errRedoUnsupported)
+				return 0
+			} else if 0 == flag {
+				return 0
+			}
+		}
+	case MlogCompRecInsert, MlogCompRecClustDeleteMark, MlogCompRecSecDeleteMark,
+		MlogCompRecUpdateInPlace, MlogCompRecDelete, MlogCompListEndDelete,
+		MlogCompListStartDelete, MlogCompListEndCopyCreated, MlogCompPageReorganize,
+		MlogZipPageCompressNoData, MlogZipPageReorganize:
+		{
+		}
+	default:
+		{
+			return 0
+		}
+	}
+	n := r.uint16()
+	if 0 != n&0x8000 {
+		// Instant columns since 8.0.13
+		n &= 0x7FFF
+		r.uint16()
+	}
+	// n_uniq and the field lengths
+	r.uint16()
+	r.bytes(int(n) * 2)
+	return int(n)
+}
+
+// sysValues skips the system columns of the clustered record, reference to
+// row_upd_parse_sys_vals
+func (r *redoReader) sysValues() uint64 {
+	// Position of the trx id field and the roll ptr
+	r.compressed()
+	r.bytes(7)
+	return r.u64Compressed()
+}
+
+// parseRedoBody parses the body of the record type, the summary is set if the
+// reader describes the record
+func parseRedoBody(r *redoReader, typ int) {
+	switch typ {
+	case Mlog1Byte, Mlog2Bytes, Mlog4Bytes:
+		{
+			offset := r.uint16()
+			value := r.compressed()
+			r.describef("offset 0x%04X value 0x%X", offset, value)
+		}
+	case Mlog8Bytes:
+		{
+			offset := r.uint16()
+			value := r.u64Compressed()
+			r.describef("offset 0x%04X value 0x%X", offset, value)
+		}
+	case MlogWriteString:
+		{
+			offset := r.uint16()
+			n := r.uint16()
+			r.bytes(int(n))
+			r.describef("offset 0x%04X length %d", offset, n)
+		}
+	case MlogRecInsert, MlogCompRecInsert, MlogRecInsert8028:
+		{
+			// page_cur_parse_insert_rec
+			fields := r.index(typ)
+			cursor := r.uint16()
+			endSegLen := r.compressed()
+			if 0 != endSegLen&0x1 {
+				// Info bits, origin offset and mismatch index
+				r.uint8()
+				r.compressed()
+				r.compressed()
+			}
+			r.bytes(int(endSegLen >> 1))
+			r.describef("cursor 0x%04X length %d index fields %d", cursor, endSegLen>>1, fields)
+		}
+	case MlogListEndCopyCreated, MlogCompListEndCopyCreated, MlogListEndCopyCreated8028:
+		{
+			fields := r.index(typ)
+			n := r.uint32()
+			r.bytes(int(n))
+			r.describef("length %d index fields %d", n, fields)
+		}
+	case MlogRecClustDeleteMark, MlogCompRecClustDeleteMark, MlogRecClustDeleteMark8028:
+		{
+			r.index(typ)
+			// Flags
+			r.uint8()
+			value := r.uint8()
+			trxID := r.sysValues()
+			offset := r.uint16()
+			r.describef("offset 0x%04X delete mark %d trx id %d", offset, value, trxID)
+		}
+	case MlogRecSecDeleteMark, MlogCompRecSecDeleteMark:
+		{
+			r.index(typ)
+			value := r.uint8()
+			offset := r.uint16()
+			r.describef("offset 0x%04X delete mark %d", offset, value)
+		}
+	case MlogRecUpdateInPlace, MlogCompRecUpdateInPlace, MlogRecUpdateInPlace8028:
+		{
+			// btr_cur_parse_update_in_place and row_upd_index_parse
+			r.index(typ)
+			r.uint8()
+			trxID := r.sysValues()
+			offset := r.uint16()
+			// Info bits
+			r.uint8()
+			n := r.compressed()
+			if n > redoMaxRecordLen {
+				r.fail(errRedoCorrupt)
+			}
+			for i := uint32(0); i < n && nil == r.err; i++ {
+				// Field no and length
+				r.compressed()
+				if length := r.compressed(); 0xFFFFFFFF != length {
+					r.bytes(int(length))
+				}
+			}
+			r.describef("offset 0x%04X trx id %d fields %d", offset, trxID, n)
+		}
+	case MlogRecDelete, MlogCompRecDelete, MlogRecDelete8028,
+		MlogListEndDelete, MlogListStartDelete, MlogCompListEndDelete, MlogCompListStartDelete,
+		MlogListEndDelete8028, MlogListStartDelete8028:
+		{
+			r.index(typ)
+			offset := r.uint16()
+			r.describef("offset 0x%04X", offset)
+		}
+	case MlogPageReorganize, MlogCompPageReorganize, MlogPageReorganize8028:
+		{
+			fields := r.index(typ)
+			r.describef("index fields %d", fields)
+		}
+	case MlogZipPageReorganize, MlogZipPageReorganize8028, MlogZipPageCompressNoData,
+		MlogZipPageCompressNoData8028:
+		{
+			r.index(typ)
+			level := r.uint8()
+			r.describef("compression level %d", level)
+		}
+	case MlogPageCreate, MlogCompPageCreate, MlogPageCreateRtree, MlogCompPageCreateRtree,
+		MlogPageCreateSDI, MlogCompPageCreateSDI, MlogUndoEraseEnd, MlogUndoHdrDiscard,
+		MlogIbufBitmapInit, MlogInitFilePage, MlogInitFilePage2, MlogMultiRecEnd, MlogDummyRecord:
+		{
+		}
+	case MlogUndoInsert:
+		{
+			n := r.uint16()
+			r.bytes(int(n))
+			r.describef("length %d", n)
+		}
+	case MlogUndoInit:
+		{
+			undoType := r.compressed()
+			r.describef("undo type %d", undoType)
+		}
+	case MlogUndoHdrCreate, MlogUndoHdrReuse:
+		{
+			trxID := r.u64Compressed()
+			r.describef("trx id %d", trxID)
+		}
+	case MlogRecMinMark, MlogCompRecMinMark:
+		{
+			offset := r.uint16()
+			r.describef("offset 0x%04X", offset)
+		}
+	case MlogZipWriteNodePtr, MlogZipWriteBlobPtr:
+		{
+			offset := r.uint16()
+			zipOffset := r.uint16()
+			if MlogZipWriteNodePtr == typ {
+				r.bytes(4)
+			} else {
+				r.bytes(20)
+			}
+			r.describef("offset 0x%04X zip offset 0x%04X", offset, zipOffset)
+		}
+	case MlogZipWriteHeader:
+		{
+			offset := r.uint8()
+			n := r.uint8()
+			r.bytes(int(n))
+			r.describef("offset 0x%04X length %d", offset, n)
+		}
+	case MlogZipPageCompress:
+		{
+			size := r.uint16()
+			trailerSize := r.uint16()
+			// Prev and next page no
+			r.bytes(8 + int(size) + int(trailerSize))
+			r.describef("size %d trailer %d", size, trailerSize)
+		}
+	case MlogFileCreate, MlogFileRename, MlogFileDelete, MlogFileCreate2, MlogFileRename2, MlogFileName:
+		{
+			// fil_name_parse
+			var flags uint32
+			if MlogFileCreate2 == typ {
+				flags = r.uint32()
+			}
+			name := r.bytes(int(r.uint16()))
+			switch typ {
+			case MlogFileRename, MlogFileRename2:
+				{
+					to := r.bytes(int(r.uint16()))
+					r.describef("%s to %s", trimCString(name), trimCString(to))
+				}
+			case MlogFileCreate2:
+				{
+					r.describef("%s flags 0x%X", trimCString(name), flags)
+				}
+			default:
+				{
+					r.describef("%s", trimCString(name))
+				}
+			}
+		}
+	case MlogCheckpoint, MlogTruncate:
+		{
+			lsn := r.uint64()
+			r.describef("lsn %d", lsn)
+		}
+	case MlogIndexLoad:
+		{
+			id := r.uint64()
+			r.describef("index id %d", id)
+		}
+	case MlogFileExtend:
+		{
+			offset := r.uint64()
+			size := r.uint64()
+			r.describef("offset %d size %d", offset, size)
+		}
+	case MlogLSN, MlogTableDynamicMeta, MlogTest:
+		{
+			r.fail(errRedoUnsupported)
+		}
+	default:
+		{
+			r.fail(errRedoCorrupt)
+		}
+	}
+}
+
+// trimCString returns the string without the terminating zero
+func trimCString(data []byte) string {
+	for i, v := range data {
+		if 0 == v {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
@@ -0,0 +1,345 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func TestRedoBlockChecksum(t *testing.T) {
+	// The checksums calculated by the bitwise crc32c and
+	// log_block_calc_checksum_innodb
+	block := make([]byte, RedoBlockSize)
+	for i := 0; i < redoBlockChecksumOffset; i++ {
+		block[i] = byte(i*7 + 3)
+	}
+	if v := calcRedoBlockInnodbChecksum(block[:redoBlockChecksumOffset]); 0x11571F94 != v {
+		t.Fatalf("innodb checksum of the block is 0x%08X", v)
+	}
+	for _, c := range []struct {
+		checksum uint32
+		valid    bool
+	}{
+		{0xF0B704D6, true},
+		{0x11571F94, true},
+		{0xDEADBEEF, true},
+		{0xF0B704D7, false},
+	} {
+		binary.BigEndian.PutUint32(block[redoBlockChecksumOffset:], c.checksum)
+		if c.valid != redoBlockChecksumValid(block) {
+			t.Fatalf("block checksum 0x%08X is valid %v", c.checksum, !c.valid)
+		}
+	}
+}
+
+func TestRedoLSNAdd(t *testing.T) {
+	cases := []struct {
+		lsn    uint64
+		n      int
+		expect uint64
+	}{
+		{8204, 10, 8214},
+		// The data of the block is full, the lsn is after the header of the
+		// next block
+		{8204, 496, 8716},
+		{8692, 7, 8699},
+		{8692, 8, 8716},
+		{8204, 1000, 9236},
+		{8492, 2000, 10556},
+	}
+	for _, c := range cases {
+		if v := redoLSNAdd(c.lsn, c.n); c.expect != v {
+			t.Fatalf("lsn %d + %d bytes is %d, expect %d", c.lsn, c.n, v, c.expect)
+		}
+	}
+}
+
+// redoTestCompressed encodes the integer like mach_write_compressed
+func redoTestCompressed(v uint32) []byte {
+	switch {
+	case v < 0x80:
+		{
+			return []byte{byte(v)}
+		}
+	case v < 0x4000:
+		{
+			return []byte{byte(v>>8) | 0x80, byte(v)}
+		}
+	case v < 0x200000:
+		{
+			return []byte{byte(v>>16) | 0xC0, byte(v >> 8), byte(v)}
+		}
+	case v < 0x10000000:
+		{
+			return []byte{byte(v>>24) | 0xE0, byte(v >> 16), byte(v >> 8), byte(v)}
+		}
+	}
+	return []byte{0xF0, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// redoTestRecord returns the record of the type on the page with the body
+func redoTestRecord(typ int, space uint32, page uint32, body ...[]byte) []byte {
+	rec := []byte{byte(typ)}
+	if redoTypeHasPage(typ &^ MlogSingleRecFlag) {
+		rec = append(rec, redoTestCompressed(space)...)
+		rec = append(rec, redoTestCompressed(page)...)
+	}
+	for _, b := range body {
+		rec = append(rec, b...)
+	}
+	return rec
+}
+
+func redoTestUint16(v int) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+// redoTestWriter writes the mini-transaction record groups into the log blocks
+// from the lsn of the first block
+type redoTestWriter struct {
+	blocks [][]byte
+	lsn    uint64
+	pos    int
+}
+
+func (w *redoTestWriter) finish() {
+	if 0 == len(w.blocks) {
+		return
+	}
+	block := w.blocks[len(w.blocks)-1]
+	dataLen := w.pos
+	if redoBlockChecksumOffset == dataLen {
+		dataLen = RedoBlockSize
+	}
+	binary.BigEndian.PutUint16(block[redoBlockDataLenOffset:], uint16(dataLen))
+	binary.BigEndian.PutUint32(block[redoBlockChecksumOffset:],
+		crc32.Checksum(block[:redoBlockChecksumOffset], crc32cTable))
+}
+
+func (w *redoTestWriter) next() {
+	w.finish()
+	block := make([]byte, RedoBlockSize)
+	lsn := w.lsn + uint64(len(w.blocks))*RedoBlockSize
+	binary.BigEndian.PutUint32(block[redoBlockNoOffset:], redoBlockNo(lsn))
+	w.blocks = append(w.blocks, block)
+	w.pos = redoBlockHeaderSize
+}
+
+// group writes the records of a mini-transaction, and returns the lsn of every
+// record
+func (w *redoTestWriter) group(recs ...[]byte) []uint64 {
+	lsns := make([]uint64, len(recs))
+	for i, rec := range recs {
+		for j, b := range rec {
+			if 0 == len(w.blocks) || redoBlockChecksumOffset == w.pos {
+				w.next()
+			}
+			block := w.blocks[len(w.blocks)-1]
+			if 0 == j {
+				lsns[i] = w.lsn + uint64(len(w.blocks)-1)*RedoBlockSize + uint64(w.pos)
+				if 0 == i && 0 == binary.BigEndian.Uint16(block[redoBlockFirstRecGroupOffset:]) {
+					binary.BigEndian.PutUint16(block[redoBlockFirstRecGroupOffset:], uint16(w.pos))
+				}
+			}
+			block[w.pos] = b
+			w.pos++
+		}
+	}
+	return lsns
+}
+
+// file returns the redo log file of the format with the blocks
+func (w *redoTestWriter) file(format uint32) []byte {
+	w.finish()
+	data := make([]byte, redoFileHeaderSize)
+	binary.BigEndian.PutUint32(data[redoHeaderFormatOffset:], format)
+	binary.BigEndian.PutUint64(data[redoHeaderStartLSNOffset:], w.lsn)
+	copy(data[redoHeaderCreatorOffset:], "MySQL 5.7.30")
+	binary.BigEndian.PutUint64(data[redoCheckpoint1Offset+redoCheckpointLSNOffset:], w.lsn+redoBlockHeaderSize)
+	for _, offset := range []int{0, redoCheckpoint1Offset, redoCheckpoint2Offset} {
+		block := data[offset : offset+RedoBlockSize]
+		binary.BigEndian.PutUint32(block[redoBlockChecksumOffset:],
+			crc32.Checksum(block[:redoBlockChecksumOffset], crc32cTable))
+	}
+	for _, block := range w.blocks {
+		data = append(data, block...)
+	}
+	return data
+}
+
+type redoTestResult struct {
+	typ   int
+	lsn   uint64
+	end   uint64
+	space uint32
+	page  uint32
+}
+
+func iterateTestRedoRecords(t *testing.T, data []byte) ([]*RedoRecord, *RedoParseStatistic) {
+	f, err := NewRedoLogFile(bytes.NewReader(data))
+	if nil != err {
+		t.Fatal(err)
+	}
+	var recs []*RedoRecord
+	st, err := NewRedoLog([]*RedoLogFile{f}).IterateRecords(func(rec *RedoRecord) error {
+		recs = append(recs, rec)
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	return recs, st
+}
+
+func TestRedoIterateRecords(t *testing.T) {
+	w := &redoTestWriter{lsn: 8192}
+	var expect []redoTestResult
+	group := func(recs ...[]byte) {
+		for i, lsn := range w.group(recs...) {
+			rec, _, _ := parseRedoRecord(recs[i], RedoFormat57)
+			expect = append(expect, redoTestResult{rec.Type, lsn, redoLSNAdd(lsn, len(recs[i])), rec.SpaceID, rec.PageNo})
+		}
+	}
+	// The groups span the blocks, the long string spans 3 blocks
+	for i := 0; i < 20; i++ {
+		group(redoTestRecord(MlogSingleRecFlag|Mlog4Bytes, 5, uint32(i), redoTestUint16(0x26), redoTestCompressed(0x1234)))
+		group(redoTestRecord(Mlog2Bytes, 5, 3, redoTestUint16(0x38), redoTestCompressed(7)),
+			redoTestRecord(MlogWriteString, 300, 0x4000, redoTestUint16(0x100), redoTestUint16(37*i),
+				bytes.Repeat([]byte{byte(i)}, 37*i)),
+			redoTestRecord(MlogMultiRecEnd, 0, 0))
+	}
+	file := w.file(RedoFormat57)
+
+	recs, st := iterateTestRedoRecords(t, file)
+	if len(expect) != len(recs) || 0 != st.Corrupt || 0 != st.Unparsed {
+		t.Fatalf("%d records parsed, expect %d, statistic %+v", len(recs), len(expect), st)
+	}
+	for i, rec := range recs {
+		e := expect[i]
+		if e.typ != rec.Type || e.lsn != rec.LSN || e.space != rec.SpaceID || e.page != rec.PageNo {
+			t.Fatalf("record %d is %s at lsn %d page %d:%d, expect lsn %d", i, rec.TypeString(),
+				rec.LSN, rec.SpaceID, rec.PageNo, e.lsn)
+		}
+		if i+1 < len(recs) && recs[i+1].LSN != rec.EndLSN {
+			t.Fatalf("end lsn %d of record %d is not the next lsn %d", rec.EndLSN, i, recs[i+1].LSN)
+		}
+	}
+	if 0x4000 != recs[2].PageNo || "offset 0x0100 length 0" != recs[2].Summary() {
+		t.Fatalf("MLOG_WRITE_STRING is %s", recs[2].Summary())
+	}
+
+	// The records in the blocks before the corrupt block are parsed, then the
+	// parsing restarts from the first record group of the next block
+	corrupt := 4
+	file[redoFileHeaderSize+corrupt*RedoBlockSize+100] ^= 0xff
+	blockLSN := w.lsn + uint64(corrupt)*RedoBlockSize
+	first := binary.BigEndian.Uint16(w.blocks[corrupt+1][redoBlockFirstRecGroupOffset:])
+	resync := blockLSN + RedoBlockSize + uint64(first)
+	var kept []redoTestResult
+	for _, e := range expect {
+		if e.end <= blockLSN+redoBlockHeaderSize || e.lsn >= resync {
+			kept = append(kept, e)
+		}
+	}
+	recs, st = iterateTestRedoRecords(t, file)
+	if 1 != st.Corrupt || len(kept) != len(recs) {
+		t.Fatalf("%d records parsed after the corrupt block, expect %d, statistic %+v", len(recs), len(kept), st)
+	}
+	for i, rec := range recs {
+		if kept[i].lsn != rec.LSN {
+			t.Fatalf("record %d at lsn %d, expect %d", i, rec.LSN, kept[i].lsn)
+		}
+	}
+
+	// The block of the previous round has the block no of the smaller lsn
+	file[redoFileHeaderSize+corrupt*RedoBlockSize+100] ^= 0xff
+	stale := make([]byte, RedoBlockSize)
+	binary.BigEndian.PutUint32(stale[redoBlockNoOffset:], redoBlockNo(8192))
+	binary.BigEndian.PutUint16(stale[redoBlockDataLenOffset:], RedoBlockSize)
+	binary.BigEndian.PutUint16(stale[redoBlockFirstRecGroupOffset:], redoBlockHeaderSize)
+	binary.BigEndian.PutUint32(stale[redoBlockChecksumOffset:], crc32.Checksum(stale[:redoBlockChecksumOffset], crc32cTable))
+	recs, st = iterateTestRedoRecords(t, append(file, stale...))
+	if 1 != st.Stale || len(expect) != len(recs) {
+		t.Fatalf("%d records parsed with the stale block, statistic %+v", len(recs), st)
+	}
+}
+
+func TestParseRedoRecord(t *testing.T) {
+	// The index information of 2 fields
+	index := append(append(redoTestUint16(2), redoTestUint16(1)...), 0x80, 4, 0, 0)
+	instant := append(append(redoTestUint16(0x8002), redoTestUint16(1)...), index[2:]...)
+	insert := [][]byte{redoTestUint16(0x80), redoTestCompressed(6<<1 | 1), {0}, redoTestCompressed(0x63), redoTestCompressed(2), []byte("abcdef")}
+
+	cases := []struct {
+		format  uint32
+		rec     []byte
+		name    string
+		summary string
+		err     error
+	}{
+		{RedoFormat57, redoTestRecord(Mlog4Bytes, 5, 3, redoTestUint16(0x26), redoTestCompressed(0x12345)),
+			"MLOG_4BYTES", "offset 0x0026 value 0x12345", nil},
+		{RedoFormat57, redoTestRecord(Mlog4Bytes, 5, 0x200000, redoTestUint16(0x26), redoTestCompressed(0x4000)),
+			"MLOG_4BYTES", "offset 0x0026 value 0x4000", nil},
+		{RedoFormat57, redoTestRecord(Mlog4Bytes, 5, 0x10000000, redoTestUint16(0x26), redoTestCompressed(0xFFFFFFFF)),
+			"MLOG_4BYTES", "offset 0x0026 value 0xFFFFFFFF", nil},
+		{RedoFormat57, redoTestRecord(Mlog8Bytes, 5, 3, redoTestUint16(0x10), redoTestCompressed(0xA), []byte{0x12, 0x34, 0x56, 0x78}),
+			"MLOG_8BYTES", "offset 0x0010 value 0xA12345678", nil},
+		{RedoFormat57, redoTestRecord(MlogCompRecInsert, 5, 3, append([][]byte{index}, insert...)...),
+			"MLOG_COMP_REC_INSERT", "cursor 0x0080 length 6 index fields 2", nil},
+		{RedoFormat801, redoTestRecord(MlogCompRecInsert, 5, 3, append([][]byte{instant}, insert...)...),
+			"MLOG_COMP_REC_INSERT", "cursor 0x0080 length 6 index fields 2", nil},
+		{RedoFormat8028, redoTestRecord(MlogCompRecInsert, 5, 3, append([][]byte{index}, insert...)...),
+			"MLOG_COMP_REC_INSERT_8027", "cursor 0x0080 length 6 index fields 2", nil},
+		{RedoFormat8028, redoTestRecord(MlogRecInsert8028, 5, 3, append([][]byte{{1, redoIndexFlagCompact}, index}, insert...)...),
+			"MLOG_REC_INSERT", "cursor 0x0080 length 6 index fields 2", nil},
+		// The redundant index has no field information
+		{RedoFormat8030, redoTestRecord(MlogRecInsert8028, 5, 3, append([][]byte{{1, 0}}, insert...)...),
+			"MLOG_REC_INSERT", "cursor 0x0080 length 6 index fields 0", nil},
+		{RedoFormat8030, redoTestRecord(MlogRecInsert8028, 5, 3, append([][]byte{{1, 0x02}, index}, insert...)...),
+			"MLOG_REC_INSERT", "", errRedoUnsupported},
+		{RedoFormat8030, redoTestRecord(MlogRecDelete8028, 5, 3, []byte{1, redoIndexFlagCompact}, index, redoTestUint16(0x7F)),
+			"MLOG_REC_DELETE", "offset 0x007F", nil},
+		{RedoFormat57, redoTestRecord(MlogCompRecClustDeleteMark, 5, 3, index, []byte{0, 1}, redoTestCompressed(2),
+			make([]byte, 7), redoTestCompressed(0), []byte{0, 0, 0x12, 0x34}, redoTestUint16(0x80)),
+			"MLOG_COMP_REC_CLUST_DELETE_MARK", "offset 0x0080 delete mark 1 trx id 4660", nil},
+		{RedoFormat57, redoTestRecord(MlogCompRecUpdateInPlace, 5, 3, index, []byte{0}, redoTestCompressed(2),
+			make([]byte, 7), redoTestCompressed(0), []byte{0, 0, 0x12, 0x34}, redoTestUint16(0x80), []byte{0},
+			redoTestCompressed(2), redoTestCompressed(1), redoTestCompressed(3), []byte("xyz"),
+			redoTestCompressed(2), redoTestCompressed(0xFFFFFFFF)),
+			"MLOG_COMP_REC_UPDATE_IN_PLACE", "offset 0x0080 trx id 4660 fields 2", nil},
+		{RedoFormat57, redoTestRecord(MlogFileCreate2, 5, 0, []byte{0, 0, 0x40, 0x21}, redoTestUint16(8), []byte("./t.ibd\x00")),
+			"MLOG_FILE_CREATE2", "./t.ibd flags 0x4021", nil},
+		{RedoFormat801, redoTestRecord(MlogFileCreate2, 5, 0, []byte{0, 0, 0x40, 0x21}, redoTestUint16(8), []byte("./t.ibd\x00")),
+			"MLOG_FILE_CREATE", "./t.ibd flags 0x4021", nil},
+		{RedoFormat57, redoTestRecord(MlogFileRename2, 5, 0, redoTestUint16(8), []byte("./a.ibd\x00"), redoTestUint16(8), []byte("./b.ibd\x00")),
+			"MLOG_FILE_RENAME2", "./a.ibd to ./b.ibd", nil},
+		{RedoFormat57, redoTestRecord(MlogCheckpoint, 0, 0, []byte{0, 0, 0, 0, 0, 0, 0x20, 0x0C}),
+			"MLOG_CHECKPOINT", "lsn 8204", nil},
+		{RedoFormat8030, redoTestRecord(MlogFileExtend, 5, 0, []byte{0, 0, 0, 0, 0, 1, 0, 0}, []byte{0, 0, 0, 0, 0, 4, 0, 0}),
+			"MLOG_FILE_EXTEND", "offset 65536 size 262144", nil},
+		{RedoFormat57, redoTestRecord(Mlog4Bytes, 5, 3, redoTestUint16(0x26)), "MLOG_4BYTES", "", errRedoShort},
+		{RedoFormat57, redoTestRecord(MlogCompRecInsert, 5, 3, redoTestUint16(2)), "MLOG_COMP_REC_INSERT", "", errRedoShort},
+		{RedoFormat57, redoTestRecord(MlogTest, 5, 3), "MLOG_TEST", "", errRedoUnsupported},
+		{RedoFormat57, redoTestRecord(100, 5, 3), "MLOG_UNKNOWN_100", "", errRedoCorrupt},
+	}
+	for _, c := range cases {
+		rec, n, err := parseRedoRecord(c.rec, c.format)
+		if c.name != rec.TypeString() {
+			t.Fatalf("record type is %s, expect %s", rec.TypeString(), c.name)
+		}
+		if c.err != err {
+			t.Fatalf("%s error %v, expect %v", c.name, err, c.err)
+		}
+		if nil != err {
+			continue
+		}
+		if len(c.rec) != n || (redoTypeHasPage(rec.Type) && 5 != rec.SpaceID) {
+			t.Fatalf("%s of %d bytes is parsed %d bytes, space %d", c.name, len(c.rec), n, rec.SpaceID)
+		}
+		if c.summary != rec.Summary() {
+			t.Fatalf("%s summary is %q, expect %q", c.name, rec.Summary(), c.summary)
+		}
+	}
+}
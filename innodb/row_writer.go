@@ -0,0 +1,215 @@
+package innodb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// States of the recovered rows
+const (
+	RowStateCarved       = "carved"
+	RowStateDeleteMarked = "delete-marked"
+	RowStatePurged       = "purged-intact"
+	RowStateOverwritten  = "partially-overwritten"
+)
+
+// RecoveredRow is the row decoded from the clustered index leaf page, the state
+// tells where the record is found
+type RecoveredRow struct {
+	Page   int
+	Origin int
+	State  string
+	Fields []*RecordField
+}
+
+// Output formats of the rows
+const (
+	RowFormatCSV    = "csv"
+	RowFormatNDJSON = "ndjson"
+	RowFormatSQL    = "sql"
+)
+
+// RowWriter writes the rows as CSV with the header line, JSON lines or SQL INSERT
+// statements. With meta, the state, page and offset of the recovered row are the
+// first 3 CSV fields, the first JSON fields or the comment of the statement,
+// otherwise the SQL rows are inserted in batches
+type RowWriter struct {
+	w       io.Writer
+	format  string
+	meta    bool
+	batch   int
+	csv     *csv.Writer
+	table   *Table
+	columns []*Column
+	// Values of the SQL rows not written
+	pending []string
+}
+
+func NewRowWriter(w io.Writer, table *Table, format string, meta bool, batch int) *RowWriter {
+	dw := &RowWriter{
+		w:      w,
+		format: format,
+		meta:   meta,
+		batch:  batch,
+		table:  table,
+	}
+	for _, c := range table.Columns {
+		if !c.Virtual {
+			dw.columns = append(dw.columns, c)
+		}
+	}
+	if RowFormatCSV == format {
+		dw.csv = csv.NewWriter(w)
+		header := make([]string, 0, len(dw.columns)+3)
+		if meta {
+			header = append(header, "_state", "_page", "_offset")
+		}
+		for _, c := range dw.columns {
+			header = append(header, c.Name)
+		}
+		// The error is returned by flush
+		dw.csv.Write(header)
+	}
+	return dw
+}
+
+// values returns the column values of the row, nil is NULL. The externally
+// stored field of the purged row may be freed, with meta only the local prefix
+// is kept and the row is marked partially overwritten, otherwise the error is
+// returned. The external part is not read if the table space is nil, the value
+// is truncated to the local prefix likewise. The JSON value can't be decoded is
+// NULL in the partially overwritten row
+func (dw *RowWriter) values(ts *Tablespace, row *RecoveredRow) ([][]byte, error) {
+	values := make([][]byte, len(dw.columns))
+	for i, c := range dw.columns {
+		for _, field := range row.Fields {
+			if field.Column.Name != c.Name || field.Null {
+				continue
+			}
+			data := field.Data
+			if field.Extern {
+				data = field.Data[:len(field.Data)-externFieldRefSize]
+				err := errors.New("No table space")
+				if nil != ts {
+					var ext []byte
+					if ext, err = ts.ExternField(field); nil == err {
+						data = ext
+					}
+				}
+				if nil != err {
+					if !dw.meta {
+						return nil, errors.Errorf("Read external field %s of the record at page %d offset 0x%04X error %v",
+							c.Name, row.Page, row.Origin, err)
+					}
+					row.State = RowStateOverwritten
+				}
+			}
+			if ColumnTypeJSON == c.Type {
+				if _, err := decodeBinaryJSON(data); nil != err {
+					if !dw.meta {
+						return nil, errors.Errorf("Decode JSON field %s of the record at page %d offset 0x%04X error %v",
+							c.Name, row.Page, row.Origin, err)
+					}
+					row.State = RowStateOverwritten
+					data = nil
+				}
+			}
+			values[i] = data
+			break
+		}
+	}
+	return values, nil
+}
+
+// Write writes the row, the external fields are read from the table space
+func (dw *RowWriter) Write(ts *Tablespace, row *RecoveredRow) error {
+	values, err := dw.values(ts, row)
+	if nil != err {
+		return err
+	}
+	switch dw.format {
+	case RowFormatCSV:
+		{
+			record := make([]string, 0, len(values)+3)
+			if dw.meta {
+				record = append(record, row.State, strconv.Itoa(row.Page), fmt.Sprintf("0x%04X", row.Origin))
+			}
+			for i, c := range dw.columns {
+				if nil == values[i] {
+					// NULL of LOAD DATA
+					record = append(record, `\N`)
+				} else {
+					record = append(record, columnText(c, values[i]))
+				}
+			}
+			return dw.csv.Write(record)
+		}
+	case RowFormatNDJSON:
+		{
+			var b strings.Builder
+			b.WriteByte('{')
+			if dw.meta {
+				fmt.Fprintf(&b, `"_state":%q,"_page":%d,"_offset":%d,`, row.State, row.Page, row.Origin)
+			}
+			for i, c := range dw.columns {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				name, _ := json.Marshal(c.Name)
+				b.Write(name)
+				b.WriteByte(':')
+				b.WriteString(jsonValue(c, values[i]))
+			}
+			b.WriteString("}\n")
+			_, err := io.WriteString(dw.w, b.String())
+			return err
+		}
+	}
+
+	literals := make([]string, len(dw.columns))
+	for i, c := range dw.columns {
+		literals[i] = sqlLiteral(c, values[i])
+	}
+	if dw.meta {
+		_, err := fmt.Fprintf(dw.w, "%s (%s); -- %s page %d offset 0x%04X\n",
+			dw.insertStatement(), strings.Join(literals, ","), row.State, row.Page, row.Origin)
+		return err
+	}
+	dw.pending = append(dw.pending, "("+strings.Join(literals, ",")+")")
+	if len(dw.pending) >= dw.batch {
+		return dw.flushSQL()
+	}
+	return nil
+}
+
+func (dw *RowWriter) insertStatement() string {
+	names := make([]string, len(dw.columns))
+	for i, c := range dw.columns {
+		names[i] = quoteSQLIdent(c.Name)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES", quoteSQLIdent(dw.table.Name), strings.Join(names, ","))
+}
+
+func (dw *RowWriter) flushSQL() error {
+	if 0 == len(dw.pending) {
+		return nil
+	}
+	_, err := fmt.Fprintf(dw.w, "%s\n%s;\n", dw.insertStatement(), strings.Join(dw.pending, ",\n"))
+	dw.pending = dw.pending[:0]
+	return err
+}
+
+// Flush writes the pending SQL rows and flushes the CSV writer
+func (dw *RowWriter) Flush() error {
+	if nil == dw.csv {
+		return dw.flushSQL()
+	}
+	dw.csv.Flush()
+	return dw.csv.Error()
+}
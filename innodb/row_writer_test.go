@@ -0,0 +1,109 @@
+package innodb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRowWriterJSON(t *testing.T) {
+	table := &Table{
+		Name: "t",
+		Columns: []*Column{
+			{Name: "id", Type: ColumnTypeInt},
+			{Name: "doc", Type: ColumnTypeJSON, Nullable: true},
+		},
+	}
+	id := []byte{0x80, 0, 0, 1}
+	doc := jsonbTestValue(jsonbTestObject{
+		keys:   []string{"a", "b"},
+		values: []interface{}{"x,y", []interface{}{1, nil}},
+	}, false)
+	row := func(doc []byte) *RecoveredRow {
+		return &RecoveredRow{
+			Page:  3,
+			State: RowStateCarved,
+			Fields: []*RecordField{
+				{Column: table.Columns[0], Data: id},
+				{Column: table.Columns[1], Data: doc, Null: nil == doc},
+			},
+		}
+	}
+
+	for _, c := range []struct {
+		format string
+		expect string
+	}{
+		{RowFormatCSV, "id,doc\n1,\"{\"\"a\"\": \"\"x,y\"\", \"\"b\"\": [1, null]}\"\n1,\\N\n"},
+		{RowFormatNDJSON, "{\"id\":1,\"doc\":{\"a\": \"x,y\", \"b\": [1, null]}}\n{\"id\":1,\"doc\":null}\n"},
+		{RowFormatSQL, "INSERT INTO `t` (`id`,`doc`) VALUES\n(1,'{\\\"a\\\": \\\"x,y\\\", \\\"b\\\": [1, null]}'),\n(1,NULL);\n"},
+	} {
+		var buf bytes.Buffer
+		dw := NewRowWriter(&buf, table, c.format, false, 10)
+		for _, r := range []*RecoveredRow{row(doc), row(nil)} {
+			if err := dw.Write(nil, r); nil != err {
+				t.Fatal(err)
+			}
+		}
+		if err := dw.Flush(); nil != err {
+			t.Fatal(err)
+		}
+		if c.expect != buf.String() {
+			t.Fatalf("%s rows are %q, expect %q", c.format, buf.String(), c.expect)
+		}
+	}
+
+	// The JSON value can't be decoded stops the export, and is NULL of the
+	// partially overwritten row with meta
+	var buf bytes.Buffer
+	if err := NewRowWriter(&buf, table, RowFormatSQL, false, 10).Write(nil, row(doc[:8])); nil == err {
+		t.Fatal("corrupt JSON is written")
+	}
+	r := row(doc[:8])
+	dw := NewRowWriter(&buf, table, RowFormatCSV, true, 10)
+	if err := dw.Write(nil, r); nil != err {
+		t.Fatal(err)
+	}
+	dw.Flush()
+	if RowStateOverwritten != r.State || !bytes.HasSuffix(buf.Bytes(), []byte(",1,\\N\n")) {
+		t.Fatalf("corrupt JSON row is %s %q", r.State, buf.String())
+	}
+}
+
+func TestRowWriterCarvedExtern(t *testing.T) {
+	table := &Table{
+		Name: "t",
+		Columns: []*Column{
+			{Name: "id", Type: ColumnTypeInt},
+			{Name: "body", Type: ColumnTypeBlob, Nullable: true},
+		},
+	}
+	// The local prefix and the 20 bytes external reference
+	body := append([]byte("prefix"), make([]byte, externFieldRefSize)...)
+	row := &RecoveredRow{
+		Page:   5,
+		Origin: 0x80,
+		State:  RowStateCarved,
+		Fields: []*RecordField{
+			{Column: table.Columns[0], Data: []byte{0x80, 0, 0, 1}},
+			{Column: table.Columns[1], Data: body, Extern: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	dw := NewRowWriter(&buf, table, RowFormatCSV, true, 1)
+	if err := dw.Write(nil, row); nil != err {
+		t.Fatal(err)
+	}
+	if err := dw.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	expect := "_state,_page,_offset,id,body\npartially-overwritten,5,0x0080,1,0x707265666978\n"
+	if expect != buf.String() {
+		t.Fatalf("carved row is %q, expect %q", buf.String(), expect)
+	}
+
+	row.State = RowStateCarved
+	if err := NewRowWriter(&buf, table, RowFormatSQL, false, 1).Write(nil, row); nil == err {
+		t.Fatal("truncated external field is written")
+	}
+}
@@ -0,0 +1,862 @@
+package innodb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Column types
+const (
+	ColumnTypeTinyInt = iota
+	ColumnTypeSmallInt
+	ColumnTypeMediumInt
+	ColumnTypeInt
+	ColumnTypeBigInt
+	ColumnTypeFloat
+	ColumnTypeDouble
+	ColumnTypeDecimal
+	ColumnTypeDate
+	ColumnTypeTime
+	ColumnTypeDatetime
+	ColumnTypeTimestamp
+	ColumnTypeYear
+	ColumnTypeChar
+	ColumnTypeVarchar
+	ColumnTypeBinary
+	ColumnTypeVarbinary
+	ColumnTypeTinyBlob
+	ColumnTypeBlob
+	ColumnTypeMediumBlob
+	ColumnTypeLongBlob
+	ColumnTypeTinyText
+	ColumnTypeText
+	ColumnTypeMediumText
+	ColumnTypeLongText
+	ColumnTypeEnum
+	ColumnTypeSet
+	ColumnTypeBit
+	ColumnTypeJSON
+	ColumnTypeGeometry
+	// Internal system columns
+	ColumnTypeRowID
+	ColumnTypeTrxID
+	ColumnTypeRollPtr
+	ColumnTypeChildPage
+)
+
+var columnTypeStrs = []string{
+	"tinyint",
+	"smallint",
+	"mediumint",
+	"int",
+	"bigint",
+	"float",
+	"double",
+	"decimal",
+	"date",
+	"time",
+	"datetime",
+	"timestamp",
+	"year",
+	"char",
+	"varchar",
+	"binary",
+	"varbinary",
+	"tinyblob",
+	"blob",
+	"mediumblob",
+	"longblob",
+	"tinytext",
+	"text",
+	"mediumtext",
+	"longtext",
+	"enum",
+	"set",
+	"bit",
+	"json",
+	"geometry",
+	"sys",
+	"sys",
+	"sys",
+	"sys",
+}
+
+var columnTypeNames = map[string]int{
+	"tinyint":            ColumnTypeTinyInt,
+	"bool":               ColumnTypeTinyInt,
+	"boolean":            ColumnTypeTinyInt,
+	"smallint":           ColumnTypeSmallInt,
+	"mediumint":          ColumnTypeMediumInt,
+	"int":                ColumnTypeInt,
+	"integer":            ColumnTypeInt,
+	"bigint":             ColumnTypeBigInt,
+	"float":              ColumnTypeFloat,
+	"double":             ColumnTypeDouble,
+	"real":               ColumnTypeDouble,
+	"decimal":            ColumnTypeDecimal,
+	"numeric":            ColumnTypeDecimal,
+	"dec":                ColumnTypeDecimal,
+	"date":               ColumnTypeDate,
+	"time":               ColumnTypeTime,
+	"datetime":           ColumnTypeDatetime,
+	"timestamp":          ColumnTypeTimestamp,
+	"year":               ColumnTypeYear,
+	"char":               ColumnTypeChar,
+	"varchar":            ColumnTypeVarchar,
+	"binary":             ColumnTypeBinary,
+	"varbinary":          ColumnTypeVarbinary,
+	"tinyblob":           ColumnTypeTinyBlob,
+	"blob":               ColumnTypeBlob,
+	"mediumblob":         ColumnTypeMediumBlob,
+	"longblob":           ColumnTypeLongBlob,
+	"tinytext":           ColumnTypeTinyText,
+	"text":               ColumnTypeText,
+	"mediumtext":         ColumnTypeMediumText,
+	"longtext":           ColumnTypeLongText,
+	"enum":               ColumnTypeEnum,
+	"set":                ColumnTypeSet,
+	"bit":                ColumnTypeBit,
+	"json":               ColumnTypeJSON,
+	"geometry":           ColumnTypeGeometry,
+	"point":              ColumnTypeGeometry,
+	"linestring":         ColumnTypeGeometry,
+	"polygon":            ColumnTypeGeometry,
+	"multipoint":         ColumnTypeGeometry,
+	"multilinestring":    ColumnTypeGeometry,
+	"multipolygon":       ColumnTypeGeometry,
+	"geometrycollection": ColumnTypeGeometry,
+	"geomcollection":     ColumnTypeGeometry,
+}
+
+type charsetInfo struct {
+	mbminlen int
+	mbmaxlen int
+}
+
+var charsets = map[string]charsetInfo{
+	"binary":  {1, 1},
+	"latin1":  {1, 1},
+	"ascii":   {1, 1},
+	"utf8":    {1, 3},
+	"utf8mb3": {1, 3},
+	"utf8mb4": {1, 4},
+	"gbk":     {1, 2},
+	"gb2312":  {1, 2},
+	"gb18030": {1, 4},
+	"big5":    {1, 2},
+	"sjis":    {1, 2},
+	"cp932":   {1, 2},
+	"ujis":    {1, 3},
+	"eucjpms": {1, 3},
+	"euckr":   {1, 2},
+	"ucs2":    {2, 2},
+	"utf16":   {2, 4},
+	"utf16le": {2, 4},
+	"utf32":   {4, 4},
+}
+
+// Default charset of string columns if not specified, same as mysql 8.0
+const defaultCharset = "utf8mb4"
+
+func getCharsetInfo(name string) charsetInfo {
+	if cs, ok := charsets[name]; ok {
+		return cs
+	}
+	// Other charsets are all single byte
+	return charsetInfo{1, 1}
+}
+
+// Column describes a table column or an internal system column
+type Column struct {
+	Name string
+	Type int
+	// CHAR/VARCHAR/BINARY/VARBINARY length in characters, BIT length in bits
+	Length int
+	// DECIMAL precision and scale, scale is the fractional seconds precision
+	// of TIME/DATETIME/TIMESTAMP
+	Precision int
+	Scale     int
+	Unsigned  bool
+	Nullable  bool
+	Charset   string
+	Collation string
+	// ENUM/SET elements
+	Elements []string
+	// Virtual generated column, not stored in the record
+	Virtual bool
+	// Inline PRIMARY KEY or UNIQUE in the column definition
+	primaryKey bool
+	uniqueKey  bool
+	// Prefix length in characters of the index field, only set on the
+	// column copies of the index
+	Prefix int
+}
+
+// Internal system columns of the clustered index
+var (
+	columnRowID     = &Column{Name: "DB_ROW_ID", Type: ColumnTypeRowID}
+	columnTrxID     = &Column{Name: "DB_TRX_ID", Type: ColumnTypeTrxID}
+	columnRollPtr   = &Column{Name: "DB_ROLL_PTR", Type: ColumnTypeRollPtr}
+	columnChildPage = &Column{Name: "CHILD_PAGE", Type: ColumnTypeChildPage}
+)
+
+func (c *Column) IsInteger() bool {
+	switch c.Type {
+	case ColumnTypeTinyInt, ColumnTypeSmallInt, ColumnTypeMediumInt,
+		ColumnTypeInt, ColumnTypeBigInt:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Column) IsString() bool {
+	switch c.Type {
+	case ColumnTypeChar, ColumnTypeVarchar,
+		ColumnTypeTinyText, ColumnTypeText, ColumnTypeMediumText, ColumnTypeLongText:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlob returns true for the types stored as BLOB in innodb
+func (c *Column) IsBlob() bool {
+	switch c.Type {
+	case ColumnTypeTinyBlob, ColumnTypeBlob, ColumnTypeMediumBlob, ColumnTypeLongBlob,
+		ColumnTypeTinyText, ColumnTypeText, ColumnTypeMediumText, ColumnTypeLongText,
+		ColumnTypeJSON, ColumnTypeGeometry:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Column) IsBinary() bool {
+	switch c.Type {
+	case ColumnTypeBinary, ColumnTypeVarbinary,
+		ColumnTypeTinyBlob, ColumnTypeBlob, ColumnTypeMediumBlob, ColumnTypeLongBlob,
+		ColumnTypeGeometry:
+		{
+			return true
+		}
+	}
+	return c.IsString() && "binary" == c.Charset
+}
+
+// Byte size of the fractional seconds part
+func fspSize(fsp int) int {
+	return (fsp + 1) / 2
+}
+
+// Decimal digits to bytes
+var decimalDigitsToBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+func decimalSize(precision int, scale int) int {
+	intg := precision - scale
+	return (intg/9)*4 + decimalDigitsToBytes[intg%9] +
+		(scale/9)*4 + decimalDigitsToBytes[scale%9]
+}
+
+// fixedSize returns the stored size of the fixed length column, or 0
+// if the column is variable length in COMPACT/DYNAMIC format
+func (c *Column) FixedSize() int {
+	switch c.Type {
+	case ColumnTypeTinyInt:
+		{
+			return 1
+		}
+	case ColumnTypeSmallInt:
+		{
+			return 2
+		}
+	case ColumnTypeMediumInt, ColumnTypeDate:
+		{
+			return 3
+		}
+	case ColumnTypeInt, ColumnTypeFloat, ColumnTypeChildPage:
+		{
+			return 4
+		}
+	case ColumnTypeBigInt, ColumnTypeDouble:
+		{
+			return 8
+		}
+	case ColumnTypeDecimal:
+		{
+			return decimalSize(c.Precision, c.Scale)
+		}
+	case ColumnTypeTime:
+		{
+			return 3 + fspSize(c.Scale)
+		}
+	case ColumnTypeDatetime:
+		{
+			return 5 + fspSize(c.Scale)
+		}
+	case ColumnTypeTimestamp:
+		{
+			return 4 + fspSize(c.Scale)
+		}
+	case ColumnTypeYear:
+		{
+			return 1
+		}
+	case ColumnTypeChar:
+		{
+			// Multi-byte charset CHAR is stored as variable length
+			cs := getCharsetInfo(c.Charset)
+			if cs.mbminlen != cs.mbmaxlen {
+				return 0
+			}
+			if c.Prefix > 0 && c.Prefix < c.Length {
+				return c.Prefix * cs.mbmaxlen
+			}
+			return c.Length * cs.mbmaxlen
+		}
+	case ColumnTypeBinary:
+		{
+			if c.Prefix > 0 && c.Prefix < c.Length {
+				return c.Prefix
+			}
+			return c.Length
+		}
+	case ColumnTypeEnum:
+		{
+			if len(c.Elements) > 255 {
+				return 2
+			}
+			return 1
+		}
+	case ColumnTypeSet:
+		{
+			n := (len(c.Elements) + 7) / 8
+			if n > 4 {
+				return 8
+			}
+			return n
+		}
+	case ColumnTypeBit:
+		{
+			return (c.Length + 7) / 8
+		}
+	case ColumnTypeRowID, ColumnTypeTrxID:
+		{
+			return 6
+		}
+	case ColumnTypeRollPtr:
+		{
+			return 7
+		}
+	}
+	return 0
+}
+
+// MaxSize returns the max stored size in bytes
+func (c *Column) MaxSize() int {
+	if size := c.FixedSize(); size != 0 {
+		return size
+	}
+	switch c.Type {
+	case ColumnTypeChar, ColumnTypeVarchar:
+		{
+			return c.Length * getCharsetInfo(c.Charset).mbmaxlen
+		}
+	case ColumnTypeVarbinary:
+		{
+			return c.Length
+		}
+	}
+	// Blobs
+	return 0xffffffff
+}
+
+// isBig returns true if the variable length of the column may be stored in 2 bytes
+func (c *Column) isBig() bool {
+	return c.IsBlob() || c.MaxSize() > 255
+}
+
+func (c *Column) TypeString() string {
+	name := columnTypeStrs[c.Type]
+	switch c.Type {
+	case ColumnTypeChar, ColumnTypeVarchar, ColumnTypeBinary, ColumnTypeVarbinary, ColumnTypeBit:
+		{
+			name = fmt.Sprintf("%s(%d)", name, c.Length)
+		}
+	case ColumnTypeDecimal:
+		{
+			name = fmt.Sprintf("%s(%d,%d)", name, c.Precision, c.Scale)
+		}
+	case ColumnTypeTime, ColumnTypeDatetime, ColumnTypeTimestamp:
+		{
+			if c.Scale != 0 {
+				name = fmt.Sprintf("%s(%d)", name, c.Scale)
+			}
+		}
+	case ColumnTypeEnum, ColumnTypeSet:
+		{
+			name = fmt.Sprintf("%s('%s')", name, strings.Join(c.Elements, "','"))
+		}
+	}
+	if c.Unsigned {
+		name += " unsigned"
+	}
+	return name
+}
+
+// Index describes the fields of an index
+type Index struct {
+	Name     string
+	ID       uint64
+	Primary  bool
+	Unique   bool
+	Fulltext bool
+	Spatial  bool
+	Columns  []*Column
+	Table    *Table
+}
+
+// Table describes the table columns and indexes, the clustered index is
+// the first index
+type Table struct {
+	Name      string
+	Columns   []*Column
+	Indexes   []*Index
+	Charset   string
+	Collation string
+	RowFormat string
+}
+
+func (t *Table) FindColumn(name string) *Column {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (t *Table) FindIndexByName(name string) *Index {
+	for _, i := range t.Indexes {
+		if strings.EqualFold(i.Name, name) {
+			return i
+		}
+	}
+	return nil
+}
+
+// findIndex finds the index with the index id, if the index ids are unknown,
+// the clustered index is returned
+func (t *Table) FindIndex(id uint64) *Index {
+	known := false
+	for _, i := range t.Indexes {
+		if i.ID == id {
+			return i
+		}
+		if i.ID != 0 {
+			known = true
+		}
+	}
+	if known {
+		return nil
+	}
+	return t.ClusteredIndex()
+}
+
+func (t *Table) ClusteredIndex() *Index {
+	if 0 == len(t.Indexes) {
+		return nil
+	}
+	return t.Indexes[0]
+}
+
+// newTable creates the table with the primary key columns, if no primary
+// key specified, the clustered index is generated with DB_ROW_ID
+func newTable(name string, columns []*Column, pk []string) (*Table, error) {
+	t := &Table{
+		Name:    name,
+		Columns: columns,
+	}
+	ci := &Index{
+		Name:    "PRIMARY",
+		Primary: true,
+		Unique:  true,
+		Table:   t,
+	}
+	for _, n := range pk {
+		c := t.FindColumn(n)
+		if nil == c {
+			return nil, errors.Errorf("Primary key column %s not found", n)
+		}
+		// Primary key columns are always not null
+		c.Nullable = false
+		ci.Columns = append(ci.Columns, c)
+	}
+	if 0 == len(ci.Columns) {
+		ci.Name = "GEN_CLUST_INDEX"
+	}
+	t.Indexes = append(t.Indexes, ci)
+	return t, nil
+}
+
+func (i *Index) IsClustered() bool {
+	return i == i.Table.ClusteredIndex()
+}
+
+// recordFields returns the fields stored in the index record, the clustered
+// index leaf records store primary key, DB_TRX_ID, DB_ROLL_PTR and other columns,
+// the node pointer records store the primary key and the child page number
+func (i *Index) RecordFields(leaf bool) []*Column {
+	fields := make([]*Column, 0, len(i.Table.Columns)+3)
+	if i.IsClustered() {
+		if 0 == len(i.Columns) {
+			fields = append(fields, columnRowID)
+		} else {
+			fields = append(fields, i.Columns...)
+		}
+		if !leaf {
+			return append(fields, columnChildPage)
+		}
+		fields = append(fields, columnTrxID, columnRollPtr)
+		for _, c := range i.Table.Columns {
+			if !i.containsColumn(c) && !c.Virtual {
+				fields = append(fields, c)
+			}
+		}
+		return fields
+	}
+
+	// Secondary index records store the index columns and the primary key
+	fields = append(fields, i.Columns...)
+	ci := i.Table.ClusteredIndex()
+	if 0 == len(ci.Columns) {
+		fields = append(fields, columnRowID)
+	}
+	for _, c := range ci.Columns {
+		if !i.containsColumn(c) {
+			fields = append(fields, c)
+		}
+	}
+	if !leaf {
+		fields = append(fields, columnChildPage)
+	}
+	return fields
+}
+
+// containsColumn checks whether the whole column (not prefix) is in the index
+func (i *Index) containsColumn(col *Column) bool {
+	for _, c := range i.Columns {
+		if c.Name == col.Name && 0 == c.Prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// nullableCount returns the nullable fields count of the leaf record, the null
+// bitmap of the node pointer records has the same size
+func (i *Index) nullableCount() int {
+	n := 0
+	for _, c := range i.RecordFields(true) {
+		if c.Nullable {
+			n++
+		}
+	}
+	return n
+}
+
+// parseColumnDefinitions parses the comma separated column definitions, e.g.
+// "id bigint not null, name varchar(32) charset latin1, age int unsigned"
+func parseColumnDefinitions(spec string) ([]*Column, error) {
+	lex := newSQLLexer(spec)
+	columns := make([]*Column, 0, 8)
+	for {
+		if lex.peek().typ == sqlTokenEOF {
+			break
+		}
+		c, err := parseColumnDefinition(lex, defaultCharset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		columns = append(columns, c)
+		if !lex.acceptSymbol(",") {
+			break
+		}
+	}
+	if tok := lex.peek(); tok.typ != sqlTokenEOF {
+		return nil, errors.Errorf("Unexpected %s in column definitions", tok.text)
+	}
+	if 0 == len(columns) {
+		return nil, errors.New("No column defined")
+	}
+	return columns, nil
+}
+
+// parseColumnDefinition parses column name, type and attributes, the lexer stops
+// at the token after the definition
+func parseColumnDefinition(lex *sqlLexer, charset string) (*Column, error) {
+	tok := lex.next()
+	if tok.typ != sqlTokenIdent {
+		return nil, errors.Errorf("Expect column name but got %s", tok.text)
+	}
+	c := &Column{
+		Name:     tok.text,
+		Nullable: true,
+	}
+	tok = lex.next()
+	typ, ok := columnTypeNames[strings.ToLower(tok.text)]
+	if tok.typ != sqlTokenIdent || !ok {
+		return nil, errors.Errorf("Unsupported type %s of column %s", tok.text, c.Name)
+	}
+	c.Type = typ
+
+	// Type arguments
+	args := make([]string, 0, 2)
+	if lex.acceptSymbol("(") {
+		for {
+			tok = lex.next()
+			if tok.typ != sqlTokenNumber && tok.typ != sqlTokenString {
+				return nil, errors.Errorf("Invalid type argument %s of column %s", tok.text, c.Name)
+			}
+			args = append(args, tok.text)
+			if lex.acceptSymbol(")") {
+				break
+			}
+			if !lex.acceptSymbol(",") {
+				return nil, errors.Errorf("Invalid type arguments of column %s", c.Name)
+			}
+		}
+	}
+	if err := c.setTypeArguments(args); nil != err {
+		return nil, errors.Trace(err)
+	}
+
+	// Attributes
+	for {
+		tok = lex.peek()
+		if tok.typ == sqlTokenEOF ||
+			(tok.typ == sqlTokenSymbol && (tok.text == "," || tok.text == ")")) {
+			break
+		}
+		lex.next()
+		if tok.typ == sqlTokenSymbol && tok.text == "(" {
+			// Skip the expression of DEFAULT, CHECK etc.
+			lex.skipParentheses()
+			continue
+		}
+		if tok.typ != sqlTokenIdent {
+			continue
+		}
+		switch strings.ToLower(tok.text) {
+		case "unsigned":
+			{
+				c.Unsigned = true
+			}
+		case "not":
+			{
+				if lex.acceptKeyword("null") {
+					c.Nullable = false
+				}
+			}
+		case "null":
+			{
+				c.Nullable = true
+			}
+		case "charset", "character":
+			{
+				if strings.EqualFold(tok.text, "character") && !lex.acceptKeyword("set") {
+					return nil, errors.Errorf("Expect SET after CHARACTER of column %s", c.Name)
+				}
+				lex.acceptSymbol("=")
+				c.Charset = strings.ToLower(lex.next().text)
+			}
+		case "collate":
+			{
+				lex.acceptSymbol("=")
+				collation := strings.ToLower(lex.next().text)
+				c.Collation = collation
+				if "" == c.Charset {
+					c.Charset = strings.SplitN(collation, "_", 2)[0]
+				}
+			}
+		case "primary":
+			{
+				lex.acceptKeyword("key")
+				c.primaryKey = true
+				c.Nullable = false
+			}
+		case "key":
+			{
+				// KEY alone is PRIMARY KEY in the column definition
+				c.primaryKey = true
+				c.Nullable = false
+			}
+		case "unique":
+			{
+				lex.acceptKeyword("key")
+				c.uniqueKey = true
+			}
+		case "as", "virtual":
+			{
+				// Generated column is virtual and not stored by default
+				c.Virtual = true
+			}
+		case "stored", "persistent":
+			{
+				c.Virtual = false
+			}
+		case "default", "comment", "on", "update":
+			{
+				// Skip the value, it may be a keyword like NULL
+				if next := lex.peek(); next.typ != sqlTokenSymbol ||
+					(next.text != "," && next.text != ")" && next.text != "(") {
+					lex.next()
+				}
+			}
+		}
+	}
+
+	return c.complete(charset), nil
+}
+
+func (c *Column) setTypeArguments(args []string) error {
+	var nums []int
+	if c.Type != ColumnTypeEnum && c.Type != ColumnTypeSet {
+		for _, a := range args {
+			var n int
+			if _, err := fmt.Sscanf(a, "%d", &n); nil != err {
+				return errors.Errorf("Invalid type argument %s of column %s", a, c.Name)
+			}
+			nums = append(nums, n)
+		}
+	}
+
+	switch c.Type {
+	case ColumnTypeChar, ColumnTypeBinary, ColumnTypeBit:
+		{
+			c.Length = 1
+			if len(nums) > 0 {
+				c.Length = nums[0]
+			}
+		}
+	case ColumnTypeVarchar, ColumnTypeVarbinary:
+		{
+			if 0 == len(nums) {
+				return errors.Errorf("Length of column %s not specified", c.Name)
+			}
+			c.Length = nums[0]
+		}
+	case ColumnTypeDecimal:
+		{
+			c.Precision = 10
+			if len(nums) > 0 {
+				c.Precision = nums[0]
+			}
+			if len(nums) > 1 {
+				c.Scale = nums[1]
+			}
+			if c.Precision > 65 || c.Scale > 30 || c.Scale > c.Precision {
+				return errors.Errorf("Invalid decimal(%d,%d) of column %s", c.Precision, c.Scale, c.Name)
+			}
+		}
+	case ColumnTypeTime, ColumnTypeDatetime, ColumnTypeTimestamp:
+		{
+			if len(nums) > 0 {
+				c.Scale = nums[0]
+			}
+			if c.Scale > 6 {
+				return errors.Errorf("Invalid fractional seconds precision of column %s", c.Name)
+			}
+		}
+	case ColumnTypeEnum, ColumnTypeSet:
+		{
+			c.Elements = args
+		}
+	}
+	return nil
+}
+
+// complete sets the default charset of the string column
+func (c *Column) complete(charset string) *Column {
+	switch c.Type {
+	case ColumnTypeBinary, ColumnTypeVarbinary:
+		{
+			c.Charset = "binary"
+			c.Collation = "binary"
+		}
+	case ColumnTypeChar, ColumnTypeVarchar,
+		ColumnTypeTinyText, ColumnTypeText, ColumnTypeMediumText, ColumnTypeLongText,
+		ColumnTypeEnum, ColumnTypeSet:
+		{
+			if "" == c.Charset {
+				c.Charset = charset
+			}
+			if "" == c.Collation && "" != c.Charset {
+				c.Collation = defaultCollation(c.Charset)
+			}
+		}
+	default:
+		{
+			c.Charset = ""
+			c.Collation = ""
+		}
+	}
+	return c
+}
+
+// LoadTableSchema loads the table from the CREATE TABLE statement file, or creates
+// the table from the column definitions and the comma separated primary key columns,
+// returns nil if no schema specified
+func LoadTableSchema(schema string, columns string, pk string) (*Table, error) {
+	if "" != schema {
+		return loadSchemaFile(schema)
+	}
+	if "" == columns {
+		return nil, nil
+	}
+	cols, err := parseColumnDefinitions(columns)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	var pkcols []string
+	for _, n := range strings.Split(pk, ",") {
+		if n = strings.TrimSpace(n); "" != n {
+			pkcols = append(pkcols, n)
+		}
+	}
+	if 0 == len(pkcols) {
+		for _, c := range cols {
+			if c.primaryKey {
+				pkcols = append(pkcols, c.Name)
+			}
+		}
+	}
+	return newTable("", cols, pkcols)
+}
+
+// openTableSchema loads the table schema specified by the CREATE TABLE statement
+// file or the column definitions, and resolves the index ids from the root pages.
+// The schema is read from the SDI if not specified, returns nil if no schema found
+func openTableSchema(f io.ReaderAt, schema string, columns string, pk string, pageSize int) (*Table, error) {
+	table, err := LoadTableSchema(schema, columns, pk)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	if nil != table {
+		if err = resolveIndexIDs(f, table, &ParsePageOptions{PageSize: pageSize}); nil != err {
+			return nil, errors.Annotate(err, "Resolve index id")
+		}
+		return table, nil
+	}
+	table, err = readSDITable(f, pageSize)
+	if err == errNoSDI {
+		return nil, nil
+	}
+	return table, err
+}
@@ -0,0 +1,507 @@
+package innodb
+
+import (
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// loadSchemaFile parses the first CREATE TABLE statement in the sql file
+func loadSchemaFile(path string) (*Table, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	return parseCreateTable(string(data))
+}
+
+// parseCreateTable parses the first CREATE TABLE statement, other statements
+// like SET and DROP TABLE in the mysqldump output are skipped
+func parseCreateTable(sql string) (*Table, error) {
+	lex := newSQLLexer(sql)
+	for {
+		tok := lex.next()
+		if tok.typ == sqlTokenEOF {
+			return nil, errors.New("No CREATE TABLE statement found")
+		}
+		if tok.typ != sqlTokenIdent || !strings.EqualFold(tok.text, "create") {
+			continue
+		}
+		lex.acceptKeyword("temporary")
+		if !lex.acceptKeyword("table") {
+			continue
+		}
+		return parseCreateTableBody(lex)
+	}
+}
+
+// ddlKey is the key definition before converting to the innodb index
+type ddlKey struct {
+	name     string
+	primary  bool
+	unique   bool
+	fulltext bool
+	spatial  bool
+	foreign  bool
+	check    bool
+	columns  []string
+	prefixes []int
+	// Functional key part is not supported
+	functional bool
+	order      int
+}
+
+func parseCreateTableBody(lex *sqlLexer) (*Table, error) {
+	if lex.acceptKeyword("if") {
+		if !lex.acceptKeyword("not") || !lex.acceptKeyword("exists") {
+			return nil, errors.New("Expect IF NOT EXISTS")
+		}
+	}
+	tok := lex.next()
+	if tok.typ != sqlTokenIdent {
+		return nil, errors.Errorf("Expect table name but got %s", tok.text)
+	}
+	name := tok.text
+	if lex.acceptSymbol(".") {
+		// Database name is specified
+		name = lex.next().text
+	}
+	if lex.acceptKeyword("like") {
+		return nil, errors.New("CREATE TABLE LIKE is not supported")
+	}
+	if !lex.acceptSymbol("(") {
+		return nil, errors.Errorf("Expect ( after table name %s", name)
+	}
+
+	columns := make([]*Column, 0, 16)
+	keys := make([]*ddlKey, 0, 4)
+	for {
+		key, err := parseKeyDefinition(lex)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if nil != key {
+			key.order = len(keys)
+			keys = append(keys, key)
+		} else {
+			c, err := parseColumnDefinition(lex, "")
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			columns = append(columns, c)
+			if c.primaryKey || c.uniqueKey {
+				keys = append(keys, &ddlKey{
+					name:     c.Name,
+					primary:  c.primaryKey,
+					unique:   true,
+					columns:  []string{c.Name},
+					prefixes: []int{0},
+					order:    len(keys),
+				})
+			}
+		}
+		if lex.acceptSymbol(")") {
+			break
+		}
+		if !lex.acceptSymbol(",") {
+			return nil, errors.Errorf("Unexpected %s in table %s definition", lex.peek().text, name)
+		}
+	}
+
+	t := &Table{
+		Name:    name,
+		Columns: columns,
+		Charset: defaultCharset,
+	}
+	parseTableOptions(lex, t)
+	if "REDUNDANT" == t.RowFormat {
+		return nil, errors.New("ROW_FORMAT=REDUNDANT is not supported")
+	}
+	for _, c := range t.Columns {
+		if "" == c.Charset && "" == c.Collation {
+			// Columns without charset inherit the table charset and collation
+			c.Collation = t.Collation
+		}
+		c.complete(t.Charset)
+	}
+	if err := t.buildIndexes(keys); nil != err {
+		return nil, errors.Trace(err)
+	}
+	return t, nil
+}
+
+// parseKeyDefinition parses the index and constraint definitions, returns nil
+// if it is a column definition
+func parseKeyDefinition(lex *sqlLexer) (*ddlKey, error) {
+	tok := lex.peek()
+	if tok.typ != sqlTokenIdent || tok.quoted {
+		// Quoted identifier is the column name, e.g. `key`
+		return nil, nil
+	}
+	key := &ddlKey{}
+	switch strings.ToLower(tok.text) {
+	case "constraint":
+		{
+			lex.next()
+			// Optional constraint symbol, it is the key name if the key has no name
+			symbol := ""
+			next := lex.peek()
+			if next.typ == sqlTokenIdent && (next.quoted || !isKeyKeyword(next.text)) {
+				lex.next()
+				symbol = next.text
+			}
+			key, err := parseKeyDefinition(lex)
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			if nil == key {
+				return nil, errors.Errorf("Invalid constraint %s", symbol)
+			}
+			if "" == key.name {
+				key.name = symbol
+			}
+			return key, nil
+		}
+	case "primary":
+		{
+			lex.next()
+			if !lex.acceptKeyword("key") {
+				return nil, errors.New("Expect KEY after PRIMARY")
+			}
+			key.primary = true
+			key.unique = true
+			key.name = "PRIMARY"
+		}
+	case "unique":
+		{
+			lex.next()
+			key.unique = true
+			if !lex.acceptKeyword("key") {
+				lex.acceptKeyword("index")
+			}
+		}
+	case "key", "index":
+		{
+			lex.next()
+		}
+	case "fulltext", "spatial":
+		{
+			lex.next()
+			key.fulltext = strings.EqualFold(tok.text, "fulltext")
+			key.spatial = !key.fulltext
+			if !lex.acceptKeyword("key") {
+				lex.acceptKeyword("index")
+			}
+		}
+	case "foreign":
+		{
+			lex.next()
+			if !lex.acceptKeyword("key") {
+				return nil, errors.New("Expect KEY after FOREIGN")
+			}
+			key.foreign = true
+		}
+	case "check":
+		{
+			lex.next()
+			if lex.acceptSymbol("(") {
+				lex.skipParentheses()
+			}
+			skipDefinition(lex)
+			return &ddlKey{check: true}, nil
+		}
+	default:
+		{
+			return nil, nil
+		}
+	}
+
+	// Index name and index type
+	if next := lex.peek(); next.typ == sqlTokenIdent && !strings.EqualFold(next.text, "using") {
+		lex.next()
+		if !key.primary {
+			key.name = next.text
+		}
+	}
+	if lex.acceptKeyword("using") {
+		lex.next()
+	}
+	if !lex.acceptSymbol("(") {
+		return nil, errors.Errorf("Expect key parts of key %s", key.name)
+	}
+	for {
+		if lex.acceptSymbol("(") {
+			// Functional key part
+			lex.skipParentheses()
+			key.functional = true
+		} else {
+			tok := lex.next()
+			if tok.typ != sqlTokenIdent {
+				return nil, errors.Errorf("Invalid key part %s of key %s", tok.text, key.name)
+			}
+			prefix := 0
+			if lex.acceptSymbol("(") {
+				n, err := strconv.Atoi(lex.next().text)
+				if nil != err {
+					return nil, errors.Errorf("Invalid prefix length of key %s", key.name)
+				}
+				prefix = n
+				if !lex.acceptSymbol(")") {
+					return nil, errors.Errorf("Invalid prefix length of key %s", key.name)
+				}
+			}
+			key.columns = append(key.columns, tok.text)
+			key.prefixes = append(key.prefixes, prefix)
+		}
+		if !lex.acceptKeyword("asc") {
+			lex.acceptKeyword("desc")
+		}
+		if lex.acceptSymbol(")") {
+			break
+		}
+		if !lex.acceptSymbol(",") {
+			return nil, errors.Errorf("Invalid key parts of key %s", key.name)
+		}
+	}
+	// Index options and the foreign key reference definition
+	skipDefinition(lex)
+	return key, nil
+}
+
+func isKeyKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "primary", "unique", "foreign", "check", "key", "index":
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// skipDefinition skips the tokens until the next definition or the end of
+// the definitions
+func skipDefinition(lex *sqlLexer) {
+	for {
+		tok := lex.peek()
+		if tok.typ == sqlTokenEOF ||
+			(tok.typ == sqlTokenSymbol && (tok.text == "," || tok.text == ")")) {
+			return
+		}
+		lex.next()
+		if tok.typ == sqlTokenSymbol && tok.text == "(" {
+			lex.skipParentheses()
+		}
+	}
+}
+
+// parseTableOptions parses the charset and row format, other options are skipped
+func parseTableOptions(lex *sqlLexer, t *Table) {
+	for {
+		tok := lex.next()
+		if tok.typ == sqlTokenEOF || (tok.typ == sqlTokenSymbol && tok.text == ";") {
+			return
+		}
+		if tok.typ != sqlTokenIdent {
+			continue
+		}
+		switch strings.ToLower(tok.text) {
+		case "charset", "character":
+			{
+				if strings.EqualFold(tok.text, "character") {
+					lex.acceptKeyword("set")
+				}
+				lex.acceptSymbol("=")
+				t.Charset = strings.ToLower(lex.next().text)
+			}
+		case "collate":
+			{
+				lex.acceptSymbol("=")
+				t.Collation = strings.ToLower(lex.next().text)
+				t.Charset = strings.SplitN(t.Collation, "_", 2)[0]
+			}
+		case "row_format":
+			{
+				lex.acceptSymbol("=")
+				t.RowFormat = strings.ToUpper(lex.next().text)
+			}
+		case "partition":
+			{
+				// Partition definitions are not related to the table space format
+				return
+			}
+		}
+	}
+}
+
+// buildIndexes sorts the keys like mysql does and creates the innodb indexes, the
+// primary key or the first unique NOT NULL key without prefix is the clustered index,
+// otherwise the clustered index is generated with DB_ROW_ID
+func (t *Table) buildIndexes(keys []*ddlKey) error {
+	indexKeys := make([]*ddlKey, 0, len(keys))
+	for _, k := range keys {
+		if k.check {
+			continue
+		}
+		if k.foreign {
+			// Foreign key creates an index if no index starts with the columns
+			if !hasKeyPrefix(keys, k.columns) {
+				if "" == k.name {
+					k.name = t.Name + "_ibfk"
+				}
+				indexKeys = append(indexKeys, k)
+			}
+			continue
+		}
+		indexKeys = append(indexKeys, k)
+	}
+	// Primary key columns are NOT NULL even if not declared, it must be set
+	// before sorting, otherwise a unique NOT NULL key sorts before the primary key
+	for _, k := range indexKeys {
+		if !k.primary {
+			continue
+		}
+		for _, n := range k.columns {
+			if c := t.FindColumn(n); nil != c {
+				c.Nullable = false
+			}
+		}
+	}
+
+	// Key flags used to sort
+	nullable := func(k *ddlKey) bool {
+		for _, n := range k.columns {
+			if c := t.FindColumn(n); nil != c && c.Nullable {
+				return true
+			}
+		}
+		return false
+	}
+	partial := func(k *ddlKey) bool {
+		for _, p := range k.prefixes {
+			if p != 0 {
+				return true
+			}
+		}
+		return false
+	}
+	sort.SliceStable(indexKeys, func(i, j int) bool {
+		a, b := indexKeys[i], indexKeys[j]
+		if a.unique != b.unique {
+			return a.unique
+		}
+		if a.unique {
+			if nullable(a) != nullable(b) {
+				return !nullable(a)
+			}
+			if a.primary != b.primary {
+				return a.primary
+			}
+			if partial(a) != partial(b) {
+				return !partial(a)
+			}
+		}
+		if a.fulltext != b.fulltext {
+			return b.fulltext
+		}
+		return a.order < b.order
+	})
+
+	var pk []string
+	if len(indexKeys) > 0 &&
+		(indexKeys[0].primary ||
+			(indexKeys[0].unique && !nullable(indexKeys[0]) && !partial(indexKeys[0]) &&
+				!indexKeys[0].functional)) {
+		pk = indexKeys[0].columns
+	}
+	nt, err := newTable(t.Name, t.Columns, pk)
+	if nil != err {
+		return errors.Trace(err)
+	}
+	t.Indexes = nt.Indexes
+	t.Indexes[0].Table = t
+	if nil != pk {
+		t.Indexes[0].Name = indexKeys[0].name
+		indexKeys = indexKeys[1:]
+	}
+
+	for _, k := range indexKeys {
+		if "" == k.name && len(k.columns) > 0 {
+			// Key without name is named with the first column
+			k.name = k.columns[0]
+		}
+		if k.functional {
+			// The hidden virtual column of the functional key part is unknown,
+			// the index can't be decoded
+			continue
+		}
+		index := &Index{
+			Name:     k.name,
+			Unique:   k.unique,
+			Fulltext: k.fulltext,
+			Spatial:  k.spatial,
+			Table:    t,
+		}
+		for i, n := range k.columns {
+			c := t.FindColumn(n)
+			if nil == c {
+				return errors.Errorf("Column %s of key %s not found", n, k.name)
+			}
+			if k.prefixes[i] != 0 {
+				pc := *c
+				pc.Prefix = k.prefixes[i]
+				c = &pc
+			}
+			index.Columns = append(index.Columns, c)
+		}
+		t.Indexes = append(t.Indexes, index)
+	}
+
+	// Full text index needs the hidden FTS_DOC_ID column and the unique index on it
+	// if the table doesn't define them
+	for _, index := range t.Indexes {
+		if !index.Fulltext {
+			continue
+		}
+		docID := t.FindColumn("FTS_DOC_ID")
+		if nil == docID {
+			docID = &Column{
+				Name:     "FTS_DOC_ID",
+				Type:     ColumnTypeBigInt,
+				Unsigned: true,
+			}
+			t.Columns = append(t.Columns, docID)
+		}
+		if nil == t.FindIndexByName("FTS_DOC_ID_INDEX") {
+			t.Indexes = append(t.Indexes, &Index{
+				Name:    "FTS_DOC_ID_INDEX",
+				Unique:  true,
+				Columns: []*Column{docID},
+				Table:   t,
+			})
+		}
+		break
+	}
+	return nil
+}
+
+func hasKeyPrefix(keys []*ddlKey, columns []string) bool {
+	for _, k := range keys {
+		if k.foreign || k.check || len(k.columns) < len(columns) {
+			continue
+		}
+		match := true
+		for i, n := range columns {
+			if !strings.EqualFold(k.columns[i], n) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
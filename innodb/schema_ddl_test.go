@@ -0,0 +1,54 @@
+package innodb
+
+import "testing"
+
+func TestParseCreateTablePrimaryKeyNotNull(t *testing.T) {
+	table, err := parseCreateTable(`CREATE TABLE t (
+		id int,
+		email varchar(64) NOT NULL,
+		PRIMARY KEY (id),
+		UNIQUE KEY uk (email)
+	) ENGINE=InnoDB`)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	ci := table.ClusteredIndex()
+	if "PRIMARY" != ci.Name || 1 != len(ci.Columns) || "id" != ci.Columns[0].Name {
+		t.Fatalf("clustered index is %s, expect PRIMARY (id)", ci.Name)
+	}
+	if ci.Columns[0].Nullable {
+		t.Fatal("primary key column id is nullable")
+	}
+	if 2 != len(table.Indexes) || "uk" != table.Indexes[1].Name {
+		t.Fatalf("secondary index is not uk")
+	}
+}
+
+func TestParseCreateTableGeneratedColumn(t *testing.T) {
+	table, err := parseCreateTable(`CREATE TABLE t (
+		id int PRIMARY KEY,
+		a int,
+		b int GENERATED ALWAYS AS (a + 1),
+		c int AS (a * 2),
+		d int GENERATED ALWAYS AS (a - 1) VIRTUAL,
+		e int GENERATED ALWAYS AS (a + 2) STORED NOT NULL,
+		f int AS (a) PERSISTENT
+	) ENGINE=InnoDB`)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	virtual := map[string]bool{"id": false, "a": false, "b": true, "c": true, "d": true, "e": false, "f": false}
+	if len(virtual) != len(table.Columns) {
+		t.Fatalf("%d columns parsed, expect %d", len(table.Columns), len(virtual))
+	}
+	for _, c := range table.Columns {
+		if virtual[c.Name] != c.Virtual {
+			t.Fatalf("column %s virtual is %v", c.Name, c.Virtual)
+		}
+	}
+	if table.Columns[5].Nullable {
+		t.Fatal("column e is nullable")
+	}
+}
@@ -0,0 +1,432 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// The SDI flag of FSPHeader.Flags, set if the table space has SDI
+	fspFlagsMaskSDI = 1 << 14
+	// The SDI version and root page number are stored after the xdes entries
+	// and the encryption information in page 0
+	fspHeaderOffset       = 38
+	fspHeaderSize         = 112
+	encryptionInfoMaxSize = 115
+	sdiVersion            = 1
+	// The SDI root page of file per table space created by mysql 8.0
+	sdiDefaultRootPageNo = 3
+)
+
+// SDI object types
+const (
+	sdiTypeTable      = 1
+	sdiTypeTablespace = 2
+)
+
+var sdiTypeStrs = map[uint32]string{
+	sdiTypeTable:      "Table",
+	sdiTypeTablespace: "Tablespace",
+}
+
+func SDITypeToString(typ uint32) string {
+	if s, ok := sdiTypeStrs[typ]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", typ)
+}
+
+var errNoSDI = errors.New("No SDI found in the table space")
+
+// SDIRecord is the decoded record of the SDI index, data is the inflated json
+type SDIRecord struct {
+	Type uint32
+	ID   uint64
+	Data []byte
+}
+
+// newSDITable creates the table of the SDI index, the records are clustered by
+// type and id, the zlib compressed json is stored in the data column
+func newSDITable() (*Table, error) {
+	columns, err := parseColumnDefinitions("type int unsigned not null, " +
+		"id bigint unsigned not null, " +
+		"uncompressed_len int unsigned not null, " +
+		"compressed_len int unsigned not null, " +
+		"data longblob not null")
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	table, err := newTable("SDI", columns, []string{"type", "id"})
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	return table, nil
+}
+
+// readSDIRootPageNo reads the SDI root page number from page 0, page 3 is used
+// if the SDI header is invalid
+func readSDIRootPageNo(f io.ReaderAt, size int) (int, error) {
+	data := make([]byte, size)
+	if err := readPageData(f, 0, data); nil != err {
+		return 0, errors.Trace(err)
+	}
+	flags := binary.BigEndian.Uint32(data[fspHeaderFlagsOffset:])
+	if IsFullCrc32(flags) || 0 == flags&fspFlagsMaskSDI {
+		return 0, errNoSDI
+	}
+
+	// The extent descriptors of the compressed table space fill the zip size
+	ps := PageSize(size)
+	entries := ps.XdesEntries()
+	if z, ok := f.(*zipReader); ok {
+		entries = ps.ZipXdesEntries(z.zipSize)
+	}
+	offset := fspHeaderOffset + fspHeaderSize + ps.XdesEntrySize()*entries + encryptionInfoMaxSize
+	candidates := []int{sdiDefaultRootPageNo}
+	if binary.BigEndian.Uint32(data[offset:]) == sdiVersion {
+		candidates = append([]int{int(binary.BigEndian.Uint32(data[offset+4:]))}, candidates...)
+	}
+	for _, pageNo := range candidates {
+		if err := readPageImage(f, pageNo, data); nil != err {
+			continue
+		}
+		if binary.BigEndian.Uint16(data[24:]) == PageTypeSDI {
+			return pageNo, nil
+		}
+	}
+	return 0, errNoSDI
+}
+
+// readSDIRecords walks the leaf level of the SDI index, and inflates the json
+// of every record
+func readSDIRecords(f io.ReaderAt, pageSize int) ([]*SDIRecord, error) {
+	size, err := resolvePageSize(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	rootNo, err := readSDIRootPageNo(f, size)
+	if nil != err {
+		return nil, err
+	}
+	table, err := newSDITable()
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	options := &ParsePageOptions{
+		ParseRecords: true,
+		PageSize:     size,
+		Table:        table,
+	}
+	page, err := readLeftmostLeafPage(f, rootNo, options)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+
+	records := make([]*SDIRecord, 0, 2)
+	for {
+		for _, rc := range page.UserRecorders() {
+			if rc.Header.DeleteFlag {
+				continue
+			}
+			record, err := decodeSDIRecord(f, rc, size)
+			if nil != err {
+				return nil, errors.Annotatef(err, "SDI record at page %d offset 0x%04X",
+					page.no, rc.FieldDataOffset)
+			}
+			records = append(records, record)
+		}
+		if 0xffffffff == page.FileHeader.Next {
+			break
+		}
+		if page, err = readPageFromFile(f, int(page.FileHeader.Next), options); nil != err {
+			return nil, errors.Trace(err)
+		}
+	}
+	return records, nil
+}
+
+// decodeSDIRecord reads the fields type, id, DB_TRX_ID, DB_ROLL_PTR,
+// uncompressed_len, compressed_len and data
+func decodeSDIRecord(f io.ReaderAt, rc *CompactRecorder, pageSize int) (*SDIRecord, error) {
+	if len(rc.Fields) != 7 {
+		return nil, errors.New("Invalid SDI record")
+	}
+	record := &SDIRecord{
+		Type: uint32(bigEndianUint(rc.Fields[0].Data)),
+		ID:   bigEndianUint(rc.Fields[1].Data),
+	}
+	uncompressedLen := int(bigEndianUint(rc.Fields[4].Data))
+	compressedLen := int(bigEndianUint(rc.Fields[5].Data))
+	compressed, err := readExternField(f, rc.Fields[6], pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	if len(compressed) != compressedLen {
+		return nil, errors.Errorf("Compressed length %d mismatch, expect %d", len(compressed), compressedLen)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+	if record.Data, err = ioutil.ReadAll(r); nil != err {
+		return nil, errors.Trace(err)
+	}
+	if len(record.Data) != uncompressedLen {
+		return nil, errors.Errorf("Uncompressed length %d mismatch, expect %d", len(record.Data), uncompressedLen)
+	}
+	return record, nil
+}
+
+// The data dictionary objects in the SDI json, only the fields used to decode
+// the records are declared
+type sdiObject struct {
+	DDObjectType string          `json:"dd_object_type"`
+	DDObject     json.RawMessage `json:"dd_object"`
+}
+
+type sdiColumn struct {
+	Name           string `json:"name"`
+	IsNullable     bool   `json:"is_nullable"`
+	IsVirtual      bool   `json:"is_virtual"`
+	Hidden         int    `json:"hidden"`
+	CharLength     int    `json:"char_length"`
+	ColumnTypeUtf8 string `json:"column_type_utf8"`
+	CollationID    int    `json:"collation_id"`
+}
+
+type sdiIndexElement struct {
+	Length    int  `json:"length"`
+	Hidden    bool `json:"hidden"`
+	ColumnOpx int  `json:"column_opx"`
+}
+
+type sdiIndex struct {
+	Name          string            `json:"name"`
+	Hidden        bool              `json:"hidden"`
+	Type          int               `json:"type"`
+	SEPrivateData string            `json:"se_private_data"`
+	Elements      []sdiIndexElement `json:"elements"`
+}
+
+type sdiPartitionIndex struct {
+	SEPrivateData string `json:"se_private_data"`
+	IndexOpx      int    `json:"index_opx"`
+}
+
+type sdiPartition struct {
+	Name          string              `json:"name"`
+	Indexes       []sdiPartitionIndex `json:"indexes"`
+	Subpartitions []sdiPartition      `json:"subpartitions"`
+}
+
+type sdiTable struct {
+	Name        string         `json:"name"`
+	RowFormat   int            `json:"row_format"`
+	CollationID int            `json:"collation_id"`
+	Columns     []sdiColumn    `json:"columns"`
+	Indexes     []sdiIndex     `json:"indexes"`
+	Partitions  []sdiPartition `json:"partitions"`
+}
+
+// Data dictionary column hidden types and index types
+const (
+	sdiColumnHiddenSE = 2
+
+	sdiIndexTypePrimary  = 1
+	sdiIndexTypeUnique   = 2
+	sdiIndexTypeFulltext = 4
+	sdiIndexTypeSpatial  = 5
+)
+
+// Data dictionary row formats
+var sdiRowFormats = map[int]string{
+	1: "FIXED",
+	2: "DYNAMIC",
+	3: "COMPRESSED",
+	4: "REDUNDANT",
+	5: "COMPACT",
+	6: "PAGED",
+}
+
+// parseSEPrivateData parses the "key=value;" pairs
+func parseSEPrivateData(s string) map[string]string {
+	values := make(map[string]string)
+	for _, kv := range strings.Split(s, ";") {
+		if pos := strings.IndexByte(kv, '='); pos > 0 {
+			values[kv[:pos]] = kv[pos+1:]
+		}
+	}
+	return values
+}
+
+func parseSEPrivateDataUint(s string, key string) uint64 {
+	v, _ := strconv.ParseUint(parseSEPrivateData(s)[key], 10, 64)
+	return v
+}
+
+// readSDITable reads the table schema from the SDI, the index ids are read from
+// the data dictionary too
+func readSDITable(f io.ReaderAt, pageSize int) (*Table, error) {
+	records, err := readSDIRecords(f, pageSize)
+	if nil != err {
+		return nil, err
+	}
+	spaceData := make([]byte, 4)
+	if _, err = f.ReadAt(spaceData, fspHeaderOffset); nil != err {
+		return nil, errors.Trace(err)
+	}
+	spaceID := binary.BigEndian.Uint32(spaceData)
+
+	for _, record := range records {
+		if record.Type != sdiTypeTable {
+			continue
+		}
+		var obj sdiObject
+		if err = json.Unmarshal(record.Data, &obj); nil != err {
+			return nil, errors.Annotate(err, "Parse SDI json")
+		}
+		var st sdiTable
+		if err = json.Unmarshal(obj.DDObject, &st); nil != err {
+			return nil, errors.Annotate(err, "Parse SDI table")
+		}
+		return st.toTable(spaceID)
+	}
+	return nil, errNoSDI
+}
+
+// toTable converts the data dictionary table to the table schema, the hidden
+// system columns and the generated clustered index are created by newTable
+func (st *sdiTable) toTable(spaceID uint32) (*Table, error) {
+	t := &Table{
+		Name:      st.Name,
+		Charset:   collationCharset(st.CollationID),
+		RowFormat: sdiRowFormats[st.RowFormat],
+	}
+	if "REDUNDANT" == t.RowFormat {
+		return nil, errors.New("ROW_FORMAT=REDUNDANT is not supported")
+	}
+	if "" == t.Charset {
+		t.Charset = defaultCharset
+	}
+
+	// Columns of the index elements by column_opx
+	columns := make([]*Column, len(st.Columns))
+	for i, sc := range st.Columns {
+		if sc.Hidden == sdiColumnHiddenSE {
+			// DB_ROW_ID, DB_TRX_ID, DB_ROLL_PTR and FTS_DOC_ID are added by buildIndexes
+			continue
+		}
+		spec := "`" + strings.Replace(sc.Name, "`", "``", -1) + "` " + sc.ColumnTypeUtf8
+		if !sc.IsNullable {
+			spec += " NOT NULL"
+		}
+		charset := collationCharset(sc.CollationID)
+		if "" == charset {
+			charset = t.Charset
+		}
+		c, err := parseColumnDefinition(newSQLLexer(spec), charset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		c.Virtual = sc.IsVirtual
+		if "" != c.Charset && "binary" != c.Charset {
+			if name := collationName(sc.CollationID); "" != name {
+				c.Collation = name
+			}
+		}
+		columns[i] = c
+		t.Columns = append(t.Columns, c)
+	}
+
+	keys := make([]*ddlKey, 0, len(st.Indexes))
+	for _, si := range st.Indexes {
+		key := &ddlKey{
+			name:     si.Name,
+			primary:  si.Type == sdiIndexTypePrimary,
+			unique:   si.Type == sdiIndexTypePrimary || si.Type == sdiIndexTypeUnique,
+			fulltext: si.Type == sdiIndexTypeFulltext,
+			spatial:  si.Type == sdiIndexTypeSpatial,
+			order:    len(keys),
+		}
+		for _, e := range si.Elements {
+			if e.Hidden {
+				continue
+			}
+			if e.ColumnOpx < 0 || e.ColumnOpx >= len(columns) {
+				return nil, errors.Errorf("Invalid column of index %s", si.Name)
+			}
+			c := columns[e.ColumnOpx]
+			if nil == c {
+				// Index on the hidden system column, the generated clustered index
+				// or FTS_DOC_ID_INDEX
+				key = nil
+				break
+			}
+			prefix := 0
+			if (c.IsString() || c.IsBlob()) && e.Length < st.Columns[e.ColumnOpx].CharLength {
+				// Prefix length in bytes
+				prefix = e.Length / getCharsetInfo(c.Charset).mbmaxlen
+			}
+			key.columns = append(key.columns, c.Name)
+			key.prefixes = append(key.prefixes, prefix)
+		}
+		if nil != key {
+			keys = append(keys, key)
+		}
+	}
+	if err := t.buildIndexes(keys); nil != err {
+		return nil, errors.Trace(err)
+	}
+
+	// Index ids of the partition table space are stored in the partition
+	seData := make([]string, len(st.Indexes))
+	for i, si := range st.Indexes {
+		seData[i] = si.SEPrivateData
+	}
+	if p := findSDIPartition(st.Partitions, spaceID); nil != p {
+		for _, pi := range p.Indexes {
+			if pi.IndexOpx >= 0 && pi.IndexOpx < len(seData) {
+				seData[pi.IndexOpx] = pi.SEPrivateData
+			}
+		}
+	}
+	for i, si := range st.Indexes {
+		index := t.FindIndexByName(si.Name)
+		if nil == index && si.Hidden && si.Type == sdiIndexTypePrimary {
+			index = t.ClusteredIndex()
+		}
+		if nil != index {
+			index.ID = parseSEPrivateDataUint(seData[i], "id")
+		}
+	}
+	return t, nil
+}
+
+// findSDIPartition finds the partition or subpartition stored in the table space
+func findSDIPartition(partitions []sdiPartition, spaceID uint32) *sdiPartition {
+	for i := range partitions {
+		p := &partitions[i]
+		if len(p.Subpartitions) > 0 {
+			if sp := findSDIPartition(p.Subpartitions, spaceID); nil != sp {
+				return sp
+			}
+			continue
+		}
+		for _, pi := range p.Indexes {
+			if uint64(spaceID) == parseSEPrivateDataUint(pi.SEPrivateData, "space_id") {
+				return p
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package innodb
+
+import "testing"
+
+func TestNewSDITable(t *testing.T) {
+	table, err := newSDITable()
+	if nil != err {
+		t.Fatal(err)
+	}
+	ci := table.ClusteredIndex()
+	if 2 != len(ci.Columns) || "type" != ci.Columns[0].Name || "id" != ci.Columns[1].Name {
+		t.Fatalf("SDI clustered index is %s", ci.Name)
+	}
+}
@@ -0,0 +1,113 @@
+package innodb
+
+import (
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// SearchStatistic counts the pages read and the comparisons of the search
+type SearchStatistic struct {
+	PageSearched      int
+	IndexPageSearched int
+	SearchTimes       int
+}
+
+// searchIndexRecord descends from the root page to the leaf page, and returns
+// the last record less than the key in the leaf page, it may be the infimum. In
+// the non-leaf pages, the child of the last node pointer less than the key is
+// read, so the first record equal to the key is not missed in the non-unique
+// index. trace is called with every page searched if not nil
+func searchIndexRecord(f io.ReaderAt, rootNo int, index *Index, key []*KeyValue,
+	options *ParsePageOptions, st *SearchStatistic, trace func(page *Page)) (*Page, *CompactRecorder, error) {
+	pageNo := rootNo
+	for {
+		page, err := readPageFromFile(f, pageNo, options)
+		if nil != err {
+			return nil, nil, errors.Trace(err)
+		}
+		if page.FileHeader.Type != PageTypeIndex || page.IndexHeader.IndexID != index.ID {
+			return nil, nil, errors.Errorf("Page %d is not the index page of %s", pageNo, index.Name)
+		}
+		if nil != trace {
+			trace(page)
+		}
+		st.PageSearched++
+		rc, err := searchPageRecord(page, key, st)
+		if nil != err {
+			return nil, nil, errors.Trace(err)
+		}
+		if 0 == page.IndexHeader.Level {
+			return page, rc, nil
+		}
+		st.IndexPageSearched++
+		if rc.Header.RecordType == RecorderTypeInfimum {
+			rc = rc.Next
+		}
+		if nil == rc || rc.Header.RecordType == RecorderTypeSupremum || 0xffffffff == rc.PagePtr {
+			return nil, nil, errors.Errorf("No node pointer found in page %d", pageNo)
+		}
+		pageNo = int(rc.PagePtr)
+	}
+}
+
+// searchPageRecord returns the last record less than the key in the page. The
+// slot owning the first record not less than the key is found by binary search
+// on the directory slots, then the records of the slot are compared one by one
+func searchPageRecord(page *Page, key []*KeyValue, st *SearchStatistic) (*CompactRecorder, error) {
+	if len(page.DSlots) < 2 {
+		return nil, errors.Errorf("Invalid directory slots of page %d", page.no)
+	}
+	// The last slot is supremum which is greater than any key
+	low, high := 1, len(page.DSlots)-1
+	for low < high {
+		st.SearchTimes++
+		mid := (low + high) / 2
+		cmp, err := CompareRecordKey(page.DSlots[mid].EndRecorder.Fields, key)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if cmp >= 0 {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	rc := page.DSlots[low-1].EndRecorder
+	for next := rc.Next; nil != next && next.Header.RecordType != RecorderTypeSupremum; next = next.Next {
+		st.SearchTimes++
+		cmp, err := CompareRecordKey(next.Fields, key)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if cmp >= 0 {
+			break
+		}
+		rc = next
+	}
+	return rc, nil
+}
+
+// nextIndexRecord returns the next user record in the leaf page list, the
+// record is nil at the end of the index
+func nextIndexRecord(f io.ReaderAt, page *Page, rc *CompactRecorder,
+	options *ParsePageOptions, st *SearchStatistic) (*Page, *CompactRecorder, error) {
+	for {
+		if rc = rc.Next; nil != rc && rc.Header.RecordType != RecorderTypeSupremum {
+			return page, rc, nil
+		}
+		if 0xffffffff == page.FileHeader.Next {
+			return page, nil, nil
+		}
+		next, err := readPageFromFile(f, int(page.FileHeader.Next), options)
+		if nil != err {
+			return nil, nil, errors.Trace(err)
+		}
+		if 0 == len(next.DSlots) {
+			return nil, nil, errors.Errorf("No directory slot found in page %d", next.no)
+		}
+		st.PageSearched++
+		page = next
+		rc = page.DSlots[0].EndRecorder
+	}
+}
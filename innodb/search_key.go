@@ -0,0 +1,521 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Kinds of the comparable key values
+const (
+	keyKindInt = iota
+	keyKindUint
+	keyKindFloat
+	keyKindDecimal
+	keyKindString
+	keyKindBytes
+)
+
+// KeyValue is the comparable value of an index field, it is decoded from the
+// record or parsed from the search key. Temporal values are converted to the
+// integers in the same order
+type KeyValue struct {
+	null bool
+	i    int64
+	u    uint64
+	f    float64
+	r    *big.Rat
+	s    []rune
+	// UCA sort key of the string
+	w []uint16
+	b []byte
+}
+
+func keyKind(c *Column) int {
+	switch c.Type {
+	case ColumnTypeTinyInt, ColumnTypeSmallInt, ColumnTypeMediumInt, ColumnTypeInt, ColumnTypeBigInt:
+		{
+			if c.Unsigned {
+				return keyKindUint
+			}
+			return keyKindInt
+		}
+	case ColumnTypeDate, ColumnTypeDatetime, ColumnTypeTimestamp, ColumnTypeTime, ColumnTypeYear:
+		{
+			return keyKindInt
+		}
+	case ColumnTypeRowID, ColumnTypeTrxID, ColumnTypeRollPtr, ColumnTypeChildPage,
+		ColumnTypeEnum, ColumnTypeSet, ColumnTypeBit:
+		{
+			return keyKindUint
+		}
+	case ColumnTypeFloat, ColumnTypeDouble:
+		{
+			return keyKindFloat
+		}
+	case ColumnTypeDecimal:
+		{
+			return keyKindDecimal
+		}
+	}
+	if c.IsString() && !c.IsBinary() {
+		return keyKindString
+	}
+	return keyKindBytes
+}
+
+// DecodeKeyValue decodes the comparable value of the record field
+func DecodeKeyValue(f *RecordField) (*KeyValue, error) {
+	c := f.Column
+	if f.Null {
+		return &KeyValue{null: true}, nil
+	}
+	if f.Extern {
+		return nil, errors.Errorf("Field %s is stored externally", c.Name)
+	}
+	v := &KeyValue{}
+	switch keyKind(c) {
+	case keyKindInt:
+		{
+			v.i = decodeIntKeyValue(c, f.Data)
+		}
+	case keyKindUint:
+		{
+			v.u = bigEndianUint(f.Data)
+		}
+	case keyKindFloat:
+		{
+			if c.Type == ColumnTypeFloat {
+				v.f = float64(math.Float32frombits(binary.LittleEndian.Uint32(f.Data)))
+			} else {
+				v.f = math.Float64frombits(binary.LittleEndian.Uint64(f.Data))
+			}
+		}
+	case keyKindDecimal:
+		{
+			r, ok := new(big.Rat).SetString(formatDecimal(f.Data, c.Precision, c.Scale))
+			if !ok {
+				return nil, errors.Errorf("Invalid decimal field %s", c.Name)
+			}
+			v.r = r
+		}
+	case keyKindString:
+		{
+			v.s = decodeCharsetRunes(f.Data, c.Charset)
+			if c.Type == ColumnTypeChar {
+				// CHAR is padded with spaces
+				v.s = trimTrailingSpaces(v.s)
+			}
+			if err := v.collate(c); nil != err {
+				return nil, errors.Annotatef(err, "Field %s", c.Name)
+			}
+		}
+	default:
+		{
+			v.b = f.Data
+		}
+	}
+	return v, nil
+}
+
+// collate checks the collation of the string value, the UCA sort key is made
+// for the UCA collations
+func (v *KeyValue) collate(c *Column) error {
+	rule, err := getCollationRule(c.Collation)
+	if nil != err {
+		return errors.Trace(err)
+	}
+	if rule.ucaLevels > 0 {
+		v.w, err = rule.ucaSortKey(v.s)
+	}
+	return errors.Trace(err)
+}
+
+func decodeIntKeyValue(c *Column, data []byte) int64 {
+	switch c.Type {
+	case ColumnTypeDate:
+		{
+			return int64(bigEndianUint(data) ^ 0x800000)
+		}
+	case ColumnTypeDatetime:
+		{
+			v := int64(bigEndianUint(data[:5])) - 0x8000000000
+			return v*1e6 + fractionMicros(data[5:], c.Scale)
+		}
+	case ColumnTypeTimestamp:
+		{
+			return int64(binary.BigEndian.Uint32(data))*1e6 + fractionMicros(data[4:], c.Scale)
+		}
+	case ColumnTypeTime:
+		{
+			n := 3 + fspSize(c.Scale)
+			v := int64(bigEndianUint(data[:n])) - (int64(0x80) << uint(8*(n-1)))
+			sign := int64(1)
+			if v < 0 {
+				sign = -1
+				v = -v
+			}
+			var fb [3]byte
+			size := n - 3
+			frac := v & (int64(1)<<uint(8*size) - 1)
+			for i := 0; i < size; i++ {
+				fb[i] = byte(frac >> uint(8*(size-1-i)))
+			}
+			return sign * ((v>>uint(8*size))*1e6 + fractionMicros(fb[:size], c.Scale))
+		}
+	case ColumnTypeYear:
+		{
+			if 0 == data[0] {
+				return 0
+			}
+			return 1900 + int64(data[0])
+		}
+	}
+	f := RecordField{Column: c, Data: data}
+	v, _ := f.integer()
+	return v
+}
+
+// parseKeyValue parses the search key value of the column, \N is NULL. The
+// temporal values are in the format of YYYY-MM-DD HH:MM:SS.ffffff, and the
+// binary values can be specified in hex with the 0x prefix
+func parseKeyValue(c *Column, s string) (*KeyValue, error) {
+	if `\N` == s {
+		return &KeyValue{null: true}, nil
+	}
+	v := &KeyValue{}
+	var err error
+	switch keyKind(c) {
+	case keyKindInt:
+		{
+			v.i, err = parseIntKeyValue(c, s)
+		}
+	case keyKindUint:
+		{
+			v.u, err = parseUintKeyValue(c, s)
+		}
+	case keyKindFloat:
+		{
+			v.f, err = strconv.ParseFloat(s, 64)
+			if c.Type == ColumnTypeFloat {
+				// Compare with the single precision value stored
+				v.f = float64(float32(v.f))
+			}
+		}
+	case keyKindDecimal:
+		{
+			r, ok := new(big.Rat).SetString(s)
+			if !ok {
+				err = errors.New("Invalid decimal")
+			}
+			v.r = r
+		}
+	case keyKindString:
+		{
+			v.s = []rune(s)
+			if c.Prefix > 0 && len(v.s) > c.Prefix {
+				v.s = v.s[:c.Prefix]
+			}
+			if c.Type == ColumnTypeChar {
+				v.s = trimTrailingSpaces(v.s)
+			}
+			if err := v.collate(c); nil != err {
+				return nil, errors.Annotatef(err, "Column %s", c.Name)
+			}
+		}
+	default:
+		{
+			v.b = []byte(s)
+			if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+				v.b, err = hex.DecodeString(s[2:])
+			}
+			if c.Prefix > 0 && len(v.b) > c.Prefix {
+				v.b = v.b[:c.Prefix]
+			}
+			if c.Type == ColumnTypeBinary && len(v.b) < c.Length {
+				// BINARY is padded with 0x00
+				v.b = append(v.b, make([]byte, c.Length-len(v.b))...)
+			}
+		}
+	}
+	if nil != err {
+		return nil, errors.Errorf("Invalid %s value %s of column %s", c.TypeString(), s, c.Name)
+	}
+	return v, nil
+}
+
+func parseIntKeyValue(c *Column, s string) (int64, error) {
+	switch c.Type {
+	case ColumnTypeDate, ColumnTypeDatetime, ColumnTypeTimestamp:
+		{
+			p, err := parseDatetimeParts(s)
+			if nil != err {
+				return 0, errors.Trace(err)
+			}
+			if c.Type == ColumnTypeDate {
+				return (p[0]*13+p[1])<<5 | p[2], nil
+			}
+			if c.Type == ColumnTypeDatetime {
+				v := ((p[0]*13+p[1])<<5|p[2])<<17 | p[3]<<12 | p[4]<<6 | p[5]
+				return v*1e6 + p[6], nil
+			}
+			if 0 == p[0] && 0 == p[1] && 0 == p[2] {
+				// Zero timestamp
+				return 0, nil
+			}
+			tm := time.Date(int(p[0]), time.Month(p[1]), int(p[2]), int(p[3]), int(p[4]), int(p[5]), 0, time.UTC)
+			return tm.Unix()*1e6 + p[6], nil
+		}
+	case ColumnTypeTime:
+		{
+			sign := int64(1)
+			if strings.HasPrefix(s, "-") {
+				sign = -1
+				s = s[1:]
+			}
+			h, m, sec, us, err := parseClock(s)
+			if nil != err {
+				return 0, errors.Trace(err)
+			}
+			return sign * ((h<<12|m<<6|sec)*1e6 + us), nil
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseUintKeyValue(c *Column, s string) (uint64, error) {
+	switch c.Type {
+	case ColumnTypeEnum:
+		{
+			// The element or the element index starting from 1
+			if v, err := strconv.ParseUint(s, 10, 64); nil == err {
+				return v, nil
+			}
+			for i, e := range c.Elements {
+				if strings.EqualFold(e, s) {
+					return uint64(i + 1), nil
+				}
+			}
+			return 0, errors.Errorf("Element %s not found", s)
+		}
+	case ColumnTypeSet:
+		{
+			if v, err := strconv.ParseUint(s, 10, 64); nil == err {
+				return v, nil
+			}
+			var v uint64
+			for _, m := range strings.Split(s, ",") {
+				found := false
+				for i, e := range c.Elements {
+					if strings.EqualFold(e, m) {
+						v |= 1 << uint(i)
+						found = true
+						break
+					}
+				}
+				if !found && "" != m {
+					return 0, errors.Errorf("Element %s not found", m)
+				}
+			}
+			return v, nil
+		}
+	case ColumnTypeBit:
+		{
+			if strings.HasPrefix(s, "b'") && strings.HasSuffix(s, "'") {
+				return strconv.ParseUint(s[2:len(s)-1], 2, 64)
+			}
+			return strconv.ParseUint(s, 0, 64)
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// parseDatetimeParts parses YYYY-MM-DD[ HH:MM:SS[.ffffff]] to year, month, day,
+// hour, minute, second and microseconds
+func parseDatetimeParts(s string) ([7]int64, error) {
+	var p [7]int64
+	s = strings.TrimSpace(s)
+	date, clock := s, ""
+	if i := strings.IndexAny(s, " T"); i >= 0 {
+		date, clock = s[:i], strings.TrimSpace(s[i+1:])
+	}
+	ds := strings.Split(date, "-")
+	if len(ds) != 3 {
+		return p, errors.Errorf("Invalid date %s", date)
+	}
+	for i, d := range ds {
+		n, err := strconv.ParseInt(d, 10, 64)
+		if nil != err {
+			return p, errors.Errorf("Invalid date %s", date)
+		}
+		p[i] = n
+	}
+	if "" != clock {
+		var err error
+		if p[3], p[4], p[5], p[6], err = parseClock(clock); nil != err {
+			return p, errors.Trace(err)
+		}
+	}
+	return p, nil
+}
+
+// parseClock parses HH:MM:SS[.ffffff]
+func parseClock(s string) (int64, int64, int64, int64, error) {
+	us := int64(0)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		frac := (s[i+1:] + "000000")[:6]
+		v, err := strconv.ParseInt(frac, 10, 64)
+		if nil != err {
+			return 0, 0, 0, 0, errors.Errorf("Invalid fractional seconds %s", s[i+1:])
+		}
+		us = v
+		s = s[:i]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, 0, errors.Errorf("Invalid time %s", s)
+	}
+	var hms [3]int64
+	for i, part := range parts {
+		v, err := strconv.ParseInt(part, 10, 64)
+		if nil != err {
+			return 0, 0, 0, 0, errors.Errorf("Invalid time %s", s)
+		}
+		hms[i] = v
+	}
+	return hms[0], hms[1], hms[2], us, nil
+}
+
+// compareKeyValue compares the values of the column, NULL is less than any
+// other values, returns -1, 0 or 1
+func compareKeyValue(c *Column, a *KeyValue, b *KeyValue) int {
+	if a.null || b.null {
+		if a.null && b.null {
+			return 0
+		}
+		if a.null {
+			return -1
+		}
+		return 1
+	}
+	switch keyKind(c) {
+	case keyKindInt:
+		{
+			if a.i != b.i {
+				if a.i < b.i {
+					return -1
+				}
+				return 1
+			}
+		}
+	case keyKindUint:
+		{
+			if a.u != b.u {
+				if a.u < b.u {
+					return -1
+				}
+				return 1
+			}
+		}
+	case keyKindFloat:
+		{
+			if a.f != b.f {
+				if a.f < b.f {
+					return -1
+				}
+				return 1
+			}
+		}
+	case keyKindDecimal:
+		{
+			return a.r.Cmp(b.r)
+		}
+	case keyKindString:
+		{
+			// The collation is checked when the values are decoded
+			rule, _ := getCollationRule(c.Collation)
+			if rule.ucaLevels > 0 {
+				return compareWeights(a.w, b.w)
+			}
+			return compareCollated(a.s, b.s, rule)
+		}
+	default:
+		{
+			return bytes.Compare(a.b, b.b)
+		}
+	}
+	return 0
+}
+
+// CompareRecordKey compares the leading fields of the record with the key, the
+// key may have less values than the index fields
+func CompareRecordKey(fields []*RecordField, key []*KeyValue) (int, error) {
+	if len(fields) < len(key) {
+		return 0, errors.New("Record fields not decoded")
+	}
+	for i, k := range key {
+		v, err := DecodeKeyValue(fields[i])
+		if nil != err {
+			return 0, errors.Trace(err)
+		}
+		if cmp := compareKeyValue(fields[i].Column, v, k); 0 != cmp {
+			return cmp, nil
+		}
+	}
+	return 0, nil
+}
+
+// IndexKeyFields returns the key fields of the index, they are the fields of the
+// node pointer record except the child page number
+func IndexKeyFields(index *Index) []*Column {
+	fields := index.RecordFields(false)
+	return fields[:len(fields)-1]
+}
+
+// ParseSearchKey parses the comma separated key values of the index, the values
+// containing comma can be quoted with double quotes
+func ParseSearchKey(index *Index, s string) ([]*KeyValue, error) {
+	r := csv.NewReader(strings.NewReader(s))
+	r.TrimLeadingSpace = true
+	values, err := r.Read()
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	fields := IndexKeyFields(index)
+	if len(values) > len(fields) {
+		return nil, errors.Errorf("Too many key values, index %s has %d key field(s)",
+			index.Name, len(fields))
+	}
+	key := make([]*KeyValue, 0, len(values))
+	for i, value := range values {
+		v, err := parseKeyValue(fields[i], value)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		key = append(key, v)
+	}
+	return key, nil
+}
+
+// PrimaryKeyFields returns the primary key fields of the secondary index record
+func PrimaryKeyFields(index *Index, fields []*RecordField) []*RecordField {
+	pk := IndexKeyFields(index.Table.ClusteredIndex())
+	result := make([]*RecordField, 0, len(pk))
+	for _, c := range pk {
+		for _, f := range fields {
+			if f.Column.Name == c.Name && 0 == f.Column.Prefix {
+				result = append(result, f)
+				break
+			}
+		}
+	}
+	return result
+}
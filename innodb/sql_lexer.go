@@ -0,0 +1,209 @@
+package innodb
+
+import (
+	"strings"
+)
+
+// SQL token types
+const (
+	sqlTokenEOF = iota
+	sqlTokenIdent
+	sqlTokenNumber
+	sqlTokenString
+	sqlTokenSymbol
+)
+
+type sqlToken struct {
+	typ  int
+	text string
+	// Quoted identifier can't be a keyword
+	quoted bool
+}
+
+// sqlLexer splits the sql text into tokens, comments are skipped and quoted
+// identifiers are unquoted
+type sqlLexer struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func newSQLLexer(sql string) *sqlLexer {
+	lex := &sqlLexer{}
+	lex.tokenize(sql)
+	return lex
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c >= 0x80
+}
+
+func (l *sqlLexer) tokenize(sql string) {
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			{
+				i++
+			}
+		case c == '#' || (c == '-' && strings.HasPrefix(sql[i:], "-- ")):
+			{
+				// Line comment
+				for i < len(sql) && sql[i] != '\n' {
+					i++
+				}
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			{
+				// Block comment, the mysql executable comment /*!40101 ... */
+				// is also skipped
+				end := strings.Index(sql[i+2:], "*/")
+				if end < 0 {
+					i = len(sql)
+				} else {
+					i += end + 4
+				}
+			}
+		case c == '`' || c == '"':
+			{
+				// Quoted identifier
+				text, n := readQuoted(sql[i:], c)
+				l.tokens = append(l.tokens, sqlToken{sqlTokenIdent, text, true})
+				i += n
+			}
+		case c == '\'':
+			{
+				text, n := readQuoted(sql[i:], c)
+				l.tokens = append(l.tokens, sqlToken{sqlTokenString, text, false})
+				i += n
+			}
+		case c >= '0' && c <= '9':
+			{
+				j := i
+				for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '.') {
+					j++
+				}
+				l.tokens = append(l.tokens, sqlToken{sqlTokenNumber, sql[i:j], false})
+				i = j
+			}
+		case isIdentChar(c):
+			{
+				j := i
+				for j < len(sql) && isIdentChar(sql[j]) {
+					j++
+				}
+				l.tokens = append(l.tokens, sqlToken{sqlTokenIdent, sql[i:j], false})
+				i = j
+			}
+		default:
+			{
+				l.tokens = append(l.tokens, sqlToken{sqlTokenSymbol, sql[i : i+1], false})
+				i++
+			}
+		}
+	}
+}
+
+// readQuoted reads the quoted text, the doubled quote and backslash escapes
+// are unescaped, returns the text and the consumed length
+func readQuoted(s string, quote byte) (string, int) {
+	var b strings.Builder
+	i := 1
+	for i < len(s) {
+		c := s[i]
+		if c == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i += 2
+				continue
+			}
+			return b.String(), i + 1
+		}
+		if c == '\\' && quote == '\'' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				{
+					b.WriteByte('\n')
+				}
+			case 't':
+				{
+					b.WriteByte('\t')
+				}
+			case 'r':
+				{
+					b.WriteByte('\r')
+				}
+			case '0':
+				{
+					b.WriteByte(0)
+				}
+			default:
+				{
+					b.WriteByte(s[i])
+				}
+			}
+			i++
+			continue
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String(), len(s)
+}
+
+func (l *sqlLexer) peek() sqlToken {
+	if l.pos >= len(l.tokens) {
+		return sqlToken{sqlTokenEOF, "EOF", false}
+	}
+	return l.tokens[l.pos]
+}
+
+func (l *sqlLexer) next() sqlToken {
+	tok := l.peek()
+	if l.pos < len(l.tokens) {
+		l.pos++
+	}
+	return tok
+}
+
+// acceptSymbol consumes the next token if it is the symbol
+func (l *sqlLexer) acceptSymbol(sym string) bool {
+	tok := l.peek()
+	if tok.typ == sqlTokenSymbol && tok.text == sym {
+		l.pos++
+		return true
+	}
+	return false
+}
+
+// acceptKeyword consumes the next token if it is the keyword (case insensitive)
+func (l *sqlLexer) acceptKeyword(kw string) bool {
+	tok := l.peek()
+	if tok.typ == sqlTokenIdent && strings.EqualFold(tok.text, kw) {
+		l.pos++
+		return true
+	}
+	return false
+}
+
+// skipParentheses skips the tokens until the matched right parenthesis, the
+// left parenthesis is already consumed
+func (l *sqlLexer) skipParentheses() {
+	depth := 1
+	for depth > 0 {
+		tok := l.next()
+		if tok.typ == sqlTokenEOF {
+			return
+		}
+		if tok.typ == sqlTokenSymbol {
+			if tok.text == "(" {
+				depth++
+			} else if tok.text == ")" {
+				depth--
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package innodb parses the innodb table space files, the pages, the records
+// and the data dictionary, nothing is printed and the errors are returned
+package innodb
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// Tablespace is the innodb table space file, the pages are read from the
+// reader on demand, so the file of any size can be inspected
+type Tablespace struct {
+	r        io.ReaderAt
+	pageSize int
+	zipSize  int
+}
+
+// NewTablespace opens the table space from the reader, the page size is detected
+// from the FSP header flags on page 0 if pageSize is 0. The pages of the
+// ROW_FORMAT=COMPRESSED table space are decompressed when they are read
+func NewTablespace(r io.ReaderAt, pageSize int) (*Tablespace, error) {
+	size, err := resolvePageSize(r, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	ts := &Tablespace{
+		r:        r,
+		pageSize: size,
+	}
+	if flags, err := readFSPFlags(r); nil == err {
+		ts.zipSize = zipSizeFromFlags(flags)
+	}
+	if 0 != ts.zipSize {
+		if ts.r, err = newZipReader(r, ts.zipSize, size); nil != err {
+			return nil, errors.Trace(err)
+		}
+	}
+	return ts, nil
+}
+
+// PageSize returns the page size in bytes, it is the uncompressed page size of
+// the compressed table space
+func (ts *Tablespace) PageSize() int {
+	return ts.pageSize
+}
+
+// ZipSize returns the physical page size of the compressed table space, 0 if
+// the table space is not compressed
+func (ts *Tablespace) ZipSize() int {
+	return ts.zipSize
+}
+
+// PageCount returns the number of the complete pages, the reader must have the
+// Size or Stat method like bytes.Reader and os.File
+func (ts *Tablespace) PageCount() (int, error) {
+	size, err := readerSize(ts.r)
+	if nil != err {
+		return 0, errors.Trace(err)
+	}
+	return int(size / int64(ts.pageSize)), nil
+}
+
+// readerSize returns the size of the reader with the Size or Stat method
+func readerSize(r io.ReaderAt) (int64, error) {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		{
+			return r.Size(), nil
+		}
+	case interface{ Stat() (os.FileInfo, error) }:
+		{
+			fi, err := r.Stat()
+			if nil != err {
+				return 0, errors.Trace(err)
+			}
+			return fi.Size(), nil
+		}
+	}
+	return 0, errors.New("Size of the reader is unknown")
+}
+
+// FSPFlags returns the FSP header flags of page 0
+func (ts *Tablespace) FSPFlags() (uint32, error) {
+	return readFSPFlags(ts.r)
+}
+
+// parseOptions returns the options with the page size of the table space
+func (ts *Tablespace) parseOptions(options *ParsePageOptions) *ParsePageOptions {
+	if nil == options {
+		options = &ParsePageOptions{}
+	}
+	options.PageSize = ts.pageSize
+	options.ZipSize = ts.zipSize
+	return options
+}
+
+// ReadPageData reads the raw page, the length of data is the page size
+func (ts *Tablespace) ReadPageData(no int, data []byte) error {
+	if len(data) != ts.pageSize {
+		return errors.Errorf("Invalid page buffer size %d", len(data))
+	}
+	return readPageData(ts.r, no, data)
+}
+
+// ReadPage reads and parses the page
+func (ts *Tablespace) ReadPage(no int, options *ParsePageOptions) (*Page, error) {
+	return readPageFromFile(ts.r, no, ts.parseOptions(options))
+}
+
+// IteratePages calls fn with the parsed pages from the beginning. The pages of
+// the types not in options.ParsePageTypeFlag are skipped, all types if zero. The
+// page data is reused and the record fields refer to it, so they must not be kept
+// after fn returns. fn can return ErrStopIteration to stop early
+func (ts *Tablespace) IteratePages(options *ParsePageOptions, fn func(page *Page, data []byte) error) error {
+	return iteratePages(ts.r, ts.parseOptions(options), fn)
+}
+
+// MapPages calls fn with the pages and the values returned by work. If
+// options.Jobs is greater than 1, the parsing and the work are spread across the
+// workers, fn is still called in the page order
+func (ts *Tablespace) MapPages(options *ParsePageOptions, work PageWorkFunc,
+	fn func(page *Page, data []byte, v interface{}) error) error {
+	return mapPages(ts.r, ts.parseOptions(options), work, fn)
+}
+
+// LoadTable loads the table schema from the CREATE TABLE statement file or the
+// column definitions, and resolves the index ids from the root pages. The schema
+// is read from the SDI if not specified, returns nil if no schema found
+func (ts *Tablespace) LoadTable(schema string, columns string, pk string) (*Table, error) {
+	return openTableSchema(ts.r, schema, columns, pk, ts.pageSize)
+}
+
+// Indexes returns all B+ tree indexes of the table space, the pages are parsed
+// by the jobs workers
+func (ts *Tablespace) Indexes(jobs int) ([]*BtreeIndex, error) {
+	collector := newIndexCollector()
+	err := iteratePages(ts.r, &ParsePageOptions{
+		ParsePageTypeFlag: ParsePageInode | ParsePageIndex,
+		PageSize:          ts.pageSize,
+		ZipSize:           ts.zipSize,
+		Jobs:              jobs,
+	}, func(page *Page, data []byte) error {
+		collector.add(page)
+		return nil
+	})
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	return collector.result(), nil
+}
+
+// IndexRootPageNo returns the root page of the index
+func (ts *Tablespace) IndexRootPageNo(id uint64) (int, error) {
+	return findIndexRootPageNo(ts.r, id, ts.parseOptions(nil))
+}
+
+// FirstRootPageNo returns the root page of the first index, it is the clustered
+// index if the schema is unknown
+func (ts *Tablespace) FirstRootPageNo() (int, error) {
+	return readFirstRootPageNo(ts.r, ts.parseOptions(nil))
+}
+
+// FirstIndexInode returns the non-leaf inode entry of the first index
+func (ts *Tablespace) FirstIndexInode(inodePage *Page) (*INodeEntry, error) {
+	return findFirstIndexInode(ts.r, inodePage)
+}
+
+// LeftmostLeafPage returns the leftmost leaf page of the index
+func (ts *Tablespace) LeftmostLeafPage(rootNo int, options *ParsePageOptions) (*Page, error) {
+	return readLeftmostLeafPage(ts.r, rootNo, ts.parseOptions(options))
+}
+
+// LeafPageByKey returns the leaf page which may hold the integer key, the key of
+// the unsigned column is the bits of the uint64 value
+func (ts *Tablespace) LeafPageByKey(rootNo int, key int64, options *ParsePageOptions) (*Page, error) {
+	return readLeafPageByKey(ts.r, rootNo, key, ts.parseOptions(options))
+}
+
+// SearchIndex returns the last record less than the key in the leaf page, trace
+// is called with every page searched if not nil
+func (ts *Tablespace) SearchIndex(rootNo int, index *Index, key []*KeyValue, options *ParsePageOptions,
+	st *SearchStatistic, trace func(page *Page)) (*Page, *CompactRecorder, error) {
+	return searchIndexRecord(ts.r, rootNo, index, key, ts.parseOptions(options), st, trace)
+}
+
+// NextIndexRecord returns the next user record in the leaf page list, the record
+// is nil at the end of the index
+func (ts *Tablespace) NextIndexRecord(page *Page, rc *CompactRecorder, options *ParsePageOptions,
+	st *SearchStatistic) (*Page, *CompactRecorder, error) {
+	return nextIndexRecord(ts.r, page, rc, ts.parseOptions(options), st)
+}
+
+// ExternField reads the whole data of the externally stored field
+func (ts *Tablespace) ExternField(field *RecordField) ([]byte, error) {
+	return readExternField(ts.r, field, ts.pageSize)
+}
+
+// SDIRecords returns the SDI records of the table space
+func (ts *Tablespace) SDIRecords() ([]*SDIRecord, error) {
+	return readSDIRecords(ts.r, ts.pageSize)
+}
+
+// DataDict reads the mysql 5.x data dictionary of the system table space
+func (ts *Tablespace) DataDict() (*DataDict, error) {
+	return readDataDict(ts.r, ts.pageSize)
+}
+
+// IterateUndoLogs calls fn with the undo logs of the undo segments, the records
+// are decoded with the table schemas of decode. fn can return ErrStopIteration
+// to stop early
+func (ts *Tablespace) IterateUndoLogs(decode *UndoDecodeOptions, fn func(l *UndoLog) error) error {
+	return iterateUndoLogs(ts.r, ts.parseOptions(nil), decode, fn)
+}
+
+// SpaceID returns the space id of the FSP header
+func (ts *Tablespace) SpaceID() (uint32, error) {
+	data := make([]byte, 4)
+	if _, err := ts.r.ReadAt(data, fspHeaderOffset); nil != err {
+		return 0, errors.Trace(err)
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+// TrxSys reads the transaction system header of the system table space
+func (ts *Tablespace) TrxSys() (*TrxSys, error) {
+	return readTrxSys(ts.r, ts.pageSize)
+}
+
+// RsegHeader reads the rollback segment header page
+func (ts *Tablespace) RsegHeader(pageNo uint32) (*RsegHeader, error) {
+	return readRsegHeader(ts.r, pageNo, ts.pageSize)
+}
+
+// RsegArray reads the rollback segment array of the 8.0 undo table space,
+// returns nil if not found
+func (ts *Tablespace) RsegArray() (*RsegArray, error) {
+	return readRsegArray(ts.r, ts.pageSize)
+}
+
+// DoublewritePages reads the page copies of the doublewrite buffer in the
+// system table space
+func (ts *Tablespace) DoublewritePages() ([]*DoublewritePage, error) {
+	return readSysDoublewritePages(ts.r, ts.pageSize)
+}
+
+// MatchDoublewritePages checks the pages of the table space which have the
+// copies, sorted by the page number
+func (ts *Tablespace) MatchDoublewritePages(copies []*DoublewritePage) ([]*DoublewriteMatch, error) {
+	if 0 != ts.zipSize {
+		return nil, errors.New("Compressed table space is not supported")
+	}
+	return matchDoublewritePages(ts.r, ts.pageSize, copies)
+}
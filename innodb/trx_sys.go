@@ -0,0 +1,275 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// The transaction system header page of the system table space, reference to
+// trx0sys.h. The binlog info and the doublewrite buffer info are stored at the
+// end of the page, the binlog info is relative to the header and the doublewrite
+// buffer info is relative to the page
+const (
+	trxSysPageNo       = 5
+	trxSysOffset       = 38
+	trxSysTrxIDStore   = 0
+	trxSysFsegHeader   = 8
+	trxSysRsegs        = 18
+	trxSysRsegSlotSize = 8
+	// Rollback segment slots of the transaction system header
+	TrxSysRsegSlots = 128
+
+	trxSysMysqlLogInfoFromEnd = 1000
+	trxSysMysqlLogMagic       = 873422344
+	trxSysMysqlLogOffset      = 4
+	trxSysMysqlLogName        = 12
+	trxSysMysqlLogNameLen     = 512
+
+	trxSysDoublewriteFromEnd       = 200
+	trxSysDoublewriteMagic         = 10
+	trxSysDoublewriteBlock1        = 14
+	trxSysDoublewriteBlock2        = 18
+	trxSysDoublewriteRepeat        = 22
+	trxSysDoublewriteSpaceIDStored = 34
+	// The doublewrite buffer is created if the magic number is stored
+	TrxSysDoublewriteMagicN             = 536853855
+	trxSysDoublewriteSpaceIDStoredMagic = 1783657386
+)
+
+// The rollback segment header page, reference to trx0rseg.h. The slots of the
+// undo segments follow the file segment header
+const (
+	rsegHeaderOffset  = 38
+	rsegMaxSize       = 0
+	rsegHistorySize   = 4
+	rsegHistory       = 8
+	rsegFsegHeader    = 24
+	rsegUndoSlots     = 34
+	rsegUndoSlotSize  = 4
+	rsegFsegHeaderLen = 10
+)
+
+// The rollback segment array page of the 8.0 undo table space, reference to
+// trx0purge.h
+const (
+	rsegArrayPageNo   = 3
+	pageTypeRsegArray = 21
+	rsegArrayOffset   = 38
+	rsegArrayVersion  = 0x52534547 + 1
+	rsegArraySize     = 4
+	rsegArrayFseg     = 8
+	rsegArrayPages    = rsegArrayFseg + rsegFsegHeaderLen
+)
+
+// RsegSlot locates the rollback segment header page
+type RsegSlot struct {
+	SpaceID uint32
+	PageNo  uint32
+}
+
+// Used returns false if the slot is free
+func (s *RsegSlot) Used() bool {
+	return 0xffffffff != s.PageNo
+}
+
+// BinlogInfo is the binlog position of the last committed transaction, it is
+// stored by the mysql before 8.0
+type BinlogInfo struct {
+	Name   string
+	Offset uint64
+}
+
+// DoublewriteInfo describes the two blocks of the doublewrite buffer in the
+// system table space
+type DoublewriteInfo struct {
+	FileSegment FileSegmentHeader
+	Magic       uint32
+	// The first page numbers of the two blocks, each block is an extent
+	Block1 uint32
+	Block2 uint32
+	// The copy of the magic number and the blocks
+	RepeatMagic  uint32
+	RepeatBlock1 uint32
+	RepeatBlock2 uint32
+	// The space ids are stored in the pages of the doublewrite buffer
+	SpaceIDStored bool
+}
+
+// Created returns true if the doublewrite buffer is created
+func (d *DoublewriteInfo) Created() bool {
+	return TrxSysDoublewriteMagicN == d.Magic
+}
+
+// Consistent returns true if the repeated fields are the same
+func (d *DoublewriteInfo) Consistent() bool {
+	return d.Magic == d.RepeatMagic && d.Block1 == d.RepeatBlock1 && d.Block2 == d.RepeatBlock2
+}
+
+// TrxSys is the transaction system header
+type TrxSys struct {
+	// The max trx id is written every 256 transactions, the trx id after the
+	// restart starts from it
+	MaxTrxID    uint64
+	FileSegment FileSegmentHeader
+	Rsegs       [TrxSysRsegSlots]RsegSlot
+	// nil if not stored
+	Binlog      *BinlogInfo
+	Doublewrite DoublewriteInfo
+}
+
+// RsegHeader is the rollback segment header
+type RsegHeader struct {
+	PageNo uint32
+	// Max pages of the rollback segment
+	MaxSize uint32
+	// Pages of the undo logs in the history list
+	HistorySize uint32
+	// Committed undo logs to be purged, the length is the history list length
+	History     ListBaseNode
+	FileSegment FileSegmentHeader
+	// The first page of the undo segments, 0xffffffff if the slot is free
+	UndoSlots []uint32
+}
+
+// ActiveSlots returns the count of the used undo slots
+func (h *RsegHeader) ActiveSlots() int {
+	n := 0
+	for _, s := range h.UndoSlots {
+		if 0xffffffff != s {
+			n++
+		}
+	}
+	return n
+}
+
+// RsegArray is the rollback segment array of the 8.0 undo table space
+type RsegArray struct {
+	Version     uint32
+	FileSegment FileSegmentHeader
+	// The rollback segment header pages
+	Rsegs []uint32
+}
+
+func parseFileSegmentHeader(data []byte) FileSegmentHeader {
+	var fseg FileSegmentHeader
+	fseg.parse(bytes.NewReader(data))
+	return fseg
+}
+
+// readTrxSys reads the transaction system header page of the system table space
+func readTrxSys(f io.ReaderAt, pageSize int) (*TrxSys, error) {
+	size, err := resolvePageSize(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	data := make([]byte, size)
+	if err = readPageData(f, trxSysPageNo, data); nil != err {
+		return nil, errors.Trace(err)
+	}
+	if binary.BigEndian.Uint16(data[24:]) != PageTypeTrxSys {
+		return nil, errors.Errorf("Page %d is not transaction system header page", trxSysPageNo)
+	}
+	header := data[trxSysOffset:]
+	ts := &TrxSys{
+		MaxTrxID:    binary.BigEndian.Uint64(header[trxSysTrxIDStore:]),
+		FileSegment: parseFileSegmentHeader(header[trxSysFsegHeader:]),
+	}
+	for i := range ts.Rsegs {
+		slot := header[trxSysRsegs+i*trxSysRsegSlotSize:]
+		ts.Rsegs[i].SpaceID = binary.BigEndian.Uint32(slot)
+		ts.Rsegs[i].PageNo = binary.BigEndian.Uint32(slot[4:])
+	}
+
+	// TRX_SYS_MYSQL_LOG_INFO is relative to the header
+	info := header[size-trxSysMysqlLogInfoFromEnd:]
+	if trxSysMysqlLogMagic == binary.BigEndian.Uint32(info) {
+		name := info[trxSysMysqlLogName : trxSysMysqlLogName+trxSysMysqlLogNameLen]
+		if pos := bytes.IndexByte(name, 0); pos >= 0 {
+			name = name[:pos]
+		}
+		ts.Binlog = &BinlogInfo{
+			Name:   strings.TrimSpace(string(name)),
+			Offset: binary.BigEndian.Uint64(info[trxSysMysqlLogOffset:]),
+		}
+	}
+
+	// TRX_SYS_DOUBLEWRITE is relative to the page
+	dw := data[size-trxSysDoublewriteFromEnd:]
+	ts.Doublewrite = DoublewriteInfo{
+		FileSegment:   parseFileSegmentHeader(dw),
+		Magic:         binary.BigEndian.Uint32(dw[trxSysDoublewriteMagic:]),
+		Block1:        binary.BigEndian.Uint32(dw[trxSysDoublewriteBlock1:]),
+		Block2:        binary.BigEndian.Uint32(dw[trxSysDoublewriteBlock2:]),
+		RepeatMagic:   binary.BigEndian.Uint32(dw[trxSysDoublewriteRepeat:]),
+		RepeatBlock1:  binary.BigEndian.Uint32(dw[trxSysDoublewriteRepeat+4:]),
+		RepeatBlock2:  binary.BigEndian.Uint32(dw[trxSysDoublewriteRepeat+8:]),
+		SpaceIDStored: trxSysDoublewriteSpaceIDStoredMagic == binary.BigEndian.Uint32(dw[trxSysDoublewriteSpaceIDStored:]),
+	}
+	return ts, nil
+}
+
+// readRsegHeader reads the rollback segment header page, the undo slots fill
+// 1/16 of the page
+func readRsegHeader(f io.ReaderAt, pageNo uint32, pageSize int) (*RsegHeader, error) {
+	size, err := resolvePageSize(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	data := make([]byte, size)
+	if err = readPageData(f, int(pageNo), data); nil != err {
+		return nil, errors.Trace(err)
+	}
+	if binary.BigEndian.Uint16(data[24:]) != PageTypeSys {
+		return nil, errors.Errorf("Page %d is not rollback segment header page", pageNo)
+	}
+	header := data[rsegHeaderOffset:]
+	h := &RsegHeader{
+		PageNo:      pageNo,
+		MaxSize:     binary.BigEndian.Uint32(header[rsegMaxSize:]),
+		HistorySize: binary.BigEndian.Uint32(header[rsegHistorySize:]),
+		FileSegment: parseFileSegmentHeader(header[rsegFsegHeader:]),
+		UndoSlots:   make([]uint32, size/16),
+	}
+	if err = h.History.parse(bytes.NewReader(header[rsegHistory:])); nil != err {
+		return nil, errors.Trace(err)
+	}
+	for i := range h.UndoSlots {
+		h.UndoSlots[i] = binary.BigEndian.Uint32(header[rsegUndoSlots+i*rsegUndoSlotSize:])
+	}
+	return h, nil
+}
+
+// readRsegArray reads the rollback segment array page of the 8.0 undo table
+// space, returns nil if the page is not found
+func readRsegArray(f io.ReaderAt, pageSize int) (*RsegArray, error) {
+	size, err := resolvePageSize(f, pageSize)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	data := make([]byte, size)
+	if err = readPageData(f, rsegArrayPageNo, data); nil != err {
+		return nil, errors.Trace(err)
+	}
+	header := data[rsegArrayOffset:]
+	if binary.BigEndian.Uint16(data[24:]) != pageTypeRsegArray ||
+		rsegArrayVersion != binary.BigEndian.Uint32(header) {
+		return nil, nil
+	}
+	n := int(binary.BigEndian.Uint32(header[rsegArraySize:]))
+	if rsegArrayOffset+rsegArrayPages+n*rsegUndoSlotSize > size-8 {
+		return nil, errors.Errorf("Invalid rollback segment array size %d", n)
+	}
+	a := &RsegArray{
+		Version:     rsegArrayVersion,
+		FileSegment: parseFileSegmentHeader(header[rsegArrayFseg:]),
+		Rsegs:       make([]uint32, n),
+	}
+	for i := range a.Rsegs {
+		a.Rsegs[i] = binary.BigEndian.Uint32(header[rsegArrayPages+i*rsegUndoSlotSize:])
+	}
+	return a, nil
+}
@@ -0,0 +1,60 @@
+package innodb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// newTestSysSpace returns the system table space of 16KB pages, the transaction
+// system header page is laid out per trx0sys.h of 5.7: TRX_SYS_MYSQL_LOG_INFO at
+// TRX_SYS + UNIV_PAGE_SIZE - 1000 and TRX_SYS_DOUBLEWRITE at UNIV_PAGE_SIZE - 200
+// of the page frame. The doublewrite blocks are the extents at page 64 and 128
+func newTestSysSpace() []byte {
+	const size = 16384
+	file := make([]byte, 192*size)
+	page := file[trxSysPageNo*size : (trxSysPageNo+1)*size]
+	binary.BigEndian.PutUint32(page[4:], trxSysPageNo)
+	binary.BigEndian.PutUint16(page[24:], PageTypeTrxSys)
+	binary.BigEndian.PutUint64(page[38:], 0x1200)
+
+	// TRX_SYS_MYSQL_LOG_INFO
+	info := page[38+size-1000:]
+	binary.BigEndian.PutUint32(info[0:], 873422344)
+	binary.BigEndian.PutUint32(info[4:], 1)
+	binary.BigEndian.PutUint32(info[8:], 0x9a)
+	copy(info[12:], "mysql-bin.000042")
+
+	// TRX_SYS_DOUBLEWRITE
+	dw := page[size-200:]
+	for _, off := range []int{10, 22} {
+		binary.BigEndian.PutUint32(dw[off:], 536853855)
+		binary.BigEndian.PutUint32(dw[off+4:], 64)
+		binary.BigEndian.PutUint32(dw[off+8:], 128)
+	}
+	binary.BigEndian.PutUint32(dw[34:], 1783657386)
+	return file
+}
+
+func TestReadTrxSys(t *testing.T) {
+	ts, err := readTrxSys(bytes.NewReader(newTestSysSpace()), 16384)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 0x1200 != ts.MaxTrxID {
+		t.Fatalf("max trx id is %d, expect %d", ts.MaxTrxID, 0x1200)
+	}
+	if nil == ts.Binlog {
+		t.Fatal("binlog info is not found")
+	}
+	if "mysql-bin.000042" != ts.Binlog.Name || 1<<32|0x9a != ts.Binlog.Offset {
+		t.Fatalf("binlog info is %s:%d", ts.Binlog.Name, ts.Binlog.Offset)
+	}
+	dw := &ts.Doublewrite
+	if !dw.Created() || !dw.Consistent() || !dw.SpaceIDStored {
+		t.Fatalf("doublewrite buffer is not found, magic %d", dw.Magic)
+	}
+	if 64 != dw.Block1 || 128 != dw.Block2 {
+		t.Fatalf("doublewrite blocks are %d and %d, expect 64 and 128", dw.Block1, dw.Block2)
+	}
+}
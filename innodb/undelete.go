@@ -0,0 +1,90 @@
+package innodb
+
+import (
+	"encoding/binary"
+)
+
+// The user records of the COMPACT page start after the supremum
+const pageNewSupremumEnd = 0x78
+
+type recordRange struct {
+	start int
+	end   int
+}
+
+// FindDeletedRows finds the delete-marked records in the record list, and the
+// purged records in the free record list starting from PAGE_FREE. The purged
+// record is not cleared, it is partially overwritten if it overlaps other
+// records or the record header is invalid. Returns the rows and the count of
+// the free records which can not be decoded
+func FindDeletedRows(page *Page, data []byte, index *Index) ([]*RecoveredRow, int) {
+	rows := make([]*RecoveredRow, 0, 8)
+	nullable := index.nullableCount()
+	used := make([]recordRange, 0, page.IndexHeader.NRecs)
+	for _, rc := range page.UserRecorders() {
+		if nil == rc.Fields {
+			continue
+		}
+		start, end := recordExtent(int(rc.FieldDataOffset), rc.Fields, nullable)
+		used = append(used, recordRange{start, end})
+		if rc.Header.DeleteFlag {
+			rows = append(rows, &RecoveredRow{
+				Page:   page.no,
+				Origin: int(rc.FieldDataOffset),
+				State:  RowStateDeleteMarked,
+				Fields: rc.Fields,
+			})
+		}
+	}
+	overlapped := func(start int, end int) bool {
+		for _, r := range used {
+			if start < r.end && r.start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	fields := index.RecordFields(true)
+	nHeap := page.IndexHeader.NHeap & 0x7fff
+	unreadable := 0
+	visited := make(map[int]bool)
+	origin := int(page.IndexHeader.Free)
+	for 0 != origin {
+		if visited[origin] || origin < pageNewSupremumEnd+5 || origin >= len(data)-8 {
+			// The free record list is broken
+			unreadable++
+			break
+		}
+		visited[origin] = true
+		var header CompactRecorderHeader
+		header.parse(data[origin-5:])
+		values, err := parseRecordFields(data, origin, fields, nullable)
+		if nil != err {
+			unreadable++
+		} else {
+			state := RowStatePurged
+			start, end := recordExtent(origin, values, nullable)
+			if header.RecordType != RecorderTypeConventional ||
+				header.HeapNo < 2 || header.HeapNo >= nHeap ||
+				start < pageNewSupremumEnd || end > int(page.IndexHeader.HeapTop) ||
+				overlapped(start, end) {
+				state = RowStateOverwritten
+			}
+			used = append(used, recordRange{start, end})
+			rows = append(rows, &RecoveredRow{
+				Page:   page.no,
+				Origin: origin,
+				State:  state,
+				Fields: values,
+			})
+		}
+		// The next record offset is relative to the origin
+		next := int(binary.BigEndian.Uint16(data[origin-2:]))
+		if 0 == next {
+			break
+		}
+		origin = (origin + next) & (len(data) - 1)
+	}
+	return rows, unreadable
+}
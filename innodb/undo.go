@@ -0,0 +1,416 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// Undo record types, reference to trx0rec.h. The low 4 bits of the first byte
+// is the type, the next 2 bits are the compiler info of the update
+const (
+	UndoInsertRec    = 11
+	UndoUpdExistRec  = 12
+	UndoUpdDelRec    = 13
+	UndoDelMarkRec   = 14
+	undoCmplInfoMult = 16
+	// 8.0 modify records are followed by a flag byte, the updated external
+	// fields are followed by the LOB undo information
+	undoModifyBlob = 64
+	// Some external fields are updated
+	undoUpdExtern = 128
+)
+
+// Lengths of the undo record fields
+const (
+	undoSQLNull           = 0xFFFFFFFF
+	undoExternStorageLen  = undoSQLNull - 16384
+	undoSpatialStatusMask = 3 << 12
+	// The virtual columns are logged with the field number after the max fields
+	undoMaxFields = 1023
+	// The first virtual field is followed by the format and the length of the
+	// virtual index information, reference to VIRTUAL_COL_UNDO_FORMAT_1
+	undoVirtualFormat1 = 0xF1
+)
+
+var undoRecTypeStrs = map[int]string{
+	UndoInsertRec:   "insert",
+	UndoUpdExistRec: "update existing",
+	UndoUpdDelRec:   "update deleted",
+	UndoDelMarkRec:  "delete mark",
+}
+
+func UndoRecTypeToString(tp int) string {
+	if s, ok := undoRecTypeStrs[tp]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown(%d)", tp)
+}
+
+var errUndoCorrupt = errors.New("Undo record is corrupt")
+
+// UndoField is a field of the undo record
+type UndoField struct {
+	RecordField
+	// Position of the field in the clustered index record, or the position of
+	// the virtual column
+	FieldNo int
+	Virtual bool
+}
+
+// UndoRecord is the undo log record to roll back the change of the clustered
+// index record. The insert record stores the primary key, the modify records
+// store the primary key, the old system fields and the old values of the
+// updated fields
+type UndoRecord struct {
+	// Page and offset of the record
+	PageNo int
+	Offset int
+	Type   int
+	// Compiler info of the update
+	CmplInfo  int
+	UpdExtern bool
+	UndoNo    uint64
+	TableID   uint64
+	// Old info bits, DB_TRX_ID and DB_ROLL_PTR of the modified record
+	InfoBits uint8
+	TrxID    uint64
+	RollPtr  uint64
+	Key      []*UndoField
+	// Old values of the updated fields
+	Fields []*UndoField
+	// The updated fields are not decoded completely, the LOB undo information
+	// is not supported
+	Partial bool
+	// The record body between the next record offset and the record start
+	Data []byte
+}
+
+// TypeString returns the record type name
+func (rec *UndoRecord) TypeString() string {
+	return UndoRecTypeToString(rec.Type)
+}
+
+// UndoDecodeOptions decodes the fields of the undo records
+type UndoDecodeOptions struct {
+	// Schema of the tables by the table id, the fields of the other tables
+	// are decoded as the binary
+	Tables map[uint64]*Table
+	// Fields of the primary key of the unknown tables
+	KeyFields int
+}
+
+func (o *UndoDecodeOptions) table(id uint64) *Table {
+	if nil == o {
+		return nil
+	}
+	return o.Tables[id]
+}
+
+func (o *UndoDecodeOptions) keyFields(table *Table) int {
+	if nil != table {
+		if n := len(table.ClusteredIndex().Columns); n > 0 {
+			return n
+		}
+		// DB_ROW_ID
+		return 1
+	}
+	if nil == o || o.KeyFields <= 0 {
+		return 1
+	}
+	return o.KeyFields
+}
+
+// undoFieldColumn returns the column of the field, the field is decoded as the
+// binary if the table is unknown
+func undoFieldColumn(table *Table, fields []*Column, no int, virtual bool) *Column {
+	if nil != table {
+		if !virtual && no < len(fields) {
+			return fields[no]
+		}
+		if virtual {
+			n := 0
+			for _, c := range table.Columns {
+				if !c.Virtual {
+					continue
+				}
+				if n == no {
+					return c
+				}
+				n++
+			}
+		}
+	}
+	name := fmt.Sprintf("field %d", no)
+	if virtual {
+		name = fmt.Sprintf("virtual %d", no)
+	}
+	return &Column{Name: name, Type: ColumnTypeVarbinary}
+}
+
+// muchCompressed reads the integer compressed by mach_u64_write_much_compressed
+func (r *redoReader) muchCompressed() uint64 {
+	if nil != r.err {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.fail(errUndoCorrupt)
+		return 0
+	}
+	if 0xFF != r.data[r.pos] {
+		return uint64(r.compressed())
+	}
+	r.uint8()
+	high := r.compressed()
+	return uint64(high)<<32 | uint64(r.compressed())
+}
+
+// skipVirtualIndex skips the indexes of the virtual field before the field data,
+// reference to trx_undo_read_v_idx_low. The length includes itself
+func (r *redoReader) skipVirtualIndex() {
+	n := int(r.uint16())
+	if nil == r.err && n < 2 {
+		r.fail(errUndoCorrupt)
+		return
+	}
+	r.bytes(n - 2)
+}
+
+// undoField reads the field length and data, reference to trx_undo_rec_get_col_val
+func (r *redoReader) undoField() *UndoField {
+	f := &UndoField{}
+	f.Offset = r.pos
+	n := r.compressed()
+	switch {
+	case undoSQLNull == n:
+		{
+			f.Null = true
+		}
+	case undoExternStorageLen == n:
+		{
+			// The original length is followed by the length of the prefix
+			r.compressed()
+			n = r.compressed()
+			f.Extern = true
+			f.Data = r.bytes(int(n &^ undoSpatialStatusMask))
+		}
+	case n > undoExternStorageLen:
+		{
+			f.Extern = true
+			f.Data = r.bytes(int((n - undoExternStorageLen) &^ undoSpatialStatusMask))
+		}
+	default:
+		{
+			f.Data = r.bytes(int(n))
+		}
+	}
+	// The local prefix ends with the external field reference
+	if f.Extern && len(f.Data) < externFieldRefSize {
+		f.Extern = false
+	}
+	return f
+}
+
+// parseUndoRecord decodes the record body at offset of the page, reference to
+// trx_undo_rec_get_pars and trx_undo_update_rec_get_update
+func parseUndoRecord(data []byte, offset int, options *UndoDecodeOptions) (*UndoRecord, error) {
+	r := &redoReader{data: data}
+	rec := &UndoRecord{Data: data}
+	typeCmpl := int(r.uint8())
+	rec.UpdExtern = 0 != typeCmpl&undoUpdExtern
+	modifyBlob := 0 != typeCmpl&undoModifyBlob
+	if modifyBlob {
+		r.uint8()
+	}
+	typeCmpl &^= undoUpdExtern | undoModifyBlob
+	rec.Type = typeCmpl & (undoCmplInfoMult - 1)
+	rec.CmplInfo = typeCmpl / undoCmplInfoMult
+	rec.UndoNo = r.muchCompressed()
+	rec.TableID = r.muchCompressed()
+	if nil != r.err {
+		return nil, errUndoCorrupt
+	}
+	if _, ok := undoRecTypeStrs[rec.Type]; !ok {
+		return nil, errors.Errorf("Unknown undo record type %d", rec.Type)
+	}
+
+	if UndoInsertRec != rec.Type {
+		rec.InfoBits = r.uint8()
+		rec.TrxID = r.u64Compressed()
+		rec.RollPtr = r.u64Compressed()
+	}
+	table := options.table(rec.TableID)
+	var fields []*Column
+	if nil != table {
+		fields = table.ClusteredIndex().RecordFields(true)
+	}
+	n := options.keyFields(table)
+	for i := 0; i < n && nil == r.err; i++ {
+		f := r.undoField()
+		f.Offset += offset
+		f.FieldNo = i
+		f.Column = undoFieldColumn(table, fields, i, false)
+		rec.Key = append(rec.Key, f)
+	}
+	if UndoInsertRec == rec.Type || UndoDelMarkRec == rec.Type {
+		// The insert records may be followed by the virtual columns and the
+		// delete mark records by the ordering fields, they are not decoded
+		if nil != r.err {
+			return nil, errUndoCorrupt
+		}
+		return rec, nil
+	}
+
+	updated := int(r.compressed())
+	firstVirtual, virtualIndex := true, false
+	for i := 0; i < updated && nil == r.err; i++ {
+		no := int(r.compressed())
+		virtual := no >= undoMaxFields
+		if virtual {
+			no -= undoMaxFields
+			if firstVirtual {
+				virtualIndex = r.pos < len(r.data) && undoVirtualFormat1 == r.data[r.pos]
+				if virtualIndex {
+					r.uint8()
+				}
+				firstVirtual = false
+			}
+			if virtualIndex {
+				r.skipVirtualIndex()
+			}
+		}
+		f := r.undoField()
+		f.Offset += offset
+		f.FieldNo = no
+		f.Virtual = virtual
+		f.Column = undoFieldColumn(table, fields, no, virtual)
+		rec.Fields = append(rec.Fields, f)
+		if f.Extern && modifyBlob {
+			rec.Partial = true
+			break
+		}
+	}
+	if nil != r.err {
+		return nil, errUndoCorrupt
+	}
+	return rec, nil
+}
+
+// parseUndoRecords decodes the records between start and end, every record is
+// linked by the offset of the next record before it, and the offset of the
+// record start is stored after it
+func parseUndoRecords(pageNo int, data []byte, start int, end int,
+	options *UndoDecodeOptions) ([]*UndoRecord, error) {
+	var records []*UndoRecord
+	if end > len(data)-8 {
+		return nil, errors.Errorf("Undo free offset 0x%04X out of page", end)
+	}
+	for offset := start; offset < end; {
+		next := int(binary.BigEndian.Uint16(data[offset:]))
+		if next <= offset+4 || next > end ||
+			offset != int(binary.BigEndian.Uint16(data[next-2:])) {
+			return records, errors.Errorf("Invalid undo record at page %d offset 0x%04X", pageNo, offset)
+		}
+		rec, err := parseUndoRecord(data[offset+2:next-2], offset+2, options)
+		if nil != err {
+			return records, errors.Annotatef(err, "Page %d offset 0x%04X", pageNo, offset)
+		}
+		rec.PageNo = pageNo
+		rec.Offset = offset
+		records = append(records, rec)
+		offset = next
+	}
+	return records, nil
+}
+
+// UndoLog is an undo log of a transaction. The undo segment is reused by the
+// following transactions if it is cached, so the segment header page may store
+// several undo logs, only the latest one continues in the other pages
+type UndoLog struct {
+	// The segment header page
+	PageNo  int
+	Page    *Page
+	Header  *UndoLogHeader
+	Records []*UndoRecord
+	// The pages of the undo log
+	Pages []int
+	// The records after the error are not decoded
+	Err error
+}
+
+// Latest returns true if the undo log is the latest one of the segment
+func (l *UndoLog) Latest() bool {
+	return l.Header.Offset == int(l.Page.UndoSegmentHeader.LastLog)
+}
+
+// readUndoLog decodes the records of the undo log header, the records of the
+// latest undo log are read along the page list of the segment
+func readUndoLog(r io.ReaderAt, page *Page, data []byte, h *UndoLogHeader,
+	options *UndoDecodeOptions) *UndoLog {
+	l := &UndoLog{
+		PageNo: page.No(),
+		Page:   page,
+		Header: h,
+		Pages:  []int{page.No()},
+	}
+	end := int(page.UndoPageHeader.Free)
+	if !l.Latest() && 0 != h.NextLog {
+		end = int(h.NextLog)
+	}
+	l.Records, l.Err = parseUndoRecords(page.No(), data, int(h.LogStart), end, options)
+	if nil != l.Err || !l.Latest() {
+		return l
+	}
+
+	next := page.UndoPageHeader.PageList.NextPageNo
+	pageData := make([]byte, len(data))
+	for 0xffffffff != next && nil == l.Err {
+		// The page list is broken if it loops
+		if len(l.Pages) > int(page.UndoSegmentHeader.PageList.Length) {
+			l.Err = errors.Errorf("Undo page list of page %d is broken", page.No())
+			break
+		}
+		if err := readPageData(r, int(next), pageData); nil != err {
+			l.Err = errors.Trace(err)
+			break
+		}
+		np, err := ParsePage(int(next), pageData, &ParsePageOptions{})
+		if nil != err {
+			l.Err = errors.Trace(err)
+			break
+		}
+		if np.FileHeader.Type != PageTypeUndoLog {
+			l.Err = errors.Errorf("Page %d in the undo page list is not an undo page", next)
+			break
+		}
+		records, err := parseUndoRecords(np.No(), append([]byte(nil), pageData...),
+			int(np.UndoPageHeader.Start), int(np.UndoPageHeader.Free), options)
+		l.Records = append(l.Records, records...)
+		l.Pages = append(l.Pages, np.No())
+		l.Err = err
+		next = np.UndoPageHeader.PageList.NextPageNo
+	}
+	return l
+}
+
+// iterateUndoLogs calls fn with the undo logs of the segment header pages
+func iterateUndoLogs(r io.ReaderAt, options *ParsePageOptions, decode *UndoDecodeOptions,
+	fn func(l *UndoLog) error) error {
+	opts := *options
+	opts.ParsePageTypeFlag = ParsePageUndoLog
+	return iteratePages(r, &opts, func(page *Page, data []byte) error {
+		if !page.IsUndoSegmentHeaderPage() {
+			return nil
+		}
+		// The page data is reused by the iteration
+		data = append([]byte(nil), data...)
+		for _, h := range page.UndoLogHeaders {
+			if err := fn(readUndoLog(r, page, data, h, decode)); nil != err {
+				return err
+			}
+		}
+		return nil
+	})
+}
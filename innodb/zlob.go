@@ -0,0 +1,250 @@
+package innodb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+
+	"github.com/juju/errors"
+)
+
+// The compressed LOB first page of mysql 8.0, reference to zlob0first.h. The
+// index entries, the fragment entries and the data follow the header
+const (
+	zlobFirstIndexList  = 88
+	zlobFirstIndexBegin = 136
+	zlobIndexEntrySize  = 66
+	zlobFragEntrySize   = 24
+	zlobFirstDataLen    = 54
+	zlobDataPageDataLen = 39
+	zlobDataPageDataBeg = 49
+)
+
+// The index entry of the compressed LOB, reference to zlob0index.h. Every entry
+// is a zlib stream of a chunk, stored in the pages or in a fragment
+const (
+	zlobEntryNext       = 6
+	zlobEntryVersions   = 12
+	zlobEntryPageNo     = 48
+	zlobEntryFragID     = 52
+	zlobEntryDataLen    = 54
+	zlobEntryZDataLen   = 58
+	zlobEntryLobVersion = 62
+	zlobFragIDNull      = 0xffff
+)
+
+// The fragment page stores the small streams, the page directory before the
+// trailer stores the offset of the fragments by the fragment id, reference to
+// z_frag_page_t and frag_node_t
+const (
+	zlobFragDirCount = 10
+	zlobFragDirFirst = 12
+	zlobFragNodeLen  = 4
+	zlobFragNodeData = 8
+)
+
+// zlobIndexEntries returns the count of the index and fragment entries in the
+// first page of the physical page size
+func zlobIndexEntries(pageSize int) (int, int) {
+	switch pageSize {
+	case 1024:
+		{
+			return 5, 5
+		}
+	case 2048:
+		{
+			return 20, 20
+		}
+	case 4096:
+		{
+			return 40, 40
+		}
+	case 8192:
+		{
+			return 80, 100
+		}
+	}
+	return 100, 200
+}
+
+// zlobIndexEntry is the index entry of the compressed LOB
+type zlobIndexEntry struct {
+	nextPageNo     uint32
+	nextOffset     uint16
+	versionsPageNo uint32
+	versionsOffset uint16
+	pageNo         uint32
+	fragID         uint16
+	dataLen        uint32
+	zdataLen       uint32
+	lobVersion     uint32
+}
+
+func (r *lobReader) zentry(pageNo uint32, offset uint16) (*zlobIndexEntry, error) {
+	page, err := r.page(pageNo)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	if int(offset)+zlobIndexEntrySize > r.pageSize-8 {
+		return nil, errors.Errorf("Invalid compressed LOB index entry offset %d of page %d", offset, pageNo)
+	}
+	data := page[offset:]
+	return &zlobIndexEntry{
+		nextPageNo:     binary.BigEndian.Uint32(data[zlobEntryNext:]),
+		nextOffset:     binary.BigEndian.Uint16(data[zlobEntryNext+4:]),
+		versionsPageNo: binary.BigEndian.Uint32(data[zlobEntryVersions+4:]),
+		versionsOffset: binary.BigEndian.Uint16(data[zlobEntryVersions+8:]),
+		pageNo:         binary.BigEndian.Uint32(data[zlobEntryPageNo:]),
+		fragID:         binary.BigEndian.Uint16(data[zlobEntryFragID:]),
+		dataLen:        binary.BigEndian.Uint32(data[zlobEntryDataLen:]),
+		zdataLen:       binary.BigEndian.Uint32(data[zlobEntryZDataLen:]),
+		lobVersion:     binary.BigEndian.Uint32(data[zlobEntryLobVersion:]),
+	}, nil
+}
+
+// zversion returns the entry visible to the LOB version like version, nil is
+// returned if the chunk is inserted after the version
+func (r *lobReader) zversion(e *zlobIndexEntry, version uint32) (*zlobIndexEntry, error) {
+	if 0 == version || e.lobVersion <= version {
+		return e, nil
+	}
+	pageNo, offset := e.versionsPageNo, e.versionsOffset
+	for i := 0; 0xffffffff != pageNo; i++ {
+		if i > r.pageSize {
+			return nil, errors.Errorf("Compressed LOB versions list of page %d is too long", e.pageNo)
+		}
+		v, err := r.zentry(pageNo, offset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if v.lobVersion <= version {
+			return v, nil
+		}
+		pageNo, offset = v.nextPageNo, v.nextOffset
+	}
+	return nil, nil
+}
+
+// zstream returns the zlib stream of the entry, reference to z_read_chunk. The
+// stream is in the fragment, or in the first page and the data pages linked by
+// FIL_PAGE_NEXT
+func (r *lobReader) zstream(e *zlobIndexEntry, firstPageNo uint32) ([]byte, error) {
+	if zlobFragIDNull != e.fragID {
+		page, err := r.page(e.pageNo)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if typ := binary.BigEndian.Uint16(page[24:]); typ != PageTypeZLobFrag {
+			return nil, errors.Errorf("Page %d is %s page, not compressed LOB fragment page", e.pageNo, PageTypeToString(int(typ)))
+		}
+		if int(e.fragID) >= int(binary.BigEndian.Uint16(page[r.pageSize-zlobFragDirCount:])) {
+			return nil, errors.Errorf("Invalid fragment id %d of page %d", e.fragID, e.pageNo)
+		}
+		offset := int(binary.BigEndian.Uint16(page[r.pageSize-zlobFragDirFirst-2*int(e.fragID):]))
+		if offset+zlobFragNodeData > r.pageSize-8 {
+			return nil, errors.Errorf("Invalid fragment %d offset %d of page %d", e.fragID, offset, e.pageNo)
+		}
+		end := offset + int(binary.BigEndian.Uint16(page[offset+zlobFragNodeLen:]))
+		if end > r.pageSize-8 || offset+zlobFragNodeData+int(e.zdataLen) > end {
+			return nil, errors.Errorf("Invalid fragment %d length of page %d", e.fragID, e.pageNo)
+		}
+		start := offset + zlobFragNodeData
+		return page[start : start+int(e.zdataLen)], nil
+	}
+
+	stream := make([]byte, 0, e.zdataLen)
+	pageNo := e.pageNo
+	for uint32(len(stream)) < e.zdataLen {
+		if 0xffffffff == pageNo {
+			return nil, errors.Errorf("Compressed LOB page list ends at %d/%d bytes", len(stream), e.zdataLen)
+		}
+		page, err := r.page(pageNo)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		var start, n int
+		typ := binary.BigEndian.Uint16(page[24:])
+		switch {
+		case pageNo == firstPageNo && typ == PageTypeZLobFirst:
+			{
+				entries, frags := zlobIndexEntries(r.pageSize)
+				start = zlobFirstIndexBegin + entries*zlobIndexEntrySize + frags*zlobFragEntrySize
+				n = int(binary.BigEndian.Uint32(page[zlobFirstDataLen:]))
+			}
+		case typ == PageTypeZLobData:
+			{
+				start = zlobDataPageDataBeg
+				n = int(binary.BigEndian.Uint32(page[zlobDataPageDataLen:]))
+			}
+		default:
+			{
+				return nil, errors.Errorf("Page %d is %s page, not compressed LOB data page", pageNo, PageTypeToString(int(typ)))
+			}
+		}
+		if start+n > r.pageSize-8 {
+			return nil, errors.Errorf("Invalid compressed LOB data length %d of page %d", n, pageNo)
+		}
+		stream = append(stream, page[start:start+n]...)
+		// FIL_PAGE_NEXT
+		pageNo = binary.BigEndian.Uint32(page[12:])
+	}
+	return stream[:e.zdataLen], nil
+}
+
+// readZLob reads the compressed LOB of mysql 8.0 through the index entries of
+// the first page, every chunk is inflated separately. The pages are read in the
+// physical page size
+func readZLob(f io.ReaderAt, ref *externFieldRef, pageSize int) ([]byte, error) {
+	r := &lobReader{
+		f:        f,
+		pageSize: pageSize,
+		pages:    make(map[uint32][]byte),
+	}
+	first, err := r.page(ref.pageNo)
+	if nil != err {
+		return nil, errors.Trace(err)
+	}
+	var list ListBaseNode
+	if err := list.parse(bytes.NewReader(first[zlobFirstIndexList:])); nil != err {
+		return nil, errors.Trace(err)
+	}
+
+	data := make([]byte, 0, ref.length)
+	pageNo, offset := list.PrevPageNo, list.PrevPageOffset
+	for i := uint32(0); 0xffffffff != pageNo; i++ {
+		if i > list.Length {
+			return nil, errors.Errorf("Compressed LOB index list of page %d is longer than %d", ref.pageNo, list.Length)
+		}
+		e, err := r.zentry(pageNo, offset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		v, err := r.zversion(e, ref.offset)
+		if nil != err {
+			return nil, errors.Trace(err)
+		}
+		if nil != v && 0 != v.dataLen {
+			stream, err := r.zstream(v, ref.pageNo)
+			if nil != err {
+				return nil, errors.Trace(err)
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(stream))
+			if nil != err {
+				return nil, errors.Annotatef(err, "Inflate compressed LOB chunk of page %d", v.pageNo)
+			}
+			part := make([]byte, v.dataLen)
+			_, err = io.ReadFull(zr, part)
+			zr.Close()
+			if nil != err {
+				return nil, errors.Annotatef(err, "Inflate compressed LOB chunk of page %d", v.pageNo)
+			}
+			data = append(data, part...)
+		}
+		pageNo, offset = e.nextPageNo, e.nextOffset
+	}
+	if uint64(len(data)) != ref.length {
+		return nil, errors.Errorf("Compressed LOB of page %d has %d bytes, expect %d", ref.pageNo, len(data), ref.length)
+	}
+	return data, nil
+}
@@ -6,12 +6,32 @@ import (
 )
 
 func main() {
-	var cmdEntry = &cobra.Command{Use: "innoisp"}
+	var cmdEntry = &cobra.Command{
+		Use: "innoisp",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return checkOutputFormat(cmd)
+		},
+	}
+	cmdEntry.PersistentFlags().StringVar(&outputFormat, "format", outputFormatText, "output format, json or text, the json documents are written one per line")
 	cmdEntry.AddCommand(newOverviewCommand())
 	cmdEntry.AddCommand(newDslotsCommand())
 	cmdEntry.AddCommand(newSpaceCommand())
 	cmdEntry.AddCommand(newInodeCommand())
 	cmdEntry.AddCommand(newSearchCommand())
+	cmdEntry.AddCommand(newChecksumCommand())
+	cmdEntry.AddCommand(newRecordsCommand())
+	cmdEntry.AddCommand(newSDICommand())
+	cmdEntry.AddCommand(newDictCommand())
+	cmdEntry.AddCommand(newIndexesCommand())
+	cmdEntry.AddCommand(newScanCommand())
+	cmdEntry.AddCommand(newUndeleteCommand())
+	cmdEntry.AddCommand(newCarveCommand())
+	cmdEntry.AddCommand(newExportCommand())
+	cmdEntry.AddCommand(newRedoCommand())
+	cmdEntry.AddCommand(newRecoverCommand())
+	cmdEntry.AddCommand(newUndoCommand())
+	cmdEntry.AddCommand(newTrxsysCommand())
+	cmdEntry.AddCommand(newDblwrCommand())
 	if err := cmdEntry.Execute(); nil != err {
 		fmt.Println("command execute error ", err)
 	}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"spf13/cobra"
+
+	"github.com/sryanyuan/innoisp/innodb"
+)
+
+// Output formats of the global --format flag
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+var outputFormat = outputFormatText
+
+// jsonCommands are the commands writing the structured documents with --format json,
+// the row commands have their own --rows-format
+var jsonCommands = map[string]bool{
+	"overview": true,
+	"dslots":   true,
+	"space":    true,
+	"inode":    true,
+	"search":   true,
+}
+
+// checkOutputFormat checks the global --format flag before the command runs
+func checkOutputFormat(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputFormatText:
+		{
+			return nil
+		}
+	case outputFormatJSON:
+		{
+			if !jsonCommands[cmd.Name()] {
+				return fmt.Errorf("Command %s doesn't support the %s format", cmd.Name(), outputFormat)
+			}
+			return nil
+		}
+	default:
+		{
+			return fmt.Errorf("Invalid output format %s, must be json or text", outputFormat)
+		}
+	}
+}
+
+func isJSONOutput() bool {
+	return outputFormatJSON == outputFormat
+}
+
+// printJSON writes the document as one line, the documents of the pages are
+// written one per line, so the output of a large file can be read as a stream
+func printJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if nil != err {
+		fmt.Println("Encode json error ", err)
+		return
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
+
+// listNodeDoc is the list node, the offsets are adjusted to the start of the
+// entry holding the node like the text output
+type listNodeDoc struct {
+	Length     *uint32 `json:"length,omitempty"`
+	PrevPage   uint32  `json:"prev_page"`
+	PrevOffset uint16  `json:"prev_offset"`
+	NextPage   uint32  `json:"next_page"`
+	NextOffset uint16  `json:"next_offset"`
+}
+
+func newListNodeDoc(n *innodb.ListNode, offset uint16) *listNodeDoc {
+	doc := &listNodeDoc{
+		PrevPage:   n.PrevPageNo,
+		PrevOffset: n.PrevPageOffset,
+		NextPage:   n.NextPageNo,
+		NextOffset: n.NextPageOffset,
+	}
+	if 0xffffffff != n.PrevPageNo {
+		doc.PrevOffset -= offset
+	}
+	if 0xffffffff != n.NextPageNo {
+		doc.NextOffset -= offset
+	}
+	return doc
+}
+
+func newListBaseNodeDoc(n *innodb.ListBaseNode, offset uint16) *listNodeDoc {
+	doc := newListNodeDoc(&n.ListNode, offset)
+	length := n.Length
+	doc.Length = &length
+	return doc
+}
+
+type fileHeaderDoc struct {
+	Type     uint16 `json:"type"`
+	Checksum uint32 `json:"checksum"`
+	Offset   uint32 `json:"offset"`
+	Prev     uint32 `json:"prev"`
+	Next     uint32 `json:"next"`
+	LSN      uint64 `json:"lsn"`
+	SpaceID  uint32 `json:"space_id"`
+}
+
+func newFileHeaderDoc(h *innodb.FileHeader) *fileHeaderDoc {
+	return &fileHeaderDoc{
+		Type:     h.Type,
+		Checksum: h.SpaceOrChecksum,
+		Offset:   h.Offset,
+		Prev:     h.Prev,
+		Next:     h.Next,
+		LSN:      h.LSN,
+		SpaceID:  h.ArchLogNoOrSpaceID,
+	}
+}
+
+type inodePointerDoc struct {
+	Page   uint32 `json:"page"`
+	Offset uint16 `json:"offset"`
+}
+
+type pageIndexHeaderDoc struct {
+	HeapTop      uint16           `json:"heap_top"`
+	NHeap        uint16           `json:"n_heap"`
+	Free         uint16           `json:"free"`
+	Garbage      uint16           `json:"garbage"`
+	LastInsert   uint16           `json:"last_insert"`
+	Direction    uint16           `json:"direction"`
+	NDirection   uint16           `json:"n_direction"`
+	NRecs        uint16           `json:"n_recs"`
+	IndexID      uint64           `json:"index_id"`
+	LeafInode    *inodePointerDoc `json:"leaf_inode"`
+	NonleafInode *inodePointerDoc `json:"nonleaf_inode"`
+}
+
+func newPageIndexHeaderDoc(h *innodb.PageIndexHeader) *pageIndexHeaderDoc {
+	return &pageIndexHeaderDoc{
+		HeapTop:    h.HeapTop,
+		NHeap:      h.NHeap,
+		Free:       h.Free,
+		Garbage:    h.Garbage,
+		LastInsert: h.LastInsert,
+		Direction:  h.Direction,
+		NDirection: h.NDirection,
+		NRecs:      h.NRecs,
+		IndexID:    h.IndexID,
+		LeafInode: &inodePointerDoc{
+			Page:   h.LeafInode.InodePageNumber,
+			Offset: h.LeafInode.InodeOffset,
+		},
+		NonleafInode: &inodePointerDoc{
+			Page:   h.NonleafInode.InodePageNumber,
+			Offset: h.NonleafInode.InodeOffset,
+		},
+	}
+}
+
+type fileTrailerDoc struct {
+	Checksum uint32 `json:"checksum"`
+	LSN      uint32 `json:"lsn"`
+}
+
+func newFileTrailerDoc(p *innodb.Page) *fileTrailerDoc {
+	return &fileTrailerDoc{
+		Checksum: binary.BigEndian.Uint32(p.Trailer[0:4]),
+		LSN:      binary.BigEndian.Uint32(p.Trailer[4:8]),
+	}
+}
+
+// recordFieldDoc is the decoded field, the value is null for NULL
+type recordFieldDoc struct {
+	Column string  `json:"column"`
+	Value  *string `json:"value"`
+}
+
+func newRecordFieldDocs(fields []*innodb.RecordField) []*recordFieldDoc {
+	docs := make([]*recordFieldDoc, 0, len(fields))
+	for _, f := range fields {
+		doc := &recordFieldDoc{Column: f.Column.Name}
+		if !f.Null {
+			value := f.String()
+			doc.Value = &value
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}